@@ -16,6 +16,13 @@ type Client struct {
 	client.Client
 }
 
+// WithIdempotencyKey returns a copy of the context carrying the given idempotency key. If a bootstrap or join request
+// sent with it is retried with the same key, the daemon returns the result of the first attempt instead of running it
+// again.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return client.WithIdempotencyKey(ctx, key)
+}
+
 // IsNotification determines if this request is to be considered a cluster-wide notification.
 func IsNotification(r *http.Request) bool {
 	return r.Header.Get("User-Agent") == clusterRequest.UserAgentNotifier
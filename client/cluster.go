@@ -4,6 +4,8 @@ import (
 	"context"
 	"math/rand"
 	"sync"
+
+	"github.com/canonical/microcluster/internal/rest/client"
 )
 
 // Cluster is a list of clients belonging to a cluster.
@@ -15,7 +17,12 @@ func (c Cluster) SelectRandom() Client {
 }
 
 // Query executes the given hook across all members of the cluster.
+// Every query shares the request ID of the given context, or a newly generated one if it has none.
 func (c Cluster) Query(ctx context.Context, concurrent bool, query func(context.Context, *Client) error) error {
+	if client.RequestID(ctx) == "" {
+		ctx = client.WithRequestID(ctx, client.NewRequestID())
+	}
+
 	if !concurrent {
 		for _, client := range c {
 			err := query(ctx, &client)
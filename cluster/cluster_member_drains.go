@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// InternalClusterMemberDrain records a cluster member that is being drained before its removal.
+type InternalClusterMemberDrain struct {
+	ID        int
+	Name      string
+	StartedAt time.Time
+	Drained   bool // Whether the OnDrain hook of the member has completed.
+}
+
+// GetInternalClusterMemberDrains returns all cluster members that are draining or drained.
+func GetInternalClusterMemberDrains(ctx context.Context, tx *sql.Tx) ([]InternalClusterMemberDrain, error) {
+	stmt := "SELECT id, name, started_at, drained FROM internal_cluster_member_drains ORDER BY name"
+
+	drains := []InternalClusterMemberDrain{}
+	dest := func(scan func(dest ...any) error) error {
+		d := InternalClusterMemberDrain{}
+		err := scan(&d.ID, &d.Name, &d.StartedAt, &d.Drained)
+		if err != nil {
+			return err
+		}
+
+		drains = append(drains, d)
+
+		return nil
+	}
+
+	err := query.Scan(ctx, tx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"internal_cluster_member_drains\" table: %w", err)
+	}
+
+	return drains, nil
+}
+
+// GetInternalClusterMemberDrain returns the drain record of the cluster member with the given name.
+func GetInternalClusterMemberDrain(ctx context.Context, tx *sql.Tx, name string) (*InternalClusterMemberDrain, error) {
+	drains, err := GetInternalClusterMemberDrains(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, drain := range drains {
+		if drain.Name == name {
+			return &drain, nil
+		}
+	}
+
+	return nil, api.StatusErrorf(http.StatusNotFound, "InternalClusterMemberDrain not found")
+}
+
+// CreateInternalClusterMemberDrain records the cluster member as draining.
+func CreateInternalClusterMemberDrain(ctx context.Context, tx *sql.Tx, drain InternalClusterMemberDrain) error {
+	stmt := "INSERT INTO internal_cluster_member_drains (name, started_at, drained) VALUES (?, ?, ?)"
+	_, err := tx.ExecContext(ctx, stmt, drain.Name, drain.StartedAt, drain.Drained)
+	if err != nil {
+		return fmt.Errorf("Failed to create \"internal_cluster_member_drains\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateInternalClusterMemberDrained records that the cluster member with the given name has finished draining.
+func UpdateInternalClusterMemberDrained(ctx context.Context, tx *sql.Tx, name string) error {
+	stmt := "UPDATE internal_cluster_member_drains SET drained = 1 WHERE name = ?"
+	result, err := tx.ExecContext(ctx, stmt, name)
+	if err != nil {
+		return fmt.Errorf("Update \"internal_cluster_member_drains\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "InternalClusterMemberDrain not found")
+	}
+
+	return nil
+}
+
+// DeleteInternalClusterMemberDrain deletes the drain record of the cluster member with the given name.
+func DeleteInternalClusterMemberDrain(ctx context.Context, tx *sql.Tx, name string) error {
+	stmt := "DELETE FROM internal_cluster_member_drains WHERE name = ?"
+	result, err := tx.ExecContext(ctx, stmt, name)
+	if err != nil {
+		return fmt.Errorf("Delete \"internal_cluster_member_drains\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "InternalClusterMemberDrain not found")
+	}
+
+	return nil
+}
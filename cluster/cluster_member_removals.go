@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// InternalClusterMemberRemoval records a soft-deleted cluster member that can still be restored until it expires.
+type InternalClusterMemberRemoval struct {
+	ID        int
+	Name      string
+	Role      Role      // The dqlite role of the member before it was soft-deleted.
+	ExpiresAt time.Time // When the removal is finalized.
+}
+
+// GetInternalClusterMemberRemovals returns all soft-deleted cluster members.
+func GetInternalClusterMemberRemovals(ctx context.Context, tx *sql.Tx) ([]InternalClusterMemberRemoval, error) {
+	stmt := "SELECT id, name, role, expires_at FROM internal_cluster_member_removals ORDER BY name"
+
+	removals := []InternalClusterMemberRemoval{}
+	dest := func(scan func(dest ...any) error) error {
+		r := InternalClusterMemberRemoval{}
+		err := scan(&r.ID, &r.Name, &r.Role, &r.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		removals = append(removals, r)
+
+		return nil
+	}
+
+	err := query.Scan(ctx, tx, stmt, dest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch from \"internal_cluster_member_removals\" table: %w", err)
+	}
+
+	return removals, nil
+}
+
+// GetInternalClusterMemberRemoval returns the removal record of the cluster member with the given name.
+func GetInternalClusterMemberRemoval(ctx context.Context, tx *sql.Tx, name string) (*InternalClusterMemberRemoval, error) {
+	removals, err := GetInternalClusterMemberRemovals(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, removal := range removals {
+		if removal.Name == name {
+			return &removal, nil
+		}
+	}
+
+	return nil, api.StatusErrorf(http.StatusNotFound, "InternalClusterMemberRemoval not found")
+}
+
+// CreateInternalClusterMemberRemoval records the cluster member as soft-deleted.
+func CreateInternalClusterMemberRemoval(ctx context.Context, tx *sql.Tx, removal InternalClusterMemberRemoval) error {
+	stmt := "INSERT INTO internal_cluster_member_removals (name, role, expires_at) VALUES (?, ?, ?)"
+	_, err := tx.ExecContext(ctx, stmt, removal.Name, removal.Role, removal.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("Failed to create \"internal_cluster_member_removals\" entry: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteInternalClusterMemberRemoval deletes the removal record of the cluster member with the given name.
+func DeleteInternalClusterMemberRemoval(ctx context.Context, tx *sql.Tx, name string) error {
+	stmt := "DELETE FROM internal_cluster_member_removals WHERE name = ?"
+	result, err := tx.ExecContext(ctx, stmt, name)
+	if err != nil {
+		return fmt.Errorf("Delete \"internal_cluster_member_removals\": %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fetch affected rows: %w", err)
+	}
+
+	if n == 0 {
+		return api.StatusErrorf(http.StatusNotFound, "InternalClusterMemberRemoval not found")
+	}
+
+	return nil
+}
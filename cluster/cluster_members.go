@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/canonical/lxd/lxd/db/query"
+	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
 
 	"github.com/canonical/microcluster/internal/extensions"
@@ -39,6 +41,26 @@ type Role string
 // Pending indicates that a node is about to be added or removed.
 const Pending Role = "PENDING"
 
+// Observer indicates a node that was declared an observer when it joined. Observers replicate the database and serve
+// the API as dqlite spares, but are never promoted to voters or stand-bys.
+const Observer Role = "OBSERVER"
+
+// PendingObserver indicates an observer that is about to be added.
+const PendingObserver Role = "PENDING_OBSERVER"
+
+// IsPending returns whether the node is about to be added or removed.
+func (r Role) IsPending() bool {
+	return r == Pending || r == PendingObserver
+}
+
+// IsObserver returns whether the node was declared an observer, including while it is about to be added.
+func (r Role) IsObserver() bool {
+	return r == Observer || r == PendingObserver
+}
+
+// DefaultMinVoters is the default minimum number of voters that must remain after removing cluster members.
+const DefaultMinVoters = 1
+
 // InternalClusterMember represents the global database entry for a dqlite cluster member.
 type InternalClusterMember struct {
 	ID             int
@@ -50,6 +72,10 @@ type InternalClusterMember struct {
 	APIExtensions  extensions.Extensions
 	Heartbeat      time.Time
 	Role           Role
+
+	// ClientCertificate is the certificate the member presents when querying other cluster members.
+	// It is empty if the member uses its server certificate for this.
+	ClientCertificate string
 }
 
 // InternalClusterMemberFilter is used for filtering queries using generated methods.
@@ -71,11 +97,20 @@ func (c InternalClusterMember) ToAPI() (*internalTypes.ClusterMember, error) {
 		return nil, fmt.Errorf("Failed to parse certificate of database cluster member with address %q: %w", c.Address, err)
 	}
 
+	var clientCertificate *types.X509Certificate
+	if c.ClientCertificate != "" {
+		clientCertificate, err = types.ParseX509Certificate(c.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse client certificate of database cluster member with address %q: %w", c.Address, err)
+		}
+	}
+
 	return &internalTypes.ClusterMember{
 		ClusterMemberLocal: internalTypes.ClusterMemberLocal{
-			Name:        c.Name,
-			Address:     address,
-			Certificate: *certificate,
+			Name:              c.Name,
+			Address:           address,
+			Certificate:       *certificate,
+			ClientCertificate: clientCertificate,
 		},
 		Role:                  string(c.Role),
 		SchemaInternalVersion: c.SchemaInternal,
@@ -86,6 +121,78 @@ func (c InternalClusterMember) ToAPI() (*internalTypes.ClusterMember, error) {
 	}, nil
 }
 
+// GetInternalClusterMembersPage returns the cluster members with the given role, or all cluster members if role is
+// nil, ordered by name. At most limit cluster members are returned, after skipping the first offset of them. A limit
+// of 0 returns all remaining cluster members. The total number of matching cluster members is returned as well.
+func GetInternalClusterMembersPage(ctx context.Context, tx *sql.Tx, role *Role, limit int, offset int) ([]InternalClusterMember, int, error) {
+	where := ""
+	args := []any{}
+	if role != nil {
+		where = "WHERE internal_cluster_members.role = ?"
+		args = append(args, *role)
+	}
+
+	var total int
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM internal_cluster_members %s", where), args...).Scan(&total)
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to count \"internal_cluster_members\" entries: %w", err)
+	}
+
+	// SQLite treats a negative limit as no limit.
+	if limit == 0 {
+		limit = -1
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM internal_cluster_members %s ORDER BY internal_cluster_members.name LIMIT ? OFFSET ?", internalClusterMemberColumns(), where)
+	args = append(args, limit, offset)
+
+	members, err := getInternalClusterMembersRaw(ctx, tx, stmt, args...)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return members, total, nil
+}
+
+// RenameInternalClusterMember renames the cluster member with the given name, along with its configuration. It fails
+// with a 409 error if another cluster member already has the new name, or if the cluster member is soft-deleted or
+// draining, as those records refer to it by name.
+func RenameInternalClusterMember(ctx context.Context, tx *sql.Tx, name string, newName string) error {
+	_, err := GetInternalClusterMember(ctx, tx, newName)
+	if err == nil {
+		return api.StatusErrorf(http.StatusConflict, "A cluster member with name %q already exists", newName)
+	} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return err
+	}
+
+	_, err = GetInternalClusterMemberRemoval(ctx, tx, name)
+	if err == nil {
+		return api.StatusErrorf(http.StatusConflict, "Cluster member %q is soft-deleted", name)
+	} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return err
+	}
+
+	_, err = GetInternalClusterMemberDrain(ctx, tx, name)
+	if err == nil {
+		return api.StatusErrorf(http.StatusConflict, "Cluster member %q is draining", name)
+	} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return err
+	}
+
+	clusterMember, err := GetInternalClusterMember(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	clusterMember.Name = newName
+	err = UpdateInternalClusterMember(ctx, tx, name, *clusterMember)
+	if err != nil {
+		return err
+	}
+
+	return RenameMemberConfig(ctx, tx, name, newName)
+}
+
 // prepareUpdateV1 creates the temporary table `internal_cluster_members_new` if we have not yet run `updateFromV1`.
 // To keep this table in sync with `internal_cluster_members`, a create & update trigger is created as well.
 // This table (and its triggers) will be deleted by `updateFromV1`.
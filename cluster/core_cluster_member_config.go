@@ -0,0 +1,196 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// LeadershipWeightKey is the configuration key of a cluster member holding its leadership weight. When dqlite
+// leadership is handed over, it goes to the voter with the highest weight, and the leader hands it over by itself to
+// a reachable voter with a higher weight. Cluster members without a weight have a weight of 0.
+const LeadershipWeightKey = "leadership.weight"
+
+// LeadershipWeight returns the leadership weight set in the given cluster member configuration, or 0 if there is none.
+func LeadershipWeight(config map[string]string) int {
+	weight, err := strconv.Atoi(config[LeadershipWeightKey])
+	if err != nil {
+		return 0
+	}
+
+	return weight
+}
+
+// MaintenanceKey is the configuration key of a cluster member that is set to "true" while the member is in maintenance
+// mode. A cluster member in maintenance mode stays in the cluster and keeps serving requests, but hands over dqlite
+// leadership instead of beginning heartbeat rounds, and is only picked as the dqlite leader if no other voter is left.
+//
+// The key is reserved: SetMemberConfig keeps its current value, and refuses to change it. It is only set by
+// SetMemberMaintenance, so that entering maintenance mode always goes through the leadership handover.
+const MaintenanceKey = "maintenance"
+
+// InMaintenance returns whether the given cluster member configuration puts the cluster member in maintenance mode.
+func InMaintenance(config map[string]string) bool {
+	return config[MaintenanceKey] == "true"
+}
+
+//go:generate -command mapper lxd-generate db mapper -t core_cluster_member_config.mapper.go
+//go:generate mapper reset
+//
+//go:generate mapper stmt -e core_cluster_member_config objects table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config objects-by-Member table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config objects-by-Member-and-Key table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config id table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config create table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config delete-by-Member table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config delete-by-Member-and-Key table=core_cluster_member_config
+//go:generate mapper stmt -e core_cluster_member_config update table=core_cluster_member_config
+//
+//go:generate mapper method -e core_cluster_member_config GetMany table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config GetOne table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config ID table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config Exists table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config Create table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config DeleteOne-by-Member-and-Key table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config DeleteMany-by-Member table=core_cluster_member_config
+//go:generate mapper method -e core_cluster_member_config Update table=core_cluster_member_config
+
+// CoreClusterMemberConfig is the database representation of a configuration key of a single cluster member.
+type CoreClusterMemberConfig struct {
+	ID     int
+	Member string `db:"primary=yes"`
+	Key    string `db:"primary=yes"`
+	Value  string
+}
+
+// CoreClusterMemberConfigFilter is the filter struct for filtering results from generated methods.
+type CoreClusterMemberConfigFilter struct {
+	ID     *int
+	Member *string
+	Key    *string
+}
+
+// GetMemberConfig returns the configuration keys and values of the cluster member with the given name.
+func GetMemberConfig(ctx context.Context, tx *sql.Tx, name string) (map[string]string, error) {
+	configs, err := GetCoreClusterMemberConfigs(ctx, tx, CoreClusterMemberConfigFilter{Member: &name})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(configs))
+	for _, config := range configs {
+		values[config.Key] = config.Value
+	}
+
+	return values, nil
+}
+
+// GetMembersConfig returns the configuration keys and values of all cluster members, keyed by cluster member name.
+func GetMembersConfig(ctx context.Context, tx *sql.Tx) (map[string]map[string]string, error) {
+	configs, err := GetCoreClusterMemberConfigs(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]map[string]string{}
+	for _, config := range configs {
+		if values[config.Member] == nil {
+			values[config.Member] = map[string]string{}
+		}
+
+		values[config.Member][config.Key] = config.Value
+	}
+
+	return values, nil
+}
+
+// SetMemberConfig replaces the configuration of the cluster member with the given name with the given keys and values.
+// The reserved MaintenanceKey keeps its current value, and may only be given with that value.
+func SetMemberConfig(ctx context.Context, tx *sql.Tx, name string, config map[string]string) error {
+	current, err := GetMemberConfig(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	value, ok := config[MaintenanceKey]
+	if ok && value != current[MaintenanceKey] {
+		return api.StatusErrorf(http.StatusBadRequest, "Configuration key %q is reserved for maintenance mode", MaintenanceKey)
+	}
+
+	weight, ok := config[LeadershipWeightKey]
+	if ok {
+		_, err := strconv.Atoi(weight)
+		if err != nil {
+			return api.StatusErrorf(http.StatusBadRequest, "Invalid %q %q: must be an integer", LeadershipWeightKey, weight)
+		}
+	}
+
+	newConfig := make(map[string]string, len(config)+1)
+	for key, value := range config {
+		newConfig[key] = value
+	}
+
+	delete(newConfig, MaintenanceKey)
+	if InMaintenance(current) {
+		newConfig[MaintenanceKey] = current[MaintenanceKey]
+	}
+
+	return setMemberConfig(ctx, tx, name, newConfig)
+}
+
+// SetMemberMaintenance records whether the cluster member with the given name is in maintenance mode, leaving the rest
+// of its configuration untouched.
+func SetMemberMaintenance(ctx context.Context, tx *sql.Tx, name string, maintenance bool) error {
+	config, err := GetMemberConfig(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	if maintenance {
+		config[MaintenanceKey] = "true"
+	} else {
+		delete(config, MaintenanceKey)
+	}
+
+	return setMemberConfig(ctx, tx, name, config)
+}
+
+// setMemberConfig replaces the configuration of the cluster member with the given name, without checking its keys.
+func setMemberConfig(ctx context.Context, tx *sql.Tx, name string, config map[string]string) error {
+	exists, err := InternalClusterMemberExists(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return api.StatusErrorf(http.StatusNotFound, "No cluster member exists with name %q", name)
+	}
+
+	err = DeleteCoreClusterMemberConfigs(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range config {
+		_, err = CreateCoreClusterMemberConfig(ctx, tx, CoreClusterMemberConfig{Member: name, Key: key, Value: value})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenameMemberConfig moves the configuration of the cluster member with the given name to its new name.
+func RenameMemberConfig(ctx context.Context, tx *sql.Tx, name string, newName string) error {
+	_, err := tx.ExecContext(ctx, "UPDATE core_cluster_member_config SET member = ? WHERE member = ?", newName, name)
+	if err != nil {
+		return fmt.Errorf("Failed to rename configuration of cluster member %q: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package cluster
+
+//go:generate -command mapper lxd-generate db mapper -t core_config.mapper.go
+//go:generate mapper reset
+//
+//go:generate mapper stmt -e core_config objects table=core_config
+//go:generate mapper stmt -e core_config objects-by-Key table=core_config
+//go:generate mapper stmt -e core_config id table=core_config
+//go:generate mapper stmt -e core_config create table=core_config
+//go:generate mapper stmt -e core_config delete-by-Key table=core_config
+//go:generate mapper stmt -e core_config update table=core_config
+//
+//go:generate mapper method -e core_config GetMany table=core_config
+//go:generate mapper method -e core_config GetOne table=core_config
+//go:generate mapper method -e core_config ID table=core_config
+//go:generate mapper method -e core_config Exists table=core_config
+//go:generate mapper method -e core_config Create table=core_config
+//go:generate mapper method -e core_config DeleteOne-by-Key table=core_config
+//go:generate mapper method -e core_config Update table=core_config
+
+// CoreConfig is the database representation of a cluster-wide configuration key.
+type CoreConfig struct {
+	ID    int
+	Key   string `db:"primary=yes"`
+	Value string
+}
+
+// CoreConfigFilter is the filter struct for filtering results from generated methods.
+type CoreConfigFilter struct {
+	ID  *int
+	Key *string
+}
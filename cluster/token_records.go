@@ -1,9 +1,15 @@
 package cluster
 
 import (
+	"context"
 	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
 
 	internalTypes "github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/rest/types"
@@ -29,9 +35,10 @@ import (
 
 // InternalTokenRecord is the database representation of a join token record.
 type InternalTokenRecord struct {
-	ID     int
-	Secret string `db:"primary=yes"`
-	Name   string
+	ID         int
+	Secret     string `db:"primary=yes"`
+	Name       string
+	ExpiryDate sql.NullTime // The token never expires if this is not set.
 }
 
 // InternalTokenRecordFilter is the filter struct for filtering results from generated methods.
@@ -54,8 +61,52 @@ func (t *InternalTokenRecord) ToAPI(clusterCert *x509.Certificate, joinAddresses
 		return nil, err
 	}
 
-	return &internalTypes.TokenRecord{
+	record := &internalTypes.TokenRecord{
 		Token: tokenString,
 		Name:  t.Name,
-	}, nil
+	}
+
+	if t.ExpiryDate.Valid {
+		record.ExpiryDate = t.ExpiryDate.Time.UTC()
+	}
+
+	return record, nil
+}
+
+// DefaultTokenExpirySkew is the default tolerance for clock skew between cluster members when checking whether a
+// join token has expired.
+const DefaultTokenExpirySkew = 30 * time.Second
+
+// Expired returns whether the token record's expiry date has passed by more than the given clock skew tolerance.
+func (t *InternalTokenRecord) Expired(skew time.Duration) bool {
+	return t.ExpiryDate.Valid && time.Now().UTC().After(t.ExpiryDate.Time.UTC().Add(skew))
+}
+
+// CreateInternalTokenRecords adds all of the given token records to the database, failing if any of their names
+// already has a token record. It should be called in a transaction so that either all or none of them are created.
+func CreateInternalTokenRecords(ctx context.Context, tx *sql.Tx, records ...InternalTokenRecord) error {
+	existing, err := GetInternalTokenRecords(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(existing)+len(records))
+	for _, record := range existing {
+		names[record.Name] = true
+	}
+
+	for _, record := range records {
+		if names[record.Name] {
+			return api.StatusErrorf(http.StatusConflict, "A join token already exists for %q", record.Name)
+		}
+
+		names[record.Name] = true
+
+		_, err := CreateInternalTokenRecord(ctx, tx, record)
+		if err != nil {
+			return fmt.Errorf("Failed to create join token for %q: %w", record.Name, err)
+		}
+	}
+
+	return nil
 }
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"context"
+	"time"
+
 	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
 )
 
 // Hooks holds customizable functions that can be called at varying points by the daemon to.
@@ -24,15 +28,61 @@ type Hooks struct {
 	// their 'OnNewMember' hooks.
 	PreJoin func(s *state.State, initConfig map[string]string) error
 
+	// OnInit is run after PostBootstrap when bootstrapping the cluster, or after PostJoin when joining it, with
+	// bootstrap set accordingly. It lets consumers share initialization logic between both paths. It is not run when
+	// an initialized daemon restarts.
+	OnInit func(ctx context.Context, s *state.State, bootstrap bool, initConfig map[string]string) error
+
+	// OnQuorum is run on the bootstrapped cluster member once the number of cluster members set by the QuorumMembers
+	// option have joined, or once the quorum timeout elapses, with the number of cluster members at that point. It is
+	// run in the background, only once, and not at all if the daemon shuts down or restarts before.
+	OnQuorum func(s *state.State, members int) error
+
 	// PreRemove is run on a cluster member just before it is removed from the cluster.
 	PreRemove func(s *state.State, force bool) error
 
 	// PostRemove is run on all other peers after one is removed from the cluster.
 	PostRemove func(s *state.State, force bool) error
 
+	// OnDrain is run in the background on a cluster member once it starts draining before its removal, after it has
+	// become read-only and handed over dqlite leadership. Returning nil signals that the member's workloads have been
+	// moved away, and that it can be removed. The context is cancelled if the drain is cancelled. If it returns an
+	// error, the member stays draining until the drain is started again or cancelled.
+	OnDrain func(ctx context.Context, s *state.State) error
+
+	// OnDrainChange is run on each cluster member when a heartbeat shows that the cluster member with the given name
+	// started draining, or that its drain was cancelled. Consumers can use it to stop scheduling work onto draining
+	// members. It is also run for each draining member after a restart, once the first heartbeat is seen.
+	OnDrainChange func(s *state.State, name string, draining bool) error
+
 	// OnHeartbeat is run after a successful heartbeat round.
 	OnHeartbeat func(s *state.State) error
 
+	// OnHeartbeatPayloads is run after a successful heartbeat round, after 'OnHeartbeat'. It receives the payloads
+	// returned by each cluster member's 'HeartbeatPayload' hook, keyed by cluster member name.
+	OnHeartbeatPayloads func(s *state.State, payloads map[string]map[string]string) error
+
+	// HeartbeatPayload is run on each cluster member when it receives a heartbeat, and on the leader when it begins
+	// a heartbeat round. The returned key/value pairs are delivered to the leader's 'OnHeartbeatPayloads' hook.
+	// Entries larger than 1KiB, or beyond 16KiB in total per cluster member, are dropped.
+	HeartbeatPayload func(s *state.State) map[string]string
+
 	// OnNewMember is run on each peer after a new cluster member has joined and executed their 'PreJoin' hook.
 	OnNewMember func(s *state.State) error
+
+	// OnAutoUpdate is run when a heartbeat reveals this member's schema version differs from the rest of the cluster.
+	// If unset, the executable specified by the SCHEMA_UPDATE environment variable is run instead.
+	OnAutoUpdate func(s *state.State) error
+
+	// ValidateDaemonConfig is run before a new daemon configuration (name and address) is applied, when
+	// bootstrapping, joining, or changing this cluster member's address. If it returns an error, the change is
+	// aborted and the current configuration is kept. The current configuration is empty before the daemon is
+	// initialized.
+	ValidateDaemonConfig func(ctx context.Context, s *state.State, current trust.Location, new trust.Location) error
+
+	// OnCertExpiring is run when a certificate of this member expires within the configured warning window. The
+	// certificate is given by name: "server", "cluster", or "extension:" followed by the interface and address of an
+	// extension server with a dedicated certificate. It is run at startup and then hourly until the certificate is
+	// renewed.
+	OnCertExpiring func(s *state.State, name string, expiry time.Time) error
 }
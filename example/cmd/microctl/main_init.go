@@ -17,6 +17,7 @@ type cmdInit struct {
 	flagBootstrap bool
 	flagToken     string
 	flagConfig    []string
+	flagObserver  bool
 }
 
 func (c *cmdInit) command() *cobra.Command {
@@ -25,13 +26,16 @@ func (c *cmdInit) command() *cobra.Command {
 		Short: "Initialize the network endpoint and create or join a new cluster",
 		RunE:  c.run,
 		Example: `  microctl init member1 127.0.0.1:8443 --bootstrap
-    microctl init member1 127.0.0.1:8443 --token <token>`,
+    microctl init member1 127.0.0.1:8443 --token <token>
+    microctl init member1 127.0.0.1:8443 --token <token> --observer`,
 	}
 
 	cmd.Flags().BoolVar(&c.flagBootstrap, "bootstrap", false, "Configure a new cluster with this daemon")
 	cmd.Flags().StringVar(&c.flagToken, "token", "", "Join a cluster with a join token")
 	cmd.Flags().StringSliceVar(&c.flagConfig, "config", nil, "Extra configuration to be applied during bootstrap")
+	cmd.Flags().BoolVar(&c.flagObserver, "observer", false, "Join as an observer that is never promoted to a voter")
 	cmd.MarkFlagsMutuallyExclusive("bootstrap", "token")
+	cmd.MarkFlagsMutuallyExclusive("bootstrap", "observer")
 
 	return cmd
 }
@@ -63,6 +67,10 @@ func (c *cmdInit) run(cmd *cobra.Command, args []string) error {
 		return m.NewCluster(ctx, args[0], args[1], conf)
 	}
 
+	if c.flagToken != "" && c.flagObserver {
+		return m.JoinClusterAsObserver(ctx, args[0], args[1], c.flagToken, conf)
+	}
+
 	if c.flagToken != "" {
 		return m.JoinCluster(ctx, args[0], args[1], c.flagToken, conf)
 	}
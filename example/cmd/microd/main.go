@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"os"
 
 	"github.com/canonical/lxd/shared/logger"
@@ -154,6 +155,13 @@ func (c *cmdDaemon) run(cmd *cobra.Command, args []string) error {
 			return nil
 		},
 
+		// OnInit is run after PostBootstrap or PostJoin, whichever initialized the daemon.
+		OnInit: func(ctx context.Context, s *state.State, bootstrap bool, initConfig map[string]string) error {
+			logger.Info("This is a hook that runs after the daemon is initialized, whether it bootstrapped or joined a cluster", logger.Ctx{"bootstrap": bootstrap})
+
+			return nil
+		},
+
 		// PostRemove is run after the daemon is removed from a cluster.
 		PostRemove: func(s *state.State, force bool) error {
 			logger.Infof("This is a hook that is run on peer %q after a cluster member is removed, with the force flag set to %v", s.Name(), force)
@@ -175,6 +183,18 @@ func (c *cmdDaemon) run(cmd *cobra.Command, args []string) error {
 			return nil
 		},
 
+		// OnHeartbeatPayloads is run after a successful heartbeat round, with the payloads of each peer.
+		OnHeartbeatPayloads: func(s *state.State, payloads map[string]map[string]string) error {
+			logger.Info("This is a hook that is run on the dqlite leader with the heartbeat payloads", logger.Ctx{"payloads": len(payloads)})
+
+			return nil
+		},
+
+		// HeartbeatPayload is run on each peer during a heartbeat, and its result is passed to OnHeartbeatPayloads on the leader.
+		HeartbeatPayload: func(s *state.State) map[string]string {
+			return map[string]string{"version": version.Version}
+		},
+
 		// OnNewMember is run after a new member has joined.
 		OnNewMember: func(s *state.State) error {
 			logger.Infof("This is a hook that is run on peer %q when a new cluster member has joined", s.Name())
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// DefaultCertExpiryWarning is how long before the daemon certificates expire that warnings start being logged.
+const DefaultCertExpiryWarning = 30 * 24 * time.Hour
+
+// certExpiryCheckInterval is how often the expiry of the daemon certificates is checked.
+const certExpiryCheckInterval = time.Hour
+
+// certificateExpiry returns the expiry dates of the daemon certificates, keyed by certificate name: "server",
+// "cluster" once the daemon is initialized, and the name given by extensionServerCertName for each extension server
+// with a dedicated certificate.
+func (d *Daemon) certificateExpiry() map[string]time.Time {
+	certs := map[string]*shared.CertInfo{}
+	serverCert := d.ServerCert()
+	if serverCert != nil {
+		certs["server"] = serverCert
+	}
+
+	d.clusterMu.RLock()
+	if d.clusterCert != nil {
+		certs["cluster"] = d.clusterCert
+	}
+
+	d.clusterMu.RUnlock()
+
+	for _, extensionServer := range d.extensionServers {
+		if extensionServer.CoreAPI || extensionServer.Certificate == nil {
+			continue
+		}
+
+		certs[extensionServerCertName(extensionServer)] = extensionServer.Certificate
+	}
+
+	expiry := make(map[string]time.Time, len(certs))
+	for name, cert := range certs {
+		x509Cert, err := cert.PublicKeyX509()
+		if err != nil {
+			logger.Warn("Failed to parse certificate", logger.Ctx{"certificate": name, "error": err})
+			continue
+		}
+
+		expiry[name] = x509Cert.NotAfter
+	}
+
+	return expiry
+}
+
+// extensionServerCertName names the dedicated certificate of an extension server after the interface and address it
+// listens on, such as "extension:eth0/0.0.0.0:9000" or "extension:10.0.0.1:9000".
+func extensionServerCertName(extensionServer rest.Server) string {
+	var parts []string
+	if extensionServer.Interface != "" {
+		parts = append(parts, extensionServer.Interface)
+	}
+
+	if extensionServer.Address != (types.AddrPort{}) {
+		parts = append(parts, extensionServer.Address.String())
+	}
+
+	return "extension:" + strings.Join(parts, "/")
+}
+
+// expiringCertificates returns the sorted names of the certificates that expire within the given window from now.
+func expiringCertificates(expiry map[string]time.Time, now time.Time, window time.Duration) []string {
+	var names []string
+	for name, notAfter := range expiry {
+		if notAfter.Sub(now) <= window {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// checkCertExpiry logs a warning and runs the OnCertExpiring hook for each daemon certificate that expires within the
+// configured window. It returns the names of those certificates.
+func (d *Daemon) checkCertExpiry(now time.Time) []string {
+	if d.options.CertExpiryWarning < 0 {
+		return nil
+	}
+
+	expiry := d.certificateExpiry()
+	names := expiringCertificates(expiry, now, d.options.CertExpiryWarning)
+	for _, name := range names {
+		logger.Warn("Certificate is about to expire", logger.Ctx{"certificate": name, "expiry": expiry[name]})
+
+		err := d.hooks.OnCertExpiring(d.State(), name, expiry[name])
+		if err != nil {
+			logger.Error("Failed to run OnCertExpiring hook", logger.Ctx{"certificate": name, "error": err})
+		}
+	}
+
+	return names
+}
+
+// loopCertExpiry checks the expiry of the daemon certificates every certExpiryCheckInterval until the daemon shuts
+// down.
+func (d *Daemon) loopCertExpiry() {
+	for {
+		d.checkCertExpiry(time.Now())
+
+		select {
+		case <-d.shutdownCtx.Done():
+			return
+		case <-time.After(certExpiryCheckInterval):
+		}
+	}
+}
@@ -2,11 +2,17 @@ package daemon
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +24,7 @@ import (
 	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/lxd/shared/revert"
 	"github.com/gorilla/mux"
 	"gopkg.in/yaml.v2"
 
@@ -45,7 +52,9 @@ type Daemon struct {
 	address api.URL // Listen Address.
 	name    string  // Name of the cluster member.
 
-	os         *sys.OS
+	os *sys.OS
+
+	serverMu   sync.RWMutex
 	serverCert *shared.CertInfo
 
 	clusterMu   sync.RWMutex
@@ -56,6 +65,11 @@ type Daemon struct {
 
 	fsWatcher  *sys.Watcher
 	trustStore *trust.Store
+	events     *state.EventBus       // Cluster lifecycle events published to subscribers of the events endpoint.
+	requests   *state.RequestTracker // API requests being served.
+	drains     *state.DrainTracker   // Drains of cluster members, as seen by this member.
+
+	trustedCerts *internalClient.TrustedCerts // Cluster certificates trusted alongside the current one during a rotation.
 
 	hooks config.Hooks // Hooks to be called upon various daemon actions.
 
@@ -64,12 +78,128 @@ type Daemon struct {
 	shutdownDoneCh chan error         // Receives the result of state.Stop() when exit() is called and tells the daemon to end.
 	shutdownCancel context.CancelFunc // Cancels the shutdownCtx to indicate shutdown starting.
 
-	Extensions extensions.Extensions // Extensions supported at runtime by the daemon.
+	extensionsMu sync.RWMutex
+	Extensions   extensions.Extensions // Extensions supported at runtime by the daemon.
 
 	// stop is a sync.Once which wraps the daemon's stop sequence. Each call will block until the first one completes.
 	stop func() error
 
 	extensionServers []rest.Server
+
+	options    Options       // Optional daemon behaviour configured by the consumer.
+	baseLogger logger.Logger // Global logger before any member context was added.
+}
+
+// Options holds optional daemon behaviour configured by the consumer.
+type Options struct {
+	// LogMemberContext seeds every log line with the member name and address once they are known.
+	// As it sets the process-wide logger, it only tags log lines correctly with a single daemon per process.
+	LogMemberContext bool
+
+	// TLS holds optional hardening parameters for the core network listener.
+	TLS types.TLSOptions
+
+	// Version is the version of the consumer of microcluster, reported by the API.
+	Version string
+
+	// ListenInterface is the name of the network interface whose address the listener on the listen port binds to.
+	ListenInterface string
+
+	// HeartbeatJitter is the fraction of the heartbeat interval by which this member shifts its heartbeat interval.
+	// Defaults to db.DefaultHeartbeatJitter if zero.
+	HeartbeatJitter float64
+
+	// DrainTimeouts configures how long network listeners wait for API requests and streaming connections to finish
+	// when they are closed.
+	DrainTimeouts endpoints.DrainTimeouts
+
+	// TokenExpirySkew is how long past their expiry date join tokens are still accepted, to tolerate clock skew
+	// between cluster members. Defaults to cluster.DefaultTokenExpirySkew if zero.
+	TokenExpirySkew time.Duration
+
+	// HealthAddress is the address of an optional unauthenticated HTTP listener serving only the health status of
+	// the daemon. The listener is disabled if empty.
+	HealthAddress string
+
+	// AccessLog enables logging of every API request served by the daemon.
+	AccessLog bool
+
+	// AccessLogExcludedPaths are the request paths left out of the access log.
+	// Defaults to internalREST.DefaultAccessLogExcludedPaths if nil.
+	AccessLogExcludedPaths []string
+
+	// SlowTransactionThreshold is how long a database transaction may take before it is logged as slow.
+	// Defaults to db.DefaultSlowTransactionThreshold if zero, and disables the logging if negative.
+	SlowTransactionThreshold time.Duration
+
+	// MinVoters is the minimum number of dqlite voters that must remain after removing cluster members, unless the
+	// removal is forced. Defaults to cluster.DefaultMinVoters if zero.
+	MinVoters int
+
+	// DqliteTCPUserTimeout is how long data sent on a dqlite connection to another member may remain unacknowledged
+	// before the connection is dropped. Defaults to 2 minutes if zero.
+	DqliteTCPUserTimeout time.Duration
+
+	// DqliteTCPKeepAlivePeriod is the TCP keepalive period of dqlite connections to other members.
+	// Defaults to 3 seconds if zero.
+	DqliteTCPKeepAlivePeriod time.Duration
+
+	// CertExpiryWarning is how long before the daemon certificates expire that the daemon starts logging
+	// warnings and running the OnCertExpiring hook. Defaults to DefaultCertExpiryWarning if zero, and disables the
+	// warnings if negative.
+	CertExpiryWarning time.Duration
+
+	// StrictSocketGroup makes the daemon fail to start if the group of a unix socket doesn't exist, instead of
+	// falling back to the process group.
+	StrictSocketGroup bool
+
+	// ReadOnly makes this a read-only cluster member, whose transactions may not modify the database.
+	ReadOnly bool
+
+	// SchemaHooks are run right after the schema extension of the version they are keyed by is applied, starting
+	// at 1 for the first schema extension.
+	SchemaHooks map[int]schema.Hook
+
+	// DqliteMaxConnections is the maximum number of concurrent inbound dqlite connections from all peers.
+	// Defaults to db.DefaultMaxConnections if zero, and disables the limit if negative.
+	DqliteMaxConnections int
+
+	// DqliteMaxConnectionsPerPeer is the maximum number of concurrent inbound dqlite connections from a single peer.
+	// Defaults to db.DefaultMaxConnectionsPerPeer if zero, and disables the limit if negative.
+	DqliteMaxConnectionsPerPeer int
+
+	// ControlSocketPath overrides the path of the control socket under the state directory, if set. It must be absolute.
+	ControlSocketPath string
+
+	// CertificateCustomizer changes the subject and names of the server and cluster certificates before they are
+	// generated, if set.
+	CertificateCustomizer func(*types.CertificateOptions)
+
+	// QuorumMembers is the number of cluster members that the bootstrapped member waits for before running the
+	// OnQuorum hook. The hook is not run if zero.
+	QuorumMembers int
+
+	// QuorumTimeout is how long the bootstrapped member waits for QuorumMembers cluster members before running the
+	// OnQuorum hook anyway. Defaults to DefaultQuorumTimeout if zero.
+	QuorumTimeout time.Duration
+
+	// ClientCertificate is presented instead of the server certificate when querying the API of other cluster
+	// members. Dqlite connections keep using the server certificate.
+	ClientCertificate *shared.CertInfo
+
+	// DebugHTTPAddress is the loopback address of an optional, insecure HTTP listener serving resources.DebugEndpoints
+	// without authentication. The listener is disabled if empty.
+	DebugHTTPAddress string
+
+	// RootHandler responds to requests for "/". Defaults to rootResponse if nil.
+	RootHandler func(s *state.State, r *http.Request) response.Response
+
+	// NotFoundHandler responds to requests that match no endpoint. Defaults to notFoundResponse if nil.
+	NotFoundHandler func(s *state.State, r *http.Request) response.Response
+
+	// DivergencePolicy is how the local database is handled if it diverged from the cluster when the daemon restarts.
+	// Defaults to types.DivergenceAbort if empty.
+	DivergencePolicy types.DivergencePolicy
 }
 
 // NewDaemon initializes the Daemon context and channels.
@@ -78,6 +208,10 @@ func NewDaemon(project string) *Daemon {
 		shutdownDoneCh: make(chan error),
 		ReadyChan:      make(chan struct{}),
 		project:        project,
+		events:         state.NewEventBus(),
+		requests:       state.NewRequestTracker(),
+		drains:         state.NewDrainTracker(),
+		trustedCerts:   &internalClient.TrustedCerts{},
 	}
 
 	d.stop = sync.OnceValue(func() error {
@@ -98,8 +232,111 @@ func NewDaemon(project string) *Daemon {
 // - `extensionsSchema` is a list of schema updates in the order that they should be applied.
 // - `extensionServers` is a list of rest.Server that will be initialized and managed by microcluster.
 // - `hooks` are a set of functions that trigger at certain points during cluster communication.
-func (d *Daemon) Run(ctx context.Context, listenPort string, stateDir string, socketGroup string, extensionsSchema []schema.Update, apiExtensions []string, extensionServers []rest.Server, hooks *config.Hooks) error {
+// - `options` configures optional daemon behaviour.
+func (d *Daemon) Run(ctx context.Context, listenPort string, stateDir string, socketGroup string, extensionsSchema []schema.Update, apiExtensions []string, extensionServers []rest.Server, hooks *config.Hooks, options Options) error {
 	d.shutdownCtx, d.shutdownCancel = context.WithCancel(ctx)
+	d.options = options
+	err := d.options.TLS.Validate()
+	if err != nil {
+		return fmt.Errorf("Invalid TLS options: %w", err)
+	}
+
+	if d.options.HeartbeatJitter < 0 || d.options.HeartbeatJitter >= 1 {
+		return fmt.Errorf("Heartbeat jitter must be at least 0 and less than 1, got %v", d.options.HeartbeatJitter)
+	}
+
+	if d.options.DrainTimeouts.Requests < 0 || d.options.DrainTimeouts.Streams < 0 {
+		return fmt.Errorf("Drain timeouts must not be negative")
+	}
+
+	if d.options.TokenExpirySkew < 0 {
+		return fmt.Errorf("Token expiry skew must not be negative")
+	}
+
+	if d.options.MinVoters < 0 {
+		return fmt.Errorf("Minimum number of voters must not be negative")
+	}
+
+	if d.options.QuorumMembers < 0 || d.options.QuorumTimeout < 0 {
+		return fmt.Errorf("Quorum members and timeout must not be negative")
+	}
+
+	if d.options.DqliteTCPUserTimeout < 0 || d.options.DqliteTCPKeepAlivePeriod < 0 {
+		return fmt.Errorf("Dqlite TCP timeouts must not be negative")
+	}
+
+	if d.options.ClientCertificate != nil {
+		_, err := d.options.ClientCertificate.PublicKeyX509()
+		if err != nil {
+			return fmt.Errorf("Invalid client certificate: %w", err)
+		}
+	}
+
+	for version := range d.options.SchemaHooks {
+		if version < 1 || version > len(extensionsSchema) {
+			return fmt.Errorf("Schema hook version %d does not match any of the %d schema extensions", version, len(extensionsSchema))
+		}
+	}
+
+	if d.options.ControlSocketPath != "" && !filepath.IsAbs(d.options.ControlSocketPath) {
+		return fmt.Errorf("Control socket path %q must be absolute", d.options.ControlSocketPath)
+	}
+
+	if d.options.HealthAddress != "" {
+		_, _, err := net.SplitHostPort(d.options.HealthAddress)
+		if err != nil {
+			return fmt.Errorf("Invalid health address %q: %w", d.options.HealthAddress, err)
+		}
+	}
+
+	if d.options.DebugHTTPAddress != "" {
+		err := endpoints.ValidateLoopbackAddress(d.options.DebugHTTPAddress)
+		if err != nil {
+			return fmt.Errorf("Invalid debug http address: %w", err)
+		}
+	}
+
+	err = d.options.DivergencePolicy.Validate()
+	if err != nil {
+		return err
+	}
+
+	if d.options.HeartbeatJitter == 0 {
+		d.options.HeartbeatJitter = db.DefaultHeartbeatJitter
+	}
+
+	if d.options.TokenExpirySkew == 0 {
+		d.options.TokenExpirySkew = cluster.DefaultTokenExpirySkew
+	}
+
+	if d.options.MinVoters == 0 {
+		d.options.MinVoters = cluster.DefaultMinVoters
+	}
+
+	if d.options.QuorumTimeout == 0 {
+		d.options.QuorumTimeout = DefaultQuorumTimeout
+	}
+
+	if d.options.AccessLogExcludedPaths == nil {
+		d.options.AccessLogExcludedPaths = internalREST.DefaultAccessLogExcludedPaths
+	}
+
+	if d.options.CertExpiryWarning == 0 {
+		d.options.CertExpiryWarning = DefaultCertExpiryWarning
+	}
+
+	if d.options.SlowTransactionThreshold == 0 {
+		d.options.SlowTransactionThreshold = db.DefaultSlowTransactionThreshold
+	}
+
+	if d.options.DqliteMaxConnections == 0 {
+		d.options.DqliteMaxConnections = db.DefaultMaxConnections
+	}
+
+	if d.options.DqliteMaxConnectionsPerPeer == 0 {
+		d.options.DqliteMaxConnectionsPerPeer = db.DefaultMaxConnectionsPerPeer
+	}
+
 	if stateDir == "" {
 		stateDir = os.Getenv(sys.StateDir)
 	}
@@ -108,7 +345,7 @@ func (d *Daemon) Run(ctx context.Context, listenPort string, stateDir string, so
 		return fmt.Errorf("State directory must be specified")
 	}
 
-	_, err := os.Stat(stateDir)
+	_, err = os.Stat(stateDir)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("Failed to find state directory: %w", err)
 	}
@@ -118,6 +355,8 @@ func (d *Daemon) Run(ctx context.Context, listenPort string, stateDir string, so
 		return fmt.Errorf("Failed to initialize directory structure: %w", err)
 	}
 
+	d.os.ControlSocketFile = d.options.ControlSocketPath
+
 	isAlreadyRunning, err := d.os.IsControlSocketPresent()
 	if err != nil {
 		return err
@@ -127,7 +366,7 @@ func (d *Daemon) Run(ctx context.Context, listenPort string, stateDir string, so
 		return fmt.Errorf("Control socket already present (%q); is another daemon already running?", d.os.ControlSocketPath())
 	}
 
-	d.extensionServers = extensionServers
+	d.extensionServers = orderExtensionServers(extensionServers)
 
 	err = d.init(listenPort, extensionsSchema, apiExtensions, hooks)
 	if err != nil {
@@ -160,16 +399,16 @@ func (d *Daemon) init(listenPort string, schemaExtensions []schema.Update, apiEx
 		return fmt.Errorf("Failed to assign default system name: %w", err)
 	}
 
-	// Initialize the extensions registry with the internal extensions.
-	d.Extensions, err = extensions.NewExtensionRegistry(true)
+	d.Extensions, err = newExtensionRegistry(apiExtensions, d.os.RuntimeExtensionsPath())
 	if err != nil {
 		return err
 	}
 
-	// Register the extensions passed at initialization.
-	err = d.Extensions.Register(apiExtensions)
-	if err != nil {
-		return err
+	if d.options.CertificateCustomizer != nil {
+		err = sys.GenerateCert(d.os.StateDir, "server", d.options.CertificateCustomizer)
+		if err != nil {
+			return fmt.Errorf("Failed to generate server certificate: %w", err)
+		}
 	}
 
 	d.serverCert, err = util.LoadServerCert(d.os.StateDir)
@@ -182,7 +421,14 @@ func (d *Daemon) init(listenPort string, schemaExtensions []schema.Update, apiEx
 		return fmt.Errorf("Failed to initialize trust store: %w", err)
 	}
 
-	d.db = db.NewDB(d.shutdownCtx, d.serverCert, d.ClusterCert, d.os)
+	d.db = db.NewDB(d.shutdownCtx, d.ServerCert, d.ClusterCert, d.os)
+	d.db.SetHeartbeatJitter(d.options.HeartbeatJitter)
+	d.db.SetSlowTransactionThreshold(max(d.options.SlowTransactionThreshold, 0))
+	d.db.SetTCPTimeouts(d.options.DqliteTCPUserTimeout, d.options.DqliteTCPKeepAlivePeriod)
+	d.db.SetConnectionLimits(max(d.options.DqliteMaxConnections, 0), max(d.options.DqliteMaxConnectionsPerPeer, 0))
+	d.db.SetReadOnly(d.options.ReadOnly)
+	d.db.SetDivergencePolicy(d.options.DivergencePolicy)
+	d.db.SetTrustedCerts(d.trustedCerts)
 
 	// Extract user defined endpoints for core listener.
 	coreEndpoints, err := resources.GetAndValidateCoreEndpoints(d.extensionServers)
@@ -190,33 +436,90 @@ func (d *Daemon) init(listenPort string, schemaExtensions []schema.Update, apiEx
 		return err
 	}
 
+	// Extract user defined endpoints for the control socket.
+	unixEndpoints, err := resources.GetAndValidateUnixEndpoints(d.extensionServers)
+	if err != nil {
+		return err
+	}
+
+	// Extract user defined servers with a unix socket of their own.
+	socketServers, err := resources.GetAndValidateSocketServers(d.extensionServers, d.os.ControlSocketPath())
+	if err != nil {
+		return err
+	}
+
 	serverEndpoints := []rest.Resources{
 		resources.UnixEndpoints,
 		resources.InternalEndpoints,
 		resources.PublicEndpoints,
 	}
 	serverEndpoints = append(serverEndpoints, coreEndpoints...)
-	ctlServer := d.initServer(serverEndpoints...)
+	serverEndpoints = append(serverEndpoints, unixEndpoints...)
+	ctlServer := d.initServer(d.compressCoreAPI(), serverEndpoints...)
 	ctl := endpoints.NewSocket(d.shutdownCtx, ctlServer, d.os.ControlSocket(), d.os.SocketGroup)
+	ctl.StrictGroup = d.options.StrictSocketGroup
 	d.endpoints = endpoints.NewEndpoints(d.shutdownCtx, ctl)
 	err = d.endpoints.Up()
 	if err != nil {
 		return err
 	}
 
+	for _, socketServer := range socketServers {
+		server := d.initServer(socketServer.Compress, socketServer.Resources...)
+		url := api.NewURL().Scheme("http").Host(socketServer.SocketPath)
+		socket := endpoints.NewSocket(d.shutdownCtx, server, *url, socketServer.SocketGroup)
+		socket.StrictGroup = d.options.StrictSocketGroup
+		err = d.endpoints.Add(socket)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.options.HealthAddress != "" {
+		healthServer := &http.Server{
+			Handler:     resources.HealthHandler(d.State()),
+			ReadTimeout: 5 * time.Second,
+			IdleTimeout: 30 * time.Second,
+		}
+
+		health := endpoints.NewHealth(d.shutdownCtx, healthServer, d.options.HealthAddress, d.options.DrainTimeouts)
+		err = d.endpoints.Add(health)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.options.DebugHTTPAddress != "" {
+		debug := endpoints.NewDebugHTTP(d.shutdownCtx, d.debugServer(), d.options.DebugHTTPAddress, d.options.DrainTimeouts)
+		err = d.endpoints.Add(debug)
+		if err != nil {
+			return err
+		}
+	}
+
 	if listenPort != "" {
-		serverEndpoints = []rest.Resources{resources.PublicEndpoints}
-		serverEndpoints = append(serverEndpoints, coreEndpoints...)
-		server := d.initServer(serverEndpoints...)
-		url := api.NewURL().Host(fmt.Sprintf(":%s", listenPort))
-		network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, *url, d.serverCert)
-		err = d.endpoints.Add(network)
+		host := fmt.Sprintf(":%s", listenPort)
+		if d.options.ListenInterface != "" {
+			port, err := strconv.ParseUint(listenPort, 10, 16)
+			if err != nil {
+				return fmt.Errorf("Invalid listen port %q: %w", listenPort, err)
+			}
+
+			addr, err := endpoints.ResolveInterfaceAddress(d.options.ListenInterface, types.AddrPort{AddrPort: netip.AddrPortFrom(netip.Addr{}, uint16(port))})
+			if err != nil {
+				return err
+			}
+
+			host = addr.String()
+		}
+
+		err = d.startPreInitListener(host, coreEndpoints)
 		if err != nil {
 			return err
 		}
 	}
 
-	d.db.SetSchema(schemaExtensions, d.Extensions)
+	d.db.SetSchema(schemaExtensions, d.options.SchemaHooks, d.Extensions)
 
 	err = d.reloadIfBootstrapped()
 	if err != nil {
@@ -228,6 +531,44 @@ func (d *Daemon) init(listenPort string, schemaExtensions []schema.Update, apiEx
 		return err
 	}
 
+	go d.loopCertExpiry()
+
+	return nil
+}
+
+// startPreInitListener starts the network listener that serves the public API before the daemon is initialized,
+// replacing any that is already running.
+func (d *Daemon) startPreInitListener(host string, coreEndpoints []rest.Resources) error {
+	serverEndpoints := []rest.Resources{resources.PublicEndpoints}
+	serverEndpoints = append(serverEndpoints, coreEndpoints...)
+	server := d.initServer(d.compressCoreAPI(), serverEndpoints...)
+	url := api.NewURL().Host(host)
+	network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, *url, d.serverCert, d.options.TLS, d.options.DrainTimeouts)
+	err := d.endpoints.Down(endpoints.EndpointNetwork)
+	if err != nil {
+		return err
+	}
+
+	return d.endpoints.Add(network)
+}
+
+// setPreInitAddress moves the network listener that serves the public API before the daemon is initialized to the
+// given address. It fails once the database is open, as the daemon then listens on its configured address instead.
+func (d *Daemon) setPreInitAddress(address types.AddrPort) error {
+	if d.db.IsOpen() {
+		return api.StatusErrorf(http.StatusConflict, "The listen address can only be changed before the daemon is initialized")
+	}
+
+	coreEndpoints, err := resources.GetAndValidateCoreEndpoints(d.extensionServers)
+	if err != nil {
+		return err
+	}
+
+	err = d.startPreInitListener(address.String(), coreEndpoints)
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %q: %w", address.String(), err)
+	}
+
 	return nil
 }
 
@@ -236,6 +577,18 @@ func (d *Daemon) applyHooks(hooks *config.Hooks) {
 	noOpHook := func(s *state.State) error { return nil }
 	noOpRemoveHook := func(s *state.State, force bool) error { return nil }
 	noOpInitHook := func(s *state.State, initConfig map[string]string) error { return nil }
+	noOpHeartbeatHook := func(s *state.State, payloads map[string]map[string]string) error { return nil }
+	noOpCertExpiringHook := func(s *state.State, name string, expiry time.Time) error { return nil }
+	noOpValidateConfigHook := func(ctx context.Context, s *state.State, current trust.Location, new trust.Location) error {
+		return nil
+	}
+	noOpOnInitHook := func(ctx context.Context, s *state.State, bootstrap bool, initConfig map[string]string) error {
+		return nil
+	}
+
+	noOpDrainHook := func(ctx context.Context, s *state.State) error { return nil }
+	noOpDrainChangeHook := func(s *state.State, name string, draining bool) error { return nil }
+	noOpQuorumHook := func(s *state.State, members int) error { return nil }
 
 	if hooks == nil {
 		d.hooks = config.Hooks{}
@@ -259,6 +612,10 @@ func (d *Daemon) applyHooks(hooks *config.Hooks) {
 		d.hooks.PreJoin = noOpInitHook
 	}
 
+	if d.hooks.OnInit == nil {
+		d.hooks.OnInit = noOpOnInitHook
+	}
+
 	if d.hooks.OnStart == nil {
 		d.hooks.OnStart = noOpHook
 	}
@@ -267,6 +624,10 @@ func (d *Daemon) applyHooks(hooks *config.Hooks) {
 		d.hooks.OnHeartbeat = noOpHook
 	}
 
+	if d.hooks.OnHeartbeatPayloads == nil {
+		d.hooks.OnHeartbeatPayloads = noOpHeartbeatHook
+	}
+
 	if d.hooks.OnNewMember == nil {
 		d.hooks.OnNewMember = noOpHook
 	}
@@ -278,6 +639,28 @@ func (d *Daemon) applyHooks(hooks *config.Hooks) {
 	if d.hooks.PostRemove == nil {
 		d.hooks.PostRemove = noOpRemoveHook
 	}
+
+	if d.hooks.OnDrain == nil {
+		d.hooks.OnDrain = noOpDrainHook
+	}
+
+	if d.hooks.OnDrainChange == nil {
+		d.hooks.OnDrainChange = noOpDrainChangeHook
+	}
+
+	if d.hooks.OnQuorum == nil {
+		d.hooks.OnQuorum = noOpQuorumHook
+	}
+
+	if d.hooks.ValidateDaemonConfig == nil {
+		d.hooks.ValidateDaemonConfig = noOpValidateConfigHook
+	}
+
+	if d.hooks.OnCertExpiring == nil {
+		d.hooks.OnCertExpiring = noOpCertExpiringHook
+	}
+
+	// OnAutoUpdate is left unset so that the SCHEMA_UPDATE executable can be used as a fallback.
 }
 
 func (d *Daemon) reloadIfBootstrapped() error {
@@ -306,7 +689,7 @@ func (d *Daemon) reloadIfBootstrapped() error {
 		return fmt.Errorf("Failed to retrieve daemon configuration yaml: %w", err)
 	}
 
-	err = d.StartAPI(false, nil, nil)
+	err = d.StartAPI(d.shutdownCtx, false, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -321,7 +704,7 @@ func (d *Daemon) initStore() error {
 		return err
 	}
 
-	d.trustStore, err = trust.Init(d.fsWatcher, nil, d.os.TrustDir)
+	d.trustStore, err = trust.Init(d.fsWatcher, nil, d.os.TrustDir, d.trustedCerts)
 	if err != nil {
 		return err
 	}
@@ -329,7 +712,28 @@ func (d *Daemon) initStore() error {
 	return nil
 }
 
-func (d *Daemon) initServer(resources ...rest.Resources) *http.Server {
+// compressCoreAPI returns whether the core API server asks for its responses to be compressed. This applies to the
+// core listener and the control socket, which serve its resources.
+func (d *Daemon) compressCoreAPI() bool {
+	for _, extensionServer := range d.extensionServers {
+		if extensionServer.CoreAPI {
+			return extensionServer.Compress
+		}
+	}
+
+	return false
+}
+
+// debugServer returns the server of the debug http listener, serving resources.DebugEndpoints to unauthenticated
+// clients.
+func (d *Daemon) debugServer() *http.Server {
+	server := d.initServer(false, resources.DebugEndpoints)
+	server.Handler = internalREST.TrustDebugHTTP(server.Handler)
+
+	return server
+}
+
+func (d *Daemon) initServer(compress bool, resources ...rest.Resources) *http.Server {
 	/* Setup the web server */
 	mux := mux.NewRouter()
 	mux.StrictSlash(false)
@@ -351,9 +755,20 @@ func (d *Daemon) initServer(resources ...rest.Resources) *http.Server {
 		}
 	}
 
+	rootHandler := d.options.RootHandler
+	if rootHandler == nil {
+		rootHandler = rootResponse
+	}
+
+	notFoundHandler := d.options.NotFoundHandler
+	if notFoundHandler == nil {
+		notFoundHandler = notFoundResponse
+	}
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Default to JSON, which responses rendering something else can override.
 		w.Header().Set("Content-Type", "application/json")
-		err := response.SyncResponse(true, []string{"/1.0"}).Render(w)
+		err := rootHandler(state, r).Render(w)
 		if err != nil {
 			logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
 		}
@@ -362,23 +777,57 @@ func (d *Daemon) initServer(resources ...rest.Resources) *http.Server {
 	mux.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Info("Sending top level 404", logger.Ctx{"url": r.URL})
 		w.Header().Set("Content-Type", "application/json")
-		err := response.NotFound(nil).Render(w)
+		err := notFoundHandler(state, r).Render(w)
 		if err != nil {
 			logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
 		}
 	})
 
+	var handler http.Handler = internalREST.Encode(mux)
+	if compress {
+		handler = internalREST.Compress(handler)
+	}
+
+	if d.options.AccessLog {
+		handler = internalREST.AccessLog(state, d.options.AccessLogExcludedPaths, handler)
+	}
+
 	return &http.Server{
-		Handler:     mux,
+		Handler:     handler,
 		ConnContext: request.SaveConnectionInContext,
 	}
 }
 
+// rootResponse lists the API versions served by the daemon. For legacy callers, the bare list is kept by default,
+// and the full server information is only returned with ?recursion=1.
+func rootResponse(s *state.State, r *http.Request) response.Response {
+	if r.URL.Query().Get("recursion") != "1" {
+		return response.SyncResponse(true, []string{"/1.0"})
+	}
+
+	server, err := resources.ServerInfo(s)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, server)
+}
+
+// notFoundResponse is the response to requests that match no endpoint.
+func notFoundResponse(s *state.State, r *http.Request) response.Response {
+	return response.NotFound(nil)
+}
+
 // StartAPI starts up the admin and consumer APIs, and generates a cluster cert
 // if we are bootstrapping the first node.
-func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error {
+func (d *Daemon) StartAPI(ctx context.Context, bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error {
 	if newConfig != nil {
-		err := d.setDaemonConfig(newConfig)
+		err := d.hooks.ValidateDaemonConfig(ctx, d.State(), d.daemonConfig(), *newConfig)
+		if err != nil {
+			return fmt.Errorf("Daemon configuration was rejected: %w", err)
+		}
+
+		err = d.setDaemonConfig(newConfig)
 		if err != nil {
 			return fmt.Errorf("Failed to apply and save new daemon configuration: %w", err)
 		}
@@ -405,9 +854,15 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 		return fmt.Errorf("Failed to parse listen address when bootstrapping API: %w", err)
 	}
 
+	clientCert, err := trust.ClientCertificate(d.serverCert, d.options.ClientCertificate)
+	if err != nil {
+		return err
+	}
+
 	localNode := trust.Remote{
-		Location:    trust.Location{Name: d.name, Address: addrPort},
-		Certificate: types.X509Certificate{Certificate: serverCert},
+		Location:          trust.Location{Name: d.name, Address: addrPort},
+		Certificate:       types.X509Certificate{Certificate: serverCert},
+		ClientCertificate: clientCert,
 	}
 
 	if bootstrap {
@@ -415,6 +870,14 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 		if err != nil {
 			return fmt.Errorf("Failed to initialize local remote entry: %w", err)
 		}
+
+		// Joining members receive the cluster certificate from the cluster instead.
+		if d.options.CertificateCustomizer != nil {
+			err = sys.GenerateCert(d.os.StateDir, "cluster", d.options.CertificateCustomizer)
+			if err != nil {
+				return fmt.Errorf("Failed to generate cluster certificate: %w", err)
+			}
+		}
 	}
 
 	err = d.ReloadClusterCert()
@@ -430,8 +893,8 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 
 	serverEndpoints := []rest.Resources{resources.InternalEndpoints, resources.PublicEndpoints}
 	serverEndpoints = append(serverEndpoints, coreEndpoints...)
-	server := d.initServer(serverEndpoints...)
-	network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, d.address, d.ClusterCert())
+	server := d.initServer(d.compressCoreAPI(), serverEndpoints...)
+	network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, d.address, d.ClusterCert(), d.options.TLS, d.options.DrainTimeouts)
 	err = d.endpoints.Down(endpoints.EndpointNetwork)
 	if err != nil {
 		return err
@@ -452,9 +915,13 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 			Role:        cluster.Pending,
 		}
 
+		if localNode.ClientCertificate != nil {
+			clusterMember.ClientCertificate = localNode.ClientCertificate.String()
+		}
+
 		clusterMember.SchemaInternal, clusterMember.SchemaExternal = d.db.Schema().Version()
 
-		err = d.db.Bootstrap(d.Extensions, d.project, d.address, clusterMember)
+		err = d.db.Bootstrap(d.extensions(), d.project, d.address, clusterMember)
 		if err != nil {
 			return err
 		}
@@ -475,20 +942,34 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 			return fmt.Errorf("Failed to run post-bootstrap actions: %w", err)
 		}
 
+		err = d.hooks.OnInit(ctx, d.State(), true, initConfig)
+		if err != nil {
+			return fmt.Errorf("Failed to run post-init actions: %w", err)
+		}
+
+		if d.options.QuorumMembers > 0 {
+			go d.waitForQuorum()
+		}
+
 		// Return as we have completed the bootstrap process.
 		return nil
 	}
 
 	if len(joinAddresses) != 0 {
-		err = d.db.Join(d.Extensions, d.project, d.address, joinAddresses...)
+		err = d.db.Join(d.extensions(), d.project, d.address, joinAddresses...)
 		if err != nil {
 			return fmt.Errorf("Failed to join cluster: %w", err)
 		}
 	} else {
-		err = d.db.StartWithCluster(d.Extensions, d.project, d.address, d.trustStore.Remotes().Addresses())
+		err = d.db.StartWithCluster(d.extensions(), d.project, d.address, d.trustStore.Remotes().Addresses())
 		if err != nil {
 			return fmt.Errorf("Failed to re-establish cluster connection: %w", err)
 		}
+
+		err = d.updateClientCertificate(ctx, localNode.ClientCertificate)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = d.trustStore.Refresh()
@@ -507,7 +988,10 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 		return err
 	}
 
-	localMemberInfo := internalTypes.ClusterMemberLocal{Name: localNode.Name, Address: localNode.Address, Certificate: localNode.Certificate}
+	// Carry the request ID of the caller over to the requests sent to other cluster members.
+	queryCtx := internalClient.WithRequestID(d.shutdownCtx, internalClient.RequestID(ctx))
+
+	localMemberInfo := internalTypes.ClusterMemberLocal{Name: localNode.Name, Address: localNode.Address, Certificate: localNode.Certificate, ClientCertificate: localNode.ClientCertificate}
 	if len(joinAddresses) > 0 {
 		err = d.hooks.PreJoin(d.State(), initConfig)
 		if err != nil {
@@ -518,7 +1002,7 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 	if len(joinAddresses) > 0 {
 		var lastErr error
 		var clusterConfirmation bool
-		err = cluster.Query(d.shutdownCtx, true, func(ctx context.Context, c *client.Client) error {
+		err = cluster.Query(queryCtx, true, func(ctx context.Context, c *client.Client) error {
 			// No need to send a request to ourselves.
 			if d.address.URL.Host == c.URL().URL.Host {
 				return nil
@@ -548,9 +1032,12 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 
 	// Tell the other nodes that this system is up.
 	remotes := d.trustStore.Remotes()
-	err = cluster.Query(d.shutdownCtx, true, func(ctx context.Context, c *client.Client) error {
-		c.SetClusterNotification()
+	notifyCluster, err := remotes.Cluster(true, d.ServerCert(), publicKey)
+	if err != nil {
+		return err
+	}
 
+	err = notifyCluster.Query(queryCtx, true, func(ctx context.Context, c *client.Client) error {
 		// No need to send a request to ourselves.
 		if d.address.URL.Host == c.URL().URL.Host {
 			return nil
@@ -594,32 +1081,85 @@ func (d *Daemon) StartAPI(bootstrap bool, initConfig map[string]string, newConfi
 	}
 
 	if len(joinAddresses) > 0 {
-		return d.hooks.PostJoin(d.State(), initConfig)
+		d.State().PublishEvent(types.EventMemberJoined, map[string]string{"name": d.name})
+
+		err = d.hooks.PostJoin(d.State(), initConfig)
+		if err != nil {
+			return err
+		}
+
+		err = d.hooks.OnInit(ctx, d.State(), false, initConfig)
+		if err != nil {
+			return fmt.Errorf("Failed to run post-init actions: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// orderExtensionServers returns a copy of the extension servers sorted by the order in which they should be started.
+func orderExtensionServers(extensionServers []rest.Server) []rest.Server {
+	ordered := append([]rest.Server{}, extensionServers...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Order < ordered[j].Order })
+
+	return ordered
+}
+
 // addExtensionServers initialises a new *endpoints.Network for each extension server and adds it to the Daemon endpoints.
+// It returns an error before starting any of them if two servers, or a server and the core API, would listen on
+// colliding addresses.
 func (d *Daemon) addExtensionServers() error {
+	coreAddress, err := types.ParseAddrPort(d.address.URL.Host)
+	if err != nil {
+		return err
+	}
+
 	var networks []endpoints.Endpoint
+	var addresses []types.AddrPort
 	for _, extensionServer := range d.extensionServers {
 		if extensionServer.CoreAPI {
 			continue
 		}
 
+		// Servers with no address of their own are only served on a unix socket.
+		if (extensionServer.ServeUnix || extensionServer.SocketPath != "") && extensionServer.Address == (types.AddrPort{}) && extensionServer.Interface == "" {
+			continue
+		}
+
 		cert := extensionServer.Certificate
 		if cert == nil {
 			cert = d.ClusterCert()
 		}
 
-		server := d.initServer(extensionServer.Resources...)
-		url := api.NewURL().Scheme(extensionServer.Protocol).Host(extensionServer.Address.String())
-		network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, *url, cert)
+		address := extensionServer.Address
+		if extensionServer.Interface != "" {
+			var err error
+			address, err = endpoints.ResolveInterfaceAddress(extensionServer.Interface, extensionServer.Address)
+			if err != nil {
+				return err
+			}
+		}
+
+		if addressesCollide(address, coreAddress) {
+			return fmt.Errorf("Extension server address %q collides with the core API address %q", address.String(), coreAddress.String())
+		}
+
+		for _, other := range addresses {
+			if addressesCollide(address, other) {
+				return fmt.Errorf("Extension server address %q collides with extension server address %q", address.String(), other.String())
+			}
+		}
+
+		addresses = append(addresses, address)
+
+		server := d.initServer(extensionServer.Compress, extensionServer.Resources...)
+		url := api.NewURL().Scheme(extensionServer.Protocol).Host(address.String())
+		network := endpoints.NewNetwork(d.shutdownCtx, endpoints.EndpointNetwork, server, *url, cert, extensionServer.TLS, d.options.DrainTimeouts)
+		network.DedicatedCert = extensionServer.Certificate != nil
 		networks = append(networks, network)
 	}
 
-	err := d.endpoints.Add(networks...)
+	err = d.endpoints.Add(networks...)
 	if err != nil {
 		return err
 	}
@@ -627,6 +1167,59 @@ func (d *Daemon) addExtensionServers() error {
 	return nil
 }
 
+// addressesCollide returns whether listeners on both addresses would fight over the same port. An unspecified IPv4
+// address collides with every IPv4 address on the same port, and an unspecified IPv6 address with every address on
+// the same port, as IPv6 listeners also accept IPv4 connections by default.
+func addressesCollide(a types.AddrPort, b types.AddrPort) bool {
+	if a.Port() != b.Port() {
+		return false
+	}
+
+	addrA := a.Addr().Unmap()
+	addrB := b.Addr().Unmap()
+	if addrA == addrB {
+		return true
+	}
+
+	wildcard := func(wildcard netip.Addr, other netip.Addr) bool {
+		return wildcard.IsUnspecified() && (wildcard.Is6() || other.Is4())
+	}
+
+	return wildcard(addrA, addrB) || wildcard(addrB, addrA)
+}
+
+// updateClientCertificate records the given client certificate of this member in the database if it changed since the
+// member last started. Other cluster members trust the new certificate once their truststore is next replaced by a
+// heartbeat, so queries made while starting up keep using the server certificate.
+func (d *Daemon) updateClientCertificate(ctx context.Context, clientCert *types.X509Certificate) error {
+	newCert := ""
+	if clientCert != nil {
+		newCert = clientCert.String()
+	}
+
+	return d.db.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		member, err := cluster.GetInternalClusterMember(ctx, tx, d.name)
+		if err != nil {
+			return fmt.Errorf("Failed to get local cluster member record: %w", err)
+		}
+
+		if member.ClientCertificate == newCert {
+			return nil
+		}
+
+		member.ClientCertificate = newCert
+
+		err = cluster.UpdateInternalClusterMember(ctx, tx, d.name, *member)
+		if err != nil {
+			return err
+		}
+
+		d.db.RecordChange(ctx, db.ClusterMembersTable, types.ChangeUpdate, d.name)
+
+		return nil
+	})
+}
+
 func (d *Daemon) sendUpgradeNotification(ctx context.Context, c *client.Client) error {
 	path := c.URL()
 	parts := strings.Split(string(internalTypes.InternalEndpoint), "/")
@@ -659,6 +1252,207 @@ func (d *Daemon) sendUpgradeNotification(ctx context.Context, c *client.Client)
 	return nil
 }
 
+// extensions returns the API extensions currently supported by the daemon.
+func (d *Daemon) extensions() extensions.Extensions {
+	d.extensionsMu.RLock()
+	defer d.extensionsMu.RUnlock()
+
+	return d.Extensions
+}
+
+// RegisterExtensions adds API extensions to the running daemon, for example after the consumer updated itself in
+// place or loaded a plugin. Extensions can only be added and never removed, so the API extensions of a member only
+// ever move forward. Names that are malformed are rejected with a 400 error, and names that are already registered
+// or repeated with a 409 error, in which case none of the extensions are registered.
+//
+// The extensions registered at runtime are recorded in the state directory, and registered again when the daemon
+// restarts, right after those passed to Start. If the daemon is initialized, they are also recorded in the database
+// for the local cluster member. If either fails, neither record is kept and the registry is left unchanged.
+//
+// Once registered, the other cluster members are notified, as for a schema update. A failure to notify them is only
+// logged, as the extensions are already registered.
+//
+// At startup, waitUpgrade records the API extensions of the member and compares them with those of every other
+// member: a member that is behind any other refuses to start, and a member that is ahead waits for the others until
+// they catch up or send an upgrade notification. Registering extensions at runtime moves the member ahead of the
+// others without that wait: a mismatch is only logged, and the running members keep serving requests. Another member
+// that restarts before registering the same extensions, at Start or at runtime, is then behind and refuses to start,
+// while a member waiting in waitUpgrade is woken by the notification to compare the extensions again. The member
+// itself registers its recorded runtime extensions again on restart, so it never restarts behind its own record in
+// the database.
+func (d *Daemon) RegisterExtensions(ctx context.Context, newExtensions []string) error {
+	added, err := d.registerExtensions(ctx, newExtensions)
+	if err != nil {
+		return err
+	}
+
+	if len(added) == 0 || !d.db.IsOpen() {
+		return nil
+	}
+
+	// Notify the other cluster members without holding the lock, so that slow or unreachable members don't block
+	// readers of the registry.
+	publicKey, err := d.ClusterCert().PublicKeyX509()
+	if err != nil {
+		logger.Error("Failed to notify cluster members of new API extensions", logger.Ctx{"error": err})
+		return nil
+	}
+
+	cluster, err := d.trustStore.Remotes().Cluster(true, d.ServerCert(), publicKey)
+	if err != nil {
+		logger.Error("Failed to notify cluster members of new API extensions", logger.Ctx{"error": err})
+		return nil
+	}
+
+	err = cluster.Query(ctx, true, func(ctx context.Context, c *client.Client) error {
+		if d.address.URL.Host == c.URL().URL.Host {
+			return nil
+		}
+
+		return d.sendUpgradeNotification(ctx, c)
+	})
+	if err != nil {
+		logger.Error("Failed to notify cluster members of new API extensions", logger.Ctx{"error": err})
+	}
+
+	return nil
+}
+
+// registerExtensions records the given API extensions on disk and in the database, and adds them to the registry.
+// It returns the extensions that were added.
+func (d *Daemon) registerExtensions(ctx context.Context, newExtensions []string) ([]string, error) {
+	d.extensionsMu.Lock()
+	defer d.extensionsMu.Unlock()
+
+	added := make([]string, 0, len(newExtensions))
+	for _, extension := range newExtensions {
+		if d.Extensions.HasExtension(extension) || shared.ValueInSlice(extension, added) {
+			return nil, api.StatusErrorf(http.StatusConflict, "API extension %q is already registered", extension)
+		}
+
+		added = append(added, extension)
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	registry := make(extensions.Extensions, len(d.Extensions))
+	copy(registry, d.Extensions)
+
+	err := registry.Register(added)
+	if err != nil {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Failed to register API extensions: %v", err)
+	}
+
+	runtimeExtensions, err := loadRuntimeExtensions(d.os.RuntimeExtensionsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	reverter := revert.New()
+	defer reverter.Fail()
+
+	err = saveRuntimeExtensions(d.os.RuntimeExtensionsPath(), append(runtimeExtensions, added...))
+	if err != nil {
+		return nil, err
+	}
+
+	// If recording the extensions in the database fails, restore the previous set of runtime extensions so that
+	// they aren't registered again on restart.
+	reverter.Add(func() {
+		err := saveRuntimeExtensions(d.os.RuntimeExtensionsPath(), runtimeExtensions)
+		if err != nil {
+			logger.Error("Failed to restore runtime API extensions", logger.Ctx{"error": err})
+		}
+	})
+
+	if d.db.IsOpen() {
+		err = d.db.UpdateAPIExtensions(ctx, registry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.Extensions = registry
+	reverter.Success()
+
+	logger.Info("Registered API extensions", logger.Ctx{"extensions": added})
+
+	return added, nil
+}
+
+// newExtensionRegistry returns the registry of the internal API extensions, followed by the given ones, and then those
+// registered at runtime before the restart that aren't among them, as recorded at the given path.
+func newExtensionRegistry(apiExtensions []string, runtimeExtensionsPath string) (extensions.Extensions, error) {
+	registry, err := extensions.NewExtensionRegistry(true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = registry.Register(apiExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeExtensions, err := loadRuntimeExtensions(runtimeExtensionsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extension := range runtimeExtensions {
+		if registry.HasExtension(extension) {
+			continue
+		}
+
+		err = registry.Register([]string{extension})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to register API extension %q registered before the restart: %w", extension, err)
+		}
+	}
+
+	return registry, nil
+}
+
+// runtimeExtensionsFile is the format of the API extensions registered at runtime in the state directory.
+type runtimeExtensionsFile struct {
+	Extensions []string `yaml:"extensions"`
+}
+
+// loadRuntimeExtensions reads the API extensions registered at runtime from the given path, in the order they were
+// registered. It returns nil if none have been registered yet.
+func loadRuntimeExtensions(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read runtime API extensions: %w", err)
+	}
+
+	var file runtimeExtensionsFile
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse runtime API extensions: %w", err)
+	}
+
+	return file.Extensions, nil
+}
+
+// saveRuntimeExtensions writes the API extensions registered at runtime to the given path.
+func saveRuntimeExtensions(path string, names []string) error {
+	data, err := yaml.Marshal(runtimeExtensionsFile{Extensions: names})
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write runtime API extensions: %w", err)
+	}
+
+	return nil
+}
+
 // ClusterCert ensures both the daemon and state have the same cluster cert.
 func (d *Daemon) ClusterCert() *shared.CertInfo {
 	d.clusterMu.RLock()
@@ -685,9 +1479,37 @@ func (d *Daemon) ReloadClusterCert() error {
 
 // ServerCert ensures both the daemon and state have the same server cert.
 func (d *Daemon) ServerCert() *shared.CertInfo {
+	d.serverMu.RLock()
+	defer d.serverMu.RUnlock()
+
 	return d.serverCert
 }
 
+// ReloadServerCert reloads the server keypair from the state directory. Only new connections are affected.
+func (d *Daemon) ReloadServerCert() error {
+	d.serverMu.Lock()
+	defer d.serverMu.Unlock()
+
+	serverCert, err := util.LoadServerCert(d.os.StateDir)
+	if err != nil {
+		return err
+	}
+
+	d.serverCert = serverCert
+
+	return nil
+}
+
+// ClientCert returns the certificate presented when querying the API of other cluster members.
+// It is the server certificate unless a distinct client certificate is configured.
+func (d *Daemon) ClientCert() *shared.CertInfo {
+	if d.options.ClientCertificate != nil {
+		return d.options.ClientCertificate
+	}
+
+	return d.ServerCert()
+}
+
 // Address ensures both the daemon and state have the same address.
 func (d *Daemon) Address() *api.URL {
 	copyURL := d.address
@@ -703,9 +1525,32 @@ func (d *Daemon) Name() string {
 func (d *Daemon) State() *state.State {
 	state.PreRemoveHook = d.hooks.PreRemove
 	state.PostRemoveHook = d.hooks.PostRemove
+	state.OnDrainHook = d.hooks.OnDrain
+	state.OnDrainChangeHook = d.hooks.OnDrainChange
 	state.OnHeartbeatHook = d.hooks.OnHeartbeat
+	state.OnHeartbeatPayloadsHook = d.hooks.OnHeartbeatPayloads
+	state.HeartbeatPayloadHook = d.hooks.HeartbeatPayload
 	state.OnNewMemberHook = d.hooks.OnNewMember
+	state.OnAutoUpdateHook = d.hooks.OnAutoUpdate
+	state.ValidateDaemonConfigHook = d.hooks.ValidateDaemonConfig
 	state.ReloadClusterCert = d.ReloadClusterCert
+	state.ReloadServerCert = d.ReloadServerCert
+	state.NewServerCert = func(name string) ([]byte, []byte, error) {
+		return sys.NewNamedCertificate(name, d.options.CertificateCustomizer)
+	}
+	state.RefreshTrustStore = d.trustStore.Refresh
+	state.UpdateDaemonAddress = func(address types.AddrPort) error {
+		return d.setDaemonConfig(&trust.Location{Name: d.name, Address: address})
+	}
+	state.UpdateDaemonName = func(name string) error {
+		address, err := types.ParseAddrPort(d.address.URL.Host)
+		if err != nil {
+			return err
+		}
+
+		return d.setDaemonConfig(&trust.Location{Name: name, Address: address})
+	}
+	state.SetPreInitAddress = d.setPreInitAddress
 	state.StopListeners = func() error {
 		err := d.fsWatcher.Close()
 		if err != nil {
@@ -723,10 +1568,17 @@ func (d *Daemon) State() *state.State {
 		Name:        d.Name,
 		Endpoints:   d.endpoints,
 		ServerCert:  d.ServerCert,
+		ClientCert:  d.ClientCert,
 		ClusterCert: d.ClusterCert,
 		Database:    d.db,
 		Remotes:     d.trustStore.Remotes,
 		StartAPI:    d.StartAPI,
+		WatchFile:   d.fsWatcher.WatchFile,
+		Events:      d.events,
+		Requests:    d.requests,
+		Drains:      d.drains,
+
+		TrustedClusterCerts: d.trustedCerts,
 		Stop: func() (exit func(), stopErr error) {
 			stopErr = d.stop()
 			exit = func() {
@@ -735,12 +1587,30 @@ func (d *Daemon) State() *state.State {
 
 			return exit, stopErr
 		},
-		Extensions: d.Extensions,
+		Extensions:         d.extensions(),
+		RegisterExtensions: d.RegisterExtensions,
+		Version:            d.options.Version,
+
+		TokenExpirySkew: d.options.TokenExpirySkew,
+		MinVoters:       d.options.MinVoters,
+
+		CertificateExpiry: d.certificateExpiry,
+		OpenAPI:           d.openAPI,
 	}
 
 	return state
 }
 
+// openAPI describes the REST API of the daemon, including the resources of its extension servers.
+func (d *Daemon) openAPI() *internalTypes.OpenAPI {
+	version := d.options.Version
+	if version == "" {
+		version = "1.0"
+	}
+
+	return resources.OpenAPI(d.project, version, d.extensionServers)
+}
+
 // setDaemonConfig sets the daemon's address and name from the given location information. If none is supplied, the file
 // at `state-dir/daemon.yaml` will be read for the information.
 func (d *Daemon) setDaemonConfig(config *trust.Location) error {
@@ -770,5 +1640,34 @@ func (d *Daemon) setDaemonConfig(config *trust.Location) error {
 	d.address = *api.NewURL().Scheme("https").Host(config.Address.String())
 	d.name = config.Name
 
+	if d.options.LogMemberContext {
+		d.setLogContext()
+	}
+
 	return nil
 }
+
+// daemonConfig returns the daemon's current name and address, which are empty until the daemon is initialized.
+func (d *Daemon) daemonConfig() trust.Location {
+	config := trust.Location{Name: d.name}
+	if d.address.URL.Host != "" {
+		address, err := types.ParseAddrPort(d.address.URL.Host)
+		if err == nil {
+			config.Address = address
+		}
+	}
+
+	return config
+}
+
+// setLogContext replaces the global logger with one that adds the member name and address to every log line.
+// The context is always applied on top of the original logger, so calling this again after the daemon configuration
+// changes replaces the previous values. The logger is shared by the whole process, so with several daemons in one
+// process, the daemon that sets its context last tags the log lines of all of them.
+func (d *Daemon) setLogContext() {
+	if d.baseLogger == nil {
+		d.baseLogger = logger.Log
+	}
+
+	logger.Log = d.baseLogger.AddContext(logger.Ctx{"member": d.name, "address": d.address.URL.Host})
+}
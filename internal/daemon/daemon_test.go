@@ -0,0 +1,350 @@
+package daemon
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/config"
+	"github.com/canonical/microcluster/internal/extensions"
+	"github.com/canonical/microcluster/internal/rest/resources"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type daemonSuite struct {
+	suite.Suite
+}
+
+func TestDaemonSuite(t *testing.T) {
+	suite.Run(t, new(daemonSuite))
+}
+
+// Ensures log lines carry the member name and address once the daemon configuration is set.
+func (t *daemonSuite) Test_logMemberContext() {
+	stateDir := t.T().TempDir()
+	logFile := filepath.Join(stateDir, "daemon.log")
+
+	originalLogger := logger.Log
+	defer func() { logger.Log = originalLogger }()
+
+	err := logger.InitLogger(logFile, "", true, false, nil)
+	t.Require().NoError(err)
+
+	addr, err := types.ParseAddrPort("127.0.0.1:9000")
+	t.Require().NoError(err)
+
+	d := NewDaemon("test")
+	d.os = &sys.OS{StateDir: stateDir}
+	d.options = Options{LogMemberContext: true}
+
+	err = d.setDaemonConfig(&trust.Location{Name: "member01", Address: addr})
+	t.Require().NoError(err)
+
+	logger.Info("Sample log line")
+
+	data, err := os.ReadFile(logFile)
+	t.Require().NoError(err)
+	t.Contains(string(data), "Sample log line")
+	t.Contains(string(data), "member01")
+	t.Contains(string(data), "127.0.0.1:9000")
+}
+
+// Ensures the daemon configuration is left untouched when the ValidateDaemonConfig hook rejects a new one.
+func (t *daemonSuite) Test_validateDaemonConfig() {
+	stateDir := t.T().TempDir()
+
+	addr, err := types.ParseAddrPort("127.0.0.1:9000")
+	t.Require().NoError(err)
+
+	var current, proposed trust.Location
+	d := NewDaemon("test")
+	d.os = &sys.OS{StateDir: stateDir}
+	d.shutdownCtx = context.Background()
+	d.applyHooks(&config.Hooks{
+		ValidateDaemonConfig: func(ctx context.Context, s *state.State, currentConfig trust.Location, newConfig trust.Location) error {
+			current, proposed = currentConfig, newConfig
+
+			return fmt.Errorf("Address not allowed")
+		},
+	})
+
+	err = d.StartAPI(context.Background(), true, nil, &trust.Location{Name: "member01", Address: addr})
+	t.ErrorContains(err, "Address not allowed")
+	t.Equal(trust.Location{}, current)
+	t.Equal(trust.Location{Name: "member01", Address: addr}, proposed)
+
+	// The rejected configuration was not applied.
+	t.Empty(d.Name())
+	t.NoFileExists(filepath.Join(stateDir, "daemon.yaml"))
+}
+
+// Ensures extension servers are started by increasing order, keeping the given order for servers with the same one.
+func (t *daemonSuite) Test_orderExtensionServers() {
+	servers := []rest.Server{
+		{Protocol: "a", Order: 1},
+		{Protocol: "b"},
+		{Protocol: "c", Order: -1},
+		{Protocol: "d", Order: 1},
+		{Protocol: "e"},
+	}
+
+	ordered := orderExtensionServers(servers)
+	protocols := make([]string, 0, len(ordered))
+	for _, server := range ordered {
+		protocols = append(protocols, server.Protocol)
+	}
+
+	t.Equal([]string{"c", "b", "e", "a", "d"}, protocols)
+
+	// The given servers are left untouched.
+	t.Equal("a", servers[0].Protocol)
+}
+
+// Ensures a certificate expiring within the warning window runs the OnCertExpiring hook, and one that doesn't is ignored.
+func (t *daemonSuite) Test_checkCertExpiry() {
+	newCert := func(notAfter time.Time) *shared.CertInfo {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		t.Require().NoError(err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "member01"},
+			NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+			NotAfter:     notAfter,
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		t.Require().NoError(err)
+
+		return shared.NewCertInfo(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil, nil)
+	}
+
+	now := time.Now()
+	expiring := map[string]time.Time{}
+	d := NewDaemon("test")
+	d.options = Options{CertExpiryWarning: 7 * 24 * time.Hour}
+	d.serverCert = newCert(now.Add(24 * time.Hour))
+	d.applyHooks(&config.Hooks{
+		OnCertExpiring: func(s *state.State, name string, expiry time.Time) error {
+			expiring[name] = expiry
+
+			return nil
+		},
+	})
+
+	t.Equal([]string{"server"}, d.checkCertExpiry(now))
+	t.WithinDuration(now.Add(24*time.Hour), expiring["server"], time.Second)
+
+	// The expiry date is exposed through the state.
+	t.WithinDuration(now.Add(24*time.Hour), d.State().CertificateExpiry()["server"], time.Second)
+
+	// A certificate outside of the window is not reported.
+	expiring = map[string]time.Time{}
+	d.serverCert = newCert(now.Add(30 * 24 * time.Hour))
+	t.Empty(d.checkCertExpiry(now))
+	t.Empty(expiring)
+
+	// Dedicated certificates of extension servers are checked as well.
+	address, err := types.ParseAddrPort("10.0.0.1:9000")
+	t.Require().NoError(err)
+	d.extensionServers = []rest.Server{{Address: address, Certificate: newCert(now.Add(time.Hour))}, {CoreAPI: true}}
+	t.Equal([]string{"extension:10.0.0.1:9000"}, d.checkCertExpiry(now))
+	t.Contains(expiring, "extension:10.0.0.1:9000")
+	d.extensionServers = nil
+
+	// Negative windows disable the check.
+	d.serverCert = newCert(now.Add(-time.Hour))
+	d.options.CertExpiryWarning = -1
+	expiring = map[string]time.Time{}
+	t.Empty(d.checkCertExpiry(now))
+	t.Empty(expiring)
+}
+
+// Ensures addresses are reported as colliding when they share a port and an address, including through wildcards.
+func (t *daemonSuite) Test_addressesCollide() {
+	tests := []struct {
+		a       string
+		b       string
+		collide bool
+	}{
+		{a: "10.0.0.1:9000", b: "10.0.0.1:9000", collide: true},
+		{a: "10.0.0.1:9000", b: "10.0.0.1:9001", collide: false},
+		{a: "10.0.0.1:9000", b: "10.0.0.2:9000", collide: false},
+		{a: "0.0.0.0:9000", b: "10.0.0.1:9000", collide: true},
+		{a: "10.0.0.1:9000", b: "0.0.0.0:9000", collide: true},
+		{a: "0.0.0.0:9000", b: "[fd00::1]:9000", collide: false},
+		{a: "[::]:9000", b: "10.0.0.1:9000", collide: true},
+		{a: "[::]:9000", b: "[fd00::1]:9000", collide: true},
+		{a: "[::ffff:10.0.0.1]:9000", b: "10.0.0.1:9000", collide: true},
+	}
+
+	for _, test := range tests {
+		a, err := types.ParseAddrPort(test.a)
+		t.Require().NoError(err)
+
+		b, err := types.ParseAddrPort(test.b)
+		t.Require().NoError(err)
+
+		t.Equal(test.collide, addressesCollide(a, b), "%s and %s", test.a, test.b)
+	}
+}
+
+// Ensures waiting for cluster members ends once their number reaches the threshold, on timeout, or on cancellation.
+func (t *daemonSuite) Test_waitForMembers() {
+	members := 0
+	count := func(ctx context.Context) (int, error) {
+		members++
+
+		return members, nil
+	}
+
+	n, err := waitForMembers(context.Background(), 3, time.Minute, time.Millisecond, count)
+	t.NoError(err)
+	t.Equal(3, n)
+
+	// The last number of members is returned once the timeout elapses.
+	n, err = waitForMembers(context.Background(), 3, 10*time.Millisecond, time.Millisecond, func(ctx context.Context) (int, error) { return 1, nil })
+	t.NoError(err)
+	t.Equal(1, n)
+
+	// Errors are retried.
+	failures := 2
+	n, err = waitForMembers(context.Background(), 1, time.Minute, time.Millisecond, func(ctx context.Context) (int, error) {
+		if failures > 0 {
+			failures--
+			return 0, fmt.Errorf("Database is not ready")
+		}
+
+		return 1, nil
+	})
+	t.NoError(err)
+	t.Equal(1, n)
+
+	// Cancelling the context stops waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = waitForMembers(ctx, 3, time.Minute, time.Millisecond, func(ctx context.Context) (int, error) { return 1, nil })
+	t.ErrorIs(err, context.Canceled)
+}
+
+// Ensures requests for "/" and requests that match no endpoint get the default JSON responses, unless their
+// handlers are replaced.
+func (t *daemonSuite) Test_initServerRootHandlers() {
+	d := NewDaemon("test")
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		d.initServer(false).Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := serve("/")
+	t.Equal(http.StatusOK, recorder.Code)
+	t.Equal("application/json", recorder.Header().Get("Content-Type"))
+	t.Contains(recorder.Body.String(), `"/1.0"`)
+
+	recorder = serve("/missing")
+	t.Equal(http.StatusNotFound, recorder.Code)
+	t.Equal("application/json", recorder.Header().Get("Content-Type"))
+
+	d.options.RootHandler = func(s *state.State, r *http.Request) response.Response {
+		return response.ManualResponse(func(w http.ResponseWriter) error {
+			http.Redirect(w, r, "/ui/", http.StatusFound)
+			return nil
+		})
+	}
+
+	d.options.NotFoundHandler = func(s *state.State, r *http.Request) response.Response {
+		return response.NotFound(fmt.Errorf("No page at %q", r.URL.Path))
+	}
+
+	recorder = serve("/")
+	t.Equal(http.StatusFound, recorder.Code)
+	t.Equal("/ui/", recorder.Header().Get("Location"))
+
+	recorder = serve("/missing")
+	t.Equal(http.StatusNotFound, recorder.Code)
+	t.Equal("application/json", recorder.Header().Get("Content-Type"))
+	t.Contains(recorder.Body.String(), "No page at")
+}
+
+// Ensures the debug http listener refuses endpoints that reveal secrets or hold long-lived connections.
+func (t *daemonSuite) Test_debugServerRefusesSecrets() {
+	d := NewDaemon("test")
+	server := d.debugServer()
+
+	for _, path := range []string{"/cluster/1.0/tokens", "/cluster/1.0/changes/members", "/cluster/1.0/events", "/cluster/internal/database", "/cluster/control"} {
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		t.Equal(http.StatusNotFound, recorder.Code, path)
+	}
+
+	paths := []string{}
+	for _, e := range resources.DebugEndpoints.Endpoints {
+		paths = append(paths, e.Path)
+		t.Nil(e.Post.Handler, e.Path)
+		t.Nil(e.Put.Handler, e.Path)
+		t.Nil(e.Patch.Handler, e.Path)
+		t.Nil(e.Delete.Handler, e.Path)
+	}
+
+	t.NotContains(paths, "tokens")
+	t.Contains(paths, "cluster")
+}
+
+// Ensures API extensions can be registered at runtime and are kept across restarts, while duplicate and malformed
+// names are rejected.
+func (t *daemonSuite) Test_registerExtensions() {
+	stateDir := t.T().TempDir()
+
+	d := NewDaemon("test")
+	d.os = &sys.OS{StateDir: stateDir}
+	d.Extensions = extensions.Extensions{"internal:runtime_extension_v1", "ext_a"}
+
+	ctx := context.Background()
+	t.Require().NoError(d.RegisterExtensions(ctx, []string{"ext_b"}))
+	t.Require().NoError(d.RegisterExtensions(ctx, []string{"ext_c"}))
+	t.Equal(extensions.Extensions{"internal:runtime_extension_v1", "ext_a", "ext_b", "ext_c"}, d.extensions())
+
+	for _, names := range [][]string{{"ext_a"}, {"ext_d", "ext_c"}, {"ext_d", "ext_d"}} {
+		err := d.RegisterExtensions(ctx, names)
+		t.True(api.StatusErrorCheck(err, http.StatusConflict), "Extensions %v were not rejected", names)
+	}
+
+	for _, name := range []string{"", "Ext_D", "_ext_d", "internal:ext_d"} {
+		err := d.RegisterExtensions(ctx, []string{name})
+		t.True(api.StatusErrorCheck(err, http.StatusBadRequest), "Extension %q was not rejected", name)
+	}
+
+	t.Equal(extensions.Extensions{"internal:runtime_extension_v1", "ext_a", "ext_b", "ext_c"}, d.extensions())
+
+	// The extensions registered at runtime are registered again after those passed at initialization.
+	runtimeExtensions, err := loadRuntimeExtensions(d.os.RuntimeExtensionsPath())
+	t.Require().NoError(err)
+	t.Equal([]string{"ext_b", "ext_c"}, runtimeExtensions)
+
+	registry, err := newExtensionRegistry([]string{"ext_a", "ext_c"}, d.os.RuntimeExtensionsPath())
+	t.Require().NoError(err)
+	t.Equal(extensions.Extensions{"ext_a", "ext_c", "ext_b"}, registry[len(registry)-3:])
+}
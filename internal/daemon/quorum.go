@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/cluster"
+)
+
+// DefaultQuorumTimeout is how long a bootstrapped daemon waits for the expected number of cluster members before
+// running the OnQuorum hook anyway.
+const DefaultQuorumTimeout = 30 * time.Minute
+
+// quorumCheckInterval is how often the number of cluster members is checked while waiting for quorum.
+const quorumCheckInterval = 5 * time.Second
+
+// waitForMembers checks the number of cluster members with count every interval until it reaches expected, or until
+// the timeout elapses. It returns the last number of cluster members, or the context error if ctx is cancelled first.
+func waitForMembers(ctx context.Context, expected int, timeout time.Duration, interval time.Duration, count func(ctx context.Context) (int, error)) (int, error) {
+	deadline := time.After(timeout)
+	members := 0
+	for {
+		n, err := count(ctx)
+		if err != nil {
+			logger.Warn("Failed to count cluster members", logger.Ctx{"error": err})
+		} else {
+			members = n
+		}
+
+		if members >= expected {
+			return members, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return members, ctx.Err()
+		case <-deadline:
+			return members, nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// countClusterMembers returns the number of cluster members that have finished joining.
+func (d *Daemon) countClusterMembers(ctx context.Context) (int, error) {
+	var members int
+	err := d.db.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		clusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterMember := range clusterMembers {
+			if !clusterMember.Role.IsPending() {
+				members++
+			}
+		}
+
+		return nil
+	})
+
+	return members, err
+}
+
+// waitForQuorum runs the OnQuorum hook once the configured number of cluster members have joined, or once the quorum
+// timeout elapses, unless the daemon shuts down first.
+func (d *Daemon) waitForQuorum() {
+	members, err := waitForMembers(d.shutdownCtx, d.options.QuorumMembers, d.options.QuorumTimeout, quorumCheckInterval, d.countClusterMembers)
+	if err != nil {
+		return
+	}
+
+	if members < d.options.QuorumMembers {
+		logger.Warn("Timed out waiting for cluster members", logger.Ctx{"expected": d.options.QuorumMembers, "members": members})
+	}
+
+	err = d.hooks.OnQuorum(d.State(), members)
+	if err != nil {
+		logger.Error("Failed to run OnQuorum hook", logger.Ctx{"error": err})
+	}
+}
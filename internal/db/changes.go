@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// changeSubscriberBuffer is the number of events buffered for each subscriber before events are dropped.
+const changeSubscriberBuffer = 64
+
+const (
+	// ClusterMembersTable is the table of cluster members. Its changes are recorded as cluster members join, leave, or
+	// change their name, address or certificates, keyed by cluster member name. Heartbeats are not recorded.
+	ClusterMembersTable = "internal_cluster_members"
+
+	// TokenRecordsTable is the table of join tokens. Its changes are recorded as tokens are issued, used or revoked,
+	// keyed by token name.
+	TokenRecordsTable = "internal_token_records"
+)
+
+// coreChangeTables are the tables of the internal schema whose changes are always recorded by the daemon.
+var coreChangeTables = map[string]bool{ClusterMembersTable: true, TokenRecordsTable: true}
+
+// changesKey is the context key used to record changes made within a transaction.
+type changesKey struct{}
+
+// pendingChanges holds the changes recorded within a transaction until it is committed.
+type pendingChanges struct {
+	mu     sync.Mutex
+	events []types.ChangeEvent
+}
+
+// changeFeed fans out committed changes of registered tables to their subscribers.
+type changeFeed struct {
+	mu          sync.Mutex
+	tables      map[string]bool
+	subscribers map[chan types.ChangeEvent]string
+}
+
+// RegisterChangeTables allows the given tables of the schema extensions to be watched for changes, which must be
+// recorded with RecordChange by the transactions writing to them. Changes to tables that are not registered are never
+// recorded, to bound the cost of the change feed. ClusterMembersTable and TokenRecordsTable are always registered.
+func (db *DB) RegisterChangeTables(tables ...string) {
+	db.changes.mu.Lock()
+	defer db.changes.mu.Unlock()
+
+	if db.changes.tables == nil {
+		db.changes.tables = map[string]bool{}
+	}
+
+	for _, table := range tables {
+		db.changes.tables[table] = true
+	}
+}
+
+// RecordChange records a change to a row of a table. It must be called with the context of a running
+// DB.Transaction. The change is sent to the table's watchers only once the transaction is committed.
+func (db *DB) RecordChange(ctx context.Context, table string, action types.ChangeAction, key string) {
+	if !db.changes.registered(table) {
+		return
+	}
+
+	pending, ok := ctx.Value(changesKey{}).(*pendingChanges)
+	if !ok {
+		logger.Warn("Ignoring change recorded outside of a transaction", logger.Ctx{"table": table, "key": key})
+		return
+	}
+
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+
+	pending.events = append(pending.events, types.ChangeEvent{Table: table, Action: action, Key: key})
+}
+
+// WatchTable returns a channel receiving the changes committed to the given table through this cluster member,
+// and a function to stop watching. The table must have been registered with RegisterChangeTables.
+func (db *DB) WatchTable(table string) (<-chan types.ChangeEvent, func(), error) {
+	if !db.changes.registered(table) {
+		return nil, nil, fmt.Errorf("Table %q is not registered for change notifications", table)
+	}
+
+	db.changes.mu.Lock()
+	defer db.changes.mu.Unlock()
+
+	if db.changes.subscribers == nil {
+		db.changes.subscribers = map[chan types.ChangeEvent]string{}
+	}
+
+	ch := make(chan types.ChangeEvent, changeSubscriberBuffer)
+	db.changes.subscribers[ch] = table
+
+	unsubscribe := func() {
+		db.changes.mu.Lock()
+		defer db.changes.mu.Unlock()
+
+		delete(db.changes.subscribers, ch)
+	}
+
+	return ch, unsubscribe, nil
+}
+
+// registered returns whether changes to the given table are recorded.
+func (c *changeFeed) registered(table string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return coreChangeTables[table] || c.tables[table]
+}
+
+// publish sends the given committed changes to the subscribers of each table.
+// Events are dropped for subscribers that are not keeping up.
+func (c *changeFeed) publish(events []types.ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, event := range events {
+		for ch, table := range c.subscribers {
+			if table != event.Table {
+				continue
+			}
+
+			select {
+			case ch <- event:
+			default:
+				logger.Warn("Dropping change event for slow watcher", logger.Ctx{"table": event.Table, "key": event.Key})
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// DefaultMaxConnections is the default limit of concurrent inbound dqlite connections from all peers.
+const DefaultMaxConnections = 1024
+
+// DefaultMaxConnectionsPerPeer is the default limit of concurrent inbound dqlite connections from a single peer.
+const DefaultMaxConnectionsPerPeer = 256
+
+// connectionLimiter bounds the number of concurrent inbound dqlite connections, in total and per peer.
+type connectionLimiter struct {
+	mu sync.Mutex
+
+	maxTotal   int // Disabled if zero.
+	maxPerPeer int // Disabled if zero.
+
+	total   int
+	perPeer map[string]int
+}
+
+// SetConnectionLimits sets the maximum number of concurrent inbound dqlite connections, in total and from a single
+// peer. A zero limit is disabled.
+func (db *DB) SetConnectionLimits(total int, perPeer int) {
+	db.connections.mu.Lock()
+	defer db.connections.mu.Unlock()
+
+	db.connections.maxTotal = total
+	db.connections.maxPerPeer = perPeer
+}
+
+// ReserveConnection claims a slot for an inbound dqlite connection from the given peer, before the connection is
+// upgraded. It fails with a 429 status error if either limit is reached. The returned function releases the slot,
+// and is passed to Accept along with the upgraded connection, or called directly if the upgrade fails.
+func (db *DB) ReserveConnection(peer string) (release func(), err error) {
+	l := &db.connections
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		return nil, api.StatusErrorf(http.StatusTooManyRequests, "Too many dqlite connections (limit %d)", l.maxTotal)
+	}
+
+	if l.maxPerPeer > 0 && l.perPeer[peer] >= l.maxPerPeer {
+		return nil, api.StatusErrorf(http.StatusTooManyRequests, "Too many dqlite connections from %q (limit %d)", peer, l.maxPerPeer)
+	}
+
+	if l.perPeer == nil {
+		l.perPeer = map[string]int{}
+	}
+
+	l.total++
+	l.perPeer[peer]++
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.total--
+			l.perPeer[peer]--
+			if l.perPeer[peer] <= 0 {
+				delete(l.perPeer, peer)
+			}
+		})
+	}
+
+	return release, nil
+}
+
+// releasingConn releases its connection slot once it is closed.
+type releasingConn struct {
+	net.Conn
+
+	release func()
+}
+
+// Close closes the connection and releases its slot.
+func (c *releasingConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+
+	return err
+}
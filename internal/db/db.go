@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/canonical/go-dqlite/driver"
 	"github.com/canonical/lxd/lxd/db/query"
 	"github.com/canonical/lxd/lxd/db/schema"
 	"github.com/canonical/lxd/shared"
@@ -17,6 +19,7 @@ import (
 	"github.com/canonical/microcluster/cluster"
 	"github.com/canonical/microcluster/internal/extensions"
 	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/rest/types"
 )
 
 // Open opens the dqlite database and loads the schema.
@@ -81,35 +84,12 @@ func (db *DB) waitUpgrade(bootstrap bool, ext extensions.Extensions) error {
 		return nodeIsBehind, nil
 	}
 
-	checkAPIExtensions := func(currentAPIExtensions extensions.Extensions, clusterMemberAPIExtensions []extensions.Extensions) (otherNodesBehind bool, err error) {
-		logger.Debugf("Local API extensions: %v, cluster members API extensions: %v", currentAPIExtensions, clusterMemberAPIExtensions)
-
-		nodeIsBehind := false
-		for _, extensions := range clusterMemberAPIExtensions {
-			if currentAPIExtensions.IsSameVersion(extensions) == nil {
-				// API extensions are equal, there's hope for the
-				// update. Let's check the next node.
-				continue
-			} else if extensions == nil || currentAPIExtensions.Version() > extensions.Version() {
-				// Our version is bigger, we should stop here
-				// and wait for other nodes to be upgraded and
-				// restarted.
-				nodeIsBehind = true
-				continue
-			} else {
-				// Another node has a version greater than ours
-				// and presumeably is waiting for other nodes
-				// to upgrade. Let's error out and shutdown
-				// since we need a greater version.
-				return false, fmt.Errorf("This node's API extensions are behind, please upgrade")
-			}
-		}
-
-		return nodeIsBehind, nil
-	}
-
 	otherNodesBehind := false
 	newSchema := db.Schema()
+	if db.os != nil {
+		newSchema.File(filepath.Join(db.os.DatabaseDir, "patch.global.sql"))
+	}
+
 	if !bootstrap {
 		checkVersions := func(ctx context.Context, current int, tx *sql.Tx) error {
 			schemaVersionInternal, schemaVersionExternal := newSchema.Version()
@@ -147,7 +127,7 @@ func (db *DB) waitUpgrade(bootstrap bool, ext extensions.Extensions) error {
 		newSchema.Check(checkVersions)
 	}
 
-	err := db.retry(context.TODO(), func(_ context.Context) error {
+	err := db.retry(db.ctx, func(ctx context.Context) error {
 		_, err := newSchema.Ensure(db.db)
 		if err != nil {
 			return err
@@ -156,7 +136,7 @@ func (db *DB) waitUpgrade(bootstrap bool, ext extensions.Extensions) error {
 		if !bootstrap {
 			otherNodesBehindAPI := false
 			// Perform the API extensions check.
-			err = query.Transaction(context.TODO(), db.db, func(ctx context.Context, tx *sql.Tx) error {
+			err = query.Transaction(ctx, db.db, func(ctx context.Context, tx *sql.Tx) error {
 				err := cluster.UpdateClusterMemberAPIExtensions(tx, ext, db.listenAddr.URL.Host)
 				if err != nil {
 					return fmt.Errorf("Failed to update API extensions when joining cluster: %w", err)
@@ -188,31 +168,204 @@ func (db *DB) waitUpgrade(bootstrap bool, ext extensions.Extensions) error {
 	})
 
 	// If we are not bootstrapping, wait for an upgrade notification, or wait a minute before checking again.
+	// Stop waiting if the daemon is shutting down.
 	if otherNodesBehind && !bootstrap {
 		logger.Warn("Waiting for other cluster members to upgrade their versions", logger.Ctx{"address": db.listenAddr.String()})
 		select {
 		case <-db.upgradeCh:
 		case <-time.After(30 * time.Second):
+		case <-db.ctx.Done():
+			return fmt.Errorf("Stopped waiting for other cluster members to upgrade: %w", db.ctx.Err())
 		}
 	}
 
 	return err
 }
 
+// UpdateAPIExtensions records the given API extensions for the local cluster member, for example after more
+// extensions were registered at runtime, and compares them with the API extensions of the other cluster members.
+// Unlike at startup, a mismatch is only logged, as members that are behind catch up once they are upgraded.
+func (db *DB) UpdateAPIExtensions(ctx context.Context, ext extensions.Extensions) error {
+	var clusterMembersAPIExtensions []extensions.Extensions
+	err := db.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.UpdateClusterMemberAPIExtensions(tx, ext, db.listenAddr.URL.Host)
+		if err != nil {
+			return fmt.Errorf("Failed to update API extensions: %w", err)
+		}
+
+		clusterMembersAPIExtensions, err = cluster.GetClusterMemberAPIExtensions(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("Failed to get other members' API extensions: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	otherNodesBehind, err := checkAPIExtensions(ext, clusterMembersAPIExtensions)
+	if err != nil {
+		logger.Warn("API extensions of the cluster members have not converged", logger.Ctx{"address": db.listenAddr.String(), "error": err})
+	} else if otherNodesBehind {
+		logger.Warn("Waiting for other cluster members to upgrade their API extensions", logger.Ctx{"address": db.listenAddr.String()})
+	}
+
+	return nil
+}
+
+// checkAPIExtensions compares the local API extensions with those of every cluster member. It returns an error if any
+// cluster member is ahead, and reports whether any of them are behind.
+func checkAPIExtensions(currentAPIExtensions extensions.Extensions, clusterMemberAPIExtensions []extensions.Extensions) (otherNodesBehind bool, err error) {
+	logger.Debugf("Local API extensions: %v, cluster members API extensions: %v", currentAPIExtensions, clusterMemberAPIExtensions)
+
+	nodeIsBehind := false
+	for _, extensions := range clusterMemberAPIExtensions {
+		if currentAPIExtensions.IsSameVersion(extensions) == nil {
+			// API extensions are equal, there's hope for the
+			// update. Let's check the next node.
+			continue
+		} else if extensions == nil || currentAPIExtensions.Version() > extensions.Version() {
+			// Our version is bigger, we should stop here
+			// and wait for other nodes to be upgraded and
+			// restarted.
+			nodeIsBehind = true
+			continue
+		} else {
+			// Another node has a version greater than ours
+			// and presumeably is waiting for other nodes
+			// to upgrade. Let's error out and shutdown
+			// since we need a greater version.
+			return false, fmt.Errorf("This node's API extensions are behind, please upgrade")
+		}
+	}
+
+	return nodeIsBehind, nil
+}
+
 // Transaction handles performing a transaction on the dqlite database.
+// On a read-only cluster member, transactions that modify any rows are rolled back with types.ErrReadOnlyMember.
 func (db *DB) Transaction(outerCtx context.Context, f func(context.Context, *sql.Tx) error) error {
-	return db.retry(outerCtx, func(ctx context.Context) error {
-		err := query.Transaction(ctx, db.db, f)
+	return db.TransactionNamed(outerCtx, "", f)
+}
+
+// TransactionNamed is like Transaction, but attributes the transaction to the given operation name if it is logged
+// as slow.
+func (db *DB) TransactionNamed(outerCtx context.Context, name string, f func(context.Context, *sql.Tx) error) error {
+	return db.transaction(outerCtx, name, db.IsReadOnly(), f)
+}
+
+// InternalTransaction is like Transaction, but may modify the database on read-only cluster members. It is used for
+// the records that every cluster member maintains to take part in the cluster, such as its cluster member record,
+// join tokens and heartbeats.
+func (db *DB) InternalTransaction(outerCtx context.Context, f func(context.Context, *sql.Tx) error) error {
+	return db.transaction(outerCtx, "", false, f)
+}
+
+// InternalTransactionNamed is like InternalTransaction, but attributes the transaction to the given operation name if
+// it is logged as slow.
+func (db *DB) InternalTransactionNamed(outerCtx context.Context, name string, f func(context.Context, *sql.Tx) error) error {
+	return db.transaction(outerCtx, name, false, f)
+}
+
+func (db *DB) transaction(outerCtx context.Context, name string, readOnly bool, f func(context.Context, *sql.Tx) error) error {
+	start := time.Now()
+	attempts := 0
+
+	if readOnly {
+		f = readOnlyTransaction(f)
+	}
+
+	// Changes recorded by f are only kept for the last attempt, and are published once it is committed.
+	var pending *pendingChanges
+	transaction := func(ctx context.Context) error {
+		attempts++
+		pending = &pendingChanges{}
+		return query.Transaction(context.WithValue(ctx, changesKey{}, pending), db.db, f)
+	}
+
+	err := db.retry(outerCtx, func(ctx context.Context) error {
+		err := transaction(ctx)
 		if errors.Is(err, context.DeadlineExceeded) {
 			// If the query timed out it likely means that the leader has abruptly become unreachable.
 			// Now that this query has been cancelled, a leader election should have taken place by now.
 			// So let's retry the transaction once more in case the global database is now available again.
 			logger.Warn("Transaction timed out. Retrying once", logger.Ctx{"err": err})
-			return query.Transaction(ctx, db.db, f)
+			return transaction(ctx)
+		}
+
+		return err
+	})
+
+	if err == nil {
+		db.changes.publish(pending.events)
+	}
+
+	// Track whether the database could be reached, so that endpoints depending on it can fail early.
+	if err == nil {
+		db.SetOffline(false)
+	} else if leaderLost(err) {
+		db.SetOffline(true)
+	}
+
+	elapsed := time.Since(start)
+	if db.slowTransactionThreshold > 0 && elapsed >= db.slowTransactionThreshold {
+		logger.Warn("Slow database transaction", logger.Ctx{"name": name, "elapsed": elapsed, "attempts": attempts, "retried": attempts > 1, "err": err})
+	}
+
+	return err
+}
+
+// leaderLost returns whether a transaction failed because dqlite has no leader. Errors of the caller's context, such
+// as a deadline it set itself, don't count, as they say nothing about whether the database can be reached.
+func leaderLost(err error) bool {
+	return errors.Is(err, driver.ErrNoAvailableLeader)
+}
+
+// readOnlyTransaction wraps f so that it fails with types.ErrReadOnlyMember if it modified any rows, which rolls back
+// the transaction. Changes are counted by the connection of the transaction, so statements that don't modify rows,
+// such as schema changes, aren't detected.
+func readOnlyTransaction(f func(context.Context, *sql.Tx) error) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		var before, after int64
+		err := tx.QueryRowContext(ctx, "SELECT total_changes()").Scan(&before)
+		if err != nil {
+			return err
+		}
+
+		err = f(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT total_changes()").Scan(&after)
+		if err != nil {
+			return err
+		}
+
+		if after != before {
+			return types.ErrReadOnlyMember
 		}
 
+		return nil
+	}
+}
+
+// Exec runs a single statement in its own transaction and returns its result, with the same retry behaviour as
+// Transaction. It is meant for one-off statements, such as in a PostBootstrap hook. Use Transaction for larger
+// batches, so that they are applied atomically and not retried one statement at a time.
+func (db *DB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := db.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		result, err = tx.ExecContext(ctx, query, args...)
 		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (db *DB) retry(ctx context.Context, f func(context.Context) error) error {
@@ -223,25 +376,41 @@ func (db *DB) retry(ctx context.Context, f func(context.Context) error) error {
 	return query.Retry(ctx, f)
 }
 
-// Update attempts to update the database with the executable at the path specified by the SCHEMA_UPDATE variable.
-func (db *DB) Update() error {
+// autoUpdateJitter returns how long to wait before triggering an auto-update, up to the given maximum.
+var autoUpdateJitter = func(max time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// Update attempts to update the database. If `onUpdate` is non-nil, it is called to perform the update in-process.
+// Otherwise, the executable at the path specified by the SCHEMA_UPDATE variable is run.
+func (db *DB) Update(ctx context.Context, onUpdate func(ctx context.Context) error) error {
 	if !db.IsOpen() {
 		return fmt.Errorf("Failed to update, database is not yet open")
 	}
 
 	updateExec := os.Getenv(sys.SchemaUpdate)
-	if updateExec == "" {
-		logger.Warn("No SCHEMA_UPDATE variable set, skipping auto-update")
+	if onUpdate == nil && updateExec == "" {
+		logger.Warn("No auto-update hook or SCHEMA_UPDATE variable set, skipping auto-update")
 		return nil
 	}
 
 	// Wait a random amount of seconds (up to 30) to space out the update.
-	wait := time.Duration(rand.Intn(30)) * time.Second
+	wait := autoUpdateJitter(30 * time.Second)
 	logger.Info("Triggering cluster auto-update soon", logger.Ctx{"wait": wait, "updateExecutable": updateExec})
-	time.Sleep(wait)
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 
 	logger.Info("Triggering cluster auto-update now")
-	_, err := shared.RunCommand(updateExec)
+	var err error
+	if onUpdate != nil {
+		err = onUpdate(ctx)
+	} else {
+		_, err = shared.RunCommandContext(ctx, updateExec)
+	}
+
 	if err != nil {
 		logger.Error("Triggering cluster update failed", logger.Ctx{"err": err})
 		return err
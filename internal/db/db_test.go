@@ -5,17 +5,30 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	dqlite "github.com/canonical/go-dqlite/app"
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/driver"
 	"github.com/canonical/lxd/lxd/db/query"
 	"github.com/canonical/lxd/lxd/db/schema"
 	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/cancel"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v2"
 
 	"github.com/canonical/microcluster/cluster"
 	"github.com/canonical/microcluster/internal/db/update"
 	"github.com/canonical/microcluster/internal/extensions"
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/sys"
+	apiTypes "github.com/canonical/microcluster/rest/types"
 )
 
 type dbSuite struct {
@@ -647,6 +660,845 @@ func (s *dbSuite) Test_waitUpgradeSchemaAndAPI() {
 	}
 }
 
+// Ensures waitUpgrade stops waiting for other cluster members to upgrade once the daemon context is cancelled.
+func (s *dbSuite) Test_waitUpgradeCancel() {
+	db, err := NewTestDB([]schema.Update{})
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db.ctx = ctx
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	s.Require().NoError(err)
+
+	// The other cluster member is behind on API extensions, so the local member waits for it to upgrade.
+	for i, ext := range []extensions.Extensions{{"internal:a", "ext", "ext2"}, {"internal:a", "ext"}} {
+		_, err = cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+			Name:           fmt.Sprintf("cluster-member-%d", i),
+			Address:        fmt.Sprintf("10.0.0.%d:8443", i),
+			Certificate:    fmt.Sprintf("test-cert-%d", i),
+			SchemaInternal: 1,
+			SchemaExternal: 1,
+			APIExtensions:  ext,
+			Role:           "voter",
+		})
+		s.Require().NoError(err)
+	}
+
+	s.Require().NoError(tx.Commit())
+
+	_, err = db.db.Exec("delete from schemas")
+	s.Require().NoError(err)
+
+	stmt := `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`
+	manager := &update.SchemaUpdateManager{}
+	for _, schemaType := range []int{0, 1} {
+		_, err = db.db.Exec(stmt, 0, schemaType)
+		s.Require().NoError(err)
+	}
+
+	manager.SetInternalUpdates([]schema.Update{func(ctx context.Context, tx *sql.Tx) error { return nil }})
+	manager.SetExternalUpdates([]schema.Update{func(ctx context.Context, tx *sql.Tx) error { return nil }})
+	db.schema = manager.Schema()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = db.waitUpgrade(false, extensions.Extensions{"internal:a", "ext", "ext2"})
+	s.ErrorIs(err, context.Canceled)
+	s.Less(time.Since(start), 5*time.Second)
+}
+
+// Ensures a member waiting for other cluster members to upgrade checks their versions again as soon as it is
+// notified, and that the notification is a no-op when the member isn't waiting.
+func (s *dbSuite) Test_waitUpgradeRecheck() {
+	db, err := NewTestDB([]schema.Update{})
+	s.Require().NoError(err)
+
+	// Use an unbuffered channel as the daemon does, so that notifications only reach a waiting member.
+	db.upgradeCh = make(chan struct{})
+	s.False(db.NotifyUpgraded())
+
+	ctx := context.Background()
+	tx, err := db.db.BeginTx(ctx, nil)
+	s.Require().NoError(err)
+
+	// The other cluster member is behind on API extensions, so the local member waits for it to upgrade.
+	for i, ext := range []extensions.Extensions{{"internal:a", "ext", "ext2"}, {"internal:a", "ext"}} {
+		_, err = cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+			Name:           fmt.Sprintf("cluster-member-%d", i),
+			Address:        fmt.Sprintf("10.0.0.%d:8443", i),
+			Certificate:    fmt.Sprintf("test-cert-%d", i),
+			SchemaInternal: 1,
+			SchemaExternal: 1,
+			APIExtensions:  ext,
+			Role:           "voter",
+		})
+		s.Require().NoError(err)
+	}
+
+	s.Require().NoError(tx.Commit())
+
+	_, err = db.db.Exec("delete from schemas")
+	s.Require().NoError(err)
+
+	stmt := `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`
+	manager := &update.SchemaUpdateManager{}
+	for _, schemaType := range []int{0, 1} {
+		_, err = db.db.Exec(stmt, 0, schemaType)
+		s.Require().NoError(err)
+	}
+
+	manager.SetInternalUpdates([]schema.Update{func(ctx context.Context, tx *sql.Tx) error { return nil }})
+	manager.SetExternalUpdates([]schema.Update{func(ctx context.Context, tx *sql.Tx) error { return nil }})
+	db.schema = manager.Schema()
+
+	done := make(chan error)
+	go func() {
+		done <- db.waitUpgrade(false, extensions.Extensions{"internal:a", "ext", "ext2"})
+	}()
+
+	// Keep notifying until the member is waiting, well before its periodic check would run.
+	s.Eventually(db.NotifyUpgraded, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-done:
+		s.ErrorIs(err, schema.ErrGracefulAbort)
+	case <-time.After(5 * time.Second):
+		s.Fail("Member kept waiting after being notified")
+	}
+
+	s.False(db.NotifyUpgraded())
+}
+
+// Ensures Update waits for the jitter and invokes the auto-update callback instead of the SCHEMA_UPDATE executable.
+func (s *dbSuite) Test_updateCallback() {
+	s.T().Setenv(sys.SchemaUpdate, "/bin/false")
+
+	originalJitter := autoUpdateJitter
+	defer func() { autoUpdateJitter = originalJitter }()
+
+	var maxJitter time.Duration
+	autoUpdateJitter = func(max time.Duration) time.Duration {
+		maxJitter = max
+		return 0
+	}
+
+	db := &DB{openCanceller: cancel.New(context.Background())}
+
+	called := false
+	onUpdate := func(ctx context.Context) error {
+		called = true
+		return nil
+	}
+
+	// The database is not open yet, so the update should not happen.
+	err := db.Update(context.Background(), onUpdate)
+	s.Error(err)
+	s.False(called)
+
+	db.openCanceller.Cancel()
+
+	err = db.Update(context.Background(), onUpdate)
+	s.NoError(err)
+	s.True(called)
+	s.Equal(30*time.Second, maxJitter)
+
+	// Errors from the callback are returned.
+	err = db.Update(context.Background(), func(ctx context.Context) error { return fmt.Errorf("Update failed") })
+	s.EqualError(err, "Update failed")
+}
+
+// Ensures committed writes to a watched table produce change events, and that rolled back or unregistered writes don't.
+func (s *dbSuite) Test_changeFeed() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	// Tables of the internal schema are registered by default, others must be registered to be watched.
+	_, _, err = db.WatchTable("test")
+	s.Error(err)
+
+	db.RegisterChangeTables("test")
+	testEvents, unsubscribeTest, err := db.WatchTable("test")
+	s.Require().NoError(err)
+	unsubscribeTest()
+
+	events, unsubscribe, err := db.WatchTable(TokenRecordsTable)
+	s.Require().NoError(err)
+	defer unsubscribe()
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.CreateInternalTokenRecord(ctx, tx, cluster.InternalTokenRecord{Name: "n1", Secret: "secret"})
+		if err != nil {
+			return err
+		}
+
+		db.RecordChange(ctx, TokenRecordsTable, apiTypes.ChangeCreate, "n1")
+		db.RecordChange(ctx, "test", apiTypes.ChangeCreate, "n1")
+		db.RecordChange(ctx, "unregistered", apiTypes.ChangeCreate, "n1")
+
+		return nil
+	})
+	s.Require().NoError(err)
+
+	select {
+	case event := <-events:
+		s.Equal(apiTypes.ChangeEvent{Table: TokenRecordsTable, Action: apiTypes.ChangeCreate, Key: "n1"}, event)
+	case <-time.After(time.Second):
+		s.Fail("Expected a change event")
+	}
+
+	s.Empty(events)
+	s.Empty(testEvents)
+
+	// Changes of a failed transaction are not sent.
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		db.RecordChange(ctx, TokenRecordsTable, apiTypes.ChangeDelete, "n1")
+
+		return fmt.Errorf("Rolled back")
+	})
+	s.Error(err)
+	s.Empty(events)
+}
+
+// Ensures the core_config table created by the internal schema can be written and read back.
+func (s *dbSuite) Test_coreConfig() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.CreateCoreConfig(ctx, tx, cluster.CoreConfig{Key: "k1", Value: "v1"})
+		if err != nil {
+			return err
+		}
+
+		_, err = cluster.CreateCoreConfig(ctx, tx, cluster.CoreConfig{Key: "k1", Value: "v2"})
+		s.Error(err)
+
+		err = cluster.UpdateCoreConfig(ctx, tx, "k1", cluster.CoreConfig{Key: "k1", Value: "v2"})
+		if err != nil {
+			return err
+		}
+
+		config, err := cluster.GetCoreConfig(ctx, tx, "k1")
+		if err != nil {
+			return err
+		}
+
+		s.Equal("v2", config.Value)
+
+		err = cluster.DeleteCoreConfig(ctx, tx, "k1")
+		if err != nil {
+			return err
+		}
+
+		configs, err := cluster.GetCoreConfigs(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		s.Empty(configs)
+
+		return nil
+	})
+	s.NoError(err)
+}
+
+// Ensures cluster member annotations are replaced as a whole, except for the reserved maintenance mode key, and follow
+// the cluster member when it is renamed.
+func (s *dbSuite) Test_memberConfig() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+			Name:        "member01",
+			Address:     "10.0.0.1:8443",
+			Certificate: "test-cert-1",
+			Role:        cluster.Pending,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{"rack": "r1", "zone": "z1"})
+		if err != nil {
+			return err
+		}
+
+		err = cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{"zone": "z2"})
+		if err != nil {
+			return err
+		}
+
+		config, err := cluster.GetMemberConfig(ctx, tx, "member01")
+		if err != nil {
+			return err
+		}
+
+		s.Equal(map[string]string{"zone": "z2"}, config)
+
+		// Maintenance mode is kept by annotations, and can only be changed on its own.
+		err = cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{cluster.MaintenanceKey: "true"})
+		s.Error(err)
+
+		err = cluster.SetMemberMaintenance(ctx, tx, "member01", true)
+		if err != nil {
+			return err
+		}
+
+		err = cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{"zone": "z2"})
+		if err != nil {
+			return err
+		}
+
+		config, err = cluster.GetMemberConfig(ctx, tx, "member01")
+		if err != nil {
+			return err
+		}
+
+		s.Equal(map[string]string{"zone": "z2", cluster.MaintenanceKey: "true"}, config)
+
+		err = cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{"zone": "z2", cluster.MaintenanceKey: ""})
+		s.Error(err)
+
+		err = cluster.SetMemberMaintenance(ctx, tx, "member01", false)
+		if err != nil {
+			return err
+		}
+
+		// Annotations can't be set for unknown cluster members.
+		err = cluster.SetMemberConfig(ctx, tx, "member02", map[string]string{"zone": "z1"})
+		s.Error(err)
+
+		err = cluster.RenameMemberConfig(ctx, tx, "member01", "member02")
+		if err != nil {
+			return err
+		}
+
+		configs, err := cluster.GetMembersConfig(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		s.Equal(map[string]map[string]string{"member02": {"zone": "z2"}}, configs)
+
+		return nil
+	})
+	s.NoError(err)
+}
+
+// Ensures a cluster member is renamed along with its configuration, unless the new name is taken or the member is
+// soft-deleted or draining.
+func (s *dbSuite) Test_renameInternalClusterMember() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		for i, name := range []string{"member01", "member02", "member03", "member04"} {
+			_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+				Name:        name,
+				Address:     fmt.Sprintf("10.0.0.%d:8443", i+1),
+				Certificate: fmt.Sprintf("test-cert-%d", i+1),
+				Role:        cluster.Role("voter"),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := cluster.SetMemberConfig(ctx, tx, "member01", map[string]string{"zone": "z1"})
+		if err != nil {
+			return err
+		}
+
+		err = cluster.CreateInternalClusterMemberRemoval(ctx, tx, cluster.InternalClusterMemberRemoval{Name: "member03", Role: cluster.Role("voter"), ExpiresAt: time.Now().Add(time.Hour)})
+		if err != nil {
+			return err
+		}
+
+		err = cluster.CreateInternalClusterMemberDrain(ctx, tx, cluster.InternalClusterMemberDrain{Name: "member04", StartedAt: time.Now()})
+		if err != nil {
+			return err
+		}
+
+		err = cluster.RenameInternalClusterMember(ctx, tx, "member01", "member02")
+		s.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+		err = cluster.RenameInternalClusterMember(ctx, tx, "member03", "member05")
+		s.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+		err = cluster.RenameInternalClusterMember(ctx, tx, "member04", "member05")
+		s.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+		err = cluster.RenameInternalClusterMember(ctx, tx, "member06", "member05")
+		s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+		err = cluster.RenameInternalClusterMember(ctx, tx, "member01", "member05")
+		if err != nil {
+			return err
+		}
+
+		_, err = cluster.GetInternalClusterMember(ctx, tx, "member01")
+		s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+		member, err := cluster.GetInternalClusterMember(ctx, tx, "member05")
+		if err != nil {
+			return err
+		}
+
+		s.Equal("10.0.0.1:8443", member.Address)
+
+		config, err := cluster.GetMemberConfig(ctx, tx, "member05")
+		if err != nil {
+			return err
+		}
+
+		s.Equal(map[string]string{"zone": "z1"}, config)
+
+		return nil
+	})
+	s.NoError(err)
+}
+
+// Ensures the heartbeat offset stays within the configured fraction of the interval.
+func (s *dbSuite) Test_heartbeatJitter() {
+	db := &DB{}
+	for i := 0; i < 100; i++ {
+		db.SetHeartbeatJitter(DefaultHeartbeatJitter)
+		s.LessOrEqual(db.heartbeatOffset, time.Second)
+		s.GreaterOrEqual(db.heartbeatOffset, -time.Second)
+	}
+
+	db.SetHeartbeatJitter(0)
+	s.Equal(time.Duration(0), db.heartbeatOffset)
+}
+
+// Ensures API extensions registered at runtime are recorded for the local cluster member, even if other members differ.
+func (s *dbSuite) Test_updateAPIExtensions() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		for i, ext := range []extensions.Extensions{{"internal:a"}, {"internal:a", "ext", "ext2"}} {
+			_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+				Name:          fmt.Sprintf("cluster-member-%d", i),
+				Address:       fmt.Sprintf("10.0.0.%d:8443", i),
+				Certificate:   fmt.Sprintf("test-cert-%d", i),
+				APIExtensions: ext,
+				Role:          "voter",
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	s.Require().NoError(err)
+
+	// The other member is still ahead, which is only logged.
+	s.NoError(db.UpdateAPIExtensions(context.Background(), extensions.Extensions{"internal:a", "ext"}))
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		member, err := cluster.GetInternalClusterMember(ctx, tx, "cluster-member-0")
+		if err != nil {
+			return err
+		}
+
+		s.Equal(extensions.Extensions{"internal:a", "ext"}, member.APIExtensions)
+
+		return nil
+	})
+	s.NoError(err)
+
+	// Members that are behind or ahead are reported as such.
+	behind, err := checkAPIExtensions(extensions.Extensions{"internal:a", "ext", "ext2"}, []extensions.Extensions{{"internal:a", "ext"}})
+	s.NoError(err)
+	s.True(behind)
+
+	_, err = checkAPIExtensions(extensions.Extensions{"internal:a"}, []extensions.Extensions{{"internal:a", "ext"}})
+	s.Error(err)
+}
+
+// Ensures single statements run through Exec are committed, and failing ones return an error.
+func (s *dbSuite) Test_exec() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	_, err = db.Exec(context.Background(), "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	s.Require().NoError(err)
+
+	result, err := db.Exec(context.Background(), "INSERT INTO test (name) VALUES (?), (?)", "a", "b")
+	s.Require().NoError(err)
+
+	n, err := result.RowsAffected()
+	s.NoError(err)
+	s.Equal(int64(2), n)
+
+	_, err = db.Exec(context.Background(), "INSERT INTO test (name) VALUES (NULL)")
+	s.Error(err)
+}
+
+// Ensures read-only members reject transactions that modify rows, while reads and internal transactions succeed.
+func (s *dbSuite) Test_readOnly() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	_, err = db.Exec(context.Background(), "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	s.Require().NoError(err)
+
+	db.SetReadOnly(true)
+	s.True(db.IsReadOnly())
+
+	_, err = db.Exec(context.Background(), "INSERT INTO test (name) VALUES (?)", "a")
+	s.ErrorIs(err, apiTypes.ErrReadOnlyMember)
+
+	err = db.InternalTransaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO test (name) VALUES (?)", "b")
+		return err
+	})
+	s.Require().NoError(err)
+
+	// The rejected insert was rolled back.
+	var names []string
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		names, err = query.SelectStrings(ctx, tx, "SELECT name FROM test")
+		return err
+	})
+	s.NoError(err)
+	s.Equal([]string{"b"}, names)
+
+	db.SetReadOnly(false)
+	_, err = db.Exec(context.Background(), "INSERT INTO test (name) VALUES (?)", "c")
+	s.NoError(err)
+}
+
+// Ensures only the loss of the dqlite leader marks the database offline, and that the next successful transaction
+// marks it back online.
+func (s *dbSuite) Test_offline() {
+	s.True(leaderLost(fmt.Errorf("Failed to begin transaction: %w", driver.ErrNoAvailableLeader)))
+	s.False(leaderLost(context.DeadlineExceeded))
+	s.False(leaderLost(context.Canceled))
+
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	err = db.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error { return nil })
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.False(db.IsOffline())
+
+	db.SetOffline(true)
+	s.True(db.IsOffline())
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error { return nil })
+	s.Require().NoError(err)
+	s.False(db.IsOffline())
+}
+
+// Ensures draining members are read-only until the drain is cancelled, and that drain records can be tracked.
+func (s *dbSuite) Test_draining() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	_, err = db.Exec(context.Background(), "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	s.Require().NoError(err)
+
+	db.SetDraining(true)
+	s.True(db.IsDraining())
+	s.True(db.IsReadOnly())
+
+	_, err = db.Exec(context.Background(), "INSERT INTO test (name) VALUES (?)", "a")
+	s.ErrorIs(err, apiTypes.ErrReadOnlyMember)
+
+	// Drain records are maintained by the draining member itself.
+	err = db.InternalTransaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.CreateInternalClusterMemberDrain(ctx, tx, cluster.InternalClusterMemberDrain{Name: "member", StartedAt: time.Now()})
+		if err != nil {
+			return err
+		}
+
+		drain, err := cluster.GetInternalClusterMemberDrain(ctx, tx, "member")
+		if err != nil {
+			return err
+		}
+
+		s.False(drain.Drained)
+
+		err = cluster.UpdateInternalClusterMemberDrained(ctx, tx, "member")
+		if err != nil {
+			return err
+		}
+
+		drain, err = cluster.GetInternalClusterMemberDrain(ctx, tx, "member")
+		if err != nil {
+			return err
+		}
+
+		s.True(drain.Drained)
+
+		err = cluster.DeleteInternalClusterMemberDrain(ctx, tx, "member")
+		if err != nil {
+			return err
+		}
+
+		_, err = cluster.GetInternalClusterMemberDrain(ctx, tx, "member")
+		s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+		return nil
+	})
+	s.Require().NoError(err)
+
+	db.SetDraining(false)
+	s.False(db.IsReadOnly())
+
+	_, err = db.Exec(context.Background(), "INSERT INTO test (name) VALUES (?)", "b")
+	s.NoError(err)
+}
+
+// Ensures the local dqlite node information matches what dqlite reports, and is unavailable until the database is open.
+func (s *dbSuite) Test_nodeInfo() {
+	// Reserve a free port for the dqlite node.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+
+	address := listener.Addr().String()
+	s.Require().NoError(listener.Close())
+
+	app, err := dqlite.New(s.T().TempDir(), dqlite.WithAddress(address))
+	s.Require().NoError(err)
+	defer func() { _ = app.Close() }()
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelCtx()
+
+	err = app.Ready(ctx)
+	s.Require().NoError(err)
+
+	db := &DB{dqlite: app, openCanceller: cancel.New(context.Background())}
+
+	_, err = db.NodeInfo(ctx)
+	s.Error(err)
+
+	db.openCanceller.Cancel()
+
+	info, err := db.NodeInfo(ctx)
+	s.Require().NoError(err)
+	s.Equal(app.ID(), info.ID)
+	s.Equal(app.Address(), info.Address)
+	s.Equal(dqliteClient.Voter, info.Role)
+}
+
+// Ensures tables are dumped with their column names, and that unknown table names are rejected.
+func (s *dbSuite) Test_dump() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.CreateCoreConfig(ctx, tx, cluster.CoreConfig{Key: "k1", Value: "v1"})
+
+		return err
+	})
+	s.Require().NoError(err)
+
+	rows, err := db.Dump(context.Background(), "core_config")
+	s.Require().NoError(err)
+	s.Require().Len(rows, 1)
+	s.Equal("k1", rows[0]["key"])
+	s.Equal("v1", rows[0]["value"])
+	s.Contains(rows[0], "id")
+
+	rows, err = db.Dump(context.Background(), "internal_token_records")
+	s.NoError(err)
+	s.Empty(rows)
+
+	_, err = db.Dump(context.Background(), "missing")
+	s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+	_, err = db.Dump(context.Background(), `core_config"; DROP TABLE core_config; --`)
+	s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+	_, err = db.Dump(context.Background(), "core_config")
+	s.NoError(err)
+}
+
+// Ensures a batch of join tokens is created atomically, and that a conflicting name rolls back the whole batch.
+func (s *dbSuite) Test_createInternalTokenRecords() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	expiryDate := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.CreateInternalTokenRecords(ctx, tx,
+			cluster.InternalTokenRecord{Name: "n1", Secret: "secret1", ExpiryDate: expiryDate},
+			cluster.InternalTokenRecord{Name: "n2", Secret: "secret2"},
+		)
+	})
+	s.Require().NoError(err)
+
+	// The second token conflicts with an existing one, so the first must not be created either.
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.CreateInternalTokenRecords(ctx, tx,
+			cluster.InternalTokenRecord{Name: "n3", Secret: "secret3"},
+			cluster.InternalTokenRecord{Name: "n1", Secret: "secret4"},
+		)
+	})
+	s.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+	// Duplicate names within a batch are rejected too.
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.CreateInternalTokenRecords(ctx, tx,
+			cluster.InternalTokenRecord{Name: "n5", Secret: "secret5"},
+			cluster.InternalTokenRecord{Name: "n5", Secret: "secret6"},
+		)
+	})
+	s.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+	var records []cluster.InternalTokenRecord
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		records, err = cluster.GetInternalTokenRecords(ctx, tx)
+
+		return err
+	})
+	s.Require().NoError(err)
+	s.Require().Len(records, 2)
+
+	byName := map[string]cluster.InternalTokenRecord{}
+	for _, record := range records {
+		byName[record.Name] = record
+	}
+
+	s.True(byName["n1"].ExpiryDate.Valid)
+	s.WithinDuration(expiryDate.Time, byName["n1"].ExpiryDate.Time, time.Second)
+	s.False(byName["n2"].ExpiryDate.Valid)
+	s.False(byName["n1"].Expired(0))
+
+	// Tokens are still accepted within the clock skew tolerance of their expiry date.
+	expired := cluster.InternalTokenRecord{ExpiryDate: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}}
+	s.True(expired.Expired(0))
+	s.True(expired.Expired(30 * time.Second))
+	s.False(expired.Expired(2 * time.Minute))
+}
+
+// Ensures pages of cluster members are ordered by name, filtered by role, and counted across all pages.
+func (s *dbSuite) Test_getInternalClusterMembersPage() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		for i, role := range []cluster.Role{"voter", "spare", "voter", "voter", cluster.Pending} {
+			_, err := cluster.CreateInternalClusterMember(ctx, tx, cluster.InternalClusterMember{
+				Name:        fmt.Sprintf("cluster-member-%d", 4-i),
+				Address:     fmt.Sprintf("10.0.0.%d:8443", i),
+				Certificate: fmt.Sprintf("test-cert-%d", i),
+				Role:        role,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	s.Require().NoError(err)
+
+	getPage := func(role *cluster.Role, limit int, offset int) ([]string, int) {
+		var names []string
+		var total int
+		err := db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+			members, count, err := cluster.GetInternalClusterMembersPage(ctx, tx, role, limit, offset)
+			if err != nil {
+				return err
+			}
+
+			total = count
+			for _, member := range members {
+				names = append(names, member.Name)
+			}
+
+			return nil
+		})
+		s.Require().NoError(err)
+
+		return names, total
+	}
+
+	names, total := getPage(nil, 0, 0)
+	s.Equal([]string{"cluster-member-0", "cluster-member-1", "cluster-member-2", "cluster-member-3", "cluster-member-4"}, names)
+	s.Equal(5, total)
+
+	names, total = getPage(nil, 2, 1)
+	s.Equal([]string{"cluster-member-1", "cluster-member-2"}, names)
+	s.Equal(5, total)
+
+	voter := cluster.Role("voter")
+	names, total = getPage(&voter, 2, 0)
+	s.Equal([]string{"cluster-member-1", "cluster-member-2"}, names)
+	s.Equal(3, total)
+
+	names, total = getPage(&voter, 0, 2)
+	s.Equal([]string{"cluster-member-4"}, names)
+	s.Equal(3, total)
+
+	names, total = getPage(&voter, 1, 5)
+	s.Empty(names)
+	s.Equal(3, total)
+}
+
+// Ensures inbound dqlite connections beyond the total or per-peer limits are rejected until a slot is released.
+func (s *dbSuite) Test_connectionLimits() {
+	db := &DB{}
+	db.SetConnectionLimits(3, 2)
+
+	releaseA1, err := db.ReserveConnection("peer-a")
+	s.Require().NoError(err)
+
+	_, err = db.ReserveConnection("peer-a")
+	s.Require().NoError(err)
+
+	// A third connection from the same peer exceeds the per-peer limit.
+	_, err = db.ReserveConnection("peer-a")
+	s.True(api.StatusErrorCheck(err, http.StatusTooManyRequests))
+	s.ErrorContains(err, "peer-a")
+
+	releaseB, err := db.ReserveConnection("peer-b")
+	s.Require().NoError(err)
+
+	// A fourth connection exceeds the total limit, even from a peer below its own limit.
+	_, err = db.ReserveConnection("peer-b")
+	s.True(api.StatusErrorCheck(err, http.StatusTooManyRequests))
+
+	// Releasing a slot more than once only frees it once.
+	releaseA1()
+	releaseA1()
+	_, err = db.ReserveConnection("peer-b")
+	s.Require().NoError(err)
+
+	_, err = db.ReserveConnection("peer-c")
+	s.True(api.StatusErrorCheck(err, http.StatusTooManyRequests))
+
+	// Closing an accepted connection releases its slot.
+	client, server := net.Pipe()
+	defer client.Close()
+
+	conn := &releasingConn{Conn: server, release: releaseB}
+	s.NoError(conn.Close())
+	_, err = db.ReserveConnection("peer-c")
+	s.NoError(err)
+
+	// Disabled limits accept any number of connections.
+	db.SetConnectionLimits(0, 0)
+	for i := 0; i < 10; i++ {
+		_, err = db.ReserveConnection("peer-a")
+		s.NoError(err)
+	}
+}
+
 // NewTedb returns a sqlite DB set up with the default microcluster schema.
 func NewTestDB(extensionsExternal []schema.Update) (*DB, error) {
 	var err error
@@ -656,7 +1508,7 @@ func NewTestDB(extensionsExternal []schema.Update) (*DB, error) {
 		return nil, err
 	}
 
-	db.SetSchema(extensionsExternal, nil)
+	db.SetSchema(extensionsExternal, nil, nil)
 	_, err = db.schema.Ensure(db.db)
 	if err != nil {
 		return nil, err
@@ -669,3 +1521,260 @@ func NewTestDB(extensionsExternal []schema.Update) (*DB, error) {
 
 	return db, nil
 }
+
+// Ensures the configured TCP user timeout and keepalive period are applied to outbound dqlite connections.
+func (s *dbSuite) Test_setTCPTimeouts() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	db := &DB{}
+	db.SetTCPTimeouts(5*time.Second, 7*time.Second)
+	s.Require().NoError(db.setTCPTimeouts(conn.(*net.TCPConn)))
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	s.Require().NoError(err)
+
+	var userTimeout, keepAliveIdle int
+	err = rawConn.Control(func(fd uintptr) {
+		userTimeout, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT)
+		s.NoError(err)
+
+		keepAliveIdle, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPIDLE)
+		s.NoError(err)
+	})
+	s.Require().NoError(err)
+
+	s.Equal(5000, userTimeout)
+	s.Equal(7, keepAliveIdle)
+}
+
+// Ensures log positions are ordered by term first, and then by index, and that only a later term at the same index
+// counts as a conflict.
+func (s *dbSuite) Test_logPositionAfter() {
+	s.True(logPositionAfter(types.DatabaseLogPosition{Term: 3, Index: 1}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+	s.True(logPositionAfter(types.DatabaseLogPosition{Term: 2, Index: 11}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+	s.False(logPositionAfter(types.DatabaseLogPosition{Term: 2, Index: 10}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+	s.False(logPositionAfter(types.DatabaseLogPosition{Term: 1, Index: 20}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+
+	// A leader that crashed with uncommitted entries has a longer log than the rest of the cluster, at the same term.
+	s.False(logPositionConflicts(types.DatabaseLogPosition{Term: 2, Index: 11}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+	s.False(logPositionConflicts(types.DatabaseLogPosition{Term: 2, Index: 11}, types.DatabaseLogPosition{Term: 3, Index: 11}))
+	s.False(logPositionConflicts(types.DatabaseLogPosition{Term: 2, Index: 10}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+	s.True(logPositionConflicts(types.DatabaseLogPosition{Term: 3, Index: 10}, types.DatabaseLogPosition{Term: 2, Index: 10}))
+}
+
+// Ensures resyncing a diverged database moves it aside while keeping the identity of the local dqlite node.
+func (s *dbSuite) Test_resync() {
+	stateDir := s.T().TempDir()
+	db := &DB{os: &sys.OS{StateDir: stateDir, DatabaseDir: filepath.Join(stateDir, "database")}}
+	s.Require().NoError(os.Mkdir(db.os.DatabaseDir, 0700))
+
+	files := map[string]string{
+		"info.yaml":                         "id: 2\naddress: 10.0.0.2:8443\nrole: 0\n",
+		"cluster.yaml":                      "- id: 1\n  address: 10.0.0.1:8443\n  role: 0\n",
+		"metadata1":                         "metadata",
+		"0000000000000001-0000000000000042": "segment",
+		"open-1":                            "open segment",
+	}
+
+	for name, content := range files {
+		s.Require().NoError(os.WriteFile(filepath.Join(db.os.DatabaseDir, name), []byte(content), 0600))
+	}
+
+	s.Require().NoError(db.resync())
+
+	entries, err := os.ReadDir(db.os.DatabaseDir)
+	s.Require().NoError(err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	s.ElementsMatch([]string{"cluster.yaml", "info.yaml"}, names)
+
+	for _, name := range []string{"info.yaml", "cluster.yaml"} {
+		data, err := os.ReadFile(filepath.Join(db.os.DatabaseDir, name))
+		s.Require().NoError(err)
+		s.Equal(files[name], string(data))
+	}
+
+	// The diverged database is kept next to the database directory.
+	backups, err := filepath.Glob(db.os.DatabaseDir + ".diverged.*")
+	s.Require().NoError(err)
+	s.Require().Len(backups, 1)
+
+	entries, err = os.ReadDir(backups[0])
+	s.Require().NoError(err)
+	s.Len(entries, len(files))
+}
+
+// Ensures new addresses are added to the stopped dqlite node store as spares, and that a running dqlite node keeps
+// its store to itself.
+func (s *dbSuite) Test_addKnownAddresses() {
+	ctx := context.Background()
+	dir := s.T().TempDir()
+	storePath := filepath.Join(dir, "cluster.yaml")
+
+	store, err := dqliteClient.NewYamlNodeStore(storePath)
+	s.Require().NoError(err)
+
+	err = store.Set(ctx, []dqliteClient.NodeInfo{{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter}})
+	s.Require().NoError(err)
+
+	addrs := make([]apiTypes.AddrPort, 0, 3)
+	for _, addr := range []string{"10.0.0.1:9000", "10.0.0.2:9000", "10.0.0.2:9000"} {
+		addrPort, err := apiTypes.ParseAddrPort(addr)
+		s.Require().NoError(err)
+
+		addrs = append(addrs, addrPort)
+	}
+
+	db := NewDB(ctx, nil, nil, &sys.OS{DatabaseDir: dir})
+	err = db.AddKnownAddresses(ctx, addrs...)
+	s.Require().NoError(err)
+
+	store, err = dqliteClient.NewYamlNodeStore(storePath)
+	s.Require().NoError(err)
+
+	nodes, err := store.Get(ctx)
+	s.Require().NoError(err)
+	s.Equal([]dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter},
+		{Address: "10.0.0.2:9000", Role: dqliteClient.Spare},
+	}, nodes)
+
+	// The store is left alone while dqlite runs.
+	db.dqlite = &dqlite.App{}
+	addr, err := apiTypes.ParseAddrPort("10.0.0.3:9000")
+	s.Require().NoError(err)
+
+	err = db.AddKnownAddresses(ctx, addr)
+	s.Require().NoError(err)
+
+	store, err = dqliteClient.NewYamlNodeStore(storePath)
+	s.Require().NoError(err)
+
+	nodes, err = store.Get(ctx)
+	s.Require().NoError(err)
+	s.Len(nodes, 2)
+}
+
+// Ensures changing the local address rewrites the node information and the entry of the local node in the node store,
+// leaving the other nodes unchanged.
+func (s *dbSuite) Test_setLocalAddress() {
+	ctx := context.Background()
+	dir := s.T().TempDir()
+
+	data, err := yaml.Marshal(dqliteClient.NodeInfo{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter})
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "info.yaml"), data, 0600))
+
+	store, err := dqliteClient.NewYamlNodeStore(filepath.Join(dir, "cluster.yaml"))
+	s.Require().NoError(err)
+
+	err = store.Set(ctx, []dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter},
+		{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter},
+	})
+	s.Require().NoError(err)
+
+	addr, err := apiTypes.ParseAddrPort("10.0.0.3:9000")
+	s.Require().NoError(err)
+
+	db := NewDB(ctx, nil, nil, &sys.OS{DatabaseDir: dir})
+	err = db.SetLocalAddress(ctx, addr, false)
+	s.Require().NoError(err)
+
+	data, err = os.ReadFile(filepath.Join(dir, "info.yaml"))
+	s.Require().NoError(err)
+
+	info := dqliteClient.NodeInfo{}
+	s.Require().NoError(yaml.Unmarshal(data, &info))
+	s.Equal(dqliteClient.NodeInfo{ID: 1, Address: "10.0.0.3:9000", Role: dqliteClient.Voter}, info)
+
+	store, err = dqliteClient.NewYamlNodeStore(filepath.Join(dir, "cluster.yaml"))
+	s.Require().NoError(err)
+
+	nodes, err := store.Get(ctx)
+	s.Require().NoError(err)
+	s.Equal([]dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.3:9000", Role: dqliteClient.Voter},
+		{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter},
+	}, nodes)
+}
+
+// Ensures the node information keeps the old address if the node store can't be updated.
+func (s *dbSuite) Test_setLocalAddressFailure() {
+	ctx := context.Background()
+	dir := s.T().TempDir()
+
+	data, err := yaml.Marshal(dqliteClient.NodeInfo{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter})
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "info.yaml"), data, 0600))
+
+	// The node store can't be read if its path is a directory.
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "cluster.yaml"), 0700))
+
+	addr, err := apiTypes.ParseAddrPort("10.0.0.3:9000")
+	s.Require().NoError(err)
+
+	db := NewDB(ctx, nil, nil, &sys.OS{DatabaseDir: dir})
+	err = db.SetLocalAddress(ctx, addr, false)
+	s.Error(err)
+
+	stored, err := os.ReadFile(filepath.Join(dir, "info.yaml"))
+	s.Require().NoError(err)
+	s.Equal(data, stored)
+}
+
+// Ensures a cluster member can only be soft-deleted once at a time, and that restoring it clears the removal.
+func (s *dbSuite) Test_clusterMemberRemovals() {
+	db, err := NewTestDB(nil)
+	s.Require().NoError(err)
+
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	removal := cluster.InternalClusterMemberRemoval{Name: "member01", Role: cluster.Role("voter"), ExpiresAt: expiresAt}
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.CreateInternalClusterMemberRemoval(ctx, tx, removal)
+	})
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.CreateInternalClusterMemberRemoval(ctx, tx, removal)
+	})
+	s.Error(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		stored, err := cluster.GetInternalClusterMemberRemoval(ctx, tx, "member01")
+		if err != nil {
+			return err
+		}
+
+		s.Equal(cluster.Role("voter"), stored.Role)
+		s.WithinDuration(expiresAt, stored.ExpiresAt, time.Second)
+
+		_, err = cluster.GetInternalClusterMemberRemoval(ctx, tx, "member02")
+		s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+		return cluster.DeleteInternalClusterMemberRemoval(ctx, tx, "member01")
+	})
+	s.Require().NoError(err)
+
+	err = db.Transaction(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		removals, err := cluster.GetInternalClusterMemberRemovals(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		s.Empty(removals)
+
+		return cluster.DeleteInternalClusterMemberRemoval(ctx, tx, "member01")
+	})
+	s.True(api.StatusErrorCheck(err, http.StatusNotFound))
+}
@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	dqliteNode "github.com/canonical/go-dqlite"
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/lxd/shared/revert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/microcluster/internal/rest/client"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// SetDivergencePolicy sets how a local database that diverged from the rest of the cluster is handled when the
+// cluster member rejoins it. An empty policy is the same as types.DivergenceAbort.
+func (db *DB) SetDivergencePolicy(policy types.DivergencePolicy) {
+	db.divergencePolicy = policy
+}
+
+// LogPosition returns the term and index of the last entry in the raft log of the local dqlite node, as last written
+// to disk.
+func (db *DB) LogPosition() (*internalTypes.DatabaseLogPosition, error) {
+	entry, err := dqliteNode.ReadLastEntryInfo(db.os.DatabaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the last entry of the dqlite log: %w", err)
+	}
+
+	return &internalTypes.DatabaseLogPosition{Term: entry.Term, Index: entry.Index}, nil
+}
+
+// checkDivergence checks whether the local database diverged from the cluster before the local dqlite node is started
+// again, and applies the divergence policy if it did.
+//
+// If the divergence can't be determined, for example because no other cluster member is reachable during a restart of
+// the whole cluster, the dqlite node is started as usual, and raft reconciles the logs once a leader is elected.
+func (db *DB) checkDivergence(addrs []string) error {
+	if !shared.PathExists(filepath.Join(db.os.DatabaseDir, "info.yaml")) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(db.ctx, 30*time.Second)
+	defer cancel()
+
+	reason, err := db.divergence(ctx, addrs)
+	if err != nil {
+		logger.Warn("Failed to check whether the local database diverged from the cluster", logger.Ctx{"error": err})
+		return nil
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	policy := db.divergencePolicy
+	if policy == "" {
+		policy = types.DivergenceAbort
+	}
+
+	logger.Error("Local database diverged from the cluster", logger.Ctx{"reason": reason, "policy": policy})
+
+	switch policy {
+	case types.DivergenceResync:
+		return db.resync()
+	case types.DivergenceConfirm:
+		confirmPath := db.os.DatabaseResyncPath()
+		if !shared.PathExists(confirmPath) {
+			return fmt.Errorf("Local database diverged from the cluster: %s. Create %q to confirm that it may be replaced by the cluster's database", reason, confirmPath)
+		}
+
+		err := db.resync()
+		if err != nil {
+			return err
+		}
+
+		err = os.Remove(confirmPath)
+		if err != nil {
+			return fmt.Errorf("Failed to remove database resync confirmation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Local database diverged from the cluster: %s", reason)
+}
+
+// divergence returns why the local database diverged from the cluster, or an empty string if it didn't.
+//
+// dqlite does not expose the raft term or log indexes of its nodes through its client API, so divergence is detected
+// from the state that dqlite keeps on disk:
+//   - The local dqlite node ID, recorded in info.yaml, must still be part of the dqlite configuration reported by the
+//     cluster's leader, with the same address. Otherwise the cluster was reconfigured without this node, or this node
+//     was recovered into a cluster of its own.
+//   - The last entry in the local raft log must not conflict with the last entry of the most up-to-date other cluster
+//     member, by having the same index but a later term. Failed elections increase the current term, but never the
+//     term of log entries, so such an entry can only have been written by a leader that the rest of the cluster never
+//     followed. A local log that is merely longer, or whose last entry has an earlier term, is the normal state of a
+//     node that crashed with uncommitted entries, which raft truncates by itself once the node rejoins.
+func (db *DB) divergence(ctx context.Context, addrs []string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(db.os.DatabaseDir, "info.yaml"))
+	if err != nil {
+		return "", fmt.Errorf("Failed to read dqlite node information: %w", err)
+	}
+
+	info := dqliteClient.NodeInfo{}
+	err = yaml.Unmarshal(data, &info)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse dqlite node information: %w", err)
+	}
+
+	local, err := db.LogPosition()
+	if err != nil {
+		return "", err
+	}
+
+	peerAddrs := make([]string, 0, len(addrs))
+	nodes := make([]dqliteClient.NodeInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr != db.listenAddr.URL.Host {
+			peerAddrs = append(peerAddrs, addr)
+			nodes = append(nodes, dqliteClient.NodeInfo{Address: addr})
+		}
+	}
+
+	if len(peerAddrs) == 0 {
+		return "", nil
+	}
+
+	store := dqliteClient.NewInmemNodeStore()
+	err = store.Set(ctx, nodes)
+	if err != nil {
+		return "", err
+	}
+
+	leader, err := dqliteClient.FindLeader(ctx, store, dqliteClient.WithDialFunc(db.dialFunc()))
+	if err != nil {
+		return "", fmt.Errorf("Failed to find the dqlite leader: %w", err)
+	}
+
+	defer leader.Close()
+
+	members, err := leader.Cluster(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get dqlite cluster information: %w", err)
+	}
+
+	var member *dqliteClient.NodeInfo
+	for i := range members {
+		if members[i].ID == info.ID {
+			member = &members[i]
+			break
+		}
+	}
+
+	if member == nil {
+		return fmt.Sprintf("Dqlite node %d is not part of the cluster's dqlite configuration", info.ID), nil
+	}
+
+	if member.Address != info.Address {
+		return fmt.Sprintf("Dqlite node %d has address %q in the cluster's dqlite configuration, instead of %q", info.ID, member.Address, info.Address), nil
+	}
+
+	clusterCert, err := db.clusterCert().PublicKeyX509()
+	if err != nil {
+		return "", err
+	}
+
+	var latest *internalTypes.DatabaseLogPosition
+	for _, addr := range peerAddrs {
+		c, err := client.New(*api.NewURL().Scheme("https").Host(addr), db.serverCert(), clusterCert, false)
+		if err != nil {
+			return "", err
+		}
+
+		position, err := c.GetDatabaseLogPosition(ctx)
+		if err != nil {
+			logger.Debug("Failed to get the dqlite log position of cluster member", logger.Ctx{"address": addr, "error": err})
+			continue
+		}
+
+		if latest == nil || logPositionAfter(*position, *latest) {
+			latest = position
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("No other cluster member reported its dqlite log position")
+	}
+
+	if logPositionConflicts(*local, *latest) {
+		return fmt.Sprintf("The last local dqlite log entry (term %d, index %d) conflicts with the rest of the cluster (term %d, index %d)", local.Term, local.Index, latest.Term, latest.Index), nil
+	}
+
+	return "", nil
+}
+
+// logPositionAfter returns whether the log entry at position a was appended after the one at position b, comparing
+// their terms and then their indexes.
+func logPositionAfter(a internalTypes.DatabaseLogPosition, b internalTypes.DatabaseLogPosition) bool {
+	if a.Term != b.Term {
+		return a.Term > b.Term
+	}
+
+	return a.Index > b.Index
+}
+
+// logPositionConflicts returns whether the local log entry at position local was written in a later term than the
+// entry at the same index of another node's log, at position peer.
+func logPositionConflicts(local internalTypes.DatabaseLogPosition, peer internalTypes.DatabaseLogPosition) bool {
+	return local.Index == peer.Index && local.Term > peer.Term
+}
+
+// resync moves the local database aside, keeping only the identity of the local dqlite node, so that it catches up
+// from the dqlite leader once it rejoins the cluster. The previous database is kept next to the database directory.
+func (db *DB) resync() error {
+	keep := map[string][]byte{}
+	for _, name := range []string{"info.yaml", "cluster.yaml"} {
+		data, err := os.ReadFile(filepath.Join(db.os.DatabaseDir, name))
+		if err != nil {
+			return fmt.Errorf("Failed to read %q: %w", name, err)
+		}
+
+		keep[name] = data
+	}
+
+	backupDir := fmt.Sprintf("%s.diverged.%d", db.os.DatabaseDir, time.Now().Unix())
+	err := os.Rename(db.os.DatabaseDir, backupDir)
+	if err != nil {
+		return fmt.Errorf("Failed to move diverged database aside: %w", err)
+	}
+
+	// If the new database directory can't be set up, put the diverged database back in place.
+	reverter := revert.New()
+	defer reverter.Fail()
+
+	reverter.Add(func() {
+		err := os.RemoveAll(db.os.DatabaseDir)
+		if err != nil {
+			logger.Error("Failed to remove partial database directory", logger.Ctx{"error": err})
+			return
+		}
+
+		err = os.Rename(backupDir, db.os.DatabaseDir)
+		if err != nil {
+			logger.Error("Failed to restore diverged database", logger.Ctx{"backup": backupDir, "error": err})
+		}
+	})
+
+	err = os.Mkdir(db.os.DatabaseDir, 0700)
+	if err != nil {
+		return fmt.Errorf("Failed to create database directory: %w", err)
+	}
+
+	for name, data := range keep {
+		err = os.WriteFile(filepath.Join(db.os.DatabaseDir, name), data, 0600)
+		if err != nil {
+			return fmt.Errorf("Failed to restore %q: %w", name, err)
+		}
+	}
+
+	reverter.Success()
+
+	logger.Warn("Resyncing diverged database from the cluster", logger.Ctx{"backup": backupDir})
+
+	return nil
+}
@@ -5,17 +5,21 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	dqliteNode "github.com/canonical/go-dqlite"
 	dqlite "github.com/canonical/go-dqlite/app"
 	dqliteClient "github.com/canonical/go-dqlite/client"
 	"github.com/canonical/lxd/lxd/db/schema"
@@ -25,6 +29,7 @@ import (
 	"github.com/canonical/lxd/shared/logger"
 	"github.com/canonical/lxd/shared/revert"
 	"github.com/canonical/lxd/shared/tcp"
+	"gopkg.in/yaml.v2"
 
 	"github.com/canonical/microcluster/cluster"
 	"github.com/canonical/microcluster/internal/db/update"
@@ -38,7 +43,7 @@ import (
 // DB holds all information internal to the dqlite database.
 type DB struct {
 	clusterCert func() *shared.CertInfo // Cluster certificate for dqlite authentication.
-	serverCert  *shared.CertInfo        // Server certificate for dqlite authentication.
+	serverCert  func() *shared.CertInfo // Server certificate for dqlite authentication.
 	listenAddr  api.URL                 // Listen address for this dqlite node.
 
 	dbName string // This is db.bin.
@@ -54,18 +59,46 @@ type DB struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	heartbeatLock sync.Mutex
+	heartbeatLock   sync.Mutex
+	heartbeatOffset time.Duration // Stable per-process shift applied to the heartbeat interval.
+
+	slowTransactionThreshold time.Duration // Transactions taking at least this long are logged. Disabled if zero.
+
+	tcpUserTimeout     time.Duration // TCP_USER_TIMEOUT of outbound dqlite connections. The LXD default if zero.
+	tcpKeepAlivePeriod time.Duration // TCP keepalive period of outbound dqlite connections. The LXD default if zero.
+
+	divergencePolicy types.DivergencePolicy // How a local database that diverged from the cluster is handled on rejoin.
+
+	trustedCerts *client.TrustedCerts // Cluster certificates trusted alongside the current one when dialing peers.
+
+	// offline is set when the last transaction failed because the database could not be reached.
+	offline atomic.Bool
+
+	// readOnly is set on read-only cluster members, whose transactions may not modify the database.
+	readOnly atomic.Bool
+
+	// draining is set while the cluster member is drained before its removal, which makes it read-only.
+	draining atomic.Bool
+
+	connections connectionLimiter // Limits of concurrent inbound dqlite connections.
+
+	changes changeFeed // Change notifications for registered tables.
 
 	schema *update.SchemaUpdate
 }
 
-// Accept sends the outbound connection through the acceptCh channel to be received by dqlite.
-func (db *DB) Accept(conn net.Conn) {
+// Accept sends the outbound connection through the acceptCh channel to be received by dqlite. The release function
+// returned by ReserveConnection, if any, is called once the connection is closed.
+func (db *DB) Accept(conn net.Conn, release func()) {
+	if release != nil {
+		conn = &releasingConn{Conn: conn, release: release}
+	}
+
 	db.acceptCh <- conn
 }
 
 // NewDB creates an empty db struct with no dqlite connection.
-func NewDB(ctx context.Context, serverCert *shared.CertInfo, clusterCert func() *shared.CertInfo, os *sys.OS) *DB {
+func NewDB(ctx context.Context, serverCert func() *shared.CertInfo, clusterCert func() *shared.CertInfo, os *sys.OS) *DB {
 	shutdownCtx, shutdownCancel := context.WithCancel(ctx)
 
 	return &DB{
@@ -81,11 +114,13 @@ func NewDB(ctx context.Context, serverCert *shared.CertInfo, clusterCert func()
 	}
 }
 
-// SetSchema sets schema and API extensions on the DB.
-func (db *DB) SetSchema(schemaExtensions []schema.Update, apiExtensions extensions.Extensions) {
+// SetSchema sets schema and API extensions on the DB. The schema hooks, keyed by schema extension version starting
+// at 1, run in the update transaction right after the schema extension of that version is applied.
+func (db *DB) SetSchema(schemaExtensions []schema.Update, schemaHooks map[int]schema.Hook, apiExtensions extensions.Extensions) {
 	s := update.NewSchema()
 	s.AppendSchema(schemaExtensions, apiExtensions)
 	db.schema = s.Schema()
+	db.schema.PostUpdateHooks(schemaHooks)
 }
 
 // Schema returns the update.SchemaUpdate for the DB.
@@ -112,10 +147,15 @@ func (db *DB) Bootstrap(extensions extensions.Extensions, project string, addr a
 
 	// Apply initial API extensions on the bootstrap node.
 	clusterRecord.APIExtensions = extensions
-	err = db.Transaction(db.ctx, func(ctx context.Context, tx *sql.Tx) error {
+	err = db.InternalTransaction(db.ctx, func(ctx context.Context, tx *sql.Tx) error {
 		_, err := cluster.CreateInternalClusterMember(ctx, tx, clusterRecord)
+		if err != nil {
+			return err
+		}
 
-		return err
+		db.RecordChange(ctx, ClusterMembersTable, types.ChangeCreate, clusterRecord.Name)
+
+		return nil
 	})
 	if err != nil {
 		return err
@@ -164,13 +204,20 @@ func (db *DB) Join(extensions extensions.Extensions, project string, addr api.UR
 	return nil
 }
 
-// StartWithCluster starts up dqlite and joins the cluster.
+// StartWithCluster starts up dqlite and joins the cluster. If the local database diverged from the cluster, the
+// divergence policy is applied first.
 func (db *DB) StartWithCluster(extensions extensions.Extensions, project string, addr api.URL, clusterMembers map[string]types.AddrPort) error {
 	allClusterAddrs := []string{}
 	for _, clusterMemberAddrs := range clusterMembers {
 		allClusterAddrs = append(allClusterAddrs, clusterMemberAddrs.String())
 	}
 
+	db.listenAddr = addr
+	err := db.checkDivergence(allClusterAddrs)
+	if err != nil {
+		return err
+	}
+
 	return db.Join(extensions, project, addr, allClusterAddrs...)
 }
 
@@ -179,6 +226,27 @@ func (db *DB) Leader(ctx context.Context) (*dqliteClient.Client, error) {
 	return db.dqlite.Leader(ctx)
 }
 
+// Backup returns the files of a consistent dump of the database, taken by the dqlite leader.
+func (db *DB) Backup(ctx context.Context) ([]dqliteClient.File, error) {
+	if !db.IsOpen() {
+		return nil, fmt.Errorf("Failed to back up the database, database is not yet open")
+	}
+
+	client, err := db.dqlite.Leader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	files, err := client.Dump(ctx, db.dbName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to dump database: %w", err)
+	}
+
+	return files, nil
+}
+
 // Cluster returns information about dqlite cluster members.
 func (db *DB) Cluster(ctx context.Context, client *dqliteClient.Client) ([]dqliteClient.NodeInfo, error) {
 	members, err := client.Cluster(ctx)
@@ -189,6 +257,175 @@ func (db *DB) Cluster(ctx context.Context, client *dqliteClient.Client) ([]dqlit
 	return members, nil
 }
 
+// Describe returns the failure domain and weight reported by the dqlite node at the given address.
+func (db *DB) Describe(ctx context.Context, address string) (*dqliteClient.NodeMetadata, error) {
+	client, err := dqliteClient.New(ctx, address, dqliteClient.WithDialFunc(db.dialFunc()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to dqlite node %q: %w", address, err)
+	}
+
+	defer client.Close()
+
+	metadata, err := client.Describe(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to describe dqlite node %q: %w", address, err)
+	}
+
+	return metadata, nil
+}
+
+// NodeInfo returns the dqlite ID, address, and role of the local dqlite node.
+func (db *DB) NodeInfo(ctx context.Context) (*dqliteClient.NodeInfo, error) {
+	if !db.IsOpen() {
+		return nil, fmt.Errorf("Failed to get dqlite node information, database is not yet open")
+	}
+
+	client, err := db.dqlite.Leader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	members, err := db.Cluster(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		if member.ID == db.dqlite.ID() {
+			return &member, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No dqlite record exists for local node with ID %d", db.dqlite.ID())
+}
+
+// AddKnownAddresses records the given addresses in dqlite's store of cluster members, so that they can be dialed
+// when dqlite is next started, before it learns about them from the leader. Addresses already in the store are left
+// unchanged.
+//
+// While dqlite runs, it owns the store and keeps it in sync with the leader's configuration, which would race with and
+// overwrite any change made here, so nothing is recorded.
+func (db *DB) AddKnownAddresses(ctx context.Context, addrs ...types.AddrPort) error {
+	if db.dqlite != nil {
+		logger.Debug("Leaving known addresses to the running dqlite node", logger.Ctx{"addresses": addrs})
+		return nil
+	}
+
+	store, err := dqliteClient.NewYamlNodeStore(filepath.Join(db.os.DatabaseDir, "cluster.yaml"))
+	if err != nil {
+		return fmt.Errorf("Failed to open dqlite node store: %w", err)
+	}
+
+	nodes, err := store.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read dqlite node store: %w", err)
+	}
+
+	known := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		known[node.Address] = true
+	}
+
+	changed := false
+	for _, addr := range addrs {
+		if known[addr.String()] {
+			continue
+		}
+
+		nodes = append(nodes, dqliteClient.NodeInfo{Address: addr.String(), Role: dqliteClient.Spare})
+		known[addr.String()] = true
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	err = store.Set(ctx, nodes)
+	if err != nil {
+		return fmt.Errorf("Failed to update dqlite node store: %w", err)
+	}
+
+	return nil
+}
+
+// SetLocalAddress changes the address of the local dqlite node in the database directory, so that dqlite binds to it
+// when it is next started. If the node is the only cluster member, its stored raft configuration is also rewritten, as
+// there is no leader to replicate the change to it. The node information is written last, and the node store is
+// restored if a later step fails, so that dqlite keeps binding to the old address. The database must be stopped.
+func (db *DB) SetLocalAddress(ctx context.Context, addr types.AddrPort, singleMember bool) error {
+	infoPath := filepath.Join(db.os.DatabaseDir, "info.yaml")
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read dqlite node information: %w", err)
+	}
+
+	info := dqliteClient.NodeInfo{}
+	err = yaml.Unmarshal(data, &info)
+	if err != nil {
+		return fmt.Errorf("Failed to parse dqlite node information: %w", err)
+	}
+
+	oldAddress := info.Address
+	info.Address = addr.String()
+	data, err = yaml.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("Failed to encode dqlite node information: %w", err)
+	}
+
+	store, err := dqliteClient.NewYamlNodeStore(filepath.Join(db.os.DatabaseDir, "cluster.yaml"))
+	if err != nil {
+		return fmt.Errorf("Failed to open dqlite node store: %w", err)
+	}
+
+	nodes, err := store.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to read dqlite node store: %w", err)
+	}
+
+	oldNodes := append([]dqliteClient.NodeInfo(nil), nodes...)
+	for i, node := range nodes {
+		if node.ID == info.ID || node.Address == oldAddress {
+			nodes[i].Address = info.Address
+		}
+	}
+
+	reverter := revert.New()
+	defer reverter.Fail()
+
+	err = store.Set(ctx, nodes)
+	if err != nil {
+		return fmt.Errorf("Failed to update dqlite node store: %w", err)
+	}
+
+	reverter.Add(func() {
+		err := store.Set(context.Background(), oldNodes)
+		if err != nil {
+			logger.Error("Failed to restore dqlite node store", logger.Ctx{"error": err})
+		}
+	})
+
+	if singleMember {
+		reconfigured := info
+		reconfigured.Role = dqliteClient.Voter
+		err = dqliteNode.ReconfigureMembershipExt(db.os.DatabaseDir, []dqliteClient.NodeInfo{reconfigured})
+		if err != nil {
+			return fmt.Errorf("Failed to reconfigure dqlite membership: %w", err)
+		}
+	}
+
+	err = os.WriteFile(infoPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write dqlite node information: %w", err)
+	}
+
+	reverter.Success()
+
+	return nil
+}
+
 // IsOpen returns true only if the DB has been opened and the schema loaded.
 func (db *DB) IsOpen() bool {
 	if db == nil {
@@ -198,11 +435,60 @@ func (db *DB) IsOpen() bool {
 	return db.openCanceller.Err() != nil
 }
 
-// NotifyUpgraded sends a notification that we can stop waiting for a cluster member to be upgraded.
-func (db *DB) NotifyUpgraded() {
+// IsOffline returns true if the last transaction failed because the database could not be reached.
+// The database is considered online again once a transaction succeeds.
+func (db *DB) IsOffline() bool {
+	if db == nil {
+		return false
+	}
+
+	return db.offline.Load()
+}
+
+// SetOffline marks the database as offline or back online.
+func (db *DB) SetOffline(offline bool) {
+	db.offline.Store(offline)
+}
+
+// IsReadOnly returns true if this is a read-only cluster member, or one that is draining.
+func (db *DB) IsReadOnly() bool {
+	if db == nil {
+		return false
+	}
+
+	return db.readOnly.Load() || db.draining.Load()
+}
+
+// SetReadOnly makes this a read-only cluster member, whose transactions may not modify the database. It still takes
+// part in heartbeats and membership changes, through InternalTransaction.
+func (db *DB) SetReadOnly(readOnly bool) {
+	db.readOnly.Store(readOnly)
+}
+
+// IsDraining returns true if the cluster member is draining before its removal.
+func (db *DB) IsDraining() bool {
+	if db == nil {
+		return false
+	}
+
+	return db.draining.Load()
+}
+
+// SetDraining marks the cluster member as draining before its removal, which makes it read-only until the drain is
+// cancelled.
+func (db *DB) SetDraining(draining bool) {
+	db.draining.Store(draining)
+}
+
+// NotifyUpgraded sends a notification that we can stop waiting for a cluster member to be upgraded, so that the
+// versions of all cluster members are checked again right away. It returns whether the member was waiting, and is a
+// no-op otherwise.
+func (db *DB) NotifyUpgraded() bool {
 	select {
 	case db.upgradeCh <- struct{}{}:
+		return true
 	default:
+		return false
 	}
 }
 
@@ -218,11 +504,65 @@ func (db *DB) dialFunc() dqliteClient.DialFunc {
 	}
 }
 
-// loopHeartbeat runs the heartbeat command continuously every second.
+// HeartbeatInterval is the base interval between heartbeat attempts.
+const HeartbeatInterval = 10 * time.Second
+
+// DefaultHeartbeatJitter is the default fraction of HeartbeatInterval by which members shift their interval.
+const DefaultHeartbeatJitter = 0.1
+
+// SetHeartbeatJitter picks a random offset of up to the given fraction of HeartbeatInterval, in either direction,
+// to apply to every heartbeat interval of this member. The offset is picked once, so each member keeps a steady
+// but slightly different interval, and members drift apart rather than sending heartbeats in step.
+func (db *DB) SetHeartbeatJitter(fraction float64) {
+	db.heartbeatOffset = time.Duration((rand.Float64()*2 - 1) * fraction * float64(HeartbeatInterval))
+}
+
+// DefaultSlowTransactionThreshold is the default duration after which a transaction is logged as slow.
+const DefaultSlowTransactionThreshold = 5 * time.Second
+
+// SetSlowTransactionThreshold sets the duration after which a transaction is logged as slow, along with its name,
+// elapsed time and number of attempts. A zero threshold disables the logging.
+func (db *DB) SetSlowTransactionThreshold(threshold time.Duration) {
+	db.slowTransactionThreshold = threshold
+}
+
+// SetTCPTimeouts sets the TCP_USER_TIMEOUT and the TCP keepalive period of outbound dqlite connections. Lower values
+// detect dead peers sooner, which speeds up leader failover, but also drop connections that only stalled during a
+// brief network blip, forcing dqlite to reconnect. A zero value keeps the LXD default: a user timeout of 2 minutes
+// and a keepalive period of 3 seconds.
+func (db *DB) SetTCPTimeouts(userTimeout time.Duration, keepAlivePeriod time.Duration) {
+	db.tcpUserTimeout = userTimeout
+	db.tcpKeepAlivePeriod = keepAlivePeriod
+}
+
+// SetTrustedCerts sets the cluster certificates that are trusted alongside the current one when dialing dqlite peers,
+// such as those of an ongoing cluster certificate rotation.
+func (db *DB) SetTrustedCerts(trusted *client.TrustedCerts) {
+	db.trustedCerts = trusted
+}
+
+// setTCPTimeouts applies the configured TCP_USER_TIMEOUT and TCP keepalive period to an outbound dqlite connection.
+func (db *DB) setTCPTimeouts(conn *net.TCPConn) error {
+	err := tcp.SetTimeouts(conn, db.tcpUserTimeout)
+	if err != nil {
+		return err
+	}
+
+	if db.tcpKeepAlivePeriod > 0 {
+		err := conn.SetKeepAlivePeriod(db.tcpKeepAlivePeriod)
+		if err != nil {
+			return fmt.Errorf("Failed setting TCP keepalive period: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loopHeartbeat runs the heartbeat command continuously every HeartbeatInterval, shifted by the heartbeat offset.
 func (db *DB) loopHeartbeat() {
 	for {
 		db.heartbeat(db.ctx)
-		time.Sleep(10 * time.Second)
+		time.Sleep(HeartbeatInterval + db.heartbeatOffset)
 	}
 }
 
@@ -243,7 +583,7 @@ func (db *DB) heartbeat(ctx context.Context) {
 	}
 
 	// Initiate a heartbeat from this node.
-	err = client.Heartbeat(ctx, internalTypes.HeartbeatInfo{BeginRound: true})
+	_, err = client.Heartbeat(ctx, internalTypes.HeartbeatInfo{BeginRound: true})
 	if err != nil && err.Error() != "Attempt to initiate heartbeat from non-leader" {
 		logger.Error("Failed to initiate heartbeat round", logger.Ctx{"address": db.dqlite.Address(), "error": err})
 		return
@@ -257,7 +597,7 @@ func dqliteNetworkDial(ctx context.Context, addr string, db *DB) (net.Conn, erro
 		return nil, err
 	}
 
-	config, err := client.TLSClientConfig(db.serverCert, peerCert)
+	config, err := client.TLSClientConfig(db.serverCert(), peerCert, db.trustedCerts.Certs()...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse TLS config: %w", err)
 	}
@@ -306,7 +646,7 @@ func dqliteNetworkDial(ctx context.Context, addr string, db *DB) (net.Conn, erro
 	if err != nil {
 		logCtx.Error("Failed extracting TCP connection from remote connection", logger.Ctx{"error": err})
 	} else {
-		err := tcp.SetTimeouts(remoteTCP, 0)
+		err := db.setTCPTimeouts(remoteTCP)
 		if err != nil {
 			logCtx.Error("Failed setting TCP timeouts on remote connection", logger.Ctx{"error": err})
 		}
@@ -323,7 +663,7 @@ func dqliteNetworkDial(ctx context.Context, addr string, db *DB) (net.Conn, erro
 	}
 
 	defer response.Body.Close()
-	_, err = io.Copy(io.Discard, response.Body)
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		logger.Error("Failed to read dqlite response body", logger.Ctx{"error": err})
 	}
@@ -336,6 +676,12 @@ func dqliteNetworkDial(ctx context.Context, addr string, db *DB) (net.Conn, erro
 	}
 
 	if response.StatusCode != http.StatusSwitchingProtocols {
+		// Report why the connection was refused, such as a connection limit, if the response says so.
+		apiResponse := api.Response{}
+		if json.Unmarshal(body, &apiResponse) == nil && apiResponse.Error != "" {
+			return nil, fmt.Errorf("Dialing failed: expected status code 101 got %d: %s", response.StatusCode, apiResponse.Error)
+		}
+
 		return nil, fmt.Errorf("Dialing failed: expected status code 101 got %d", response.StatusCode)
 	}
 
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Dump returns every row of the given table, keyed by column name.
+// It is meant for debugging and admin tooling, where writing a mapper for the table isn't worth it.
+func (db *DB) Dump(ctx context.Context, table string) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := db.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		rows, err = dumpTable(ctx, tx, table)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// dumpTable returns every row of the given table, keyed by column name. The table name is checked against
+// sqlite_master before being used in the query, as it can't be passed as a query argument.
+func dumpTable(ctx context.Context, tx *sql.Tx, table string) ([]map[string]any, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up table %q: %w", table, err)
+	}
+
+	if count == 0 {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Table %q not found", table)
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM "%s"`, strings.ReplaceAll(table, `"`, `""`)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query table %q: %w", table, err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err := rows.Scan(pointers...)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan row of table %q: %w", table, err)
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			// Text columns may be returned as bytes, which don't render well in a dump.
+			value, ok := values[i].([]byte)
+			if ok {
+				row[column] = string(value)
+			} else {
+				row[column] = values[i]
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
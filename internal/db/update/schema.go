@@ -2,13 +2,16 @@ package update
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/canonical/lxd/lxd/db/query"
 	"github.com/canonical/lxd/lxd/db/schema"
 	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/logger"
 )
 
 // updateType represents whether the update is an internal or external schema update.
@@ -29,6 +32,8 @@ type SchemaUpdate struct {
 	fresh   string                         // Optional SQL statement used to create schema from scratch
 	check   schema.Check                   // Optional callback invoked before doing any update
 	path    string                         // Optional path to a file containing extra queries to run
+
+	postHooks map[int]schema.Hook // Optional hooks to execute after the external update of the given version
 }
 
 // Fresh sets a statement that will be used to create the schema from scratch
@@ -46,6 +51,20 @@ func (s *SchemaUpdate) Check(check schema.Check) {
 	s.check = check
 }
 
+// File sets the path of a file containing extra queries to run before any update, such as an emergency patch dropped
+// in by an operator. See execFromFile for how the file is applied.
+func (s *SchemaUpdate) File(path string) {
+	s.path = path
+}
+
+// PostUpdateHooks sets hooks to run right after the external update of the given version is applied, in the same
+// transaction, for example to fill a new column from existing data. Versions start at 1 for the first external
+// update. Hooks run after the update they are keyed by, so always after the Check callback and after the hook set
+// on the schema, which runs before every update.
+func (s *SchemaUpdate) PostUpdateHooks(hooks map[int]schema.Hook) {
+	s.postHooks = hooks
+}
+
 // Version returns the internal and external schema update versions, corresponding to the number of updates that have occurred.
 func (s *SchemaUpdate) Version() (internalVersion uint64, externalVersion uint64) {
 	return uint64(len(s.updates[updateInternal])), uint64(len(s.updates[updateExternal]))
@@ -69,8 +88,10 @@ func (s *SchemaUpdate) Ensure(db *sql.DB) (int, error) {
 	versions := []int{0, 0}
 	var updateSchemaTable bool
 	var exists bool
+	var patched bool
 	err := query.Transaction(context.TODO(), db, func(ctx context.Context, tx *sql.Tx) error {
-		err := execFromFile(ctx, tx, s.path, s.hook)
+		var err error
+		patched, err = execFromFile(ctx, tx, s.path, s.hook)
 		if err != nil {
 			return fmt.Errorf("Failed to execute queries from %s: %w", s.path, err)
 		}
@@ -100,6 +121,14 @@ func (s *SchemaUpdate) Ensure(db *sql.DB) (int, error) {
 		return -1, err
 	}
 
+	// Only remove the patch file once it is committed, so that a failed patch can be fixed and applied again.
+	if patched {
+		err = os.Remove(s.path)
+		if err != nil {
+			return -1, fmt.Errorf("Failed to remove file %s: %w", s.path, err)
+		}
+	}
+
 	// If we need to update the schemas table, disable foreign keys
 	// so references to the `internal_cluster_members` table do not get dropped.
 	if updateSchemaTable {
@@ -205,7 +234,7 @@ func (s *SchemaUpdate) Ensure(db *sql.DB) (int, error) {
 				return fmt.Errorf("Cannot apply fresh schema: %w", err)
 			}
 		} else {
-			err = ensureUpdatesAreApplied(ctx, tx, updateInternal, versions[updateInternal], s.updates[updateInternal], s.hook)
+			err = ensureUpdatesAreApplied(ctx, tx, updateInternal, versions[updateInternal], s.updates[updateInternal], s.hook, nil)
 			if err != nil {
 				return err
 			}
@@ -227,7 +256,7 @@ func (s *SchemaUpdate) Ensure(db *sql.DB) (int, error) {
 
 	err = query.Transaction(context.TODO(), db, func(ctx context.Context, tx *sql.Tx) error {
 		if s.fresh == "" || versions[updateInternal] > 0 || versions[updateExternal] > 0 {
-			err = ensureUpdatesAreApplied(ctx, tx, updateExternal, versions[updateExternal], s.updates[updateExternal], s.hook)
+			err = ensureUpdatesAreApplied(ctx, tx, updateExternal, versions[updateExternal], s.updates[updateExternal], s.hook, s.postHooks)
 			if err != nil {
 				return err
 			}
@@ -242,8 +271,9 @@ func (s *SchemaUpdate) Ensure(db *sql.DB) (int, error) {
 	return current, nil
 }
 
-// Apply any pending update that was not yet applied.
-func ensureUpdatesAreApplied(ctx context.Context, tx *sql.Tx, updateType updateType, version int, updates []schema.Update, hook schema.Hook) error {
+// Apply any pending update that was not yet applied. The post hook of each version, if any, runs once its update
+// is applied.
+func ensureUpdatesAreApplied(ctx context.Context, tx *sql.Tx, updateType updateType, version int, updates []schema.Update, hook schema.Hook, postHooks map[int]schema.Hook) error {
 	if version > len(updates) {
 		return fmt.Errorf("Schema version %d is more recent than expected %d", version, len(updates))
 	}
@@ -276,6 +306,14 @@ func ensureUpdatesAreApplied(ctx context.Context, tx *sql.Tx, updateType updateT
 
 		version++
 
+		postHook := postHooks[version]
+		if postHook != nil {
+			err := postHook(ctx, version, tx)
+			if err != nil {
+				return fmt.Errorf("Failed to execute post-update hook (version %d): %w", version, err)
+			}
+		}
+
 		statement := `INSERT INTO schemas (version, type, updated_at) VALUES (?, ?, strftime("%s"))`
 		_, err = tx.ExecContext(ctx, statement, version, updateType)
 		if err != nil {
@@ -286,35 +324,62 @@ func ensureUpdatesAreApplied(ctx context.Context, tx *sql.Tx, updateType updateT
 	return nil
 }
 
-// Read the given file (if it exists) and executes all queries it contains.
-func execFromFile(ctx context.Context, tx *sql.Tx, path string, hook schema.Hook) error {
+// execFromFile runs all queries in the given file (if it exists) within the given transaction, so that a file that
+// fails part way through leaves the database unchanged. The checksum of the file is recorded along with the queries,
+// and a file whose checksum is already recorded is skipped, so that a patch is only applied once across the cluster.
+// It returns whether the file was handled and can be removed once the transaction is committed.
+func execFromFile(ctx context.Context, tx *sql.Tx, path string, hook schema.Hook) (bool, error) {
 	if !shared.PathExists(path) {
-		return nil
+		return false, nil
 	}
 
 	bytes, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("Failed to read file: %w", err)
+		return false, fmt.Errorf("Failed to read file: %w", err)
+	}
+
+	// The table is created on demand, as patches run before any schema update.
+	stmt := `CREATE TABLE IF NOT EXISTS internal_schema_patches (
+  checksum    TEXT     PRIMARY KEY NOT NULL,
+  applied_at  DATETIME NOT NULL
+)`
+
+	_, err = tx.ExecContext(ctx, stmt)
+	if err != nil {
+		return false, fmt.Errorf("Failed to create schema patches table: %w", err)
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256(bytes))
+	checksums, err := query.SelectStrings(ctx, tx, "SELECT checksum FROM internal_schema_patches WHERE checksum = ?", checksum)
+	if err != nil {
+		return false, fmt.Errorf("Failed to check for applied schema patches: %w", err)
+	}
+
+	if len(checksums) > 0 {
+		logger.Warn("Skipping schema patch that was already applied", logger.Ctx{"path": path, "checksum": checksum})
+		return true, nil
 	}
 
 	if hook != nil {
 		err := hook(ctx, -1, tx)
 		if err != nil {
-			return fmt.Errorf("Failed to execute hook: %w", err)
+			return false, fmt.Errorf("Failed to execute hook: %w", err)
 		}
 	}
 
 	_, err = tx.ExecContext(ctx, string(bytes))
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	err = os.Remove(path)
+	_, err = tx.ExecContext(ctx, "INSERT INTO internal_schema_patches (checksum, applied_at) VALUES (?, ?)", checksum, time.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("Failed to remove file: %w", err)
+		return false, fmt.Errorf("Failed to record schema patch: %w", err)
 	}
 
-	return nil
+	logger.Info("Applied schema patch", logger.Ctx{"path": path, "checksum": checksum})
+
+	return true, nil
 }
 
 // doesSchemaTableExist return whether the schema table is present in the
@@ -36,6 +36,12 @@ func NewSchema() *SchemaUpdateManager {
 			updateFromV1,
 			updateFromV2,
 			mgr.updateFromV3,
+			updateFromV4,
+			updateFromV5,
+			updateFromV6,
+			updateFromV7,
+			updateFromV8,
+			updateFromV9,
 		},
 	}
 
@@ -73,6 +79,83 @@ func (s *SchemaUpdateManager) AppendSchema(schemaExtensions []schema.Update, api
 	s.apiExtensions = apiExtensions
 }
 
+// updateFromV9 records the client certificate of each cluster member, if it is distinct from its server certificate.
+func updateFromV9(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE internal_cluster_members ADD COLUMN client_certificate TEXT NOT NULL DEFAULT '';
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// updateFromV8 introduces the internal_cluster_member_drains table to track cluster members drained before removal.
+func updateFromV8(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE internal_cluster_member_drains (
+  id           INTEGER         PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  name         TEXT            NOT      NULL,
+  started_at   DATETIME        NOT      NULL,
+  drained      INTEGER         NOT      NULL   DEFAULT  0,
+  UNIQUE       (name)
+);
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// updateFromV7 introduces the core_cluster_member_config table, a key/value store of annotations for each cluster member.
+func updateFromV7(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE core_cluster_member_config (
+  id           INTEGER         PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  member       TEXT            NOT      NULL,
+  key          TEXT            NOT      NULL,
+  value        TEXT            NOT      NULL,
+  UNIQUE       (member, key)
+);
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// updateFromV6 adds an optional expiry date to join token records. Existing tokens never expire.
+func updateFromV6(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE internal_token_records ADD COLUMN expiry_date DATETIME;
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// updateFromV5 introduces the internal_cluster_member_removals table to track soft-deleted cluster members.
+func updateFromV5(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE internal_cluster_member_removals (
+  id           INTEGER         PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  name         TEXT            NOT      NULL,
+  role         TEXT            NOT      NULL,
+  expires_at   DATETIME        NOT      NULL,
+  UNIQUE       (name)
+);
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
+// updateFromV4 introduces the core_config table, a cluster-wide key/value store available to all consumers.
+func updateFromV4(ctx context.Context, tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE core_config (
+  id           INTEGER         PRIMARY  KEY    AUTOINCREMENT  NOT  NULL,
+  key          TEXT            NOT      NULL,
+  value        TEXT            NOT      NULL,
+  UNIQUE       (key)
+);
+`
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
+
 // updateFromV3 auto-applies the initial set of API extensions to the internal_cluster_members table.
 // This is done so that the cluster won't have to be notified twice,
 // once for the schema update that introduces API extensions to be applied,
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -226,6 +228,111 @@ func (s *updateSuite) Test_updateFromV1() {
 	}
 }
 
+// Ensures a schema patch file is applied atomically and only once, and is kept if it fails.
+func (s *updateSuite) Test_patchFile() {
+	db, err := sql.Open("sqlite3", ":memory:")
+	s.Require().NoError(err)
+
+	db.SetMaxOpenConns(1)
+
+	path := filepath.Join(s.T().TempDir(), "patch.global.sql")
+	ensure := func(patch string) error {
+		s.Require().NoError(os.WriteFile(path, []byte(patch), 0600))
+
+		schema := NewSchema().Schema()
+		schema.File(path)
+		_, err := schema.Ensure(db)
+
+		return err
+	}
+
+	count := func(table string) int {
+		var n int
+		s.Require().NoError(db.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s", table)).Scan(&n))
+
+		return n
+	}
+
+	// A valid patch is applied and removed.
+	valid := "CREATE TABLE IF NOT EXISTS test (id INTEGER PRIMARY KEY); INSERT INTO test (id) VALUES (1);"
+	s.NoError(ensure(valid))
+	s.Equal(1, count("test"))
+	s.NoFileExists(path)
+
+	// The same patch is not applied again.
+	s.NoError(ensure(valid))
+	s.Equal(1, count("test"))
+	s.NoFileExists(path)
+
+	// A patch failing part way through is rolled back entirely and kept.
+	s.Error(ensure("INSERT INTO test (id) VALUES (2); INSERT INTO missing (id) VALUES (1);"))
+	s.Equal(1, count("test"))
+	s.FileExists(path)
+	s.Equal(1, count("internal_schema_patches"))
+}
+
+// Ensures post-update hooks run after the external update of their version and after the check, and that a failing
+// hook rolls back the update.
+func (s *updateSuite) Test_postUpdateHooks() {
+	db, err := sql.Open("sqlite3", ":memory:")
+	s.Require().NoError(err)
+
+	db.SetMaxOpenConns(1)
+
+	var calls []string
+	addColumn := func(ctx context.Context, tx *sql.Tx) error {
+		calls = append(calls, "update 2")
+		_, err := tx.ExecContext(ctx, "ALTER TABLE test ADD COLUMN doubled INTEGER")
+		return err
+	}
+
+	createTable := func(ctx context.Context, tx *sql.Tx) error {
+		calls = append(calls, "update 1")
+		_, err := tx.ExecContext(ctx, "CREATE TABLE test (id INTEGER PRIMARY KEY, value INTEGER); INSERT INTO test (value) VALUES (21)")
+		return err
+	}
+
+	mgr := NewSchema()
+	mgr.AppendSchema([]schema.Update{createTable, addColumn}, nil)
+	updates := mgr.Schema()
+	updates.Check(func(ctx context.Context, current int, tx *sql.Tx) error {
+		calls = append(calls, "check")
+		return nil
+	})
+
+	backfillErr := fmt.Errorf("Backfill failed")
+	updates.PostUpdateHooks(map[int]schema.Hook{
+		2: func(ctx context.Context, version int, tx *sql.Tx) error {
+			calls = append(calls, fmt.Sprintf("hook %d", version))
+			if backfillErr != nil {
+				return backfillErr
+			}
+
+			_, err := tx.ExecContext(ctx, "UPDATE test SET doubled = value * 2")
+			return err
+		},
+	})
+
+	// The failing hook rolls back both external updates, which share the transaction.
+	_, err = updates.Ensure(db)
+	s.ErrorIs(err, backfillErr)
+	s.Equal([]string{"check", "update 1", "update 2", "hook 2"}, calls)
+
+	var tables int
+	s.Require().NoError(db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'test'").Scan(&tables))
+	s.Equal(0, tables)
+
+	calls = nil
+	backfillErr = nil
+	_, err = updates.Ensure(db)
+	s.Require().NoError(err)
+	s.Equal([]string{"check", "update 1", "update 2", "hook 2"}, calls)
+
+	var doubled int
+	s.Require().NoError(db.QueryRow("SELECT doubled FROM test").Scan(&doubled))
+	s.Equal(42, doubled)
+}
+
 // NewTestDBWithSchema returns a sqlite DB set up with the given schema updates.
 func NewTestDBWithSchema(schemaManager *SchemaUpdateManager) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", ":memory:")
@@ -0,0 +1,134 @@
+package endpoints
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// connCounter keeps track of the number of open connections to an http.Server.
+type connCounter struct {
+	active atomic.Int64
+
+	// streams holds connections hijacked from the server, such as websockets and dqlite connections, which the server
+	// no longer tracks. Only connections accepted through a streamListener can be tracked once hijacked.
+	streamsMu sync.Mutex
+	streams   map[*streamConn]struct{}
+}
+
+// track hooks into the ConnState callback of the given server to count its open connections.
+// Any ConnState callback already set on the server is still called.
+func (c *connCounter) track(server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	prev := server.ConnState
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			c.active.Add(1)
+		case http.StateHijacked:
+			c.active.Add(-1)
+			c.addStream(conn)
+		case http.StateClosed:
+			c.active.Add(-1)
+		}
+
+		if prev != nil {
+			prev(conn, state)
+		}
+	}
+}
+
+// ActiveConnections returns the number of connections that are currently open, including hijacked ones.
+func (c *connCounter) ActiveConnections() int64 {
+	return c.active.Load() + int64(c.activeStreams())
+}
+
+// addStream starts tracking a connection hijacked from the server until it is closed.
+func (c *connCounter) addStream(conn net.Conn) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if ok {
+		conn = tlsConn.NetConn()
+	}
+
+	stream, ok := conn.(*streamConn)
+	if !ok {
+		return
+	}
+
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	if c.streams == nil {
+		c.streams = map[*streamConn]struct{}{}
+	}
+
+	c.streams[stream] = struct{}{}
+}
+
+// removeStream stops tracking the given connection.
+func (c *connCounter) removeStream(stream *streamConn) {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	delete(c.streams, stream)
+}
+
+// activeStreams returns the number of hijacked connections that are still open.
+func (c *connCounter) activeStreams() int {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	return len(c.streams)
+}
+
+// closeStreams closes all hijacked connections that are still open.
+func (c *connCounter) closeStreams() {
+	c.streamsMu.Lock()
+	streams := make([]*streamConn, 0, len(c.streams))
+	for stream := range c.streams {
+		streams = append(streams, stream)
+	}
+
+	c.streamsMu.Unlock()
+
+	for _, stream := range streams {
+		_ = stream.Close()
+	}
+}
+
+// streamListener wraps the connections accepted by a listener so that they can still be tracked by the connCounter
+// once they are hijacked from the server.
+type streamListener struct {
+	net.Listener
+
+	counter *connCounter
+}
+
+// Accept waits for and returns the next connection, wrapped for tracking.
+func (l *streamListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamConn{Conn: conn, counter: l.counter}, nil
+}
+
+// streamConn is a connection that stops being tracked by its connCounter once closed.
+type streamConn struct {
+	net.Conn
+
+	counter *connCounter
+}
+
+// Close closes the connection and stops tracking it.
+func (c *streamConn) Close() error {
+	c.counter.removeStream(c)
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// DebugHTTP represents a plain HTTP listener and its server, serving a few public read endpoints without TLS or client
+// certificates. It is insecure, so it may only be bound to a loopback address, for local debugging tools and sidecars.
+type DebugHTTP struct {
+	plainHTTP
+}
+
+// NewDebugHTTP assigns a loopback address, drain timeouts, and server to the DebugHTTP listener.
+func NewDebugHTTP(ctx context.Context, server *http.Server, address string, drain DrainTimeouts) *DebugHTTP {
+	d := &DebugHTTP{plainHTTP: newPlainHTTP(ctx, "debug http", server, address, drain)}
+	d.insecure = true
+	d.track(server)
+
+	return d
+}
+
+// ValidateLoopbackAddress returns an error if the given host:port address is not a loopback address.
+// An empty host is rejected as it would bind every interface.
+func ValidateLoopbackAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Invalid address %q: %w", address, err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("Address %q is not a loopback address", address)
+	}
+
+	return nil
+}
+
+// Type returns the type of the Endpoint.
+func (d *DebugHTTP) Type() EndpointType {
+	return EndpointDebugHTTP
+}
+
+// Listen on the given address. It refuses to bind anything but a loopback address.
+func (d *DebugHTTP) Listen() error {
+	err := ValidateLoopbackAddress(d.address)
+	if err != nil {
+		return fmt.Errorf("Refusing to listen on debug http socket: %w", err)
+	}
+
+	err = d.plainHTTP.Listen()
+	if err != nil {
+		return err
+	}
+
+	// The name may resolve to a non-loopback address, so check what was actually bound.
+	addr, ok := d.listener.Addr().(*net.TCPAddr)
+	if !ok || !addr.IP.IsLoopback() {
+		bound := d.listener.Addr().String()
+		_ = d.listener.Close()
+		d.listener = nil
+
+		return fmt.Errorf("Refusing to listen on debug http socket: %q is not a loopback address", bound)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type debugSuite struct {
+	suite.Suite
+}
+
+func TestDebugSuite(t *testing.T) {
+	suite.Run(t, new(debugSuite))
+}
+
+// Ensures the debug http listener only binds loopback addresses.
+func (t *debugSuite) Test_debugHTTPLoopbackOnly() {
+	for _, address := range []string{"127.0.0.1:0", "[::1]:0", "localhost:0"} {
+		t.NoError(ValidateLoopbackAddress(address), address)
+	}
+
+	for _, address := range []string{":0", "0.0.0.0:0", "[::]:0", "10.0.0.1:0", "example.com:0", "127.0.0.1"} {
+		t.Error(ValidateLoopbackAddress(address), address)
+
+		debug := NewDebugHTTP(context.Background(), &http.Server{}, address, DrainTimeouts{})
+		t.Error(debug.Listen(), address)
+		t.Nil(debug.listener)
+	}
+
+	debug := NewDebugHTTP(context.Background(), &http.Server{}, "127.0.0.1:0", DrainTimeouts{})
+	t.Require().NoError(debug.Listen())
+	t.NotEqual("127.0.0.1:0", debug.Address())
+	t.NoError(debug.Close())
+}
@@ -6,6 +6,7 @@ type Endpoint interface {
 	Serve()
 	Close() error
 	Type() EndpointType
+	ActiveConnections() int64
 }
 
 // EndpointType enumerates the supported endpoints.
@@ -17,6 +18,12 @@ const (
 
 	// EndpointNetwork represents the user endpoint accessible over https (on a different port to the user endpoint).
 	EndpointNetwork
+
+	// EndpointHealth represents the unauthenticated health endpoint accessible over http.
+	EndpointHealth
+
+	// EndpointDebugHTTP represents the unauthenticated, read-only debug endpoint accessible over http on loopback.
+	EndpointDebugHTTP
 )
 
 // String labels EndpointTypes for logging purposes.
@@ -26,6 +33,10 @@ func (et EndpointType) String() string {
 		return "control socket"
 	case EndpointNetwork:
 		return "https socket"
+	case EndpointHealth:
+		return "health socket"
+	case EndpointDebugHTTP:
+		return "debug http socket"
 	default:
 		return ""
 	}
@@ -13,17 +13,12 @@ type Endpoints struct {
 	mu          sync.RWMutex
 	shutdownCtx context.Context // Parent context for shutting down cleanly.
 
-	listeners map[EndpointType]Endpoint // Map of supported listeners.
+	listeners []Endpoint // List of supported listeners. More than one listener may share the same type.
 }
 
 // NewEndpoints aggregates the given endpoints so we can manage them from one source.
 func NewEndpoints(shutdownCtx context.Context, endpoints ...Endpoint) *Endpoints {
-	listeners := map[EndpointType]Endpoint{}
-	for _, endpoint := range endpoints {
-		listeners[endpoint.Type()] = endpoint
-	}
-
-	return &Endpoints{listeners: listeners, shutdownCtx: shutdownCtx}
+	return &Endpoints{listeners: endpoints, shutdownCtx: shutdownCtx}
 }
 
 // Up calls Serve on each of the configured listeners.
@@ -39,31 +34,47 @@ func (e *Endpoints) Up() error {
 	return nil
 }
 
-// UpdateTLS updates the TLS configuration of the network listeners.
+// UpdateTLS updates the TLS configuration of the network listeners, except those serving a dedicated certificate.
 func (e *Endpoints) UpdateTLS(cert *shared.CertInfo) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	for _, l := range e.listeners {
 		n, ok := l.(*Network)
-		if ok {
+		if ok && !n.DedicatedCert {
 			n.UpdateTLS(cert)
 		}
 	}
 }
 
-// Add calls Serve on the additional set of listeners, and adds them to Endpoints.
-func (e *Endpoints) Add(endpoints ...Endpoint) error {
-	newListeners := map[EndpointType]Endpoint{}
-	for _, endpoint := range endpoints {
-		newListeners[endpoint.Type()] = endpoint
-	}
+// List returns the listeners that are currently up.
+func (e *Endpoints) List() []Endpoint {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	for k, v := range newListeners {
-		e.listeners[k] = v
+	return append([]Endpoint{}, e.listeners...)
+}
+
+// ActiveConnections returns the total number of open connections across all listeners.
+func (e *Endpoints) ActiveConnections() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var total int64
+	for _, l := range e.listeners {
+		total += l.ActiveConnections()
 	}
 
-	err := e.up(newListeners)
+	return total
+}
+
+// Add calls Serve on the additional set of listeners, and adds them to Endpoints.
+func (e *Endpoints) Add(endpoints ...Endpoint) error {
+	e.mu.Lock()
+	e.listeners = append(e.listeners, endpoints...)
+	e.mu.Unlock()
+
+	err := e.up(endpoints)
 	if err != nil {
 		// Attempt to call Down() in case something actually got brought up.
 		_ = e.Down()
@@ -74,19 +85,17 @@ func (e *Endpoints) Add(endpoints ...Endpoint) error {
 	return nil
 }
 
-func (e *Endpoints) up(listeners map[EndpointType]Endpoint) error {
+func (e *Endpoints) up(listeners []Endpoint) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	// Startup listeners.
-	for key := range listeners {
-		err := listeners[key].Listen()
+	for _, listener := range listeners {
+		err := listener.Listen()
 		if err != nil {
 			return err
 		}
 
-		listener := listeners[key]
-
 		go func() {
 			select {
 			case <-e.shutdownCtx.Done():
@@ -103,11 +112,13 @@ func (e *Endpoints) up(listeners map[EndpointType]Endpoint) error {
 }
 
 // Down closes all of the configured listeners, or any for the type specifically supplied.
+// Closed listeners are removed from Endpoints.
 func (e *Endpoints) Down(types ...EndpointType) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	for _, listener := range e.listeners {
+	remaining := make([]Endpoint, 0, len(e.listeners))
+	for i, listener := range e.listeners {
 		remove := false
 		for _, endpoint := range types {
 			if listener.Type() == endpoint {
@@ -118,10 +129,18 @@ func (e *Endpoints) Down(types ...EndpointType) error {
 		if types == nil || remove {
 			err := listener.Close()
 			if err != nil {
+				e.listeners = append(remaining, e.listeners[i:]...)
+
 				return err
 			}
+
+			continue
 		}
+
+		remaining = append(remaining, listener)
 	}
 
+	e.listeners = remaining
+
 	return nil
 }
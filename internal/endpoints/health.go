@@ -0,0 +1,25 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+)
+
+// Health represents a plain HTTP listener and its server, serving only the health status of the daemon.
+// It requires no client certificate, so that load balancers can check the daemon without being trusted.
+type Health struct {
+	plainHTTP
+}
+
+// NewHealth assigns an address, drain timeouts, and server to the Health listener.
+func NewHealth(ctx context.Context, server *http.Server, address string, drain DrainTimeouts) *Health {
+	h := &Health{plainHTTP: newPlainHTTP(ctx, "health", server, address, drain)}
+	h.track(server)
+
+	return h
+}
+
+// Type returns the type of the Endpoint.
+func (h *Health) Type() EndpointType {
+	return EndpointHealth
+}
@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// ResolveInterfaceAddress returns the address of the named network interface to listen on with the port of addr.
+// - If addr has no IP, any global address of the interface may be used.
+// - If addr has an unspecified IP (0.0.0.0 or ::), only addresses of the same family are considered.
+// - If addr has a specific IP, it must belong to the interface.
+// An error is returned if the interface has more than one candidate address, so that the operator can pick one.
+func ResolveInterfaceAddress(name string, addr types.AddrPort) (types.AddrPort, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return types.AddrPort{}, fmt.Errorf("Failed to find network interface %q: %w", name, err)
+	}
+
+	ifaceAddrs, err := iface.Addrs()
+	if err != nil {
+		return types.AddrPort{}, fmt.Errorf("Failed to get addresses of network interface %q: %w", name, err)
+	}
+
+	candidates := []netip.Addr{}
+	for _, ifaceAddr := range ifaceAddrs {
+		ipNet, ok := ifaceAddr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		ip, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok {
+			continue
+		}
+
+		ip = ip.Unmap()
+		if addr.Addr().IsValid() && !addr.Addr().IsUnspecified() {
+			if ip == addr.Addr().Unmap() {
+				return addr, nil
+			}
+
+			continue
+		}
+
+		if ip.IsLinkLocalUnicast() {
+			continue
+		}
+
+		if addr.Addr().IsUnspecified() && ip.Is4() != addr.Addr().Unmap().Is4() {
+			continue
+		}
+
+		candidates = append(candidates, ip)
+	}
+
+	if addr.Addr().IsValid() && !addr.Addr().IsUnspecified() {
+		return types.AddrPort{}, fmt.Errorf("Address %q does not belong to network interface %q", addr.Addr(), name)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return types.AddrPort{}, fmt.Errorf("Network interface %q has no usable address", name)
+	case 1:
+		return types.AddrPort{AddrPort: netip.AddrPortFrom(candidates[0], addr.Port())}, nil
+	default:
+		ips := make([]string, 0, len(candidates))
+		for _, ip := range candidates {
+			ips = append(ips, ip.String())
+		}
+
+		return types.AddrPort{}, fmt.Errorf("Network interface %q has multiple addresses (%s), specify which one to use", name, strings.Join(ips, ", "))
+	}
+}
@@ -0,0 +1,41 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type interfaceSuite struct {
+	suite.Suite
+}
+
+func TestInterfaceSuite(t *testing.T) {
+	suite.Run(t, new(interfaceSuite))
+}
+
+// Ensures interface addresses are resolved according to the requested address family and IP.
+func (t *interfaceSuite) Test_resolveInterfaceAddress() {
+	parse := func(addr string) types.AddrPort {
+		addrPort, err := types.ParseAddrPort(addr)
+		t.Require().NoError(err)
+
+		return addrPort
+	}
+
+	addr, err := ResolveInterfaceAddress("lo", parse("0.0.0.0:9000"))
+	t.NoError(err)
+	t.Equal("127.0.0.1:9000", addr.String())
+
+	addr, err = ResolveInterfaceAddress("lo", parse("127.0.0.1:9000"))
+	t.NoError(err)
+	t.Equal("127.0.0.1:9000", addr.String())
+
+	_, err = ResolveInterfaceAddress("lo", parse("192.0.2.1:9000"))
+	t.Error(err)
+
+	_, err = ResolveInterfaceAddress("doesnotexist0", parse("0.0.0.0:9000"))
+	t.Error(err)
+}
@@ -0,0 +1,57 @@
+package endpoints
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/canonical/lxd/lxd/util"
+	"github.com/canonical/lxd/shared"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// mutableTLSListener is a TLS listener whose certificate can be swapped at runtime.
+type mutableTLSListener struct {
+	net.Listener
+
+	mu         sync.RWMutex
+	config     *tls.Config
+	tlsOptions types.TLSOptions
+}
+
+// newMutableTLSListener wraps the given listener with TLS, applying any hardening parameters in tlsOptions.
+func newMutableTLSListener(inner net.Listener, cert *shared.CertInfo, tlsOptions types.TLSOptions) *mutableTLSListener {
+	listener := &mutableTLSListener{
+		Listener:   inner,
+		tlsOptions: tlsOptions,
+	}
+
+	listener.Config(cert)
+
+	return listener
+}
+
+// Accept waits for and returns the next incoming TLS connection then use the current TLS configuration to handle it.
+func (l *mutableTLSListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return tls.Server(c, l.config), nil
+}
+
+// Config safely swaps the underlying TLS configuration, keeping any configured hardening parameters.
+func (l *mutableTLSListener) Config(cert *shared.CertInfo) {
+	config := util.ServerTLSConfig(cert)
+	l.tlsOptions.Apply(config)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+}
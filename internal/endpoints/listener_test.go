@@ -0,0 +1,100 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type listenerSuite struct {
+	suite.Suite
+}
+
+func TestListenerSuite(t *testing.T) {
+	suite.Run(t, new(listenerSuite))
+}
+
+// Ensures the minimum TLS version is enforced, including after the certificate is rotated.
+func (t *listenerSuite) Test_tlsMinVersion() {
+	cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Require().NoError(err)
+
+	listener := newMutableTLSListener(inner, cert, types.TLSOptions{MinVersion: tls.VersionTLS13})
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	dial := func(minVersion uint16, maxVersion uint16) error {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         minVersion,
+			MaxVersion:         maxVersion,
+		})
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	t.Error(dial(tls.VersionTLS10, tls.VersionTLS11))
+	t.Error(dial(tls.VersionTLS12, tls.VersionTLS12))
+	t.NoError(dial(tls.VersionTLS13, tls.VersionTLS13))
+
+	// Rotating the certificate must keep the hardening parameters.
+	listener.Config(cert)
+	t.Error(dial(tls.VersionTLS10, tls.VersionTLS11))
+	t.NoError(dial(tls.VersionTLS13, tls.VersionTLS13))
+}
+
+// Ensures rotating the certificate of the endpoints leaves listeners with a dedicated certificate untouched.
+func (t *listenerSuite) Test_updateTLSDedicated() {
+	clusterCert, err := shared.KeyPairAndCA(t.T().TempDir(), "cluster", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	dedicatedCert, err := shared.KeyPairAndCA(t.T().TempDir(), "dedicated", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	newCert, err := shared.KeyPairAndCA(t.T().TempDir(), "new", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	address := *api.NewURL().Host("127.0.0.1:0")
+	fallback := NewNetwork(context.Background(), EndpointNetwork, &http.Server{}, address, clusterCert, types.TLSOptions{}, DrainTimeouts{})
+	dedicated := NewNetwork(context.Background(), EndpointNetwork, &http.Server{}, address, dedicatedCert, types.TLSOptions{}, DrainTimeouts{})
+	dedicated.DedicatedCert = true
+
+	endpoints := NewEndpoints(context.Background(), fallback, dedicated)
+	for _, network := range []*Network{fallback, dedicated} {
+		t.Require().NoError(network.Listen())
+		defer network.Close()
+	}
+
+	endpoints.UpdateTLS(newCert)
+	t.Equal(newCert.Fingerprint(), fallback.TLS().Fingerprint())
+	t.Equal(dedicatedCert.Fingerprint(), dedicated.TLS().Fingerprint())
+
+	// Listeners report the address they are bound to.
+	t.Len(endpoints.List(), 2)
+	t.NotEqual("127.0.0.1:0", fallback.Address())
+}
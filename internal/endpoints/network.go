@@ -6,40 +6,55 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 
-	"github.com/canonical/lxd/lxd/endpoints/listeners"
 	"github.com/canonical/lxd/lxd/util"
 	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/rest/types"
 )
 
 // Network represents an HTTPS listener and its server.
 type Network struct {
+	// DedicatedCert is set on listeners that serve their own certificate, which UpdateTLS on Endpoints leaves as is.
+	DedicatedCert bool
+
 	address     api.URL
+	certMu      sync.RWMutex
 	cert        *shared.CertInfo
+	tlsOptions  types.TLSOptions
+	drain       DrainTimeouts
 	networkType EndpointType
 
 	listener net.Listener
 	server   *http.Server
+	connCounter
 
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-// NewNetwork assigns an address, certificate, and server to the Network.
-func NewNetwork(ctx context.Context, endpointType EndpointType, server *http.Server, address api.URL, cert *shared.CertInfo) *Network {
+// NewNetwork assigns an address, certificate, TLS hardening options, drain timeouts, and server to the Network.
+func NewNetwork(ctx context.Context, endpointType EndpointType, server *http.Server, address api.URL, cert *shared.CertInfo, tlsOptions types.TLSOptions, drain DrainTimeouts) *Network {
 	ctx, cancel := context.WithCancel(ctx)
 
-	return &Network{
+	n := &Network{
 		address:     address,
 		cert:        cert,
+		tlsOptions:  tlsOptions,
+		drain:       drain,
 		networkType: endpointType,
 
 		server: server,
 		ctx:    ctx,
 		cancel: cancel,
 	}
+
+	n.track(server)
+
+	return n
 }
 
 // Type returns the type of the Endpoint.
@@ -66,20 +81,40 @@ func (n *Network) Listen() error {
 		return fmt.Errorf("Failed to listen on https socket: %w", err)
 	}
 
-	n.listener = listeners.NewFancyTLSListener(listener, n.cert)
+	n.listener = newMutableTLSListener(&streamListener{Listener: listener, counter: &n.connCounter}, n.TLS(), n.tlsOptions)
 
 	return nil
 }
 
 // UpdateTLS updates the TLS configuration of the network listener.
 func (n *Network) UpdateTLS(cert *shared.CertInfo) {
-	l, ok := n.listener.(*listeners.FancyTLSListener)
+	l, ok := n.listener.(*mutableTLSListener)
 	if ok {
+		n.certMu.Lock()
 		n.cert = cert
+		n.certMu.Unlock()
+
 		l.Config(cert)
 	}
 }
 
+// TLS returns the certificate served by the network listener.
+func (n *Network) TLS() *shared.CertInfo {
+	n.certMu.RLock()
+	defer n.certMu.RUnlock()
+
+	return n.cert
+}
+
+// Address returns the address the network listener is bound to, or the configured address if it isn't listening.
+func (n *Network) Address() string {
+	if n.listener != nil {
+		return n.listener.Addr().String()
+	}
+
+	return n.address.URL.Host
+}
+
 // Serve binds to the Network's server.
 func (n *Network) Serve() {
 	if n.listener == nil {
@@ -116,5 +151,5 @@ func (n *Network) Close() error {
 	logger.Info("Stopping REST API handler - closing https socket", logger.Ctx{"address": n.listener.Addr()})
 	n.cancel()
 
-	return n.listener.Close()
+	return shutdownServer(n.server, n.listener, &n.connCounter, n.drain)
 }
@@ -0,0 +1,104 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/logger"
+)
+
+// plainHTTP represents a plain HTTP listener and its server, without TLS or client certificates.
+type plainHTTP struct {
+	name     string // Name of the socket in log messages and errors.
+	insecure bool   // Whether serving the socket is logged as a warning.
+	address  string
+	drain    DrainTimeouts
+
+	listener net.Listener
+	server   *http.Server
+	connCounter
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newPlainHTTP assigns a name, address, drain timeouts, and server to a plain HTTP listener.
+func newPlainHTTP(ctx context.Context, name string, server *http.Server, address string, drain DrainTimeouts) plainHTTP {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return plainHTTP{
+		name:    name,
+		address: address,
+		drain:   drain,
+
+		server: server,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Address returns the address the listener is bound to, or the configured address if it isn't listening.
+func (p *plainHTTP) Address() string {
+	if p.listener != nil {
+		return p.listener.Addr().String()
+	}
+
+	return p.address
+}
+
+// Listen on the given address.
+func (p *plainHTTP) Listen() error {
+	listener, err := net.Listen("tcp", p.address)
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %s socket: %w", p.name, err)
+	}
+
+	p.listener = listener
+
+	return nil
+}
+
+// Serve binds to the listener's server.
+func (p *plainHTTP) Serve() {
+	if p.listener == nil {
+		return
+	}
+
+	ctx := logger.Ctx{"network": p.listener.Addr()}
+	if p.insecure {
+		logger.Warn(fmt.Sprintf(" - binding insecure %s socket", p.name), ctx)
+	} else {
+		logger.Info(fmt.Sprintf(" - binding %s socket", p.name), ctx)
+	}
+
+	go func() {
+		select {
+		case <-p.ctx.Done():
+			logger.Infof("Received shutdown signal - aborting %s socket server startup", p.name)
+		default:
+			err := p.server.Serve(p.listener)
+			if err != nil {
+				select {
+				case <-p.ctx.Done():
+					logger.Infof("Received shutdown signal - aborting %s socket server startup", p.name)
+				default:
+					logger.Error("Failed to start server", logger.Ctx{"err": err})
+				}
+			}
+		}
+	}()
+}
+
+// Close the listener.
+func (p *plainHTTP) Close() error {
+	if p.listener == nil {
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("Stopping %s handler - closing %s socket", p.name, p.name), logger.Ctx{"address": p.listener.Addr()})
+	p.cancel()
+
+	return shutdownServer(p.server, p.listener, &p.connCounter, p.drain)
+}
@@ -2,12 +2,15 @@ package endpoints
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
@@ -19,8 +22,13 @@ type Socket struct {
 	Path  string
 	Group string
 
-	listener *net.UnixListener
-	server   *http.Server
+	// StrictGroup makes Listen fail if Group doesn't exist, instead of falling back to the process group.
+	StrictGroup bool
+
+	listener  *net.UnixListener
+	server    *http.Server
+	inherited bool
+	connCounter
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -29,7 +37,7 @@ type Socket struct {
 // NewSocket returns a Socket struct with no listener attached yet.
 func NewSocket(ctx context.Context, server *http.Server, path api.URL, group string) *Socket {
 	ctx, cancel := context.WithCancel(ctx)
-	return &Socket{
+	s := &Socket{
 		Path:  path.Hostname(),
 		Group: group,
 
@@ -37,6 +45,10 @@ func NewSocket(ctx context.Context, server *http.Server, path api.URL, group str
 		ctx:    ctx,
 		cancel: cancel,
 	}
+
+	s.track(server)
+
+	return s
 }
 
 // Type returns the type of the Endpoint.
@@ -44,8 +56,18 @@ func (s *Socket) Type() EndpointType {
 	return EndpointControl
 }
 
-// Listen on the unix socket path.
+// Listen on the unix socket path. If a listener for the path was passed by systemd socket activation, it is adopted
+// instead of creating a new socket. The file mode and ownership of an inherited socket are left to the systemd unit.
 func (s *Socket) Listen() error {
+	listener := systemdListener(s.Path)
+	if listener != nil {
+		logger.Info("Using socket passed by systemd", logger.Ctx{"socket": s.Path})
+		s.listener = listener
+		s.inherited = true
+
+		return nil
+	}
+
 	_, err := net.Dial("unix", s.Path)
 	if err == nil {
 		return fmt.Errorf("Unix socket at %q is already running", s.Path)
@@ -66,7 +88,7 @@ func (s *Socket) Listen() error {
 		return fmt.Errorf("Cannot bind socket: %w", err)
 	}
 
-	err = localSetAccess(s.Path, s.Group)
+	err = localSetAccess(s.Path, s.Group, s.StrictGroup)
 	if err != nil {
 		closeErr := s.listener.Close()
 		if closeErr != nil {
@@ -125,6 +147,11 @@ func (s *Socket) removeStale() error {
 		return nil
 	}
 
+	// Never delete a socket owned by systemd, as it keeps listening on it across restarts of the daemon.
+	if s.inherited {
+		return nil
+	}
+
 	logger.Debugf("Detected stale control socket, deleting")
 	err := os.Remove(s.Path)
 	if err != nil {
@@ -134,16 +161,70 @@ func (s *Socket) removeStale() error {
 	return nil
 }
 
+// systemdFiles holds the file descriptors passed by systemd socket activation. They are kept open for the lifetime of
+// the process so that they are inherited again if the daemon re-executes itself.
+var systemdFiles struct {
+	once  sync.Once
+	files []*os.File
+}
+
+// systemdListener returns the unix listener passed by systemd socket activation for the given path, or nil if there
+// is none.
+//
+// It honors the sd_listen_fds(3) contract: if LISTEN_PID matches the current process, LISTEN_FDS file descriptors
+// starting at 3 were passed by systemd. Descriptors that are not unix stream sockets bound to the given path are
+// ignored. The environment variables are left in place, as the process keeps its PID and the inherited descriptors
+// when it re-executes itself.
+func systemdListener(path string) *net.UnixListener {
+	systemdFiles.once.Do(func() {
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+
+		count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || count <= 0 {
+			return
+		}
+
+		for fd := 3; fd < 3+count; fd++ {
+			systemdFiles.files = append(systemdFiles.files, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+		}
+	})
+
+	for _, file := range systemdFiles.files {
+		// FileListener duplicates the descriptor, so closing the listener leaves the inherited one open.
+		listener, err := net.FileListener(file)
+		if err != nil {
+			continue
+		}
+
+		unixListener, ok := listener.(*net.UnixListener)
+		if !ok || filepath.Clean(unixListener.Addr().String()) != filepath.Clean(path) {
+			_ = listener.Close()
+			continue
+		}
+
+		// Leave the socket file in place when the listener is closed, as systemd still owns it.
+		unixListener.SetUnlinkOnClose(false)
+
+		return unixListener
+	}
+
+	return nil
+}
+
 // Change the file mode and ownership of the local endpoint control socket file,
 // so access is granted only to the process user and to the given group (or the
-// process group if group is empty).
-func localSetAccess(path string, group string) error {
+// process group if group is empty). If strict is false, the process group is
+// also used if the given group doesn't exist.
+func localSetAccess(path string, group string, strict bool) error {
 	err := socketControlSetPermissions(path, 0660)
 	if err != nil {
 		return err
 	}
 
-	err = socketControlSetOwnership(path, group)
+	err = socketControlSetOwnership(path, group, strict)
 	if err != nil {
 		return err
 	}
@@ -161,23 +242,25 @@ func socketControlSetPermissions(path string, mode os.FileMode) error {
 	return nil
 }
 
-// Change the ownership of the given control socket file.
-func socketControlSetOwnership(path string, groupName string) error {
-	var gid int
+// Change the ownership of the given control socket file. Unless strict is set, a
+// group that doesn't exist, for example because it is only created later by the
+// package installing the daemon, is replaced by the process group.
+func socketControlSetOwnership(path string, groupName string, strict bool) error {
+	gid := os.Getgid()
 	var err error
 
 	if groupName != "" {
 		g, err := user.LookupGroup(groupName)
-		if err != nil {
+		if err == nil {
+			gid, err = strconv.Atoi(g.Gid)
+			if err != nil {
+				return err
+			}
+		} else if strict || !errors.As(err, new(user.UnknownGroupError)) {
 			return fmt.Errorf("Cannot get group ID of '%s': %w", groupName, err)
+		} else {
+			logger.Warn("Socket group does not exist, using the process group instead", logger.Ctx{"socket": path, "group": groupName})
 		}
-
-		gid, err = strconv.Atoi(g.Gid)
-		if err != nil {
-			return err
-		}
-	} else {
-		gid = os.Getgid()
 	}
 
 	err = os.Chown(path, os.Getuid(), gid)
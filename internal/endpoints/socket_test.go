@@ -0,0 +1,97 @@
+package endpoints
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+)
+
+type socketSuite struct {
+	suite.Suite
+}
+
+func TestSocketSuite(t *testing.T) {
+	suite.Run(t, new(socketSuite))
+}
+
+// Ensures a missing socket group falls back to the process group, unless strict mode is enabled.
+func (t *socketSuite) Test_socketControlSetOwnership() {
+	path := filepath.Join(t.T().TempDir(), "control.socket")
+	t.Require().NoError(os.WriteFile(path, nil, 0660))
+
+	missingGroup := "microcluster-missing-group"
+	t.Error(socketControlSetOwnership(path, missingGroup, true))
+	t.NoError(socketControlSetOwnership(path, missingGroup, false))
+
+	info, err := os.Stat(path)
+	t.Require().NoError(err)
+	t.Equal(uint32(os.Getgid()), info.Sys().(*syscall.Stat_t).Gid)
+}
+
+// Ensures the connections open to a socket are counted until they are closed or hijacked.
+func (t *socketSuite) Test_activeConnections() {
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hijack" {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})}
+
+	path := filepath.Join(t.T().TempDir(), "control.socket")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	socket := NewSocket(ctx, server, *api.NewURL().Scheme("http").Host(path), "")
+	endpoints := NewEndpoints(ctx, socket)
+	t.Require().NoError(endpoints.Up())
+	defer func() { t.NoError(endpoints.Down()) }()
+
+	newClient := func() *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+	}
+
+	get := func(c *http.Client, endpoint string) {
+		resp, err := c.Get("http://control.socket" + endpoint)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+
+	active := func(count int64) {
+		t.Eventually(func() bool { return endpoints.ActiveConnections() == count }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	first := newClient()
+	second := newClient()
+	get(first, "/")
+	get(second, "/")
+	active(2)
+	t.Equal(socket.ActiveConnections(), endpoints.ActiveConnections())
+
+	first.CloseIdleConnections()
+	active(1)
+
+	get(newClient(), "/hijack")
+	active(1)
+
+	second.CloseIdleConnections()
+	active(0)
+}
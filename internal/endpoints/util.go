@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+)
+
+// DrainTimeouts configures how long a network listener waits for each class of open connection when it is closed.
+//
+// Short-lived connections carry regular API requests and are tracked by the http.Server itself. Long-lived
+// connections, such as change feed websockets and dqlite connections, are hijacked from the http.Server on upgrade,
+// so they are instead tracked from the moment they are hijacked until their owner closes them.
+//
+// A zero timeout leaves that class of connection open to finish on its own once the listener is closed.
+type DrainTimeouts struct {
+	// Requests is how long to wait for in-flight API requests to complete before closing their connections.
+	Requests time.Duration
+
+	// Streams is how long to wait for hijacked connections to be closed before closing them.
+	Streams time.Duration
+}
+
+// shutdownServer stops the listener from accepting new connections, then drains the short-lived and long-lived
+// connections of the server concurrently, each within its own timeout, before closing any that remain.
+func shutdownServer(server *http.Server, listener net.Listener, counter *connCounter, timeouts DrainTimeouts) error {
+	err := listener.Close()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	if timeouts.Streams > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			drainStreams(counter, timeouts.Streams)
+		}()
+	}
+
+	defer wg.Wait()
+
+	if server == nil || timeouts.Requests <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Requests)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Warn("Timed out draining API requests, closing remaining connections", logger.Ctx{"timeout": timeouts.Requests})
+		err = server.Close()
+	}
+
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// drainStreams waits for the hijacked connections tracked by the counter to be closed, closing any that are still
+// open once the timeout elapses.
+func drainStreams(counter *connCounter, timeout time.Duration) {
+	deadline := time.After(timeout)
+	for counter.activeStreams() > 0 {
+		select {
+		case <-deadline:
+			logger.Warn("Timed out draining streaming connections, closing remaining connections", logger.Ctx{"timeout": timeout, "connections": counter.activeStreams()})
+			counter.closeStreams()
+
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package endpoints
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type utilSuite struct {
+	suite.Suite
+}
+
+func TestUtilSuite(t *testing.T) {
+	suite.Run(t, new(utilSuite))
+}
+
+// Ensures hijacked connections are tracked as streams and closed once their drain timeout elapses.
+func (t *utilSuite) Test_shutdownServerStreams() {
+	counter := &connCounter{}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Hijack the connection and leave it open, as a dqlite or websocket connection would.
+			_, _, err := w.(http.Hijacker).Hijack()
+			t.NoError(err)
+		}),
+	}
+
+	counter.track(server)
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Require().NoError(err)
+
+	listener := &streamListener{Listener: inner, counter: counter}
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	t.Require().NoError(err)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	t.Require().NoError(err)
+
+	t.Eventually(func() bool { return counter.activeStreams() == 1 }, 5*time.Second, 10*time.Millisecond)
+	t.Equal(int64(1), counter.ActiveConnections())
+
+	start := time.Now()
+	err = shutdownServer(server, listener, counter, DrainTimeouts{Requests: 50 * time.Millisecond, Streams: 200 * time.Millisecond})
+	t.NoError(err)
+
+	// The stream was given its own grace period before being closed.
+	t.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+	t.Equal(0, counter.activeStreams())
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	t.ErrorIs(err, io.EOF)
+}
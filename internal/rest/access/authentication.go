@@ -10,11 +10,37 @@ import (
 // TrustedRequest holds data pertaining to what level of trust we have for the request.
 type TrustedRequest struct {
 	Trusted bool
+
+	// Identity of the caller. It is nil if the caller presented no certificate and didn't use the unix socket.
+	Identity *Identity
+}
+
+// LocalPeerName is the name of the caller for requests over the unix socket.
+const LocalPeerName = "local"
+
+// Identity holds information about the caller of a request.
+// Access handlers may fill in Roles and Extra for the main handler to make finer-grained authorization decisions.
+type Identity struct {
+	// Name of the cluster member that made the request, or LocalPeerName for requests over the unix socket.
+	// It is empty if the caller is not a cluster member.
+	Name string
+
+	// CommonName is the common name of the caller's TLS certificate.
+	CommonName string
+
+	// Fingerprint is the fingerprint of the caller's TLS certificate. It is empty for requests over the unix socket.
+	Fingerprint string
+
+	// Roles held by the caller, as determined by an access handler.
+	Roles []string
+
+	// Extra holds arbitrary data set by an access handler.
+	Extra any
 }
 
-// SetRequestAuthentication sets the trusted status for the request. A trusted request will be treated as having come from a trusted system.
-func SetRequestAuthentication(r *http.Request, trusted bool) *http.Request {
-	r = r.WithContext(context.WithValue(r.Context(), any(request.CtxAccess), TrustedRequest{Trusted: trusted}))
+// SetRequestAuthentication sets the trusted status and caller identity for the request. A trusted request will be treated as having come from a trusted system.
+func SetRequestAuthentication(r *http.Request, trusted bool, identity *Identity) *http.Request {
+	r = r.WithContext(context.WithValue(r.Context(), any(request.CtxAccess), TrustedRequest{Trusted: trusted, Identity: identity}))
 
 	return r
 }
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+)
+
+// accessLogWriter records the status code written by a handler.
+type accessLogWriter struct {
+	http.ResponseWriter
+
+	status   int
+	hijacked bool
+}
+
+// WriteHeader records the status code before writing it.
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 status code if none was written yet.
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *accessLogWriter) Flush() {
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so that websocket and database connections can still be hijacked.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Webserver does not support hijacking")
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+// DefaultAccessLogExcludedPaths are the paths of frequent requests between cluster members, the dqlite connection
+// upgrade and heartbeats, that are left out of the access log by default.
+var DefaultAccessLogExcludedPaths = []string{
+	"/" + string(internalTypes.InternalEndpoint) + "/database",
+	"/" + string(internalTypes.InternalEndpoint) + "/heartbeat",
+}
+
+// AccessLog wraps the handler to log the method, path, status, duration, peer name and peer certificate fingerprint
+// of each request once it completes. Requests to any of the excluded paths aren't logged. Other requests from cluster
+// members are logged at debug level so that they don't drown out requests from clients.
+func AccessLog(s *state.State, excludedPaths []string, next http.Handler) http.Handler {
+	excluded := make(map[string]bool, len(excludedPaths))
+	for _, path := range excludedPaths {
+		excluded[path] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if excluded[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		writer := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
+
+		ctx := logger.Ctx{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   writer.status,
+			"duration": time.Since(start),
+			"remote":   r.RemoteAddr,
+		}
+
+		if writer.hijacked {
+			ctx["hijacked"] = true
+		}
+
+		identity := requestIdentity(s, r)
+		if identity != nil && identity.Fingerprint != "" {
+			ctx["fingerprint"] = identity.Fingerprint
+		}
+
+		if identity != nil && identity.Name != "" {
+			ctx["peer"] = identity.Name
+		}
+
+		if identity != nil && identity.Name != "" && identity.Fingerprint != "" {
+			logger.Debug("API request", ctx)
+		} else {
+			logger.Info("API request", ctx)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/gorilla/websocket"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+	apiTypes "github.com/canonical/microcluster/rest/types"
+)
+
+// WatchTable calls handler for each change committed to the given table through the cluster member targeted by
+// this client. It blocks until the context is cancelled, in which case it returns nil, or the connection fails.
+func (c *Client) WatchTable(ctx context.Context, table string, handler func(apiTypes.ChangeEvent)) error {
+	conn, err := c.dialWebsocket(ctx, nil, "changes", table)
+	if err != nil {
+		return fmt.Errorf("Failed to watch table %q: %w", table, err)
+	}
+
+	return readWebsocket(ctx, conn, func() error {
+		event := apiTypes.ChangeEvent{}
+		err := conn.ReadJSON(&event)
+		if err != nil {
+			return fmt.Errorf("Failed to read change event: %w", err)
+		}
+
+		handler(event)
+
+		return nil
+	})
+}
+
+// WatchEvents calls handler for each cluster lifecycle event of the given types, or of all types if none are given,
+// published by the cluster member targeted by this client. It blocks until the context is cancelled, in which case it
+// returns nil, or the connection fails.
+func (c *Client) WatchEvents(ctx context.Context, handler func(apiTypes.Event), eventTypes ...apiTypes.EventType) error {
+	var query url.Values
+	if len(eventTypes) > 0 {
+		typeNames := make([]string, 0, len(eventTypes))
+		for _, eventType := range eventTypes {
+			typeNames = append(typeNames, string(eventType))
+		}
+
+		query = url.Values{"type": []string{strings.Join(typeNames, ",")}}
+	}
+
+	conn, err := c.dialWebsocket(ctx, query, "events")
+	if err != nil {
+		return fmt.Errorf("Failed to watch events: %w", err)
+	}
+
+	return readWebsocket(ctx, conn, func() error {
+		event := apiTypes.Event{}
+		err := conn.ReadJSON(&event)
+		if err != nil {
+			return fmt.Errorf("Failed to read event: %w", err)
+		}
+
+		handler(event)
+
+		return nil
+	})
+}
+
+// dialWebsocket opens a websocket connection to the given path under the public endpoint of the cluster member
+// targeted by this client.
+func (c *Client) dialWebsocket(ctx context.Context, query url.Values, path ...string) (*websocket.Conn, error) {
+	transport, ok := c.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("Client transport does not support websockets")
+	}
+
+	dialer := websocket.Dialer{
+		NetDialContext:    transport.DialContext,
+		NetDialTLSContext: transport.DialTLSContext,
+		TLSClientConfig:   transport.TLSClientConfig,
+	}
+
+	parts := strings.Split(string(types.PublicEndpoint), "/")
+	parts = append(parts, path...)
+	watchURL := api.NewURL().Host(c.url.URL.Host).Path(parts...)
+	watchURL.URL.RawQuery = query.Encode()
+	watchURL.URL.Scheme = "wss"
+	if c.url.URL.Scheme == "http" {
+		watchURL.URL.Scheme = "ws"
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, watchURL.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (%s)", err, resp.Status)
+		}
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// readWebsocket calls read until it fails or the context is cancelled, in which case it returns nil.
+// The connection is closed once it returns.
+func readWebsocket(ctx context.Context, conn *websocket.Conn, read func() error) error {
+	defer conn.Close()
+
+	// Close the connection once the context is cancelled to unblock the read below.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		err := read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
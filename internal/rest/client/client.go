@@ -3,8 +3,10 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -30,10 +32,15 @@ import (
 type Client struct {
 	*http.Client
 	url api.URL
+
+	notification bool       // Whether requests are sent with the forwarding headers of a cluster notification.
+	evict        func()     // Removes the client from its Pool, if it was created by one.
+	etags        *etagCache // Responses to GET requests, kept to send them again as conditional requests.
 }
 
-// New returns a new client configured with the given url and certificates.
-func New(url api.URL, clientCert *shared.CertInfo, remoteCert *x509.Certificate, forwarding bool) (*Client, error) {
+// New returns a new client configured with the given url and certificates. Any trusted certificates are trusted
+// alongside the remote certificate.
+func New(url api.URL, clientCert *shared.CertInfo, remoteCert *x509.Certificate, forwarding bool, trusted ...*x509.Certificate) (*Client, error) {
 	var err error
 	var httpClient *http.Client
 
@@ -47,7 +54,7 @@ func New(url api.URL, clientCert *shared.CertInfo, remoteCert *x509.Certificate,
 			proxy = forwardingProxy
 		}
 
-		httpClient, err = tlsHTTPClient(clientCert, remoteCert, proxy)
+		httpClient, err = tlsHTTPClient(clientCert, remoteCert, proxy, trusted...)
 	}
 
 	if err != nil {
@@ -55,8 +62,10 @@ func New(url api.URL, clientCert *shared.CertInfo, remoteCert *x509.Certificate,
 	}
 
 	return &Client{
-		Client: httpClient,
-		url:    url,
+		Client:       httpClient,
+		url:          url,
+		notification: forwarding,
+		etags:        newETagCache(),
 	}, nil
 }
 
@@ -92,11 +101,11 @@ func unixHTTPClient(path string) (*http.Client, error) {
 	return client, nil
 }
 
-func tlsHTTPClient(clientCert *shared.CertInfo, remoteCert *x509.Certificate, proxy func(req *http.Request) (*url.URL, error)) (*http.Client, error) {
+func tlsHTTPClient(clientCert *shared.CertInfo, remoteCert *x509.Certificate, proxy func(req *http.Request) (*url.URL, error), trusted ...*x509.Certificate) (*http.Client, error) {
 	var tlsConfig *tls.Config
 	if remoteCert != nil {
 		var err error
-		tlsConfig, err = TLSClientConfig(clientCert, remoteCert)
+		tlsConfig, err = TLSClientConfig(clientCert, remoteCert, trusted...)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to parse TLS config: %w", err)
 		}
@@ -162,8 +171,19 @@ func tlsHTTPClient(clientCert *shared.CertInfo, remoteCert *x509.Certificate, pr
 }
 
 // SetClusterNotification sets the client's proxy to apply the forwarding headers to a request.
+// The transport is copied first, as it may be shared with other clients.
 func (c *Client) SetClusterNotification() {
-	c.Transport.(*http.Transport).Proxy = forwardingProxy
+	if c.notification {
+		return
+	}
+
+	transport := c.Transport.(*http.Transport).Clone()
+	transport.Proxy = forwardingProxy
+	transport.DisableKeepAlives = true
+
+	c.Client = &http.Client{Transport: transport, CheckRedirect: c.CheckRedirect}
+	c.notification = true
+	c.evict = nil
 }
 
 func forwardingProxy(r *http.Request) (*url.URL, error) {
@@ -243,6 +263,22 @@ func (c *Client) rawQuery(ctx context.Context, method string, url *api.URL, data
 		}
 	}
 
+	// Tag the request so that it can be traced across cluster members.
+	requestID := RequestID(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+
+	req.Header.Set(RequestIDHeader, requestID)
+
+	key := IdempotencyKey(ctx)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	// Only send the data again if it changed since the last identical request.
+	c.etags.prepare(req)
+
 	return c.MakeRequest(req)
 }
 
@@ -251,14 +287,27 @@ func (c *Client) MakeRequest(r *http.Request) (*api.Response, error) {
 	// Send the request
 	resp, err := c.Do(r)
 	if err != nil {
+		// Don't let other requests reuse the connections of a failing client.
+		if c.evict != nil {
+			c.evict()
+		}
+
 		return nil, err
 	}
 
+	if resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+
+		return c.etags.cached(r, resp)
+	}
+
 	parsedResponse, err := parseResponse(resp)
 	if err != nil {
 		return nil, err
 	}
 
+	c.etags.store(r, resp, parsedResponse)
+
 	defer resp.Body.Close()
 	_, err = io.Copy(io.Discard, resp.Body)
 	if err != nil {
@@ -273,6 +322,97 @@ func (c *Client) MakeRequest(r *http.Request) (*api.Response, error) {
 //
 // The final URL is that provided as the endpoint combined with the applicable prefix for the endpointType and the scheme and host from the client.
 func (c *Client) QueryStruct(ctx context.Context, method string, endpointType types.EndpointPrefix, endpoint *api.URL, data any, target any) error {
+	localURL := c.endpointURL(endpointType, endpoint)
+
+	// Send the actual query through.
+	resp, err := c.rawQuery(ctx, method, localURL, data)
+	if err != nil {
+		return err
+	}
+
+	// Unpack into the target struct.
+	err = resp.MetadataAsStruct(&target)
+	if err != nil {
+		return err
+	}
+
+	// Log the data.
+	logger.Debug("Got response struct from microcluster daemon", logger.Ctx{"endpoint": localURL.String(), "method": method})
+	// TODO: Log.pretty.
+	return nil
+}
+
+// ChecksumTrailer is the HTTP trailer carrying the hex-encoded SHA-256 checksum of a streamed response body.
+const ChecksumTrailer = "X-Microcluster-Checksum"
+
+// QueryRaw sends a request of the specified method to the provided endpoint (optional) on the API matching the
+// endpointType, and streams the response body to w instead of unpacking it. Unlike QueryStruct, no timeout is applied
+// to the request beyond that of the given context, as the body may be large.
+//
+// If the response declares a ChecksumTrailer, the SHA-256 checksum of the body is verified against it once the body
+// has been read. An error is returned if it doesn't match, or if the trailer is missing because the server failed
+// part way through the response.
+func (c *Client) QueryRaw(ctx context.Context, method string, endpointType types.EndpointPrefix, endpoint *api.URL, w io.Writer) error {
+	localURL := c.endpointURL(endpointType, endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, method, localURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	requestID := RequestID(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+
+	req.Header.Set(RequestIDHeader, requestID)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		if c.evict != nil {
+			c.evict()
+		}
+
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, err := parseResponse(resp)
+		if err != nil {
+			return err
+		}
+
+		return fmt.Errorf("Failed to fetch %q: %q", localURL.String(), resp.Status)
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(w, hash), resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read response body: %w", err)
+	}
+
+	_, declared := resp.Trailer[http.CanonicalHeaderKey(ChecksumTrailer)]
+	if !declared {
+		return nil
+	}
+
+	checksum := resp.Trailer.Get(ChecksumTrailer)
+	if checksum == "" {
+		return fmt.Errorf("Response from %q is incomplete: missing checksum", localURL.String())
+	}
+
+	if checksum != hex.EncodeToString(hash.Sum(nil)) {
+		return fmt.Errorf("Response from %q is corrupt: checksum mismatch", localURL.String())
+	}
+
+	return nil
+}
+
+// endpointURL returns the URL of the provided endpoint (optional) on the API matching the endpointType, combined with
+// the scheme, host and query of the client.
+func (c *Client) endpointURL(endpointType types.EndpointPrefix, endpoint *api.URL) *api.URL {
 	// Merge the provided URL with the one we have for the client.
 	localURL := api.NewURL()
 	if endpoint != nil {
@@ -294,22 +434,7 @@ func (c *Client) QueryStruct(ctx context.Context, method string, endpointType ty
 
 	localURL.URL.RawQuery = clientQuery.Encode()
 
-	// Send the actual query through.
-	resp, err := c.rawQuery(ctx, method, localURL, data)
-	if err != nil {
-		return err
-	}
-
-	// Unpack into the target struct.
-	err = resp.MetadataAsStruct(&target)
-	if err != nil {
-		return err
-	}
-
-	// Log the data.
-	logger.Debug("Got response struct from microcluster daemon", logger.Ctx{"endpoint": localURL.String(), "method": method})
-	// TODO: Log.pretty.
-	return nil
+	return localURL
 }
 
 // URL returns the address used for the client.
@@ -327,7 +452,10 @@ func (c *Client) UseTarget(name string) *Client {
 	localURL = localURL.WithQuery("target", name)
 
 	return &Client{
-		Client: c.Client,
-		url:    *localURL,
+		Client:       c.Client,
+		url:          *localURL,
+		notification: c.notification,
+		evict:        c.evict,
+		etags:        c.etags,
 	}
 }
@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"strconv"
 	"time"
 
+	dqliteClient "github.com/canonical/go-dqlite/client"
 	"github.com/canonical/lxd/shared/api"
 
 	"github.com/canonical/microcluster/internal/rest/types"
@@ -35,6 +37,131 @@ func (c *Client) GetClusterMembers(ctx context.Context) ([]types.ClusterMember,
 	return clusterMembers, err
 }
 
+// GetLeader returns the cluster member that is currently the dqlite leader. While a leader election is in progress,
+// the request fails with a 503 status, and can be retried shortly after.
+func (c *Client) GetLeader(ctx context.Context) (*types.ClusterLeader, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	leader := types.ClusterLeader{}
+	err := c.QueryStruct(queryCtx, "GET", types.PublicEndpoint, api.NewURL().Path("leader"), nil, &leader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &leader, nil
+}
+
+// GetStaleMembers returns the database record of cluster members whose last successful heartbeat is older than the
+// given duration, including those that have never received one. Heartbeat times are set by the leader, so the
+// result is subject to clock skew between the leader and the caller.
+func (c *Client) GetStaleMembers(ctx context.Context, olderThan time.Duration) ([]types.ClusterMember, error) {
+	clusterMembers, err := c.GetClusterMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return staleMembers(clusterMembers, time.Now().Add(-olderThan)), nil
+}
+
+// staleMembers returns the cluster members whose last successful heartbeat is before the given cutoff.
+func staleMembers(clusterMembers []types.ClusterMember, cutoff time.Time) []types.ClusterMember {
+	stale := []types.ClusterMember{}
+	for _, clusterMember := range clusterMembers {
+		if clusterMember.LastHeartbeat.Before(cutoff) {
+			stale = append(stale, clusterMember)
+		}
+	}
+
+	return stale
+}
+
+// GetClusterMembersPage returns the database record of the cluster members on the page selected by the filter,
+// along with the total number of cluster members matching the filter.
+func (c *Client) GetClusterMembersPage(ctx context.Context, filter types.ClusterMembersFilter) (*types.ClusterMembersPage, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("cluster").WithQuery("paged", "1").WithQuery("limit", strconv.Itoa(filter.Limit)).WithQuery("offset", strconv.Itoa(filter.Offset))
+	if filter.Role != "" {
+		endpoint = endpoint.WithQuery("role", filter.Role)
+	}
+
+	if filter.Status != "" {
+		endpoint = endpoint.WithQuery("status", string(filter.Status))
+	}
+
+	page := types.ClusterMembersPage{}
+	err := c.QueryStruct(queryCtx, "GET", types.PublicEndpoint, endpoint, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// ClusterStatus returns a summary of the health of the cluster, built from the database record of cluster members.
+// Members that could not be reached are listed as offline, and the leader is left empty if it could not be found.
+func (c *Client) ClusterStatus(ctx context.Context) (*types.ClusterStatus, error) {
+	clusterMembers, err := c.GetClusterMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusterStatus(clusterMembers), nil
+}
+
+// clusterStatus summarizes the health of the cluster from the given cluster members.
+func clusterStatus(clusterMembers []types.ClusterMember) *types.ClusterStatus {
+	status := types.ClusterStatus{
+		Behind:  []string{},
+		Offline: []string{},
+		Members: clusterMembers,
+	}
+
+	var maxInternal, maxExternal uint64
+	var maxExtensions int
+	for _, member := range clusterMembers {
+		if member.SchemaInternalVersion > maxInternal {
+			maxInternal = member.SchemaInternalVersion
+		}
+
+		if member.SchemaExternalVersion > maxExternal {
+			maxExternal = member.SchemaExternalVersion
+		}
+
+		if member.Extensions.Version() > maxExtensions {
+			maxExtensions = member.Extensions.Version()
+		}
+	}
+
+	for _, member := range clusterMembers {
+		if member.Leader {
+			status.Leader = member.Name
+		}
+
+		online := member.Status == types.MemberOnline
+		if !online {
+			status.Offline = append(status.Offline, member.Name)
+		}
+
+		if member.Role == dqliteClient.Voter.String() {
+			status.Voters++
+			if online {
+				status.OnlineVoters++
+			}
+		}
+
+		if member.SchemaInternalVersion < maxInternal || member.SchemaExternalVersion < maxExternal || member.Extensions.Version() < maxExtensions {
+			status.Behind = append(status.Behind, member.Name)
+		}
+	}
+
+	status.HasQuorum = status.Voters > 0 && status.OnlineVoters > status.Voters/2
+
+	return &status
+}
+
 // DeleteClusterMember deletes the cluster member with the given name.
 func (c *Client) DeleteClusterMember(ctx context.Context, name string, force bool) error {
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
@@ -48,6 +175,110 @@ func (c *Client) DeleteClusterMember(ctx context.Context, name string, force boo
 	return c.QueryStruct(queryCtx, "DELETE", types.PublicEndpoint, endpoint, nil, nil)
 }
 
+// Leave removes the cluster member the client is connected to from the cluster. The request must be sent to the
+// control socket of that member, which is reset and restarts once it has been removed. If force is set, the member is
+// removed even if its PreRemove hook fails.
+func (c *Client) Leave(ctx context.Context, force bool) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("cluster", "leave")
+	if force {
+		endpoint = endpoint.WithQuery("force", "1")
+	}
+
+	return c.QueryStruct(queryCtx, "POST", types.ControlEndpoint, endpoint, nil, nil)
+}
+
+// Reinitialize resets the cluster member the client is connected to, so that it can be bootstrapped or join a cluster
+// anew. The request must be sent to the control socket of that member, which clears its state directory and restarts.
+// A member that is still part of a cluster with other members is only reset if force is set, which also ignores a
+// failure of its PreRemove hook.
+func (c *Client) Reinitialize(ctx context.Context, force bool) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("reinitialize")
+	if force {
+		endpoint = endpoint.WithQuery("force", "1")
+	}
+
+	return c.QueryStruct(queryCtx, "POST", types.ControlEndpoint, endpoint, nil, nil)
+}
+
+// RemoveClusterMembers removes the cluster members with the given names, in an order that keeps quorum for as long
+// as possible, with the dqlite leader removed last. The member the client is connected to can't be among them.
+func (c *Client) RemoveClusterMembers(ctx context.Context, names []string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(len(names))*30*time.Second)
+	defer cancel()
+
+	args := types.ClusterMembersRemoval{Names: names}
+
+	return c.QueryStruct(queryCtx, "DELETE", types.PublicEndpoint, api.NewURL().Path("cluster"), args, nil)
+}
+
+// SoftDeleteClusterMember marks the cluster member with the given name as removed.
+// The member can be restored with RestoreClusterMember until the grace period elapses.
+func (c *Client) SoftDeleteClusterMember(ctx context.Context, name string, gracePeriod time.Duration) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := types.ClusterMemberRemoval{GracePeriod: gracePeriod}
+
+	return c.QueryStruct(queryCtx, "POST", types.PublicEndpoint, api.NewURL().Path("cluster", name, "removal"), args, nil)
+}
+
+// RestoreClusterMember undoes the soft-deletion of the cluster member with the given name.
+func (c *Client) RestoreClusterMember(ctx context.Context, name string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "DELETE", types.PublicEndpoint, api.NewURL().Path("cluster", name, "removal"), nil, nil)
+}
+
+// DrainClusterMember starts draining the cluster member with the given name before its removal. The member becomes
+// read-only, hands over dqlite leadership, and runs its OnDrain hook in the background. It can only be removed once
+// the hook has completed, which GetClusterMembers reports as the MemberDrained drain state.
+func (c *Client) DrainClusterMember(ctx context.Context, name string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "POST", types.PublicEndpoint, api.NewURL().Path("cluster", name, "drain"), nil, nil)
+}
+
+// CancelClusterMemberDrain cancels the drain of the cluster member with the given name. Its OnDrain hook is
+// cancelled if it is still running, and the member accepts writes again.
+func (c *Client) CancelClusterMemberDrain(ctx context.Context, name string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "DELETE", types.PublicEndpoint, api.NewURL().Path("cluster", name, "drain"), nil, nil)
+}
+
+// SetClusterMemberMaintenance puts the cluster member with the given name into maintenance mode, or takes it out of
+// it. A cluster member in maintenance mode stays in the cluster and keeps serving requests, but hands over dqlite
+// leadership and doesn't begin heartbeat rounds. GetClusterMembers reports which members are in maintenance mode.
+func (c *Client) SetClusterMemberMaintenance(ctx context.Context, name string, maintenance bool) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	method := "DELETE"
+	if maintenance {
+		method = "POST"
+	}
+
+	return c.QueryStruct(queryCtx, method, types.PublicEndpoint, api.NewURL().Path("cluster", name, "maintenance"), nil, nil)
+}
+
+// UpdateClusterMember applies a partial update to the cluster member with the given name.
+// Only the fields that are set in the patch are sent and changed.
+func (c *Client) UpdateClusterMember(ctx context.Context, name string, patch types.ClusterMemberPatch) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "PATCH", types.PublicEndpoint, api.NewURL().Path("cluster", name), patch, nil)
+}
+
 // ResetClusterMember clears the state directory of the cluster member, and re-execs its daemon.
 func (c *Client) ResetClusterMember(ctx context.Context, name string, force bool) error {
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
@@ -61,6 +292,27 @@ func (c *Client) ResetClusterMember(ctx context.Context, name string, force bool
 	return c.QueryStruct(queryCtx, "PUT", types.PublicEndpoint, endpoint, nil, nil)
 }
 
+// UpdateClusterMemberAddress changes the address of the cluster member with the given name. The request must be sent
+// to the control socket of that member, which restarts to listen on the new address.
+func (c *Client) UpdateClusterMemberAddress(ctx context.Context, name string, address apiTypes.AddrPort) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("cluster", name, "address")
+	return c.QueryStruct(queryCtx, "PUT", types.ControlEndpoint, endpoint, types.ClusterMemberAddress{Address: address}, nil)
+}
+
+// RenameClusterMember changes the name of the cluster member with the given name, and gives it a new server
+// certificate for that name. The request must be sent to the control socket of that member, which propagates the new
+// name and certificate to the rest of the cluster.
+func (c *Client) RenameClusterMember(ctx context.Context, name string, newName string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("cluster", name, "name")
+	return c.QueryStruct(queryCtx, "PUT", types.ControlEndpoint, endpoint, types.ClusterMemberName{Name: newName}, nil)
+}
+
 // UpdateClusterCertificate sets a new cluster keypair and CA.
 func (c *Client) UpdateClusterCertificate(ctx context.Context, args apiTypes.ClusterCertificatePut) error {
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
@@ -69,3 +321,12 @@ func (c *Client) UpdateClusterCertificate(ctx context.Context, args apiTypes.Clu
 	endpoint := api.NewURL().Path("cluster", "certificates")
 	return c.QueryStruct(queryCtx, "PUT", types.InternalEndpoint, endpoint, args, nil)
 }
+
+// RotateClusterCertificate runs the given phase of a coordinated cluster keypair rotation.
+func (c *Client) RotateClusterCertificate(ctx context.Context, phase apiTypes.ClusterCertificateRotation, args apiTypes.ClusterCertificatePut) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("cluster", "certificates").WithQuery("rotation", string(phase))
+	return c.QueryStruct(queryCtx, "PUT", types.InternalEndpoint, endpoint, args, nil)
+}
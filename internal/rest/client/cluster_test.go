@@ -0,0 +1,91 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/extensions"
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+type clusterSuite struct {
+	suite.Suite
+}
+
+func TestClusterSuite(t *testing.T) {
+	suite.Run(t, new(clusterSuite))
+}
+
+// Ensures the cluster status reports the leader, offline members and members behind the most up to date one, and
+// only holds quorum while more than half of the voters are online.
+func (t *clusterSuite) Test_clusterStatus() {
+	voter := dqliteClient.Voter.String()
+	member := func(name string, role string, status types.MemberStatus, schema uint64, ext extensions.Extensions) types.ClusterMember {
+		clusterMember := types.ClusterMember{Role: role, Status: status, SchemaInternalVersion: schema, SchemaExternalVersion: 1, Extensions: ext}
+		clusterMember.Name = name
+
+		return clusterMember
+	}
+
+	members := []types.ClusterMember{
+		member("member01", voter, types.MemberOnline, 2, extensions.Extensions{"ext_a", "ext_b"}),
+		member("member02", voter, types.MemberOnline, 1, extensions.Extensions{"ext_a", "ext_b"}),
+		member("member03", voter, types.MemberUnreachable, 2, extensions.Extensions{"ext_a"}),
+		member("member04", dqliteClient.Spare.String(), types.MemberNotTrusted, 2, extensions.Extensions{"ext_a", "ext_b"}),
+	}
+
+	members[1].Leader = true
+
+	status := clusterStatus(members)
+	t.Equal("member02", status.Leader)
+	t.Equal(3, status.Voters)
+	t.Equal(2, status.OnlineVoters)
+	t.True(status.HasQuorum)
+	t.Equal([]string{"member02", "member03"}, status.Behind)
+	t.Equal([]string{"member03", "member04"}, status.Offline)
+	t.Equal(members, status.Members)
+
+	// Half of the voters is not enough for quorum.
+	members[1].Status = types.MemberUnreachable
+	members[1].Leader = false
+	status = clusterStatus(members)
+	t.Empty(status.Leader)
+	t.Equal(1, status.OnlineVoters)
+	t.False(status.HasQuorum)
+
+	status = clusterStatus(nil)
+	t.False(status.HasQuorum)
+	t.Empty(status.Behind)
+	t.Empty(status.Offline)
+}
+
+// Ensures cluster members are stale once their last heartbeat is older than the cutoff, including those that have
+// never received a heartbeat.
+func (t *clusterSuite) Test_staleMembers() {
+	cutoff := time.Now().Add(-time.Minute)
+	heartbeats := map[string]time.Time{
+		"member01": cutoff.Add(time.Second),
+		"member02": cutoff,
+		"member03": cutoff.Add(-time.Second),
+		"member04": {},
+	}
+
+	members := make([]types.ClusterMember, 0, len(heartbeats))
+	for _, name := range []string{"member01", "member02", "member03", "member04"} {
+		member := types.ClusterMember{LastHeartbeat: heartbeats[name]}
+		member.Name = name
+		members = append(members, member)
+	}
+
+	stale := []string{}
+	for _, member := range staleMembers(members, cutoff) {
+		stale = append(stale, member.Name)
+	}
+
+	t.Equal([]string{"member03", "member04"}, stale)
+	t.Empty(staleMembers(members, time.Time{}))
+	t.NotNil(staleMembers(nil, cutoff))
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+// GetConnections returns the number of connections still open across all of the daemon's listeners.
+func (c *Client) GetConnections(ctx context.Context) (*types.Connections, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	connections := types.Connections{}
+	err := c.QueryStruct(queryCtx, "GET", types.ControlEndpoint, api.NewURL().Path("connections"), nil, &connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connections, nil
+}
+
+// GetListeners returns the listeners of the daemon that are currently up.
+func (c *Client) GetListeners(ctx context.Context) ([]types.ListenerInfo, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	listeners := []types.ListenerInfo{}
+	err := c.QueryStruct(queryCtx, "GET", types.ControlEndpoint, api.NewURL().Path("listeners"), nil, &listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	return listeners, nil
+}
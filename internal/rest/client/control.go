@@ -2,11 +2,26 @@ package client
 
 import (
 	"context"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
 
 	"github.com/canonical/microcluster/internal/rest/types"
+	apiTypes "github.com/canonical/microcluster/rest/types"
 )
 
 // ControlDaemon posts control data to the daemon.
 func (c *Client) ControlDaemon(ctx context.Context, args types.Control) error {
 	return c.QueryStruct(ctx, "POST", types.ControlEndpoint, nil, args, nil)
 }
+
+// SetPreInitAddress moves the network listener that serves the API before the daemon is bootstrapped or joined to a
+// cluster to the given address. The request must be sent to the control socket, and fails once the daemon is
+// initialized.
+func (c *Client) SetPreInitAddress(ctx context.Context, address apiTypes.AddrPort) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	endpoint := api.NewURL().Path("listen-address")
+	return c.QueryStruct(queryCtx, "PUT", types.ControlEndpoint, endpoint, types.PreInitAddress{Address: address}, nil)
+}
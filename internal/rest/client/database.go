@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+// GetDatabaseDiagnostics returns the state of every dqlite node, as seen by the dqlite leader.
+func (c *Client) GetDatabaseDiagnostics(ctx context.Context) (*types.DatabaseDiagnostics, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	diagnostics := types.DatabaseDiagnostics{}
+	err := c.QueryStruct(queryCtx, "GET", types.InternalEndpoint, api.NewURL().Path("database", "diagnostics"), nil, &diagnostics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diagnostics, nil
+}
+
+// TriggerSchemaRecheck makes the cluster member check the schema versions and API extensions of the other cluster
+// members again right away, if it is waiting for them to be upgraded. It is a no-op if the member isn't waiting.
+func (c *Client) TriggerSchemaRecheck(ctx context.Context) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "POST", types.InternalEndpoint, api.NewURL().Path("database", "upgrade"), nil, nil)
+}
+
+// GetDatabaseLogPosition returns the term and index of the last entry in the raft log of the cluster member's dqlite
+// node.
+func (c *Client) GetDatabaseLogPosition(ctx context.Context) (*types.DatabaseLogPosition, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	position := types.DatabaseLogPosition{}
+	err := c.QueryStruct(queryCtx, "GET", types.InternalEndpoint, api.NewURL().Path("database", "log"), nil, &position)
+	if err != nil {
+		return nil, err
+	}
+
+	return &position, nil
+}
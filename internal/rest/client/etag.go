@@ -0,0 +1,93 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// etagCacheSize is the number of URLs whose response is kept by an etagCache.
+const etagCacheSize = 32
+
+// etagCache holds the ETag and response of the last GET request to each URL that returned one, so that the request
+// can be made conditional the next time it is sent. Only the most recently stored URLs are kept.
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+	order   []string // URLs of the entries, from the least to the most recently stored.
+}
+
+type etagEntry struct {
+	etag     string
+	response api.Response
+}
+
+func newETagCache() *etagCache {
+	return &etagCache{entries: map[string]etagEntry{}}
+}
+
+// prepare sets the If-None-Match header of a GET request to the ETag cached for its URL, if there is one and the
+// header isn't set already.
+func (e *etagCache) prepare(r *http.Request) {
+	if e == nil || r.Method != http.MethodGet || r.Header.Get("If-None-Match") != "" {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entry, ok := e.entries[r.URL.String()]
+	if ok {
+		r.Header.Set("If-None-Match", entry.etag)
+	}
+}
+
+// store caches the response to a GET request along with its ETag, if the response has one.
+func (e *etagCache) store(r *http.Request, resp *http.Response, parsed *api.Response) {
+	if e == nil || r.Method != http.MethodGet {
+		return
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	url := r.URL.String()
+	for i, stored := range e.order {
+		if stored == url {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+
+	e.order = append(e.order, url)
+	e.entries[url] = etagEntry{etag: etag, response: *parsed}
+
+	// Evict the least recently stored entries once the cache is full.
+	for len(e.order) > etagCacheSize {
+		delete(e.entries, e.order[0])
+		e.order = e.order[1:]
+	}
+}
+
+// cached returns a copy of the response cached for a request that the server answered with 304 Not Modified.
+func (e *etagCache) cached(r *http.Request, resp *http.Response) (*api.Response, error) {
+	if e != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		entry, ok := e.entries[r.URL.String()]
+		if ok && entry.etag == r.Header.Get("If-None-Match") {
+			cached := entry.response
+			return &cached, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Failed to fetch %q: %q", r.URL.String(), resp.Status)
+}
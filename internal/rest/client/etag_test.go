@@ -0,0 +1,56 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+)
+
+type etagSuite struct {
+	suite.Suite
+}
+
+func TestETagSuite(t *testing.T) {
+	suite.Run(t, new(etagSuite))
+}
+
+// Ensures the ETag cache only keeps the most recently stored URLs, and replays the response of a URL it kept.
+func (t *etagSuite) Test_etagCache() {
+	cache := newETagCache()
+
+	store := func(i int) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/1.0/resource/%d", i), nil)
+		resp := &http.Response{Header: http.Header{"Etag": []string{fmt.Sprintf("%q", fmt.Sprint(i))}}}
+		cache.store(req, resp, &api.Response{StatusCode: i})
+	}
+
+	for i := 0; i <= etagCacheSize; i++ {
+		store(i)
+	}
+
+	// Storing the first URL again keeps it over the second one.
+	store(0)
+	store(etagCacheSize + 1)
+
+	t.Len(cache.entries, etagCacheSize)
+	t.Len(cache.order, etagCacheSize)
+	t.Contains(cache.entries, "/1.0/resource/0")
+	t.NotContains(cache.entries, "/1.0/resource/1")
+	t.NotContains(cache.entries, "/1.0/resource/2")
+
+	req := httptest.NewRequest("GET", "/1.0/resource/0", nil)
+	cache.prepare(req)
+	t.Equal(`"0"`, req.Header.Get("If-None-Match"))
+
+	cached, err := cache.cached(req, &http.Response{Status: "304 Not Modified"})
+	t.Require().NoError(err)
+	t.Equal(0, cached.StatusCode)
+
+	req = httptest.NewRequest("GET", "/1.0/resource/1", nil)
+	cache.prepare(req)
+	t.Empty(req.Header.Get("If-None-Match"))
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ForwardedByHeader lists the names of the cluster members that forwarded a request, in order, so that forwarding
+// loops between cluster members are detected.
+const ForwardedByHeader = "X-Microcluster-Forwarded-By"
+
+// ForwardedBy returns the names of the cluster members that forwarded the request, in order.
+func ForwardedBy(r *http.Request) []string {
+	header := r.Header.Get(ForwardedByHeader)
+	if header == "" {
+		return nil
+	}
+
+	return strings.Split(header, ",")
+}
+
+// SetForwardedBy sets the ForwardedByHeader of the request to the given chain of cluster members followed by name,
+// before the cluster member with that name forwards it. It returns an error if the cluster member is already in the
+// chain, as forwarding the request again would loop.
+func SetForwardedBy(r *http.Request, chain []string, name string) error {
+	if slices.Contains(chain, name) {
+		return api.StatusErrorf(http.StatusLoopDetected, "Request forwarding loop detected: %s -> %s", strings.Join(chain, " -> "), name)
+	}
+
+	r.Header.Set(ForwardedByHeader, strings.Join(append(slices.Clip(chain), name), ","))
+
+	return nil
+}
@@ -13,9 +13,16 @@ import (
 const HeartbeatTimeout = 30
 
 // Heartbeat initiates a new heartbeat sequence if this is a leader node.
-func (c *Client) Heartbeat(ctx context.Context, hbInfo types.HeartbeatInfo) error {
+// Otherwise it returns the heartbeat payload reported by the cluster member.
+func (c *Client) Heartbeat(ctx context.Context, hbInfo types.HeartbeatInfo) (map[string]string, error) {
 	queryCtx, cancel := context.WithTimeout(ctx, HeartbeatTimeout*time.Second)
 	defer cancel()
 
-	return c.QueryStruct(queryCtx, "POST", types.InternalEndpoint, api.NewURL().Path("heartbeat"), hbInfo, nil)
+	payload := map[string]string{}
+	err := c.QueryStruct(queryCtx, "POST", types.InternalEndpoint, api.NewURL().Path("heartbeat"), hbInfo, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
 }
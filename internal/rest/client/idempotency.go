@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+)
+
+// IdempotencyKeyHeader is the header used to identify retries of the same control request, so that the daemon runs it
+// only once.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey returns a copy of the context carrying the given idempotency key.
+// Requests sent with the returned context will have their IdempotencyKeyHeader set to the key.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKey returns the idempotency key carried by the context, or an empty string if there is none.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+
+	return key
+}
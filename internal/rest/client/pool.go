@@ -0,0 +1,103 @@
+package client
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Pool caches clients to cluster members so that their connections can be reused across requests,
+// instead of performing a new TLS handshake for every request.
+type Pool struct {
+	mu         sync.Mutex
+	clients    map[string]*Client
+	trusted    *TrustedCerts // Cluster certificates trusted by the clients alongside their remote certificate.
+	generation uint64        // Generation of the trusted cluster certificates the cached clients were created with.
+}
+
+// NewPool returns an empty client pool, whose clients also trust the given cluster certificates.
+func NewPool(trusted *TrustedCerts) *Pool {
+	return &Pool{clients: map[string]*Client{}, trusted: trusted}
+}
+
+// Get returns a cached client for the given URL and certificates, creating one if there is none yet.
+// A client is evicted from the pool as soon as a request made with it fails, so that the next caller gets a fresh
+// client with no stale connections.
+func (p *Pool) Get(url api.URL, clientCert *shared.CertInfo, remoteCert *x509.Certificate, forwarding bool) (*Client, error) {
+	var clientFingerprint, remoteFingerprint string
+	if clientCert != nil {
+		clientFingerprint = clientCert.Fingerprint()
+	}
+
+	if remoteCert != nil {
+		remoteFingerprint = shared.CertFingerprint(remoteCert)
+	}
+
+	host := url.URL.Host
+	key := fmt.Sprintf("%s/%s/%s/%t", host, clientFingerprint, remoteFingerprint, forwarding)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Drop all cached clients if the trusted cluster certificates have changed since they were created.
+	generation := p.trusted.Generation()
+	if generation != p.generation {
+		for _, c := range p.clients {
+			c.CloseIdleConnections()
+		}
+
+		p.clients = map[string]*Client{}
+		p.generation = generation
+	}
+
+	c, ok := p.clients[key]
+	if ok {
+		return c, nil
+	}
+
+	c, err := New(url, clientCert, remoteCert, forwarding, p.trusted.Certs()...)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, ok := c.Transport.(*http.Transport)
+	if ok {
+		transport.DisableKeepAlives = false
+		transport.IdleConnTimeout = 30 * time.Second
+	}
+
+	c.evict = func() { p.evict(key, c) }
+	p.clients[key] = c
+
+	return c, nil
+}
+
+// EvictAddress removes all clients to the given host:port address from the pool and closes their idle connections.
+func (p *Pool) EvictAddress(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, c := range p.clients {
+		if c.url.URL.Host == address {
+			delete(p.clients, key)
+			c.CloseIdleConnections()
+		}
+	}
+}
+
+// evict removes the client from the pool if it is still cached under the given key, and closes its idle connections.
+func (p *Pool) evict(key string, c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients[key] == c {
+		delete(p.clients, key)
+	}
+
+	c.CloseIdleConnections()
+}
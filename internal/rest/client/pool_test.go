@@ -0,0 +1,68 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+)
+
+type poolSuite struct {
+	suite.Suite
+}
+
+func TestPoolSuite(t *testing.T) {
+	suite.Run(t, new(poolSuite))
+}
+
+// Ensures pooled clients are reused for the same cluster member and certificates, and replaced once a request with
+// them fails, the member's address is evicted, or the trusted cluster certificates change.
+func (t *poolSuite) Test_pool() {
+	cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	remoteCert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	// Reserve an address that refuses connections.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.Require().NoError(err)
+
+	address := listener.Addr().String()
+	t.Require().NoError(listener.Close())
+
+	trusted := &TrustedCerts{}
+	pool := NewPool(trusted)
+	url := api.NewURL().Scheme("https").Host(address)
+	get := func(forwarding bool) *Client {
+		c, err := pool.Get(*url, cert, remoteCert, forwarding)
+		t.Require().NoError(err)
+
+		return c
+	}
+
+	c := get(false)
+	t.Same(c, get(false))
+	t.NotSame(c, get(true))
+	t.False(c.Transport.(*http.Transport).DisableKeepAlives)
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	t.Require().NoError(err)
+
+	_, err = c.MakeRequest(req)
+	t.Error(err)
+
+	fresh := get(false)
+	t.NotSame(c, fresh)
+	t.Same(fresh, get(false))
+
+	pool.EvictAddress(address)
+	evicted := get(false)
+	t.NotSame(fresh, evicted)
+
+	trusted.Set(remoteCert)
+	t.NotSame(evicted, get(false))
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to correlate a request with any requests it causes on other cluster members.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID received from a caller.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// NewRequestID returns a new unique request ID.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID returns whether the request ID received from a caller can be reused and logged as-is. It must not be
+// empty or longer than 128 characters, and may only contain ASCII letters, digits, '-', '_', '.' and ':'.
+func ValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && !strings.ContainsRune("-_.:", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRequestID returns a copy of the context carrying the given request ID.
+// Requests sent with the returned context will have their RequestIDHeader set to the ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, or an empty string if there is none.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+// GetActiveRequests returns the API requests that the daemon is still serving, oldest first.
+func (c *Client) GetActiveRequests(ctx context.Context) ([]types.ActiveRequest, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	requests := []types.ActiveRequest{}
+	err := c.QueryStruct(queryCtx, "GET", types.ControlEndpoint, api.NewURL().Path("requests"), nil, &requests)
+	if err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// CancelRequest cancels the context of the API request with the given ID, as listed by GetActiveRequests.
+func (c *Client) CancelRequest(ctx context.Context, id string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "DELETE", types.ControlEndpoint, api.NewURL().Path("requests", id), nil, nil)
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+// GetServerInfo returns the name, address, version and API extensions of the cluster member targeted by this client.
+func (c *Client) GetServerInfo(ctx context.Context) (*types.Server, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	server := types.Server{}
+	err := c.QueryStruct(queryCtx, "GET", types.PublicEndpoint, nil, nil, &server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
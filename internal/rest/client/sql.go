@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/canonical/lxd/shared/api"
@@ -42,3 +43,9 @@ func (c *Client) PostSQL(ctx context.Context, query types.SQLQuery) (*types.SQLB
 
 	return batch, nil
 }
+
+// GetDatabaseBackup streams a backup of the database to w, as a tar archive of the dqlite database files.
+// The checksum of the archive is verified once it has been received.
+func (c *Client) GetDatabaseBackup(ctx context.Context, w io.Writer) error {
+	return c.QueryRaw(ctx, "GET", types.InternalEndpoint, api.NewURL().Path("database", "backup"), w)
+}
@@ -4,14 +4,16 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"sync"
 
 	"github.com/canonical/lxd/shared"
 )
 
 // TLSClientConfig returns a TLS configuration suitable for establishing horizontal and vertical connections.
 // clientCert contains the private key pair for the client. remoteCert is the public
-// key of the server we are connecting to.
-func TLSClientConfig(clientCert *shared.CertInfo, remoteCert *x509.Certificate) (*tls.Config, error) {
+// key of the server we are connecting to. Any trusted certificates, such as those of an ongoing cluster certificate
+// rotation, are trusted alongside it.
+func TLSClientConfig(clientCert *shared.CertInfo, remoteCert *x509.Certificate, trusted ...*x509.Certificate) (*tls.Config, error) {
 	if clientCert == nil {
 		return nil, fmt.Errorf("Invalid client certificate")
 	}
@@ -30,6 +32,12 @@ func TLSClientConfig(clientCert *shared.CertInfo, remoteCert *x509.Certificate)
 	remoteCert.KeyUsage = x509.KeyUsageCertSign
 	config.RootCAs.AddCert(remoteCert)
 
+	for _, cert := range trusted {
+		cert.IsCA = true
+		cert.KeyUsage = x509.KeyUsageCertSign
+		config.RootCAs.AddCert(cert)
+	}
+
 	// Always use public key DNS name rather than server cert, so that it matches.
 	if len(remoteCert.DNSNames) > 0 {
 		config.ServerName = remoteCert.DNSNames[0]
@@ -37,3 +45,51 @@ func TLSClientConfig(clientCert *shared.CertInfo, remoteCert *x509.Certificate)
 
 	return config, nil
 }
+
+// TrustedCerts holds the cluster certificates that are trusted alongside the remote certificate of each connection
+// while the cluster certificate is being rotated, so that members can reach each other whether or not they have
+// switched to the new keypair yet. A nil TrustedCerts trusts no additional certificates.
+type TrustedCerts struct {
+	mu         sync.RWMutex
+	certs      []*x509.Certificate
+	generation uint64
+}
+
+// Set replaces the set of trusted cluster certificates. Calling it with no certificates ends the transition window.
+func (t *TrustedCerts) Set(certs ...*x509.Certificate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.certs = certs
+	t.generation++
+}
+
+// Certs returns copies of the trusted cluster certificates.
+func (t *TrustedCerts) Certs() []*x509.Certificate {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	certs := make([]*x509.Certificate, 0, len(t.certs))
+	for _, cert := range t.certs {
+		certCopy := *cert
+		certs = append(certs, &certCopy)
+	}
+
+	return certs
+}
+
+// Generation returns a counter that changes whenever the set of trusted cluster certificates changes.
+func (t *TrustedCerts) Generation() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.generation
+}
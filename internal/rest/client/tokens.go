@@ -21,6 +21,17 @@ func (c *Client) RequestToken(ctx context.Context, name string) (string, error)
 	return token, err
 }
 
+// CreateTokens requests a join token for each of the given requests. Either all of the tokens are created, or none are.
+func (c *Client) CreateTokens(ctx context.Context, tokens []types.TokenRequest) ([]types.TokenRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tokenRecords := []types.TokenRecord{}
+	err := c.QueryStruct(queryCtx, "POST", types.PublicEndpoint, api.NewURL().Path("tokens", "batch"), tokens, &tokenRecords)
+
+	return tokenRecords, err
+}
+
 // DeleteTokenRecord deletes the toekn record.
 func (c *Client) DeleteTokenRecord(ctx context.Context, name string) error {
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
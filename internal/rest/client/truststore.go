@@ -7,6 +7,7 @@ import (
 	"github.com/canonical/lxd/shared/api"
 
 	"github.com/canonical/microcluster/internal/rest/types"
+	apiTypes "github.com/canonical/microcluster/rest/types"
 )
 
 // AddTrustStoreEntry adds a new record to the truststore on all cluster members.
@@ -24,3 +25,58 @@ func DeleteTrustStoreEntry(ctx context.Context, c *Client, name string) error {
 
 	return c.QueryStruct(queryCtx, "DELETE", types.InternalEndpoint, api.NewURL().Path("truststore", name), nil, nil)
 }
+
+// RenameTrustStoreEntry renames the record corresponding to the given cluster member in the trust store. If cert is
+// set, it replaces the certificate of the record.
+func RenameTrustStoreEntry(ctx context.Context, c *Client, name string, newName string, cert *apiTypes.X509Certificate) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "PUT", types.InternalEndpoint, api.NewURL().Path("truststore", name), types.ClusterMemberName{Name: newName, Certificate: cert}, nil)
+}
+
+// ExportTrustStore returns all entries in the truststore as a bundle signed with the cluster keypair.
+func (c *Client) ExportTrustStore(ctx context.Context) (*types.TrustBundle, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	bundle := types.TrustBundle{}
+	err := c.QueryStruct(queryCtx, "GET", types.ControlEndpoint, api.NewURL().Path("truststore"), nil, &bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundle, nil
+}
+
+// ImportTrustStore adds all entries of the signed bundle to the truststore. Either all entries are added, or none.
+func (c *Client) ImportTrustStore(ctx context.Context, bundle types.TrustBundle) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "POST", types.ControlEndpoint, api.NewURL().Path("truststore"), bundle, nil)
+}
+
+// RefreshTrustStore reloads the truststore from the state directory, without waiting for the daemon to notice changes
+// made to it.
+func (c *Client) RefreshTrustStore(ctx context.Context) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.QueryStruct(queryCtx, "POST", types.ControlEndpoint, api.NewURL().Path("truststore", "refresh"), nil, nil)
+}
+
+// GetTrustPEM returns the cluster certificate, its CA and the certificate of every cluster member as a PEM bundle.
+// The bundle contains no private keys, and unlike ExportTrustStore, isn't signed.
+func (c *Client) GetTrustPEM(ctx context.Context) (string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var bundle string
+	err := c.QueryStruct(queryCtx, "GET", types.PublicEndpoint, api.NewURL().Path("truststore", "pem"), nil, &bundle)
+	if err != nil {
+		return "", err
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// minCompressSize is the size below which responses are sent uncompressed, as gzip would not make them smaller.
+const minCompressSize = 1024
+
+// compressWriter buffers the start of a response to decide whether to compress it with gzip.
+type compressWriter struct {
+	http.ResponseWriter
+
+	status int
+	buf    []byte
+
+	decided bool
+	gz      *gzip.Writer
+}
+
+// WriteHeader records the status code until the response is either compressed or sent as is.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write buffers the response until it is large enough to be worth compressing.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < minCompressSize {
+			return len(b), nil
+		}
+
+		err := w.decide()
+		if err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// decide compresses the response if it's large enough and not already compressed, then writes out the buffer.
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	if len(w.buf) >= minCompressSize && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// Close writes out any buffered data and ends the gzip stream.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		err := w.decide()
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+
+	return nil
+}
+
+// Flush implements http.Flusher, sending any buffered data first.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if nothing has been written yet.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Webserver does not support hijacking")
+	}
+
+	if w.decided || len(w.buf) > 0 {
+		return nil, nil, fmt.Errorf("Cannot hijack a connection after writing a response")
+	}
+
+	// Nothing else may be written through this writer once the connection is hijacked.
+	w.decided = true
+
+	return h.Hijack()
+}
+
+// compressible returns whether a response with the given content type is worth compressing.
+func compressible(contentType string) bool {
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/gzip", "application/zip", "application/x-xz", "application/zstd"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compress wraps the handler to compress responses with gzip for clients that accept it. Small responses, responses
+// that are already compressed, and connection upgrades such as the dqlite and websocket ones are left as is.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: w}
+		defer func() { _ = writer.Close() }()
+
+		next.ServeHTTP(writer, r)
+	})
+}
+
+// acceptsGzip returns whether the Accept-Encoding header allows gzip.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+
+	return false
+}
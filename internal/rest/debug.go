@@ -0,0 +1,25 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+// debugHTTPKey marks the context of requests served by the debug http listener.
+type debugHTTPKey struct{}
+
+// TrustDebugHTTP wraps the handler of the debug http listener to treat every request as trusted, as its clients
+// present no certificate. The listener only serves a few harmless read endpoints on loopback, so this trusts local
+// processes with those only.
+func TrustDebugHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), debugHTTPKey{}, true)))
+	})
+}
+
+// isDebugHTTP returns whether the request is served by the debug http listener.
+func isDebugHTTP(r *http.Request) bool {
+	debug, _ := r.Context().Value(debugHTTPKey{}).(bool)
+
+	return debug
+}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/rest"
+)
+
+// encodeWriter buffers a response so that a JSON body can be re-encoded once the handler is done.
+type encodeWriter struct {
+	http.ResponseWriter
+
+	status int
+	buf    bytes.Buffer
+
+	// passthrough is set once the response can no longer be re-encoded, because it was flushed or hijacked.
+	passthrough bool
+}
+
+// WriteHeader records the status code until the response is re-encoded.
+func (w *encodeWriter) WriteHeader(status int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write buffers the response body.
+func (w *encodeWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.buf.Write(b)
+}
+
+// Flush implements http.Flusher. Streamed responses are sent as they are.
+func (w *encodeWriter) Flush() {
+	if !w.passthrough {
+		w.passthrough = true
+		_ = w.writeBuffered()
+	}
+
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if nothing has been written yet.
+func (w *encodeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Webserver does not support hijacking")
+	}
+
+	if w.passthrough || w.buf.Len() > 0 {
+		return nil, nil, fmt.Errorf("Cannot hijack a connection after writing a response")
+	}
+
+	w.passthrough = true
+
+	return h.Hijack()
+}
+
+// writeBuffered writes out the buffered status and body as they are.
+func (w *encodeWriter) writeBuffered() error {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+
+	return err
+}
+
+// encode re-encodes a buffered JSON response envelope with the given encoder. Other responses are sent as they are.
+func (w *encodeWriter) encode(mediaType string, encoder rest.Encoder) error {
+	if w.passthrough {
+		return nil
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.writeBuffered()
+	}
+
+	var resp api.ResponseRaw
+	decoder := json.NewDecoder(bytes.NewReader(w.buf.Bytes()))
+	decoder.UseNumber()
+	err := decoder.Decode(&resp)
+	if err != nil {
+		return w.writeBuffered()
+	}
+
+	resp.Metadata = jsonNumbers(resp.Metadata)
+
+	var encoded bytes.Buffer
+	err = encoder(&encoded, resp)
+	if err != nil {
+		logger.Warn("Failed to encode response, sending JSON instead", logger.Ctx{"format": mediaType, "error": err})
+		return w.writeBuffered()
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(encoded.Len()))
+	w.buf = encoded
+
+	return w.writeBuffered()
+}
+
+// jsonNumbers replaces the json.Number values in decoded JSON with integers where possible, or floats otherwise.
+func jsonNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		if err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+
+		return f
+	case map[string]any:
+		for key, value := range v {
+			v[key] = jsonNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = jsonNumbers(value)
+		}
+	}
+
+	return v
+}
+
+// Encode wraps the handler to send responses in the format preferred by the client's Accept header, among those
+// registered with rest.RegisterEncoder. The JSON response envelope is decoded and re-encoded with all its fields.
+// Responses are sent as JSON if the client prefers JSON or accepts no registered format.
+func Encode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, encoder, ok := rest.ResponseEncoder(r.Header.Get("Accept"))
+		if !ok || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writer := &encodeWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
+
+		err := writer.encode(mediaType, encoder)
+		if err != nil {
+			logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
+		}
+	})
+}
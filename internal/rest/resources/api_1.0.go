@@ -12,20 +12,39 @@ import (
 )
 
 var api10Cmd = rest.Endpoint{
-	AllowedBeforeInit: true,
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
 
 	Get: rest.EndpointAction{Handler: api10Get, AllowUntrusted: true},
 }
 
 func api10Get(s *state.State, r *http.Request) response.Response {
-	addrPort, err := types.ParseAddrPort(s.Address().URL.Host)
+	server, err := ServerInfo(s)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
-	return response.SyncResponse(true, internalTypes.Server{
-		Name:    s.Name(),
-		Address: addrPort,
-		Ready:   s.Database.IsOpen(),
-	})
+	return response.SyncResponse(true, server)
+}
+
+// ServerInfo returns the name, address, readiness, version, API extensions and certificate expiry dates of the daemon.
+func ServerInfo(s *state.State) (*internalTypes.Server, error) {
+	addrPort, err := types.ParseAddrPort(s.Address().URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &internalTypes.Server{
+		Name:       s.Name(),
+		Address:    addrPort,
+		Ready:      s.Database.IsOpen(),
+		Version:    s.Version,
+		Extensions: s.Extensions,
+	}
+
+	if s.CertificateExpiry != nil {
+		server.CertificateExpiry = s.CertificateExpiry()
+	}
+
+	return server, nil
 }
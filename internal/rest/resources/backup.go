@@ -0,0 +1,84 @@
+package resources
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/lxd/response"
+
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// backupChunkSize is the size of the chunks in which backups are sent, each flushed to the client as it is written.
+const backupChunkSize = 1024 * 1024
+
+var databaseBackupCmd = rest.Endpoint{
+	Path: "database/backup",
+
+	Get: rest.EndpointAction{Handler: databaseBackupGet, AccessHandler: access.AllowAuthenticated},
+}
+
+// databaseBackupGet streams a dump of the database as a tar archive, followed by its checksum.
+func databaseBackupGet(s *state.State, r *http.Request) response.Response {
+	files, err := s.Database.Backup(r.Context())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		return writeBackup(w, files, time.Now())
+	})
+}
+
+// writeBackup writes the files as a tar archive in chunks, using chunked transfer encoding so that the size of the
+// archive needn't be known up front. The SHA-256 checksum of the archive is sent in a trailer once it is written. If
+// writing fails part way, the trailer is left out, so that the client doesn't mistake the archive for a complete one.
+func writeBackup(w http.ResponseWriter, files []dqliteClient.File, modTime time.Time) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("ResponseWriter is not type http.Flusher")
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Trailer", internalClient.ChecksumTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	// Send the headers right away, so that the archive is streamed rather than buffered by any response wrapper.
+	flusher.Flush()
+
+	hash := sha256.New()
+	archive := tar.NewWriter(io.MultiWriter(w, hash))
+	for _, file := range files {
+		err := archive.WriteHeader(&tar.Header{Name: file.Name, Mode: 0600, Size: int64(len(file.Data)), ModTime: modTime})
+		if err != nil {
+			return fmt.Errorf("Failed to write backup header of %q: %w", file.Name, err)
+		}
+
+		for offset := 0; offset < len(file.Data); offset += backupChunkSize {
+			_, err = archive.Write(file.Data[offset:min(offset+backupChunkSize, len(file.Data))])
+			if err != nil {
+				return fmt.Errorf("Failed to write backup of %q: %w", file.Name, err)
+			}
+
+			flusher.Flush()
+		}
+	}
+
+	err := archive.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to finish backup: %w", err)
+	}
+
+	w.Header().Set(internalClient.ChecksumTrailer, hex.EncodeToString(hash.Sum(nil)))
+
+	return nil
+}
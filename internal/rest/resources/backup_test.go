@@ -0,0 +1,115 @@
+package resources
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/rest/types"
+)
+
+type backupSuite struct {
+	suite.Suite
+}
+
+func TestBackupSuite(t *testing.T) {
+	suite.Run(t, new(backupSuite))
+}
+
+// corruptingWriter flips a bit of the first large chunk written to it, or fails once failAfter bytes were written.
+type corruptingWriter struct {
+	http.ResponseWriter
+
+	corrupt   bool
+	failAfter int
+	written   int
+}
+
+func (w *corruptingWriter) Write(b []byte) (int, error) {
+	if w.failAfter > 0 && w.written+len(b) > w.failAfter {
+		return 0, fmt.Errorf("Connection lost")
+	}
+
+	if w.corrupt && len(b) > 1024 {
+		b = append([]byte{}, b...)
+		b[len(b)/2] ^= 0x01
+		w.corrupt = false
+	}
+
+	w.written += len(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *corruptingWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Ensures a sizeable backup is streamed intact with its checksum, and that the client rejects a backup that was
+// corrupted or cut short on the way.
+func (t *backupSuite) Test_backupIntegrity() {
+	data := make([]byte, 8*backupChunkSize+123)
+	_, err := rand.Read(data)
+	t.Require().NoError(err)
+
+	files := []dqliteClient.File{{Name: "db.bin", Data: data}, {Name: "db.bin-wal", Data: data[:4096]}}
+
+	var writer *corruptingWriter
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer.ResponseWriter = w
+		_ = writeBackup(writer, files, time.Now())
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	t.Require().NoError(err)
+
+	c, err := internalClient.New(*api.NewURL().Scheme("http").Host(serverURL.Host), nil, nil, false)
+	t.Require().NoError(err)
+
+	backup := func() (*bytes.Buffer, error) {
+		buf := &bytes.Buffer{}
+		err := c.QueryRaw(context.Background(), "GET", types.InternalEndpoint, api.NewURL().Path("database", "backup"), buf)
+
+		return buf, err
+	}
+
+	writer = &corruptingWriter{}
+	buf, err := backup()
+	t.Require().NoError(err)
+
+	archive := tar.NewReader(buf)
+	for _, file := range files {
+		header, err := archive.Next()
+		t.Require().NoError(err)
+		t.Equal(file.Name, header.Name)
+
+		content, err := io.ReadAll(archive)
+		t.Require().NoError(err)
+		t.True(bytes.Equal(file.Data, content))
+	}
+
+	_, err = archive.Next()
+	t.ErrorIs(err, io.EOF)
+
+	writer = &corruptingWriter{corrupt: true}
+	_, err = backup()
+	t.ErrorContains(err, "checksum mismatch")
+
+	writer = &corruptingWriter{failAfter: 3 * backupChunkSize}
+	_, err = backup()
+	t.ErrorContains(err, "missing checksum")
+}
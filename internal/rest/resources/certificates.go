@@ -2,6 +2,7 @@ package resources
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -10,6 +11,8 @@ import (
 	"path/filepath"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/logger"
 
 	"github.com/canonical/microcluster/client"
 	"github.com/canonical/microcluster/internal/state"
@@ -34,6 +37,13 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	rotation := types.ClusterCertificateRotation(r.URL.Query().Get("rotation"))
+	switch rotation {
+	case "", types.ClusterCertificateStage, types.ClusterCertificateCommit, types.ClusterCertificateFinalize:
+	default:
+		return response.BadRequest(fmt.Errorf("Invalid cluster certificate rotation phase %q", rotation))
+	}
+
 	// Forward the request to all other nodes if we are the first.
 	if !client.IsNotification(r) && s.Database.IsOpen() {
 		cluster, err := s.Cluster(true)
@@ -42,6 +52,10 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 		}
 
 		err = cluster.Query(s.Context, true, func(ctx context.Context, c *client.Client) error {
+			if rotation != "" {
+				return c.RotateClusterCertificate(ctx, rotation, req)
+			}
+
 			return c.UpdateClusterCertificate(ctx, req)
 		})
 		if err != nil {
@@ -49,6 +63,16 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 		}
 	}
 
+	switch rotation {
+	case types.ClusterCertificateStage:
+		return clusterCertificateStage(s, req)
+	case types.ClusterCertificateFinalize:
+		s.TrustedClusterCerts.Set()
+		logger.Info("Finalized cluster certificate rotation")
+
+		return response.EmptySyncResponse
+	}
+
 	certBlock, _ := pem.Decode([]byte(req.PublicKey))
 	if certBlock == nil {
 		return response.BadRequest(fmt.Errorf("Certificate must be base64 encoded PEM certificate"))
@@ -59,6 +83,26 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("Private key must be base64 encoded PEM key"))
 	}
 
+	// Only replace the keypair during a rotation if all members already trust the new certificate.
+	if rotation == types.ClusterCertificateCommit {
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
+		staged := false
+		for _, trusted := range s.TrustedClusterCerts.Certs() {
+			if trusted.Equal(cert) {
+				staged = true
+				break
+			}
+		}
+
+		if !staged {
+			return response.BadRequest(fmt.Errorf("Cluster certificate must be staged before it is committed"))
+		}
+	}
+
 	// If a CA was specified, validate that as well.
 	if req.CA != "" {
 		caBlock, _ := pem.Decode([]byte(req.CA))
@@ -66,24 +110,25 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 			return response.BadRequest(fmt.Errorf("CA must be base64 encoded PEM key"))
 		}
 
-		err = os.WriteFile(filepath.Join(s.OS.StateDir, "cluster.ca"), []byte(req.CA), 0650)
+		err = writeCertFile(filepath.Join(s.OS.StateDir, "cluster.ca"), []byte(req.CA))
 		if err != nil {
 			return response.SmartError(err)
 		}
 	}
 
 	// Write the keypair to the state directory.
-	err = os.WriteFile(filepath.Join(s.OS.StateDir, "cluster.crt"), []byte(req.PublicKey), 0650)
+	err = writeCertFile(filepath.Join(s.OS.StateDir, "cluster.crt"), []byte(req.PublicKey))
 	if err != nil {
 		return response.SmartError(err)
 	}
 
-	err = os.WriteFile(filepath.Join(s.OS.StateDir, "cluster.key"), []byte(req.PrivateKey), 0650)
+	err = writeCertFile(filepath.Join(s.OS.StateDir, "cluster.key"), []byte(req.PrivateKey))
 	if err != nil {
 		return response.SmartError(err)
 	}
 
 	// Load the new cluster cert from the state directory on this node.
+	// Only new connections are affected, so established dqlite connections are kept.
 	err = state.ReloadClusterCert()
 	if err != nil {
 		return response.SmartError(err)
@@ -91,3 +136,34 @@ func clusterCertificatesPut(s *state.State, r *http.Request) response.Response {
 
 	return response.EmptySyncResponse
 }
+
+// clusterCertificateStage trusts the new cluster certificate alongside the current one, so that this member can
+// connect to peers that have already switched to the new keypair, and keep connecting to those that have not once it
+// switches itself.
+func clusterCertificateStage(s *state.State, req types.ClusterCertificatePut) response.Response {
+	cert, err := types.ParseX509Certificate(req.PublicKey)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Certificate must be base64 encoded PEM certificate: %w", err))
+	}
+
+	current, err := s.ClusterCert().PublicKeyX509()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	s.TrustedClusterCerts.Set(current, cert.Certificate)
+	logger.Info("Staged new cluster certificate", logger.Ctx{"fingerprint": shared.CertFingerprint(cert.Certificate)})
+
+	return response.EmptySyncResponse
+}
+
+// writeCertFile atomically replaces the file at the given path, so that the keypair is never loaded half-written.
+func writeCertFile(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	err := os.WriteFile(tmpPath, data, 0650)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,96 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/stretchr/testify/suite"
+
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type certificatesSuite struct {
+	suite.Suite
+}
+
+func TestCertificatesSuite(t *testing.T) {
+	suite.Run(t, new(certificatesSuite))
+}
+
+// Ensures a new cluster certificate is only committed once staged, and stays trusted alongside the current one until
+// the rotation is finalized.
+func (t *certificatesSuite) Test_clusterCertificateRotation() {
+	dir := t.T().TempDir()
+	err := sys.GenerateCert(dir, "cluster", nil)
+	t.Require().NoError(err)
+
+	current, err := shared.KeyPairAndCA(dir, "cluster", shared.CertServer, false)
+	t.Require().NoError(err)
+
+	currentCert, err := current.PublicKeyX509()
+	t.Require().NoError(err)
+
+	certPEM, keyPEM, err := sys.NewCertificate(types.CertificateOptions{CommonName: currentCert.Subject.CommonName}, time.Hour)
+	t.Require().NoError(err)
+
+	newCert, err := types.ParseX509Certificate(string(certPEM))
+	t.Require().NoError(err)
+
+	reloads := 0
+	reload := state.ReloadClusterCert
+	state.ReloadClusterCert = func() error { reloads++; return nil }
+	defer func() { state.ReloadClusterCert = reload }()
+
+	s := &state.State{
+		Context:             context.Background(),
+		OS:                  &sys.OS{StateDir: dir},
+		ClusterCert:         func() *shared.CertInfo { return current },
+		TrustedClusterCerts: &internalClient.TrustedCerts{},
+	}
+
+	put := func(phase types.ClusterCertificateRotation) int {
+		body, err := json.Marshal(types.ClusterCertificatePut{PublicKey: string(certPEM), PrivateKey: string(keyPEM)})
+		t.Require().NoError(err)
+
+		req := httptest.NewRequest("PUT", "/1.0/cluster/certificates?rotation="+string(phase), bytes.NewReader(body))
+		recorder := httptest.NewRecorder()
+		err = clusterCertificatesPut(s, req).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusBadRequest, put("unknown"))
+
+	// The new certificate can't be committed before every member trusts it.
+	t.Equal(http.StatusBadRequest, put(types.ClusterCertificateCommit))
+	t.Equal(0, reloads)
+
+	t.Equal(http.StatusOK, put(types.ClusterCertificateStage))
+	trusted := s.TrustedClusterCerts.Certs()
+	t.Require().Len(trusted, 2)
+	t.True(trusted[0].Equal(currentCert))
+	t.True(trusted[1].Equal(newCert.Certificate))
+
+	t.Equal(http.StatusOK, put(types.ClusterCertificateCommit))
+	t.Equal(1, reloads)
+	t.Len(s.TrustedClusterCerts.Certs(), 2)
+
+	written, err := os.ReadFile(filepath.Join(dir, "cluster.crt"))
+	t.Require().NoError(err)
+	t.Equal(certPEM, written)
+
+	t.Equal(http.StatusOK, put(types.ClusterCertificateFinalize))
+	t.Empty(s.TrustedClusterCerts.Certs())
+}
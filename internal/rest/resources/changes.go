@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+var changesCmd = rest.Endpoint{
+	Path: "changes/{table}",
+
+	Get: rest.EndpointAction{Handler: changesGet, AccessHandler: access.AllowAuthenticated},
+}
+
+// changesUpgrader upgrades change feed requests to websockets.
+var changesUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// changesGet streams the changes committed to the requested table through this cluster member over a websocket.
+func changesGet(s *state.State, r *http.Request) response.Response {
+	table, err := url.PathUnescape(mux.Vars(r)["table"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	events, unsubscribe, err := s.Database.WatchTable(table)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		defer unsubscribe()
+
+		conn, err := changesUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already replied with an error.
+			logger.Error("Failed to upgrade change feed connection", logger.Ctx{"table": table, "error": err})
+			return nil
+		}
+
+		defer conn.Close()
+
+		// Read from the connection so that we notice when the watcher goes away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, _, err := conn.NextReader()
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-s.Context.Done():
+				return nil
+			case <-closed:
+				return nil
+			case event := <-events:
+				err := conn.WriteJSON(event)
+				if err != nil {
+					logger.Warn("Failed to send change event", logger.Ctx{"table": table, "error": err})
+					return nil
+				}
+			}
+		}
+	})
+}
@@ -5,16 +5,18 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	dqliteClient "github.com/canonical/go-dqlite/client"
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
 	"github.com/gorilla/mux"
@@ -22,6 +24,7 @@ import (
 
 	"github.com/canonical/microcluster/client"
 	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/db"
 	internalClient "github.com/canonical/microcluster/internal/rest/client"
 	internalTypes "github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/internal/state"
@@ -34,14 +37,16 @@ import (
 var clusterCmd = rest.Endpoint{
 	Path: "cluster",
 
-	Post: rest.EndpointAction{Handler: clusterPost, AllowUntrusted: true},
-	Get:  rest.EndpointAction{Handler: clusterGet, AccessHandler: access.AllowAuthenticated},
+	Post:   rest.EndpointAction{Handler: clusterPost, AllowUntrusted: true},
+	Get:    rest.EndpointAction{Handler: clusterGet, AccessHandler: access.AllowAuthenticated},
+	Delete: rest.EndpointAction{Handler: clusterDelete, AccessHandler: access.AllowAuthenticated},
 }
 
 var clusterMemberCmd = rest.Endpoint{
 	Path: "cluster/{name}",
 
 	Put:    rest.EndpointAction{Handler: clusterMemberPut, AccessHandler: access.AllowAuthenticated},
+	Patch:  rest.EndpointAction{Handler: clusterMemberPatch, AccessHandler: access.AllowAuthenticated},
 	Delete: rest.EndpointAction{Handler: clusterMemberDelete, AccessHandler: access.AllowAuthenticated},
 }
 
@@ -85,7 +90,7 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 
-		tokenResponse, err := client.AddClusterMember(s.Context, req)
+		tokenResponse, err := client.AddClusterMember(internalClient.WithRequestID(s.Context, internalClient.RequestID(r.Context())), req)
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -99,7 +104,12 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	role := cluster.Pending
+	if req.Observer {
+		role = cluster.PendingObserver
+	}
+
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
 		dbClusterMember := cluster.InternalClusterMember{
 			Name:           req.Name,
 			Address:        req.Address.String(),
@@ -108,7 +118,11 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 			SchemaExternal: req.SchemaExternalVersion,
 			APIExtensions:  req.Extensions,
 			Heartbeat:      time.Time{},
-			Role:           cluster.Pending,
+			Role:           role,
+		}
+
+		if req.ClientCertificate != nil {
+			dbClusterMember.ClientCertificate = req.ClientCertificate.String()
 		}
 
 		record, err := cluster.GetInternalTokenRecord(ctx, tx, req.Secret)
@@ -116,12 +130,24 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 			return err
 		}
 
+		if record.Expired(s.TokenExpirySkew) {
+			return fmt.Errorf("%w for %q", types.ErrTokenExpired, record.Name)
+		}
+
 		_, err = cluster.CreateInternalClusterMember(ctx, tx, dbClusterMember)
 		if err != nil {
 			return err
 		}
 
-		return cluster.DeleteInternalTokenRecord(ctx, tx, record.Name)
+		err = cluster.DeleteInternalTokenRecord(ctx, tx, record.Name)
+		if err != nil {
+			return err
+		}
+
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeCreate, dbClusterMember.Name)
+		s.Database.RecordChange(ctx, db.TokenRecordsTable, types.ChangeDelete, record.Name)
+
+		return nil
 	})
 	if err != nil {
 		return response.SmartError(err)
@@ -131,9 +157,10 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 	clusterMembers := make([]internalTypes.ClusterMemberLocal, 0, remotes.Count())
 	for _, clusterMember := range remotes.RemotesByName() {
 		clusterMember := internalTypes.ClusterMemberLocal{
-			Name:        clusterMember.Name,
-			Address:     clusterMember.Address,
-			Certificate: clusterMember.Certificate,
+			Name:              clusterMember.Name,
+			Address:           clusterMember.Address,
+			Certificate:       clusterMember.Certificate,
+			ClientCertificate: clusterMember.ClientCertificate,
 		}
 
 		clusterMembers = append(clusterMembers, clusterMember)
@@ -149,13 +176,14 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 		ClusterCert: types.X509Certificate{Certificate: clusterCert},
 		ClusterKey:  string(s.ClusterCert().PrivateKey()),
 
-		TrustedMember:  internalTypes.ClusterMemberLocal{Name: s.Name(), Address: localRemote.Address, Certificate: localRemote.Certificate},
+		TrustedMember:  internalTypes.ClusterMemberLocal{Name: s.Name(), Address: localRemote.Address, Certificate: localRemote.Certificate, ClientCertificate: localRemote.ClientCertificate},
 		ClusterMembers: clusterMembers,
 	}
 
 	newRemote := trust.Remote{
-		Location:    trust.Location{Name: req.Name, Address: req.Address},
-		Certificate: req.Certificate,
+		Location:          trust.Location{Name: req.Name, Address: req.Address},
+		Certificate:       req.Certificate,
+		ClientCertificate: req.ClientCertificate,
 	}
 
 	// Add the cluster member to our local store for authentication.
@@ -167,10 +195,54 @@ func clusterPost(s *state.State, r *http.Request) response.Response {
 	return response.SyncResponse(true, tokenResponse)
 }
 
+// clusterGet returns the cluster members, ordered by name, optionally filtered by the "role" and "status" query
+// parameters. With paged=1, a ClusterMembersPage is returned instead, holding the page selected by the "limit" and
+// "offset" query parameters along with the total count of matching cluster members.
 func clusterGet(s *state.State, r *http.Request) response.Response {
+	filter, paged, err := parseClusterMembersFilter(r.URL.Query())
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// The status of cluster members is only known once they have been contacted, so the page can only be selected
+	// from the database if there is no status filter.
+	limit, offset := filter.Limit, filter.Offset
+	if filter.Status != "" {
+		limit, offset = 0, 0
+	}
+
+	var role *cluster.Role
+	if filter.Role != "" {
+		memberRole := cluster.Role(filter.Role)
+		role = &memberRole
+	}
+
 	var apiClusterMembers []internalTypes.ClusterMember
-	err := s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		clusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+	var total int
+	removed := map[string]bool{}
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		var clusterMembers []cluster.InternalClusterMember
+		var err error
+		clusterMembers, total, err = cluster.GetInternalClusterMembersPage(ctx, tx, role, limit, offset)
+		if err != nil {
+			return err
+		}
+
+		removals, err := cluster.GetInternalClusterMemberRemovals(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, removal := range removals {
+			removed[removal.Name] = true
+		}
+
+		drains, err := memberDrains(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		configs, err := cluster.GetMembersConfig(ctx, tx)
 		if err != nil {
 			return err
 		}
@@ -182,6 +254,9 @@ func clusterGet(s *state.State, r *http.Request) response.Response {
 				return err
 			}
 
+			apiClusterMember.Config = configs[clusterMember.Name]
+			apiClusterMember.Drain = drains[clusterMember.Name]
+			apiClusterMember.Maintenance = cluster.InMaintenance(configs[clusterMember.Name])
 			apiClusterMembers = append(apiClusterMembers, *apiClusterMember)
 		}
 
@@ -196,10 +271,38 @@ func clusterGet(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Mark the dqlite leader, if it can be found.
+	leaderAddress, err := dqliteLeaderAddress(s)
+	if err != nil {
+		logger.Warn("Failed to get dqlite leader", logger.Ctx{"error": err})
+	}
+
+	for i, clusterMember := range apiClusterMembers {
+		apiClusterMembers[i].Leader = leaderAddress != "" && clusterMember.Address.String() == leaderAddress
+	}
+
+	// The ETag is derived from the database records rather than the reachability of each member, so that unchanged
+	// lists are answered without contacting every member. Heartbeats update the records of reachable members, so a
+	// member that becomes unreachable still changes the ETag once the next heartbeat round is over.
+	tag, notModified := checkETag(r, struct {
+		Members []internalTypes.ClusterMember
+		Removed map[string]bool
+		Filter  internalTypes.ClusterMembersFilter
+		Paged   bool
+	}{Members: apiClusterMembers, Removed: removed, Filter: filter, Paged: paged})
+	if notModified != nil {
+		return notModified
+	}
+
 	// Send a small request to each node to ensure they are reachable.
 	for i, clusterMember := range apiClusterMembers {
+		if removed[clusterMember.Name] {
+			apiClusterMembers[i].Status = internalTypes.MemberRemoved
+			continue
+		}
+
 		addr := api.NewURL().Scheme("https").Host(clusterMember.Address.String())
-		d, err := internalClient.New(*addr, s.ServerCert(), clusterCert, false)
+		d, err := internalClient.New(*addr, s.ClientCert(), clusterCert, false)
 		if err != nil {
 			return response.SmartError(fmt.Errorf("Failed to create HTTPS client for cluster member with address %q: %w", addr.String(), err))
 		}
@@ -212,7 +315,106 @@ func clusterGet(s *state.State, r *http.Request) response.Response {
 		}
 	}
 
-	return response.SyncResponse(true, apiClusterMembers)
+	if filter.Status != "" {
+		apiClusterMembers, total = pageClusterMembers(apiClusterMembers, filter)
+	}
+
+	headers := map[string]string{"ETag": tag}
+	if !paged {
+		return response.SyncResponseHeaders(true, apiClusterMembers, headers)
+	}
+
+	return response.SyncResponseHeaders(true, internalTypes.ClusterMembersPage{Members: apiClusterMembers, Total: total}, headers)
+}
+
+// parseClusterMembersFilter parses the filter and pagination query parameters of the cluster members list, and
+// returns whether a page was requested with paged=1. Only a page reports the total number of matching cluster members,
+// so the limit and offset are rejected unless a page was requested. Unknown roles and statuses are rejected too.
+func parseClusterMembersFilter(query url.Values) (internalTypes.ClusterMembersFilter, bool, error) {
+	filter := internalTypes.ClusterMembersFilter{
+		Role:   query.Get("role"),
+		Status: internalTypes.MemberStatus(query.Get("status")),
+	}
+
+	if filter.Role != "" {
+		roles := []string{dqliteClient.Voter.String(), dqliteClient.StandBy.String(), dqliteClient.Spare.String(), string(cluster.Pending), string(cluster.Observer), string(cluster.PendingObserver)}
+		if !shared.ValueInSlice(filter.Role, roles) {
+			return filter, false, fmt.Errorf("Invalid role %q", filter.Role)
+		}
+	}
+
+	if filter.Status != "" {
+		statuses := []internalTypes.MemberStatus{internalTypes.MemberOnline, internalTypes.MemberUnreachable, internalTypes.MemberNotTrusted, internalTypes.MemberNotFound, internalTypes.MemberRemoved}
+		if !shared.ValueInSlice(filter.Status, statuses) {
+			return filter, false, fmt.Errorf("Invalid status %q", filter.Status)
+		}
+	}
+
+	paged := query.Get("paged") == "1"
+	if !paged && (query.Has("limit") || query.Has("offset")) {
+		return filter, false, fmt.Errorf("The limit and offset are only supported with paged=1")
+	}
+
+	var err error
+	for key, value := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if !query.Has(key) {
+			continue
+		}
+
+		*value, err = strconv.Atoi(query.Get(key))
+		if err != nil || *value < 0 {
+			return filter, false, fmt.Errorf("Invalid %s %q", key, query.Get(key))
+		}
+	}
+
+	return filter, paged, nil
+}
+
+// pageClusterMembers returns the page of cluster members matching the status of the filter, and the total number of
+// matching cluster members.
+func pageClusterMembers(clusterMembers []internalTypes.ClusterMember, filter internalTypes.ClusterMembersFilter) ([]internalTypes.ClusterMember, int) {
+	matching := make([]internalTypes.ClusterMember, 0, len(clusterMembers))
+	for _, clusterMember := range clusterMembers {
+		if clusterMember.Status == filter.Status {
+			matching = append(matching, clusterMember)
+		}
+	}
+
+	total := len(matching)
+	if filter.Offset >= total {
+		return []internalTypes.ClusterMember{}, total
+	}
+
+	matching = matching[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matching) {
+		matching = matching[:filter.Limit]
+	}
+
+	return matching, total
+}
+
+// dqliteLeaderAddress returns the address of the current dqlite leader.
+func dqliteLeaderAddress(s *state.State) (string, error) {
+	ctx, cancel := context.WithTimeout(s.Context, time.Second*5)
+	defer cancel()
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defer leader.Close()
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if leaderInfo == nil {
+		return "", fmt.Errorf("No dqlite leader found")
+	}
+
+	return leaderInfo.Address, nil
 }
 
 // clusterDisableMu is used to prevent the daemon process from being replaced/stopped during removal from the
@@ -246,6 +448,55 @@ func clusterMemberPut(s *state.State, r *http.Request) response.Response {
 	})
 }
 
+// clusterMemberPatch applies a partial update to the database record of a cluster member.
+// Fields that are not managed through the patch, such as the role and schema versions, are left untouched. The address
+// is rejected as well, since changing it only in the database would cut the member off from dqlite.
+func clusterMemberPatch(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := internalTypes.ClusterMemberPatch{}
+
+	// Parse the request, rejecting any fields that can't be patched.
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Certificate != nil && req.Certificate.Certificate == nil {
+		return response.BadRequest(fmt.Errorf("Invalid certificate for cluster member %q", name))
+	}
+
+	err = s.Database.InternalTransaction(r.Context(), func(ctx context.Context, tx *sql.Tx) error {
+		clusterMember, err := cluster.GetInternalClusterMember(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		if req.Certificate != nil {
+			clusterMember.Certificate = req.Certificate.String()
+		}
+
+		err = cluster.UpdateInternalClusterMember(ctx, tx, name, *clusterMember)
+		if err != nil {
+			return err
+		}
+
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeUpdate, name)
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
 // resetClusterMember clears the daemon state, closing the database and stopping all listeners.
 // Returns a function that can be used to re-exec the daemon, forcibly reloading its state.
 func resetClusterMember(ctx context.Context, s *state.State, force bool) (reExec func(), err error) {
@@ -264,7 +515,13 @@ func resetClusterMember(ctx context.Context, s *state.State, force bool) (reExec
 		return nil, fmt.Errorf("Failed to remove the s directory: %w", err)
 	}
 
-	reExec = func() {
+	return reExecDaemon(ctx, "Restarting daemon following removal from cluster"), nil
+}
+
+// reExecDaemon returns a function that waits for the request with the given context to finish, then re-execs the
+// daemon, forcibly reloading its state.
+func reExecDaemon(ctx context.Context, reason string) func() {
+	return func() {
 		<-ctx.Done() // Wait until request has finished.
 
 		// Wait until we can acquire the lock. This way if another request is holding the lock we won't
@@ -278,15 +535,177 @@ func resetClusterMember(ctx context.Context, s *state.State, force bool) (reExec
 
 		// The execPath from /proc/self/exe can end with " (deleted)" if the lxd binary has been removed/changed
 		// since the lxd process was started, strip this so that we only return a valid path.
-		logger.Info("Restarting daemon following removal from cluster")
+		logger.Info(reason)
 		execPath = strings.TrimSuffix(execPath, " (deleted)")
 		err = unix.Exec(execPath, os.Args, os.Environ())
 		if err != nil {
 			logger.Error("Failed restarting daemon", logger.Ctx{"err": err})
 		}
 	}
+}
+
+// clusterDelete removes several cluster members, one at a time, in an order that keeps quorum for as long as
+// possible: spares and members without a dqlite record first, then stand-bys, then voters, and the dqlite leader
+// last. Each removal goes through the leader as with clusterMemberDelete, which transfers leadership away from a
+// leader being removed. The member serving the request can't be removed, as it must stay up to finish the batch.
+func clusterDelete(s *state.State, r *http.Request) response.Response {
+	req := internalTypes.ClusterMembersRemoval{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if len(req.Names) == 0 {
+		return response.BadRequest(fmt.Errorf("No cluster members to remove"))
+	}
+
+	allRemotes := s.Remotes().RemotesByName()
+	removals := make(map[string]bool, len(req.Names))
+	for _, name := range req.Names {
+		if removals[name] {
+			return response.BadRequest(fmt.Errorf("Cluster member %q is listed more than once", name))
+		}
+
+		if name == s.Name() {
+			return response.BadRequest(fmt.Errorf("Cannot remove cluster member %q from a request it serves", name))
+		}
+
+		_, ok := allRemotes[name]
+		if !ok {
+			return response.SmartError(api.StatusErrorf(http.StatusNotFound, "No remote exists with the given name %q", name))
+		}
+
+		removals[name] = true
+	}
+
+	var clusterMembers []cluster.InternalClusterMember
+	err = s.Database.InternalTransaction(r.Context(), func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		clusterMembers, err = cluster.GetInternalClusterMembers(ctx, tx)
+
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	remaining := 0
+	for _, clusterMember := range clusterMembers {
+		if !clusterMember.Role.IsPending() && !removals[clusterMember.Name] {
+			remaining++
+		}
+	}
+
+	if remaining < 1 {
+		return response.BadRequest(fmt.Errorf("Cannot remove cluster members, there would be no remaining non-pending members"))
+	}
+
+	err = checkRemainingVoters(clusterMembers, req.Names, s.MinVoters, false)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, time.Second*30)
+	defer cancel()
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		_ = leader.Close()
+		return response.SmartError(err)
+	}
+
+	info, err := leader.Cluster(ctx)
+	_ = leader.Close()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	for _, name := range orderClusterMemberRemovals(req.Names, allRemotes, info, leaderInfo.Address) {
+		client, err := s.Leader()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		logger.Info("Removing cluster member", logger.Ctx{"member": name})
+		err = client.DeleteClusterMember(s.Context, name, false)
+		if err != nil {
+			return response.SmartError(fmt.Errorf("Failed to remove cluster member %q: %w", name, err))
+		}
+	}
 
-	return reExec, nil
+	return response.EmptySyncResponse
+}
+
+// checkRemainingVoters returns an error if removing the cluster members with the given names would leave fewer than
+// minVoters voters, as recorded in the database, since the cluster can't recover once it has lost all of its voters.
+// If force is set, the removal is allowed anyway.
+func checkRemainingVoters(clusterMembers []cluster.InternalClusterMember, names []string, minVoters int, force bool) error {
+	removals := make(map[string]bool, len(names))
+	for _, name := range names {
+		removals[name] = true
+	}
+
+	voter := cluster.Role(dqliteClient.Voter.String())
+	removedVoters := 0
+	remainingVoters := 0
+	for _, clusterMember := range clusterMembers {
+		if clusterMember.Role != voter {
+			continue
+		}
+
+		if removals[clusterMember.Name] {
+			removedVoters++
+		} else {
+			remainingVoters++
+		}
+	}
+
+	if removedVoters == 0 || remainingVoters >= minVoters {
+		return nil
+	}
+
+	if force {
+		logger.Warn("Forcing removal of cluster members below the minimum number of voters", logger.Ctx{"members": names, "voters": remainingVoters, "minimum": minVoters})
+		return nil
+	}
+
+	return api.StatusErrorf(http.StatusConflict, "Cannot remove cluster members %v, as only %d voters would remain out of a minimum of %d. Use force to remove them anyway", names, remainingVoters, minVoters)
+}
+
+// orderClusterMemberRemovals sorts the names of the cluster members to remove so that those that don't count towards
+// quorum are removed first, and the dqlite leader last. Members with the same rank keep their order.
+func orderClusterMemberRemovals(names []string, remotes map[string]trust.Remote, info []dqliteClient.NodeInfo, leaderAddress string) []string {
+	rank := func(name string) int {
+		address := remotes[name].Address.String()
+		if address == leaderAddress {
+			return 3
+		}
+
+		for _, node := range info {
+			if node.Address != address {
+				continue
+			}
+
+			switch node.Role {
+			case dqliteClient.Voter:
+				return 2
+			case dqliteClient.StandBy:
+				return 1
+			}
+		}
+
+		return 0
+	}
+
+	ordered := append([]string{}, names...)
+	sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+
+	return ordered
 }
 
 // clusterMemberDelete Removes a cluster member from dqlite and re-execs its daemon.
@@ -380,7 +799,7 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 	}
 
 	var clusterMembers []cluster.InternalClusterMember
-	err = s.Database.Transaction(r.Context(), func(ctx context.Context, tx *sql.Tx) error {
+	err = s.Database.InternalTransaction(r.Context(), func(ctx context.Context, tx *sql.Tx) error {
 		var err error
 		clusterMembers, err = cluster.GetInternalClusterMembers(ctx, tx)
 
@@ -392,7 +811,7 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 
 	numPending := 0
 	for _, clusterMember := range clusterMembers {
-		if clusterMember.Role == cluster.Pending {
+		if clusterMember.Role.IsPending() {
 			numPending++
 		}
 	}
@@ -401,6 +820,16 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(fmt.Errorf("Cannot remove cluster members, there are no remaining non-pending members"))
 	}
 
+	err = checkRemainingVoters(clusterMembers, []string{name}, s.MinVoters, force)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = checkDrained(ctx, s, name, force)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	if len(info) < 2 {
 		return response.SmartError(fmt.Errorf("Cannot leave a cluster with %d members", len(info)))
 	}
@@ -425,19 +854,7 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 
 	// If we are the leader and removing ourselves, reassign the leader role and perform the removal from there.
 	if allRemotes[name].Address.String() == leaderInfo.Address {
-		otherNodes := []uint64{}
-		for _, node := range info {
-			if node.Address != allRemotes[name].Address.String() && node.Role == dqliteClient.Voter {
-				otherNodes = append(otherNodes, node.ID)
-			}
-		}
-
-		if len(otherNodes) == 0 {
-			return response.SmartError(fmt.Errorf("Found no voters to transfer leadership to"))
-		}
-
-		randomID := otherNodes[rand.Intn(len(otherNodes))]
-		err = leader.Transfer(ctx, randomID)
+		err = transferLeadership(ctx, s, leader, info, leaderInfo.ID)
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -485,20 +902,43 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 	}
 
 	// Set the forwarded flag so that the the system to be removed knows the removal is in progress.
-	c, err := internalClient.New(remote.URL(), s.ServerCert(), publicKey, true)
+	c, err := internalClient.New(remote.URL(), s.ClientCert(), publicKey, true)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
 	// Tell the cluster member to run its PreRemove hook and return.
-	err = internalClient.RunPreRemoveHook(ctx, c.UseTarget(name), internalTypes.HookRemoveMemberOptions{Force: force})
+	err = internalClient.RunPreRemoveHook(ctx, c.UseTarget(name), internalTypes.HookRemoveMemberOptions{Force: force, Name: name})
 	if err != nil && !force {
 		return response.SmartError(err)
 	}
 
 	// Remove the cluster member from the database.
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
-		return cluster.DeleteInternalClusterMember(ctx, tx, remote.Address.String())
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.DeleteInternalClusterMember(ctx, tx, remote.Address.String())
+		if err != nil {
+			return err
+		}
+
+		// Clear any pending soft-deletion of the cluster member.
+		err = cluster.DeleteInternalClusterMemberRemoval(ctx, tx, name)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		err = cluster.DeleteInternalClusterMemberDrain(ctx, tx, name)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		err = cluster.DeleteCoreClusterMemberConfigs(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeDelete, name)
+
+		return nil
 	})
 	if err != nil {
 		return response.SmartError(err)
@@ -522,7 +962,7 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	c, err = internalClient.New(remote.URL(), s.ServerCert(), publicKey, false)
+	c, err = internalClient.New(remote.URL(), s.ClientCert(), publicKey, false)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -543,6 +983,10 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	recordMemberHook(s, name, false)
+
+	s.PublishEvent(types.EventMemberRemoved, map[string]string{"name": name})
+
 	// Run the PostRemove hook on all other members.
 	remotes := s.Remotes()
 	err = cluster.Query(s.Context, true, func(ctx context.Context, c *client.Client) error {
@@ -557,7 +1001,7 @@ func clusterMemberDelete(s *state.State, r *http.Request) response.Response {
 			return fmt.Errorf("No remote found at address %q run the post-remove hook", c.URL().URL.Host)
 		}
 
-		return internalClient.RunPostRemoveHook(ctx, c.Client.UseTarget(remote.Name), internalTypes.HookRemoveMemberOptions{Force: force})
+		return internalClient.RunPostRemoveHook(ctx, c.Client.UseTarget(remote.Name), internalTypes.HookRemoveMemberOptions{Force: force, Name: name})
 	})
 	if err != nil {
 		return response.SmartError(err)
@@ -0,0 +1,325 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/lxd/shared/revert"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/db"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// clusterMemberAddressCmd changes the address of the local cluster member. It is only served on the control socket,
+// since a member whose network has changed may no longer be reachable by its peers at its old address.
+//
+// The change is applied in the following order:
+//  1. The new address is validated: no other cluster member or dqlite node may use it, this member must be able to
+//     listen on it, and the ValidateDaemonConfig hook must accept it.
+//  2. The cluster member record is updated in the database. The leader pushes the new address to the truststore of
+//     every peer with its next heartbeat.
+//  3. The dqlite node is removed from the raft configuration and added back with the new address as a spare, after
+//     handing over leadership if it is the leader. The leader promotes it again once it is reachable. A single member
+//     cluster skips this step, as its raft configuration is rewritten locally instead.
+//  4. The local truststore, daemon configuration and dqlite node information are updated, and the daemon restarts to
+//     listen on the new address.
+//
+// If any of the first three steps fails, the steps before it are reverted. Once the dqlite node has been moved, the
+// change can't be reverted, as the rest of the cluster expects the member at its new address.
+var clusterMemberAddressCmd = rest.Endpoint{
+	Path: "cluster/{name}/address",
+
+	Put: rest.EndpointAction{Handler: clusterMemberAddressPut, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterMemberAddressPut(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := internalTypes.ClusterMemberAddress{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if !req.Address.IsValid() {
+		return response.BadRequest(fmt.Errorf("Invalid address for cluster member %q", name))
+	}
+
+	if name != s.Name() {
+		return response.BadRequest(fmt.Errorf("The address of cluster member %q can only be updated from that member", name))
+	}
+
+	oldAddress, err := types.ParseAddrPort(s.Address().URL.Host)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if req.Address == oldAddress {
+		return response.EmptySyncResponse
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, time.Second*30)
+	defer cancel()
+
+	local, err := s.DatabaseNodeInfo(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	dqliteCluster, err := s.Database.Cluster(ctx, leader)
+	_ = leader.Close()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = validateClusterMemberAddress(ctx, s, req.Address, dqliteCluster)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = state.ValidateDaemonConfigHook(ctx, s, trust.Location{Name: name, Address: oldAddress}, trust.Location{Name: name, Address: req.Address})
+	if err != nil {
+		return response.SmartError(api.StatusErrorf(http.StatusBadRequest, "Daemon configuration was rejected: %v", err))
+	}
+
+	reverter := revert.New()
+	defer reverter.Fail()
+
+	err = setClusterMemberAddress(ctx, s, name, req.Address)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	reverter.Add(func() {
+		err := setClusterMemberAddress(s.Context, s, name, oldAddress)
+		if err != nil {
+			logger.Error("Failed to revert cluster member address", logger.Ctx{"member": name, "error": err})
+		}
+	})
+
+	// Read the updated cluster member records now, as this member is briefly not part of the dqlite cluster below.
+	var clusterMembers []types.ClusterMember
+	err = s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		dbClusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		clusterMembers = make([]types.ClusterMember, 0, len(dbClusterMembers))
+		for _, clusterMember := range dbClusterMembers {
+			apiClusterMember, err := clusterMember.ToAPI()
+			if err != nil {
+				return err
+			}
+
+			clusterMembers = append(clusterMembers, *apiClusterMember)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	singleMember := len(dqliteCluster) == 1
+	if !singleMember {
+		err = moveDqliteNode(ctx, s, *local, req.Address)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	reverter.Success()
+
+	logger.Info("Updating cluster member address", logger.Ctx{"member": name, "old": oldAddress, "new": req.Address})
+
+	err = s.Remotes().Replace(s.OS.TrustDir, clusterMembers...)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = state.UpdateDaemonAddress(req.Address)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = s.Database.Stop()
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed shutting down database: %w", err))
+	}
+
+	err = s.Database.SetLocalAddress(ctx, req.Address, singleMember)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = state.StopListeners()
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed shutting down listeners: %w", err))
+	}
+
+	go reExecDaemon(r.Context(), "Restarting daemon following cluster member address change")()
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		err := response.EmptySyncResponse.Render(w)
+		if err != nil {
+			return err
+		}
+
+		// Send the response before replacing the daemon process.
+		f, ok := w.(http.Flusher)
+		if !ok {
+			return fmt.Errorf("ResponseWriter is not type http.Flusher")
+		}
+
+		f.Flush()
+
+		return nil
+	})
+}
+
+// validateClusterMemberAddress checks that the address isn't used by any cluster member or dqlite node, and that this
+// cluster member can listen on it.
+func validateClusterMemberAddress(ctx context.Context, s *state.State, address types.AddrPort, dqliteCluster []dqliteClient.NodeInfo) error {
+	newAddress := address.String()
+	err := s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		members, err := cluster.GetInternalClusterMembers(ctx, tx, cluster.InternalClusterMemberFilter{Address: &newAddress})
+		if err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			return api.StatusErrorf(http.StatusConflict, "Cluster member %q already has address %q", members[0].Name, newAddress)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, node := range dqliteCluster {
+		if node.Address == newAddress {
+			return api.StatusErrorf(http.StatusConflict, "Dqlite node %d already has address %q", node.ID, newAddress)
+		}
+	}
+
+	listener, err := net.Listen("tcp", newAddress)
+	if err != nil {
+		return api.StatusErrorf(http.StatusBadRequest, "Cannot listen on address %q: %v", newAddress, err)
+	}
+
+	return listener.Close()
+}
+
+// setClusterMemberAddress updates the address of the cluster member record with the given name.
+func setClusterMemberAddress(ctx context.Context, s *state.State, name string, address types.AddrPort) error {
+	return s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		clusterMember, err := cluster.GetInternalClusterMember(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		clusterMember.Address = address.String()
+
+		err = cluster.UpdateInternalClusterMember(ctx, tx, name, *clusterMember)
+		if err != nil {
+			return err
+		}
+
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeUpdate, name)
+
+		return nil
+	})
+}
+
+// moveDqliteNode removes the local dqlite node from the raft configuration and adds it back as a spare with the new
+// address, handing leadership over first if the node is the leader.
+func moveDqliteNode(ctx context.Context, s *state.State, local dqliteClient.NodeInfo, address types.AddrPort) error {
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = leader.Close() }()
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A node can't remove itself from the raft configuration while it is the leader, so hand leadership over first.
+	if leaderInfo.ID == local.ID {
+		dqliteCluster, err := s.Database.Cluster(ctx, leader)
+		if err != nil {
+			return err
+		}
+
+		err = transferLeadership(ctx, s, leader, dqliteCluster, local.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to transfer dqlite leadership: %w", err)
+		}
+
+		newLeader, err := s.Database.Leader(ctx)
+		if err != nil {
+			return err
+		}
+
+		_ = leader.Close()
+		leader = newLeader
+	}
+
+	return readdDqliteNode(ctx, leader, local, address)
+}
+
+// dqliteMembership changes the raft configuration of the dqlite cluster.
+type dqliteMembership interface {
+	Remove(ctx context.Context, id uint64) error
+	Add(ctx context.Context, node dqliteClient.NodeInfo) error
+}
+
+// readdDqliteNode removes the dqlite node from the raft configuration and adds it back as a spare with the new address.
+// If the node can't be added back, it is added back with its original address and role instead.
+func readdDqliteNode(ctx context.Context, leader dqliteMembership, local dqliteClient.NodeInfo, address types.AddrPort) error {
+	err := leader.Remove(ctx, local.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to remove dqlite node %d: %w", local.ID, err)
+	}
+
+	err = leader.Add(ctx, dqliteClient.NodeInfo{ID: local.ID, Address: address.String(), Role: dqliteClient.Spare})
+	if err != nil {
+		revertErr := leader.Add(ctx, local)
+		if revertErr != nil {
+			logger.Error("Failed to restore dqlite node", logger.Ctx{"id": local.ID, "address": local.Address, "error": revertErr})
+		}
+
+		return fmt.Errorf("Failed to add dqlite node %d with address %q: %w", local.ID, address.String(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type clusterAddressSuite struct {
+	suite.Suite
+}
+
+func TestClusterAddressSuite(t *testing.T) {
+	suite.Run(t, new(clusterAddressSuite))
+}
+
+// recordingMembership records the raft configuration changes made through it, failing the given ones.
+type recordingMembership struct {
+	calls    []string
+	failures map[string]bool
+}
+
+func (m *recordingMembership) Remove(ctx context.Context, id uint64) error {
+	return m.record(fmt.Sprintf("remove %d", id))
+}
+
+func (m *recordingMembership) Add(ctx context.Context, node dqliteClient.NodeInfo) error {
+	return m.record(fmt.Sprintf("add %d %s %s", node.ID, node.Address, node.Role))
+}
+
+func (m *recordingMembership) record(call string) error {
+	m.calls = append(m.calls, call)
+	if m.failures[call] {
+		return fmt.Errorf("Failed to %s", call)
+	}
+
+	return nil
+}
+
+// Ensures a dqlite node is removed before it is added back as a spare with its new address, and that it is restored
+// with its original address and role if it can't be added back.
+func (t *clusterAddressSuite) Test_readdDqliteNode() {
+	local := dqliteClient.NodeInfo{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter}
+	address, err := types.ParseAddrPort("10.0.0.3:9000")
+	t.Require().NoError(err)
+
+	leader := &recordingMembership{}
+	err = readdDqliteNode(context.Background(), leader, local, address)
+	t.NoError(err)
+	t.Equal([]string{"remove 2", "add 2 10.0.0.3:9000 spare"}, leader.calls)
+
+	leader = &recordingMembership{failures: map[string]bool{"add 2 10.0.0.3:9000 spare": true}}
+	err = readdDqliteNode(context.Background(), leader, local, address)
+	t.Error(err)
+	t.Equal([]string{"remove 2", "add 2 10.0.0.3:9000 spare", "add 2 10.0.0.2:9000 voter"}, leader.calls)
+
+	// The node is left alone if it can't be removed.
+	leader = &recordingMembership{failures: map[string]bool{"remove 2": true}}
+	err = readdDqliteNode(context.Background(), leader, local, address)
+	t.Error(err)
+	t.Equal([]string{"remove 2"}, leader.calls)
+}
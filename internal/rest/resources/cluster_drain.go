@@ -0,0 +1,301 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/cluster"
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// clusterMemberDrainCmd drains cluster members before their removal, and cancels drains.
+//
+// Requests are forwarded to the cluster member being drained. A draining member is recorded in the database, becomes
+// read-only, hands over dqlite leadership if it holds it, and runs its OnDrain hook in the background. Once the hook
+// returns, the member is recorded as drained and can be removed. Until then, removing it fails unless forced.
+//
+// Cancelling a drain deletes its record, cancels the OnDrain hook if it is still running, and makes the member
+// writable again. Members that miss the cancellation, for example because they were unreachable, pick it up from the
+// next heartbeat instead. Starting a drain again on a member whose hook failed runs the hook again.
+var clusterMemberDrainCmd = rest.Endpoint{
+	Path: "cluster/{name}/drain",
+
+	Post:   rest.EndpointAction{Handler: clusterMemberDrainPost, AccessHandler: access.AllowAuthenticated},
+	Delete: rest.EndpointAction{Handler: clusterMemberDrainDelete, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterMemberDrainPost(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	defer cancel()
+
+	// Only the cluster member being drained can stop accepting writes, so forward the request to it.
+	if name != s.Name() {
+		c, err := memberClient(s, name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = c.DrainClusterMember(internalClient.WithRequestID(ctx, internalClient.RequestID(r.Context())), name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	var drained bool
+	err = s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.GetInternalClusterMember(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		drain, err := cluster.GetInternalClusterMemberDrain(ctx, tx, name)
+		if err == nil {
+			drained = drain.Drained
+			return nil
+		} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		return cluster.CreateInternalClusterMemberDrain(ctx, tx, cluster.InternalClusterMemberDrain{Name: name, StartedAt: time.Now()})
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	s.Database.SetDraining(true)
+
+	err = shedLeadership(ctx, s)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to hand over dqlite leadership: %w", err))
+	}
+
+	if !drained {
+		logger.Info("Draining cluster member", logger.Ctx{"member": name})
+		startDrainHook(s)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func clusterMemberDrainDelete(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	defer cancel()
+
+	if name != s.Name() {
+		c, err := memberClient(s, name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = c.CancelClusterMemberDrain(internalClient.WithRequestID(ctx, internalClient.RequestID(r.Context())), name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	err = s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.DeleteInternalClusterMemberDrain(ctx, tx, name)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	s.Drains.CancelHook()
+	s.Database.SetDraining(false)
+	logger.Info("Cancelled drain of cluster member", logger.Ctx{"member": name})
+
+	return response.EmptySyncResponse
+}
+
+// memberClient returns a client to the cluster member with the given name.
+func memberClient(s *state.State, name string) (*internalClient.Client, error) {
+	remote, ok := s.Remotes().RemotesByName()[name]
+	if !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "No remote exists with the given name %q", name)
+	}
+
+	publicKey, err := s.ClusterCert().PublicKeyX509()
+	if err != nil {
+		return nil, err
+	}
+
+	return internalClient.New(remote.URL(), s.ClientCert(), publicKey, false)
+}
+
+// shedLeadership transfers dqlite leadership to another voter if this cluster member is the leader.
+func shedLeadership(ctx context.Context, s *state.State) error {
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = leader.Close() }()
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return err
+	}
+
+	if leaderInfo == nil || leaderInfo.Address != s.Address().URL.Host {
+		return nil
+	}
+
+	dqliteCluster, err := s.Database.Cluster(ctx, leader)
+	if err != nil {
+		return err
+	}
+
+	weights, err := leadershipWeights(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	target, ok := leadershipTarget(dqliteCluster, weights, leaderInfo.ID)
+	if !ok {
+		logger.Warn("No other dqlite voter to hand over leadership to", logger.Ctx{"member": s.Name()})
+		return nil
+	}
+
+	return leader.Transfer(ctx, target.ID)
+}
+
+// startDrainHook runs the OnDrain hook in the background, unless it is already running, and records this cluster
+// member as drained once it returns successfully.
+func startDrainHook(s *state.State) {
+	ctx, done, ok := s.Drains.StartHook(s.Context)
+	if !ok {
+		return
+	}
+
+	go func() {
+		defer done()
+
+		if state.OnDrainHook != nil {
+			err := state.OnDrainHook(ctx, s)
+			if err != nil {
+				logger.Error("Failed to run OnDrain hook", logger.Ctx{"member": s.Name(), "error": err})
+				return
+			}
+		}
+
+		// The drain may have been cancelled while the hook was running.
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+			return cluster.UpdateInternalClusterMemberDrained(ctx, tx, s.Name())
+		})
+		if err != nil {
+			logger.Error("Failed to record cluster member as drained", logger.Ctx{"member": s.Name(), "error": err})
+			return
+		}
+
+		logger.Info("Cluster member drained", logger.Ctx{"member": s.Name()})
+	}()
+}
+
+// checkDrained returns an error if the cluster member with the given name is still draining, unless the removal is
+// forced. Cluster members that were never drained can be removed.
+func checkDrained(ctx context.Context, s *state.State, name string, force bool) error {
+	var drain *cluster.InternalClusterMemberDrain
+	err := s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		drain, err = cluster.GetInternalClusterMemberDrain(ctx, tx, name)
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if drain == nil || drain.Drained {
+		return nil
+	}
+
+	if force {
+		logger.Warn("Forcing removal of draining cluster member", logger.Ctx{"member": name})
+		return nil
+	}
+
+	return api.StatusErrorf(http.StatusConflict, "Cluster member %q is still draining. Use force to remove it anyway", name)
+}
+
+// memberDrains returns the drain state of each cluster member being drained, keyed by cluster member name.
+func memberDrains(ctx context.Context, tx *sql.Tx) (map[string]types.MemberDrain, error) {
+	drainRecords, err := cluster.GetInternalClusterMemberDrains(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	drains := make(map[string]types.MemberDrain, len(drainRecords))
+	for _, drain := range drainRecords {
+		drains[drain.Name] = types.MemberDraining
+		if drain.Drained {
+			drains[drain.Name] = types.MemberDrained
+		}
+	}
+
+	return drains, nil
+}
+
+// notifyDrainChanges runs the OnDrainChange hook for each cluster member whose drain state differs from the one last
+// seen by this cluster member. As nothing has been seen after a restart, the hook runs for each draining member then.
+func notifyDrainChanges(s *state.State, clusterMembers []types.ClusterMember) {
+	current := make(map[string]bool, len(clusterMembers))
+	for _, clusterMember := range clusterMembers {
+		current[clusterMember.Name] = clusterMember.Drain != ""
+	}
+
+	previous := s.Drains.SwapDraining(current)
+
+	if state.OnDrainChangeHook == nil {
+		return
+	}
+
+	names := make([]string, 0, len(current))
+	for name, draining := range current {
+		if previous[name] != draining {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		err := state.OnDrainChangeHook(s, name, current[name])
+		if err != nil {
+			logger.Warn("Failed to run OnDrainChange hook", logger.Ctx{"member": name, "draining": current[name], "error": err})
+		}
+	}
+}
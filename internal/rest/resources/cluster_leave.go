@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// clusterLeaveCmd removes the local cluster member from the cluster, so that a member can scale itself down without
+// another member removing it by name. It is only served on the control socket.
+//
+// The removal goes through the same steps as removing the member by name from any other member:
+//  1. If this member is the dqlite leader, leadership is transferred to another voter first, and the removal is
+//     performed by the new leader. The member never removes itself from dqlite while it is still the leader.
+//  2. The leader runs the PreRemove hook on this member, then removes it from the database, from dqlite and from its
+//     truststore.
+//  3. The leader resets this member, which stops its database and listeners, clears its state directory and re-execs
+//     the daemon once this request has returned.
+//  4. The leader runs the PostRemove hook on itself and every remaining member.
+//
+// If the leader can't be reached, nothing is removed and the request fails. If the PreRemove hook fails, the removal
+// is aborted unless force is set. Once the member is removed from the database and dqlite, the removal can't be
+// undone: remaining members that can't be reached drop the member from their truststore with the next heartbeat, and
+// a failure to run their PostRemove hook is reported in the response.
+var clusterLeaveCmd = rest.Endpoint{
+	Path: "cluster/leave",
+
+	Post: rest.EndpointAction{Handler: clusterLeavePost, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterLeavePost(s *state.State, r *http.Request) response.Response {
+	name := s.Name()
+
+	logger.Info("Leaving the cluster", logger.Ctx{"member": name})
+
+	return clusterMemberDelete(s, mux.SetURLVars(r, map[string]string{"name": name}))
+}
@@ -0,0 +1,144 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// clusterMemberMaintenanceCmd puts cluster members into maintenance mode, and takes them out of it.
+//
+// Requests are forwarded to the cluster member concerned. A cluster member entering maintenance mode hands over dqlite
+// leadership right away if it holds it, rather than waiting until it would next begin a heartbeat round.
+var clusterMemberMaintenanceCmd = rest.Endpoint{
+	Path: "cluster/{name}/maintenance",
+
+	Post:   rest.EndpointAction{Handler: clusterMemberMaintenancePost, AccessHandler: access.AllowAuthenticated},
+	Delete: rest.EndpointAction{Handler: clusterMemberMaintenanceDelete, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterMemberMaintenancePost(s *state.State, r *http.Request) response.Response {
+	return setClusterMemberMaintenance(s, r, true)
+}
+
+func clusterMemberMaintenanceDelete(s *state.State, r *http.Request) response.Response {
+	return setClusterMemberMaintenance(s, r, false)
+}
+
+// setClusterMemberMaintenance puts the cluster member named in the request into maintenance mode, or takes it out of it.
+func setClusterMemberMaintenance(s *state.State, r *http.Request, maintenance bool) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	defer cancel()
+
+	if name != s.Name() {
+		c, err := memberClient(s, name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = c.SetClusterMemberMaintenance(internalClient.WithRequestID(ctx, internalClient.RequestID(r.Context())), name, maintenance)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	err = s.SetMaintenance(ctx, maintenance)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !maintenance {
+		logger.Info("Cluster member left maintenance mode", logger.Ctx{"member": name})
+		return response.EmptySyncResponse
+	}
+
+	logger.Info("Cluster member entered maintenance mode", logger.Ctx{"member": name})
+
+	err = shedLeadership(ctx, s)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to hand over dqlite leadership: %w", err))
+	}
+
+	return response.EmptySyncResponse
+}
+
+// handOverMaintenanceLeadership hands dqlite leadership over to a voter that is not in maintenance mode, if this
+// cluster member is the leader with the given ID and in maintenance mode. It returns whether another voter took
+// leadership, in which case this cluster member must not begin a heartbeat round.
+func handOverMaintenanceLeadership(ctx context.Context, s *state.State, leader *dqliteClient.Client, clusterMembers []internalTypes.ClusterMember, dqliteCluster []dqliteClient.NodeInfo, leaderID uint64) bool {
+	if !inMaintenance(s.Name(), clusterMembers) {
+		return false
+	}
+
+	weights, err := leadershipWeights(ctx, s)
+	if err != nil {
+		logger.Warn("Failed to get leadership weights", logger.Ctx{"error": err})
+		return false
+	}
+
+	target, ok := maintenanceHandoverTarget(clusterMembers, dqliteCluster, weights, leaderID)
+	if !ok {
+		logger.Warn("Beginning heartbeat round in maintenance mode, as no other voter can take dqlite leadership")
+		return false
+	}
+
+	logger.Info("Handing over dqlite leadership instead of beginning a heartbeat round in maintenance mode", logger.Ctx{"address": target.Address})
+	err = leader.Transfer(ctx, target.ID)
+	if err != nil {
+		logger.Warn("Failed to hand over dqlite leadership, beginning heartbeat round", logger.Ctx{"address": target.Address, "error": err})
+		return false
+	}
+
+	return true
+}
+
+// inMaintenance returns whether the cluster member with the given name is in maintenance mode.
+func inMaintenance(name string, clusterMembers []internalTypes.ClusterMember) bool {
+	for _, clusterMember := range clusterMembers {
+		if clusterMember.Name == name {
+			return clusterMember.Maintenance
+		}
+	}
+
+	return false
+}
+
+// maintenanceHandoverTarget returns the voter other than the leader with the given ID to hand dqlite leadership over
+// to, among those belonging to cluster members that are not in maintenance mode. It returns false if there is none.
+func maintenanceHandoverTarget(clusterMembers []internalTypes.ClusterMember, dqliteCluster []dqliteClient.NodeInfo, weights map[string]int, leaderID uint64) (dqliteClient.NodeInfo, bool) {
+	available := map[string]bool{}
+	for _, clusterMember := range clusterMembers {
+		if !clusterMember.Maintenance {
+			available[clusterMember.Address.String()] = true
+		}
+	}
+
+	candidates := make([]dqliteClient.NodeInfo, 0, len(dqliteCluster))
+	for _, node := range dqliteCluster {
+		if available[node.Address] {
+			candidates = append(candidates, node)
+		}
+	}
+
+	return leadershipTarget(candidates, weights, leaderID)
+}
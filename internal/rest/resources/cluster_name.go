@@ -0,0 +1,265 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/canonical/lxd/shared/revert"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/client"
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/db"
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// clusterMemberNameCmd renames the local cluster member, for example after its host was re-imaged with a new hostname.
+// It is only served on the control socket, as the daemon configuration of the member changes with it.
+//
+// The rename is applied in the following order:
+//  1. The new name is validated: it must be a valid FQDN that no other cluster member uses, and the
+//     ValidateDaemonConfig hook must accept it.
+//  2. A new server certificate is generated with the subject and names of the new name.
+//  3. The cluster member record is renamed in the database, and records the new certificate.
+//  4. The daemon configuration of this member is updated with the new name.
+//  5. The truststore entry of the member is renamed locally, with the new certificate.
+//  6. The new keypair is written to the state directory. If any of steps 3 to 6 fails, the steps before it are
+//     reverted, so the member keeps its old name and certificate.
+//  7. The truststore entry of the member is renamed on every other cluster member, with the new certificate. Members
+//     that can't be reached pick up the new name and certificate from the next heartbeat instead.
+//  8. If this member is the dqlite leader, leadership is handed over to another voter, so that the heartbeats that
+//     bring unreachable members up to date come from a member they still trust.
+//  9. The member switches to the new keypair. Only new connections use it.
+//
+// Dqlite identifies nodes by ID and address, so the dqlite configuration is left unchanged.
+var clusterMemberNameCmd = rest.Endpoint{
+	Path: "cluster/{name}/name",
+
+	Put: rest.EndpointAction{Handler: clusterMemberNamePut, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterMemberNamePut(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := internalTypes.ClusterMemberName{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if name != s.Name() {
+		return response.BadRequest(fmt.Errorf("Cluster member %q can only be renamed from that member", name))
+	}
+
+	if req.Name == name {
+		return response.EmptySyncResponse
+	}
+
+	err = validateFQDN(req.Name)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Invalid cluster member name %q: %w", req.Name, err))
+	}
+
+	address, err := types.ParseAddrPort(s.Address().URL.Host)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(internalClient.WithRequestID(s.Context, internalClient.RequestID(r.Context())), 30*time.Second)
+	defer cancel()
+
+	err = state.ValidateDaemonConfigHook(ctx, s, trust.Location{Name: name, Address: address}, trust.Location{Name: req.Name, Address: address})
+	if err != nil {
+		return response.SmartError(api.StatusErrorf(http.StatusBadRequest, "Daemon configuration was rejected: %v", err))
+	}
+
+	certPEM, keyPEM, err := state.NewServerCert(req.Name)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to generate server certificate: %w", err))
+	}
+
+	newCert, err := types.ParseX509Certificate(string(certPEM))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	currentCert, err := s.ServerCert().PublicKeyX509()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	oldCert := &types.X509Certificate{Certificate: currentCert}
+
+	reverter := revert.New()
+	defer reverter.Fail()
+
+	err = renameClusterMember(ctx, s, name, req.Name, newCert)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// If the rename can't be completed locally, rename the record back so that it still matches the daemon name and
+	// certificate used by heartbeats.
+	reverter.Add(func() {
+		err := renameClusterMember(context.Background(), s, req.Name, name, oldCert)
+		if err != nil {
+			logger.Error("Failed to revert cluster member rename", logger.Ctx{"old": name, "new": req.Name, "error": err})
+		}
+	})
+
+	err = state.UpdateDaemonName(req.Name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	reverter.Add(func() {
+		err := state.UpdateDaemonName(name)
+		if err != nil {
+			logger.Error("Failed to revert daemon name", logger.Ctx{"old": name, "new": req.Name, "error": err})
+		}
+	})
+
+	err = renameTrustStoreEntry(s, name, req.Name, newCert)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	reverter.Add(func() {
+		err := renameTrustStoreEntry(s, req.Name, name, oldCert)
+		if err != nil {
+			logger.Error("Failed to revert truststore entry rename", logger.Ctx{"old": name, "new": req.Name, "error": err})
+		}
+	})
+
+	err = replaceServerKeypair(s, certPEM, keyPEM, reverter)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	reverter.Success()
+
+	logger.Info("Renamed cluster member", logger.Ctx{"old": name, "new": req.Name})
+
+	err = renameOnClusterMembers(ctx, s, name, req.Name, newCert)
+	if err != nil {
+		logger.Warn("Failed to rename truststore entry on cluster members", logger.Ctx{"error": err})
+	}
+
+	err = shedLeadership(ctx, s)
+	if err != nil {
+		logger.Warn("Failed to hand over dqlite leadership before switching server certificate", logger.Ctx{"error": err})
+	}
+
+	err = state.ReloadServerCert()
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to load new server certificate: %w", err))
+	}
+
+	return response.EmptySyncResponse
+}
+
+// replaceServerKeypair writes the given server keypair to the state directory, and adds a revert step to the given
+// reverter that restores the previous keypair.
+func replaceServerKeypair(s *state.State, certPEM []byte, keyPEM []byte, reverter *revert.Reverter) error {
+	certPath := filepath.Join(s.OS.StateDir, "server.crt")
+	keyPath := filepath.Join(s.OS.StateDir, "server.key")
+
+	oldCertPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return err
+	}
+
+	oldKeyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	reverter.Add(func() {
+		for path, data := range map[string][]byte{certPath: oldCertPEM, keyPath: oldKeyPEM} {
+			err := writeCertFile(path, data)
+			if err != nil {
+				logger.Error("Failed to restore server keypair", logger.Ctx{"path": path, "error": err})
+			}
+		}
+	})
+
+	err = writeCertFile(keyPath, keyPEM)
+	if err != nil {
+		return fmt.Errorf("Failed to write server key: %w", err)
+	}
+
+	err = writeCertFile(certPath, certPEM)
+	if err != nil {
+		return fmt.Errorf("Failed to write server certificate: %w", err)
+	}
+
+	return nil
+}
+
+// renameOnClusterMembers renames the truststore entry of the cluster member on every other cluster member. Members
+// that can't be reached are only logged, as they pick up the new name from the next heartbeat.
+func renameOnClusterMembers(ctx context.Context, s *state.State, name string, newName string, cert *types.X509Certificate) error {
+	cluster, err := s.Cluster(true)
+	if err != nil {
+		return err
+	}
+
+	return cluster.Query(ctx, true, func(ctx context.Context, c *client.Client) error {
+		if s.Address().URL.Host == c.URL().URL.Host {
+			return nil
+		}
+
+		err := internalClient.RenameTrustStoreEntry(ctx, &c.Client, name, newName, cert)
+		if err != nil {
+			logger.Warn("Failed to rename truststore entry on cluster member", logger.Ctx{"address": c.URL().URL.Host, "error": err})
+		}
+
+		return nil
+	})
+}
+
+// renameClusterMember renames the cluster member record with the given name, and records the given server certificate
+// for it. Soft-deleted and draining cluster members can't be renamed, as their records refer to them by name.
+func renameClusterMember(ctx context.Context, s *state.State, name string, newName string, cert *types.X509Certificate) error {
+	return s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.RenameInternalClusterMember(ctx, tx, name, newName)
+		if err != nil {
+			return err
+		}
+
+		clusterMember, err := cluster.GetInternalClusterMember(ctx, tx, newName)
+		if err != nil {
+			return err
+		}
+
+		clusterMember.Certificate = cert.String()
+		err = cluster.UpdateInternalClusterMember(ctx, tx, newName, *clusterMember)
+		if err != nil {
+			return err
+		}
+
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeDelete, name)
+		s.Database.RecordChange(ctx, db.ClusterMembersTable, types.ChangeCreate, newName)
+
+		return nil
+	})
+}
@@ -0,0 +1,197 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/revert"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type clusterNameSuite struct {
+	suite.Suite
+}
+
+func TestClusterNameSuite(t *testing.T) {
+	suite.Run(t, new(clusterNameSuite))
+}
+
+// Ensures a cluster member can only rename itself, to a valid name that the ValidateDaemonConfig hook accepts, before
+// anything is renamed.
+func (t *clusterNameSuite) Test_clusterMemberNamePut() {
+	validate := state.ValidateDaemonConfigHook
+	defer func() { state.ValidateDaemonConfigHook = validate }()
+
+	var validated []trust.Location
+	state.ValidateDaemonConfigHook = func(ctx context.Context, s *state.State, current trust.Location, new trust.Location) error {
+		validated = append(validated, current, new)
+		return fmt.Errorf("Name is reserved")
+	}
+
+	s := &state.State{
+		Context: context.Background(),
+		Name:    func() string { return "member01" },
+		Address: func() *api.URL { return api.NewURL().Host("10.0.0.1:9000") },
+	}
+
+	put := func(name string, body string) int {
+		req := httptest.NewRequest("PUT", "/cluster/1.0/cluster/"+name+"/name", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"name": name})
+		recorder := httptest.NewRecorder()
+		err := clusterMemberNamePut(s, req).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusBadRequest, put("member02", `{"name": "member03"}`))
+	t.Equal(http.StatusBadRequest, put("member01", `{"name": "member_03"}`))
+	t.Equal(http.StatusOK, put("member01", `{"name": "member01"}`))
+	t.Empty(validated)
+
+	t.Equal(http.StatusBadRequest, put("member01", `{"name": "member03"}`))
+	t.Require().Len(validated, 2)
+	t.Equal("member01", validated[0].Name)
+	t.Equal("member03", validated[1].Name)
+	t.Equal(validated[0].Address, validated[1].Address)
+}
+
+// Ensures the truststore entry of a renamed cluster member is renamed once, keeping its certificate, and that unknown
+// cluster members are reported.
+func (t *clusterNameSuite) Test_renameTrustStoreEntry() {
+	cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	stateDir := t.T().TempDir()
+	trustDir := filepath.Join(stateDir, "truststore")
+	t.Require().NoError(os.Mkdir(trustDir, 0700))
+
+	members := make([]internalTypes.ClusterMember, 0, 2)
+	for i, name := range []string{"member01", "member02"} {
+		address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9000", i+1))
+		t.Require().NoError(err)
+
+		members = append(members, internalTypes.ClusterMember{ClusterMemberLocal: internalTypes.ClusterMemberLocal{
+			Name:        name,
+			Address:     address,
+			Certificate: types.X509Certificate{Certificate: x509Cert},
+		}})
+	}
+
+	remotes := &trust.Remotes{}
+	t.Require().NoError(remotes.Replace(trustDir, members...))
+
+	s := &state.State{
+		OS:      &sys.OS{StateDir: stateDir, TrustDir: trustDir},
+		Remotes: func() *trust.Remotes { return remotes },
+	}
+
+	t.Require().NoError(renameTrustStoreEntry(s, "member01", "member03", nil))
+	byName := remotes.RemotesByName()
+	t.Len(byName, 2)
+	t.NotContains(byName, "member01")
+	t.Require().Contains(byName, "member03")
+	t.Equal("10.0.0.1:9000", byName["member03"].Address.String())
+	t.True(byName["member03"].Certificate.Equal(x509Cert))
+
+	// A member that already renamed the entry, for example from a heartbeat, accepts the rename again.
+	t.NoError(renameTrustStoreEntry(s, "member01", "member03", nil))
+	t.Len(remotes.RemotesByName(), 2)
+
+	err = renameTrustStoreEntry(s, "member04", "member05", nil)
+	t.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+	// A new certificate replaces the one of the renamed entry.
+	newCert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	newX509Cert, err := newCert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	t.Require().NoError(renameTrustStoreEntry(s, "member03", "member04", &types.X509Certificate{Certificate: newX509Cert}))
+	byName = remotes.RemotesByName()
+	t.Require().Contains(byName, "member04")
+	t.True(byName["member04"].Certificate.Equal(newX509Cert))
+	t.True(byName["member02"].Certificate.Equal(x509Cert))
+
+	// An entry can't be renamed to the name of another cluster member.
+	err = renameTrustStoreEntry(s, "member04", "member02", nil)
+	t.True(api.StatusErrorCheck(err, http.StatusConflict))
+
+	err = renameTrustStoreEntry(s, "member05", "member02", &types.X509Certificate{Certificate: newX509Cert})
+	t.True(api.StatusErrorCheck(err, http.StatusConflict))
+	t.Len(remotes.RemotesByName(), 2)
+}
+
+// Ensures truststore entries aren't renamed to names that aren't valid FQDNs.
+func (t *clusterNameSuite) Test_trustPutInvalidName() {
+	for _, name := range []string{"", "member_03", "-member03"} {
+		req := httptest.NewRequest("PUT", "/cluster/internal/truststore/member01", strings.NewReader(fmt.Sprintf(`{"name": %q}`, name)))
+		req = mux.SetURLVars(req, map[string]string{"name": "member01"})
+		recorder := httptest.NewRecorder()
+		err := trustPut(&state.State{}, req).Render(recorder)
+		t.Require().NoError(err)
+		t.Equal(http.StatusBadRequest, recorder.Code, name)
+	}
+}
+
+// Ensures the server keypair is replaced in the state directory, and restored if the rename is reverted.
+func (t *clusterNameSuite) Test_replaceServerKeypair() {
+	stateDir := t.T().TempDir()
+	_, err := shared.KeyPairAndCA(stateDir, "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	read := func() (string, string) {
+		certPEM, err := os.ReadFile(filepath.Join(stateDir, "server.crt"))
+		t.Require().NoError(err)
+
+		keyPEM, err := os.ReadFile(filepath.Join(stateDir, "server.key"))
+		t.Require().NoError(err)
+
+		return string(certPEM), string(keyPEM)
+	}
+
+	oldCertPEM, oldKeyPEM := read()
+	s := &state.State{OS: &sys.OS{StateDir: stateDir}}
+
+	for _, fail := range []bool{false, true} {
+		certPEM, keyPEM, err := sys.NewNamedCertificate("member01", nil)
+		t.Require().NoError(err)
+
+		reverter := revert.New()
+		t.Require().NoError(replaceServerKeypair(s, certPEM, keyPEM, reverter))
+
+		currentCertPEM, currentKeyPEM := read()
+		t.Equal(string(certPEM), currentCertPEM)
+		t.Equal(string(keyPEM), currentKeyPEM)
+
+		if !fail {
+			reverter.Success()
+			oldCertPEM, oldKeyPEM = currentCertPEM, currentKeyPEM
+			continue
+		}
+
+		reverter.Fail()
+		currentCertPEM, currentKeyPEM = read()
+		t.Equal(oldCertPEM, currentCertPEM)
+		t.Equal(oldKeyPEM, currentKeyPEM)
+	}
+}
@@ -0,0 +1,309 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/cluster"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// clusterMemberRemovalCmd soft-deletes and restores cluster members.
+//
+// A soft-deleted member is demoted to a dqlite spare so it no longer counts towards quorum, and is left out of
+// heartbeat rounds so it receives no updates and its role is not refreshed. The leader keeps its trust store entry
+// so that it can be restored to its previous dqlite role until the grace period elapses, at which point the leader
+// finalizes the removal during its next heartbeat round.
+var clusterMemberRemovalCmd = rest.Endpoint{
+	Path: "cluster/{name}/removal",
+
+	Post:   rest.EndpointAction{Handler: clusterMemberRemovalPost, AccessHandler: access.AllowAuthenticated},
+	Delete: rest.EndpointAction{Handler: clusterMemberRemovalDelete, AccessHandler: access.AllowAuthenticated},
+}
+
+func clusterMemberRemovalPost(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := internalTypes.ClusterMemberRemoval{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.GracePeriod <= 0 {
+		return response.BadRequest(fmt.Errorf("Grace period must be greater than zero"))
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, time.Second*30)
+	defer cancel()
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// If we are not the leader, just forward the request.
+	if leaderInfo.Address != s.Address().URL.Host {
+		client, err := s.Leader()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = client.SoftDeleteClusterMember(s.Context, name, req.GracePeriod)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	remote, ok := s.Remotes().RemotesByName()[name]
+	if !ok {
+		return response.SmartError(fmt.Errorf("No remote exists with the given name %q", name))
+	}
+
+	if remote.Address.String() == leaderInfo.Address {
+		return response.SmartError(fmt.Errorf("Cannot soft-delete the dqlite leader %q", name))
+	}
+
+	info, err := leader.Cluster(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	var node *dqliteClient.NodeInfo
+	for _, member := range info {
+		if member.Address == remote.Address.String() {
+			node = &member
+			break
+		}
+	}
+
+	if node == nil {
+		return response.SmartError(fmt.Errorf("No dqlite record exists for %q", name))
+	}
+
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := cluster.GetInternalClusterMember(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = cluster.GetInternalClusterMemberRemoval(ctx, tx, name)
+		if err == nil {
+			return api.StatusErrorf(http.StatusConflict, "Cluster member %q is already removed", name)
+		} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		return cluster.CreateInternalClusterMemberRemoval(ctx, tx, cluster.InternalClusterMemberRemoval{
+			Name:      name,
+			Role:      cluster.Role(node.Role.String()),
+			ExpiresAt: time.Now().Add(req.GracePeriod),
+		})
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Demote the member to a spare so that it no longer takes part in quorum.
+	if node.Role != dqliteClient.Spare {
+		err = leader.Assign(ctx, node.ID, dqliteClient.Spare)
+		if err != nil {
+			revertErr := s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+				return cluster.DeleteInternalClusterMemberRemoval(ctx, tx, name)
+			})
+			if revertErr != nil {
+				logger.Error("Failed to revert cluster member removal", logger.Ctx{"member": name, "error": revertErr})
+			}
+
+			return response.SmartError(fmt.Errorf("Failed to demote cluster member %q: %w", name, err))
+		}
+	}
+
+	logger.Info("Soft-deleted cluster member", logger.Ctx{"member": name, "expires": time.Now().Add(req.GracePeriod)})
+
+	return response.EmptySyncResponse
+}
+
+func clusterMemberRemovalDelete(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, time.Second*30)
+	defer cancel()
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// If we are not the leader, just forward the request.
+	if leaderInfo.Address != s.Address().URL.Host {
+		client, err := s.Leader()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = client.RestoreClusterMember(s.Context, name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	remote, ok := s.Remotes().RemotesByName()[name]
+	if !ok {
+		return response.SmartError(fmt.Errorf("No remote exists with the given name %q", name))
+	}
+
+	var removal *cluster.InternalClusterMemberRemoval
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		removal, err = cluster.GetInternalClusterMemberRemoval(ctx, tx, name)
+
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	role, err := parseDqliteRole(string(removal.Role))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	info, err := leader.Cluster(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	for _, node := range info {
+		if node.Address != remote.Address.String() || node.Role == role {
+			continue
+		}
+
+		err = leader.Assign(ctx, node.ID, role)
+		if err != nil {
+			return response.SmartError(fmt.Errorf("Failed to restore dqlite role of cluster member %q: %w", name, err))
+		}
+	}
+
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.DeleteInternalClusterMemberRemoval(ctx, tx, name)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	logger.Info("Restored cluster member", logger.Ctx{"member": name, "role": removal.Role})
+
+	return response.EmptySyncResponse
+}
+
+// reconcileClusterMemberRemovals is run by the leader during a heartbeat round. It finalizes the removal of
+// soft-deleted cluster members whose grace period has elapsed, and keeps the remaining ones demoted in case dqlite
+// has since promoted them.
+func reconcileClusterMemberRemovals(ctx context.Context, s *state.State, leader *dqliteClient.Client, dqliteCluster []dqliteClient.NodeInfo) error {
+	var removals []cluster.InternalClusterMemberRemoval
+	err := s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		removals, err = cluster.GetInternalClusterMemberRemovals(ctx, tx)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	expired, demotions := planClusterMemberRemovals(removals, s.Remotes().RemotesByName(), dqliteCluster, time.Now())
+	for _, name := range expired {
+		logger.Info("Finalizing removal of soft-deleted cluster member", logger.Ctx{"member": name})
+
+		client, err := s.Leader()
+		if err != nil {
+			return err
+		}
+
+		err = client.DeleteClusterMember(ctx, name, false)
+		if err != nil {
+			logger.Error("Failed to finalize removal of cluster member", logger.Ctx{"member": name, "error": err})
+		}
+	}
+
+	for name, id := range demotions {
+		err = leader.Assign(ctx, id, dqliteClient.Spare)
+		if err != nil {
+			logger.Error("Failed to demote soft-deleted cluster member", logger.Ctx{"member": name, "error": err})
+		}
+	}
+
+	return nil
+}
+
+// planClusterMemberRemovals returns the names of the soft-deleted cluster members whose grace period has elapsed at
+// the given time, and the dqlite node IDs, by cluster member name, of the remaining ones that are no longer spares.
+func planClusterMemberRemovals(removals []cluster.InternalClusterMemberRemoval, remotes map[string]trust.Remote, dqliteCluster []dqliteClient.NodeInfo, now time.Time) (expired []string, demotions map[string]uint64) {
+	demotions = map[string]uint64{}
+	for _, removal := range removals {
+		if now.After(removal.ExpiresAt) {
+			expired = append(expired, removal.Name)
+			continue
+		}
+
+		remote, ok := remotes[removal.Name]
+		if !ok {
+			continue
+		}
+
+		for _, node := range dqliteCluster {
+			if node.Address == remote.Address.String() && node.Role != dqliteClient.Spare {
+				demotions[removal.Name] = node.ID
+			}
+		}
+	}
+
+	return expired, demotions
+}
+
+// parseDqliteRole returns the dqlite role matching its string representation.
+func parseDqliteRole(role string) (dqliteClient.NodeRole, error) {
+	for _, r := range []dqliteClient.NodeRole{dqliteClient.Voter, dqliteClient.StandBy, dqliteClient.Spare} {
+		if r.String() == role {
+			return r, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown dqlite role %q", role)
+}
@@ -0,0 +1,58 @@
+package resources
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type clusterRemovalSuite struct {
+	suite.Suite
+}
+
+func TestClusterRemovalSuite(t *testing.T) {
+	suite.Run(t, new(clusterRemovalSuite))
+}
+
+// Ensures removals are finalized once their grace period has elapsed, and that members still in their grace period
+// are demoted again if dqlite promoted them.
+func (t *clusterRemovalSuite) Test_planClusterMemberRemovals() {
+	now := time.Now()
+	remotes := map[string]trust.Remote{}
+	dqliteCluster := []dqliteClient.NodeInfo{}
+	for i, role := range []dqliteClient.NodeRole{dqliteClient.Voter, dqliteClient.Voter, dqliteClient.Spare, dqliteClient.StandBy} {
+		name := fmt.Sprintf("member%02d", i+1)
+		address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9000", i+1))
+		t.Require().NoError(err)
+
+		remotes[name] = trust.Remote{Location: trust.Location{Name: name, Address: address}}
+		dqliteCluster = append(dqliteCluster, dqliteClient.NodeInfo{ID: uint64(i + 1), Address: address.String(), Role: role})
+	}
+
+	removals := []cluster.InternalClusterMemberRemoval{
+		{Name: "member02", ExpiresAt: now.Add(-time.Minute)},
+		{Name: "member03", ExpiresAt: now.Add(time.Minute)},
+		{Name: "member04", ExpiresAt: now.Add(time.Minute)},
+		{Name: "member05", ExpiresAt: now.Add(time.Minute)},
+	}
+
+	expired, demotions := planClusterMemberRemovals(removals, remotes, dqliteCluster, now)
+	t.Equal([]string{"member02"}, expired)
+	t.Equal(map[string]uint64{"member04": 4}, demotions)
+
+	// Nothing is left to do once every removal is finalized.
+	expired, demotions = planClusterMemberRemovals(removals, remotes, dqliteCluster, now.Add(time.Hour))
+	t.Equal([]string{"member02", "member03", "member04", "member05"}, expired)
+	t.Empty(demotions)
+
+	expired, demotions = planClusterMemberRemovals(nil, remotes, dqliteCluster, now)
+	t.Empty(expired)
+	t.Empty(demotions)
+}
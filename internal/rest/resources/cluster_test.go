@@ -0,0 +1,312 @@
+package resources
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/cluster"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type clusterSuite struct {
+	suite.Suite
+}
+
+func TestClusterSuite(t *testing.T) {
+	suite.Run(t, new(clusterSuite))
+}
+
+// Ensures the cluster members list only returns a page when explicitly asked to, and rejects invalid page bounds,
+// roles and statuses.
+func (t *clusterSuite) Test_parseClusterMembersFilter() {
+	_, paged, err := parseClusterMembersFilter(url.Values{})
+	t.NoError(err)
+	t.False(paged)
+
+	filter, paged, err := parseClusterMembersFilter(url.Values{"role": {"voter"}, "status": {"ONLINE"}})
+	t.NoError(err)
+	t.False(paged)
+	t.Equal(internalTypes.ClusterMembersFilter{Role: "voter", Status: internalTypes.MemberOnline}, filter)
+
+	filter, paged, err = parseClusterMembersFilter(url.Values{"paged": {"1"}, "limit": {"10"}, "offset": {"20"}, "role": {"OBSERVER"}})
+	t.NoError(err)
+	t.True(paged)
+	t.Equal(internalTypes.ClusterMembersFilter{Limit: 10, Offset: 20, Role: "OBSERVER"}, filter)
+
+	for _, query := range []url.Values{
+		{"limit": {"10"}},
+		{"offset": {"10"}},
+		{"paged": {"1"}, "limit": {"-1"}},
+		{"paged": {"1"}, "offset": {"first"}},
+		{"role": {"leader"}},
+		{"status": {"online"}},
+	} {
+		_, _, err = parseClusterMembersFilter(query)
+		t.Error(err, query.Encode())
+	}
+}
+
+// Ensures cluster members are filtered by status before the page is selected.
+func (t *clusterSuite) Test_pageClusterMembers() {
+	members := []internalTypes.ClusterMember{}
+	for i, status := range []internalTypes.MemberStatus{internalTypes.MemberOnline, internalTypes.MemberUnreachable, internalTypes.MemberOnline, internalTypes.MemberOnline} {
+		member := internalTypes.ClusterMember{Status: status}
+		member.Name = string(rune('a' + i))
+		members = append(members, member)
+	}
+
+	page, total := pageClusterMembers(members, internalTypes.ClusterMembersFilter{Status: internalTypes.MemberOnline, Limit: 1, Offset: 1})
+	t.Equal(3, total)
+	t.Require().Len(page, 1)
+	t.Equal("c", page[0].Name)
+
+	page, total = pageClusterMembers(members, internalTypes.ClusterMembersFilter{Status: internalTypes.MemberOnline, Offset: 3})
+	t.Equal(3, total)
+	t.Empty(page)
+}
+
+// Ensures batch removals start with members that don't count towards quorum and end with the dqlite leader.
+func (t *clusterSuite) Test_orderClusterMemberRemovals() {
+	remotes := map[string]trust.Remote{}
+	var info []dqliteClient.NodeInfo
+	for i, role := range []dqliteClient.NodeRole{dqliteClient.Voter, dqliteClient.Voter, dqliteClient.StandBy, dqliteClient.Spare} {
+		name := fmt.Sprintf("member%02d", i)
+		address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9000", i))
+		t.Require().NoError(err)
+
+		remotes[name] = trust.Remote{Location: trust.Location{Name: name, Address: address}}
+		info = append(info, dqliteClient.NodeInfo{ID: uint64(i + 1), Address: address.String(), Role: role})
+	}
+
+	// member04 has no dqlite record, as it never finished joining.
+	address, err := types.ParseAddrPort("10.0.0.4:9000")
+	t.Require().NoError(err)
+	remotes["member04"] = trust.Remote{Location: trust.Location{Name: "member04", Address: address}}
+
+	ordered := orderClusterMemberRemovals([]string{"member00", "member01", "member02", "member03", "member04"}, remotes, info, "10.0.0.0:9000")
+	t.Equal([]string{"member03", "member04", "member02", "member01", "member00"}, ordered)
+}
+
+// Ensures cluster members are only removed below the minimum number of voters when the removal is forced.
+func (t *clusterSuite) Test_checkRemainingVoters() {
+	voter := cluster.Role(dqliteClient.Voter.String())
+	spare := cluster.Role(dqliteClient.Spare.String())
+
+	// Removing the only voter would make the cluster unrecoverable.
+	members := []cluster.InternalClusterMember{
+		{Name: "member01", Role: voter},
+		{Name: "member02", Role: spare},
+		{Name: "member03", Role: cluster.Pending},
+	}
+
+	err := checkRemainingVoters(members, []string{"member01"}, 1, false)
+	t.True(api.StatusErrorCheck(err, http.StatusConflict))
+	t.NoError(checkRemainingVoters(members, []string{"member01"}, 1, true))
+	t.NoError(checkRemainingVoters(members, []string{"member02", "member03"}, 1, false))
+
+	// With several voters, removals are allowed down to the minimum.
+	members = []cluster.InternalClusterMember{
+		{Name: "member01", Role: voter},
+		{Name: "member02", Role: voter},
+		{Name: "member03", Role: voter},
+	}
+
+	t.NoError(checkRemainingVoters(members, []string{"member01"}, 1, false))
+	t.NoError(checkRemainingVoters(members, []string{"member01", "member02"}, 1, false))
+	t.Error(checkRemainingVoters(members, []string{"member01", "member02", "member03"}, 1, false))
+	t.Error(checkRemainingVoters(members, []string{"member01", "member02"}, 2, false))
+	t.NoError(checkRemainingVoters(members, []string{"member01", "member02"}, 2, true))
+}
+
+// Ensures observers are only pending until they join, and never count towards the remaining voters.
+func (t *clusterSuite) Test_observerRole() {
+	t.True(cluster.PendingObserver.IsPending())
+	t.True(cluster.PendingObserver.IsObserver())
+	t.False(cluster.Observer.IsPending())
+	t.True(cluster.Observer.IsObserver())
+	t.True(cluster.Pending.IsPending())
+	t.False(cluster.Pending.IsObserver())
+
+	members := []cluster.InternalClusterMember{
+		{Name: "member01", Role: cluster.Role(dqliteClient.Voter.String())},
+		{Name: "member02", Role: cluster.Observer},
+		{Name: "member03", Role: cluster.PendingObserver},
+	}
+
+	err := checkRemainingVoters(members, []string{"member01"}, 1, false)
+	t.True(api.StatusErrorCheck(err, http.StatusConflict))
+	t.NoError(checkRemainingVoters(members, []string{"member02", "member03"}, 1, false))
+}
+
+// Ensures leadership goes to the voter with the highest leadership weight, other than the current leader.
+func (t *clusterSuite) Test_leadershipTarget() {
+	info := []dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter},
+		{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter},
+		{ID: 3, Address: "10.0.0.3:9000", Role: dqliteClient.Voter},
+		{ID: 4, Address: "10.0.0.4:9000", Role: dqliteClient.StandBy},
+	}
+
+	weights := map[string]int{"10.0.0.1:9000": 10, "10.0.0.3:9000": 5, "10.0.0.4:9000": 20}
+
+	target, ok := leadershipTarget(info, weights, 2)
+	t.True(ok)
+	t.Equal(uint64(1), target.ID)
+
+	// The leader itself is never picked, and neither are non-voters.
+	target, ok = leadershipTarget(info, weights, 1)
+	t.True(ok)
+	t.Equal(uint64(3), target.ID)
+
+	_, ok = leadershipTarget(info[:1], weights, 1)
+	t.False(ok)
+
+	// Invalid weights count as 0.
+	t.Equal(0, cluster.LeadershipWeight(map[string]string{cluster.LeadershipWeightKey: "high"}))
+	t.Equal(7, cluster.LeadershipWeight(map[string]string{cluster.LeadershipWeightKey: "7"}))
+}
+
+// Ensures cluster members in maintenance mode only get leadership if no other voter can take it.
+func (t *clusterSuite) Test_leadershipTargetMaintenance() {
+	info := []dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter},
+		{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter},
+		{ID: 3, Address: "10.0.0.3:9000", Role: dqliteClient.Voter},
+	}
+
+	t.True(cluster.InMaintenance(map[string]string{cluster.MaintenanceKey: "true"}))
+	t.False(cluster.InMaintenance(map[string]string{cluster.LeadershipWeightKey: "10"}))
+
+	// The weights of members in maintenance mode are set to the minimum by leadershipWeights.
+	weights := map[string]int{"10.0.0.2:9000": math.MinInt, "10.0.0.3:9000": -5}
+
+	target, ok := leadershipTarget(info, weights, 1)
+	t.True(ok)
+	t.Equal(uint64(3), target.ID)
+
+	target, ok = leadershipTarget(info[:2], weights, 1)
+	t.True(ok)
+	t.Equal(uint64(2), target.ID)
+}
+
+// Ensures a leader in maintenance mode only skips its heartbeat round when a voter that is not in maintenance mode can
+// take leadership, so that heartbeats never stop.
+func (t *clusterSuite) Test_maintenanceHandoverTarget() {
+	info := []dqliteClient.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9000", Role: dqliteClient.Voter},
+		{ID: 2, Address: "10.0.0.2:9000", Role: dqliteClient.Voter},
+		{ID: 3, Address: "10.0.0.3:9000", Role: dqliteClient.Voter},
+		{ID: 4, Address: "10.0.0.4:9000", Role: dqliteClient.Spare},
+	}
+
+	members := make([]internalTypes.ClusterMember, 0, len(info))
+	for i, node := range info {
+		address, err := types.ParseAddrPort(node.Address)
+		t.Require().NoError(err)
+
+		members = append(members, internalTypes.ClusterMember{ClusterMemberLocal: internalTypes.ClusterMemberLocal{Name: fmt.Sprintf("member0%d", i+1), Address: address}})
+	}
+
+	// The leader is not in maintenance mode, so it begins the round itself.
+	t.False(inMaintenance("member01", members))
+
+	members[0].Maintenance = true
+	members[1].Maintenance = true
+	t.True(inMaintenance("member01", members))
+
+	target, ok := maintenanceHandoverTarget(members, info, map[string]int{}, 1)
+	t.True(ok)
+	t.Equal(uint64(3), target.ID)
+
+	// With every other voter in maintenance mode, nobody takes over and the leader begins the round.
+	members[2].Maintenance = true
+	_, ok = maintenanceHandoverTarget(members, info, map[string]int{}, 1)
+	t.False(ok)
+
+	// Neither does a single voter, even if a spare is not in maintenance mode.
+	_, ok = maintenanceHandoverTarget(members[:1], info[:1], map[string]int{}, 1)
+	t.False(ok)
+
+	_, ok = maintenanceHandoverTarget(members, append(info[:1:1], info[3]), map[string]int{}, 1)
+	t.False(ok)
+}
+
+// Ensures listing cluster members again returns 304 Not Modified until the list changes, and a new ETag after.
+func (t *clusterSuite) Test_checkETag() {
+	members := []internalTypes.ClusterMember{
+		{ClusterMemberLocal: internalTypes.ClusterMemberLocal{Name: "member01"}},
+		{ClusterMemberLocal: internalTypes.ClusterMemberLocal{Name: "member02"}},
+	}
+
+	list := func(etag string) (string, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest("GET", "/1.0/cluster", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		tag, notModified := checkETag(req, members)
+		if notModified == nil {
+			return tag, nil
+		}
+
+		recorder := httptest.NewRecorder()
+		err := notModified.Render(recorder)
+		t.Require().NoError(err)
+
+		return tag, recorder
+	}
+
+	etag, resp := list("")
+	t.Nil(resp)
+	t.NotEmpty(etag)
+
+	tag, resp := list(etag)
+	t.Equal(etag, tag)
+	t.Require().NotNil(resp)
+	t.Equal(http.StatusNotModified, resp.Code)
+	t.Equal(etag, resp.Header().Get("ETag"))
+	t.Empty(resp.Body.Bytes())
+
+	_, resp = list(`"other", ` + etag)
+	t.Require().NotNil(resp)
+	t.Equal(http.StatusNotModified, resp.Code)
+
+	members[1].LastHeartbeat = members[1].LastHeartbeat.Add(time.Second)
+
+	tag, resp = list(etag)
+	t.Nil(resp)
+	t.NotEqual(etag, tag)
+}
+
+// Ensures a PATCH can't change the address, role or schema versions of a cluster member, leaving the database untouched.
+func (t *clusterSuite) Test_clusterMemberPatchRejected() {
+	for _, body := range []string{
+		`{"address": "10.0.0.2:9000"}`,
+		`{"role": "voter"}`,
+		`{"schema_internal": 1}`,
+		`{"certificate": "not a certificate"}`,
+	} {
+		req := httptest.NewRequest("PATCH", "/1.0/cluster/member01", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"name": "member01"})
+
+		// The state has no database, so reaching it would panic.
+		recorder := httptest.NewRecorder()
+		err := clusterMemberPatch(&state.State{}, req).Render(recorder)
+		t.Require().NoError(err)
+		t.Equal(http.StatusBadRequest, recorder.Code, body)
+	}
+}
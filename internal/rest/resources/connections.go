@@ -0,0 +1,38 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+var connectionsCmd = rest.Endpoint{
+	AllowedBeforeInit:     true,
+	AllowedDuringShutdown: true,
+	AllowedWhenDBOffline:  true,
+	Path:                  "connections",
+
+	Get: rest.EndpointAction{Handler: connectionsGet, AccessHandler: access.AllowAuthenticated},
+}
+
+var listenersCmd = rest.Endpoint{
+	AllowedBeforeInit:     true,
+	AllowedDuringShutdown: true,
+	AllowedWhenDBOffline:  true,
+	Path:                  "listeners",
+
+	Get: rest.EndpointAction{Handler: listenersGet, AccessHandler: access.AllowAuthenticated},
+}
+
+func connectionsGet(state *state.State, r *http.Request) response.Response {
+	return response.SyncResponse(true, types.Connections{Active: state.ActiveConnections()})
+}
+
+func listenersGet(state *state.State, r *http.Request) response.Response {
+	return response.SyncResponse(true, state.Listeners())
+}
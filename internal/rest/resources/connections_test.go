@@ -0,0 +1,65 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/endpoints"
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+)
+
+type connectionsSuite struct {
+	suite.Suite
+}
+
+func TestConnectionsSuite(t *testing.T) {
+	suite.Run(t, new(connectionsSuite))
+}
+
+// Ensures the control socket reports the connections open across all of the daemon's listeners.
+func (t *connectionsSuite) Test_connectionsGet() {
+	get := func(s *state.State) int64 {
+		recorder := httptest.NewRecorder()
+		err := connectionsGet(s, httptest.NewRequest("GET", "/cluster/control/connections", nil)).Render(recorder)
+		t.Require().NoError(err)
+
+		var resp api.ResponseRaw
+		err = json.NewDecoder(recorder.Result().Body).Decode(&resp)
+		t.Require().NoError(err)
+
+		metadata, err := json.Marshal(resp.Metadata)
+		t.Require().NoError(err)
+
+		connections := types.Connections{}
+		t.Require().NoError(json.Unmarshal(metadata, &connections))
+
+		return connections.Active
+	}
+
+	// The endpoints aren't set up before the daemon has started.
+	t.Equal(int64(0), get(&state.State{}))
+
+	control := &http.Server{}
+	network := &http.Server{}
+	s := &state.State{Endpoints: endpoints.NewEndpoints(context.Background(),
+		endpoints.NewSocket(context.Background(), control, *api.NewURL().Host("control.socket"), ""),
+		endpoints.NewSocket(context.Background(), network, *api.NewURL().Host("network.socket"), ""),
+	)}
+
+	control.ConnState(nil, http.StateNew)
+	network.ConnState(nil, http.StateNew)
+	network.ConnState(nil, http.StateNew)
+	t.Equal(int64(3), get(s))
+
+	network.ConnState(nil, http.StateActive)
+	network.ConnState(nil, http.StateHijacked)
+	control.ConnState(nil, http.StateClosed)
+	t.Equal(int64(1), get(s))
+}
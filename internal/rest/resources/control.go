@@ -2,10 +2,17 @@ package resources
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/lxd/util"
@@ -13,7 +20,6 @@ import (
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
 	"github.com/canonical/lxd/shared/revert"
-	"github.com/canonical/lxd/shared/validate"
 
 	"github.com/canonical/microcluster/internal/rest/client"
 	internalTypes "github.com/canonical/microcluster/internal/rest/types"
@@ -24,24 +30,83 @@ import (
 	"github.com/canonical/microcluster/rest/types"
 )
 
+// controlMu ensures only one bootstrap or join operation runs at a time. It is released once the operation has
+// completed, and is reset by a re-exec of the daemon.
+var controlMu sync.Mutex
+
 var controlCmd = rest.Endpoint{
 	AllowedBeforeInit: true,
 
 	Post: rest.EndpointAction{Handler: controlPost, AccessHandler: access.AllowAuthenticated},
 }
 
+// preInitAddressCmd moves the network listener that serves the API before the daemon is initialized, for example if
+// its port turns out to be taken. It is rejected once the database is open, and while a bootstrap or join is running.
+var preInitAddressCmd = rest.Endpoint{
+	Path:              "listen-address",
+	AllowedBeforeInit: true,
+
+	Put: rest.EndpointAction{Handler: preInitAddressPut, AccessHandler: access.AllowAuthenticated},
+}
+
+func preInitAddressPut(s *state.State, r *http.Request) response.Response {
+	req := internalTypes.PreInitAddress{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if !req.Address.IsValid() {
+		return response.BadRequest(fmt.Errorf("Invalid listen address"))
+	}
+
+	if !controlMu.TryLock() {
+		return response.SmartError(api.StatusErrorf(http.StatusConflict, "A bootstrap or join operation is in progress"))
+	}
+
+	defer controlMu.Unlock()
+
+	err = state.SetPreInitAddress(req.Address)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	logger.Info("Moved pre-initialization listener", logger.Ctx{"address": req.Address.String()})
+
+	return response.EmptySyncResponse
+}
+
 // validateFQDN validates that the given name is a a valid fully qualified domain name.
+// The returned error wraps one of the types.ErrName errors, describing the violated constraint.
 func validateFQDN(name string) error {
 	// Validate length
 	if len(name) < 1 || len(name) > 255 {
-		return fmt.Errorf("Name must be 1-255 characters long")
+		return fmt.Errorf("%w, got %d", types.ErrNameLength, len(name))
 	}
 
 	hostnames := strings.Split(name, ".")
 	for _, h := range hostnames {
-		err := validate.IsHostname(h)
-		if err != nil {
-			return err
+		if h == "" {
+			return types.ErrNameEmptyLabel
+		}
+
+		if len(h) > 63 {
+			return fmt.Errorf("%w, label %q is %d characters long", types.ErrNameLabelLength, h, len(h))
+		}
+
+		if strings.HasPrefix(h, "-") || strings.HasSuffix(h, "-") {
+			return fmt.Errorf("%w, got label %q", types.ErrNameHyphen, h)
+		}
+
+		for _, c := range h {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Errorf("%w, label %q contains %q", types.ErrNameCharacter, h, c)
+			}
+		}
+
+		_, err := strconv.ParseUint(h, 10, 64)
+		if err == nil {
+			return fmt.Errorf("%w, got label %q", types.ErrNameNumeric, h)
 		}
 	}
 
@@ -49,9 +114,14 @@ func validateFQDN(name string) error {
 }
 
 func controlPost(state *state.State, r *http.Request) response.Response {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	req := &internalTypes.Control{}
 	// Parse the request.
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err = json.Unmarshal(body, &req)
 	if err != nil {
 		return response.BadRequest(err)
 	}
@@ -60,17 +130,139 @@ func controlPost(state *state.State, r *http.Request) response.Response {
 		return response.SmartError(fmt.Errorf("Invalid options - received join token and bootstrap flag"))
 	}
 
+	if req.Bootstrap && req.Observer {
+		return response.SmartError(fmt.Errorf("Invalid options - the first cluster member can't be an observer"))
+	}
+
 	err = validateFQDN(req.Name)
 	if err != nil {
 		return response.SmartError(fmt.Errorf("Invalid cluster member name %q: %w", req.Name, err))
 	}
 
+	// Replay the result of an earlier request with the same idempotency key instead of running it again.
+	key := r.Header.Get(client.IdempotencyKeyHeader)
+	if key != "" {
+		sum := sha256.Sum256(body)
+		operation, started, err := startControlOperation(key, r.Method+" "+hex.EncodeToString(sum[:]), time.Now())
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if !started {
+			select {
+			case <-operation.done:
+				logger.Info("Replaying result of control request", logger.Ctx{"key": key})
+				return operation.resp
+			case <-r.Context().Done():
+				return response.SmartError(r.Context().Err())
+			}
+		}
+
+		resp := control(state, r, req)
+		operation.finish(resp)
+
+		return resp
+	}
+
+	return control(state, r, req)
+}
+
+// controlOperationExpiry is how long the result of a control request is kept for retries with the same idempotency key
+// once it has finished.
+const controlOperationExpiry = 10 * time.Minute
+
+// controlOperationsLimit is the maximum number of finished control requests whose result is kept. The oldest are
+// evicted first.
+const controlOperationsLimit = 32
+
+// controlOperation is the result of a control request identified by an idempotency key.
+type controlOperation struct {
+	request  string    // Method and hash of the body of the request, which retries must match.
+	finished time.Time // Zero until the request has finished. Guarded by controlOperations.mu.
+
+	done chan struct{}
+	resp response.Response
+}
+
+// finish records the response of the control request and releases any retries waiting for it.
+func (o *controlOperation) finish(resp response.Response) {
+	controlOperations.mu.Lock()
+	o.finished = time.Now()
+	controlOperations.mu.Unlock()
+
+	o.resp = resp
+	close(o.done)
+}
+
+// controlOperations records control requests by idempotency key. They are only kept in memory, as the daemon
+// re-executes itself if a bootstrap or join fails, and only runs them once otherwise.
+var controlOperations = struct {
+	mu    sync.Mutex
+	byKey map[string]*controlOperation
+}{byKey: map[string]*controlOperation{}}
+
+// startControlOperation returns the operation recorded for the idempotency key, and whether it was just started by
+// this call. If not, the caller should wait for the existing operation to finish and return its response. The request
+// identifies the method and body of the request, and it fails with a 422 error if the key was used for another one.
+//
+// Finished operations are evicted once they expire, or once there are too many of them.
+func startControlOperation(key string, request string, now time.Time) (*controlOperation, bool, error) {
+	controlOperations.mu.Lock()
+	defer controlOperations.mu.Unlock()
+
+	var finished []string
+	for existingKey, operation := range controlOperations.byKey {
+		if operation.finished.IsZero() {
+			continue
+		}
+
+		if now.Sub(operation.finished) > controlOperationExpiry {
+			delete(controlOperations.byKey, existingKey)
+			continue
+		}
+
+		finished = append(finished, existingKey)
+	}
+
+	if len(finished) >= controlOperationsLimit {
+		sort.Slice(finished, func(i, j int) bool {
+			return controlOperations.byKey[finished[i]].finished.Before(controlOperations.byKey[finished[j]].finished)
+		})
+
+		for _, existingKey := range finished[:len(finished)-controlOperationsLimit+1] {
+			delete(controlOperations.byKey, existingKey)
+		}
+	}
+
+	operation, ok := controlOperations.byKey[key]
+	if ok {
+		if operation.request != request {
+			return nil, false, api.StatusErrorf(http.StatusUnprocessableEntity, "Idempotency key %q was already used for a different request", key)
+		}
+
+		return operation, false, nil
+	}
+
+	operation = &controlOperation{request: request, done: make(chan struct{})}
+	controlOperations.byKey[key] = operation
+
+	return operation, true, nil
+}
+
+// control bootstraps the daemon or joins it to a cluster.
+func control(state *state.State, r *http.Request, req *internalTypes.Control) response.Response {
+	if !controlMu.TryLock() {
+		return response.SmartError(api.StatusErrorf(http.StatusConflict, "A bootstrap or join operation is already in progress"))
+	}
+
+	defer controlMu.Unlock()
+
 	if req.JoinToken != "" {
 		return joinWithToken(state, r, req)
 	}
 
 	daemonConfig := &trust.Location{Address: req.Address, Name: req.Name}
-	err = state.StartAPI(req.Bootstrap, req.InitConfig, daemonConfig)
+	err := state.StartAPI(r.Context(), req.Bootstrap, req.InitConfig, daemonConfig)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -89,25 +281,33 @@ func joinWithToken(state *state.State, r *http.Request, req *internalTypes.Contr
 		return response.SmartError(fmt.Errorf("Failed to parse server certificate when bootstrapping API: %w", err))
 	}
 
+	clientCert, err := trust.ClientCertificate(state.ServerCert(), state.ClientCert())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	// Add the local node to the list of clusterMembers.
 	daemonConfig := &trust.Location{Address: req.Address, Name: req.Name}
 	localClusterMember := trust.Remote{
-		Location:    *daemonConfig,
-		Certificate: types.X509Certificate{Certificate: serverCert},
+		Location:          *daemonConfig,
+		Certificate:       types.X509Certificate{Certificate: serverCert},
+		ClientCertificate: clientCert,
 	}
 
 	// Prepare the cluster for the incoming dqlite request by creating a database entry.
 	internalVersion, externalVersion := state.Database.Schema().Version()
 	newClusterMember := internalTypes.ClusterMember{
 		ClusterMemberLocal: internalTypes.ClusterMemberLocal{
-			Name:        localClusterMember.Name,
-			Address:     localClusterMember.Address,
-			Certificate: localClusterMember.Certificate,
+			Name:              localClusterMember.Name,
+			Address:           localClusterMember.Address,
+			Certificate:       localClusterMember.Certificate,
+			ClientCertificate: localClusterMember.ClientCertificate,
 		},
 		SchemaInternalVersion: internalVersion,
 		SchemaExternalVersion: externalVersion,
 		Secret:                token.Secret,
 		Extensions:            state.Extensions,
+		Observer:              req.Observer,
 	}
 
 	// Get a client to the target address.
@@ -123,7 +323,7 @@ func joinWithToken(state *state.State, r *http.Request, req *internalTypes.Contr
 
 		fingerprint := shared.CertFingerprint(cert)
 		if fingerprint != token.Fingerprint {
-			return response.SmartError(fmt.Errorf("Cluster certificate token does not match that of cluster member %q", url.URL.Host))
+			return response.SmartError(fmt.Errorf("%w that of cluster member %q", types.ErrTokenFingerprintMismatch, url.URL.Host))
 		}
 
 		d, err := client.New(*url, state.ServerCert(), cert, false)
@@ -131,17 +331,22 @@ func joinWithToken(state *state.State, r *http.Request, req *internalTypes.Contr
 			return response.SmartError(err)
 		}
 
-		joinInfo, err = d.AddClusterMember(context.Background(), newClusterMember)
+		joinInfo, err = d.AddClusterMember(client.WithRequestID(context.Background(), client.RequestID(r.Context())), newClusterMember)
 		if err == nil {
 			break
 		}
 
+		// Every cluster member shares the same token records, so there is no point in trying the others.
+		if api.StatusErrorCheck(err, http.StatusGone) {
+			return response.SmartError(fmt.Errorf("%w: %w", types.ErrTokenExpired, err))
+		}
+
 		logger.Error("Unable to complete cluster join request", logger.Ctx{"address": addr.String(), "error": err})
 		lastErr = err
 	}
 
 	if joinInfo == nil {
-		return response.SmartError(fmt.Errorf("%d join attempts were unsuccessful. Last error: %w", len(token.JoinAddresses), lastErr))
+		return response.SmartError(fmt.Errorf("%w: %d join attempts were unsuccessful. Last error: %w", types.ErrAllJoinAddressesFailed, len(token.JoinAddresses), lastErr))
 	}
 
 	reverter := revert.New()
@@ -185,8 +390,9 @@ func joinWithToken(state *state.State, r *http.Request, req *internalTypes.Contr
 	clusterMembers := make([]trust.Remote, 0, len(joinInfo.ClusterMembers))
 	for _, clusterMember := range joinInfo.ClusterMembers {
 		remote := trust.Remote{
-			Location:    trust.Location{Name: clusterMember.Name, Address: clusterMember.Address},
-			Certificate: clusterMember.Certificate,
+			Location:          trust.Location{Name: clusterMember.Name, Address: clusterMember.Address},
+			Certificate:       clusterMember.Certificate,
+			ClientCertificate: clusterMember.ClientCertificate,
 		}
 
 		joinAddrs = append(joinAddrs, clusterMember.Address)
@@ -200,7 +406,7 @@ func joinWithToken(state *state.State, r *http.Request, req *internalTypes.Contr
 	}
 
 	// Start the HTTPS listeners and join Dqlite.
-	err = state.StartAPI(false, req.InitConfig, daemonConfig, joinAddrs.Strings()...)
+	err = state.StartAPI(r.Context(), false, req.InitConfig, daemonConfig, joinAddrs.Strings()...)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -0,0 +1,191 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/trust"
+	apiTypes "github.com/canonical/microcluster/rest/types"
+)
+
+type controlSuite struct {
+	suite.Suite
+}
+
+func TestControlSuite(t *testing.T) {
+	suite.Run(t, new(controlSuite))
+}
+
+// Ensures a second bootstrap request is rejected while the first is still running, and accepted once it finishes.
+func (t *controlSuite) Test_controlPostConcurrent() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := &state.State{
+		Context: context.Background(),
+		StartAPI: func(ctx context.Context, bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}
+
+	addr, err := apiTypes.ParseAddrPort("127.0.0.1:9000")
+	t.Require().NoError(err)
+
+	post := func() int {
+		body, err := json.Marshal(types.Control{Bootstrap: true, Name: "n0", Address: addr})
+		t.Require().NoError(err)
+
+		req := httptest.NewRequest("POST", "/cluster/control", bytes.NewReader(body))
+		recorder := httptest.NewRecorder()
+		err = controlPost(s, req).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code
+	}
+
+	firstCode := make(chan int)
+	go func() { firstCode <- post() }()
+
+	// Wait for the first request to be in progress before sending the second.
+	<-started
+	t.Equal(http.StatusConflict, post())
+
+	close(release)
+	t.Equal(http.StatusOK, <-firstCode)
+
+	// The guard is released once the first request has completed.
+	go func() { <-started }()
+	t.Equal(http.StatusOK, post())
+}
+
+// Ensures retries of a bootstrap request with the same idempotency key wait for and return the result of the first.
+func (t *controlSuite) Test_controlPostIdempotent() {
+	calls := 0
+	release := make(chan struct{})
+	s := &state.State{
+		Context: context.Background(),
+		StartAPI: func(ctx context.Context, bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error {
+			calls++
+			<-release
+			return fmt.Errorf("Failed to bootstrap")
+		},
+	}
+
+	addr, err := apiTypes.ParseAddrPort("127.0.0.1:9000")
+	t.Require().NoError(err)
+
+	post := func() int {
+		body, err := json.Marshal(types.Control{Bootstrap: true, Name: "n0", Address: addr})
+		t.Require().NoError(err)
+
+		req := httptest.NewRequest("POST", "/cluster/control", bytes.NewReader(body))
+		req.Header.Set(client.IdempotencyKeyHeader, "idempotent-bootstrap")
+		recorder := httptest.NewRecorder()
+		err = controlPost(s, req).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code
+	}
+
+	codes := make(chan int, 2)
+	go func() { codes <- post() }()
+	go func() { codes <- post() }()
+
+	close(release)
+	t.Equal(http.StatusInternalServerError, <-codes)
+	t.Equal(http.StatusInternalServerError, <-codes)
+
+	// Later retries get the recorded result without running the bootstrap again.
+	t.Equal(http.StatusInternalServerError, post())
+	t.Equal(1, calls)
+}
+
+// Ensures invalid cluster member names are rejected with an error describing the violated constraint.
+func (t *controlSuite) Test_validateFQDN() {
+	for _, name := range []string{"n0", "member-01", "member01.example.com", "1member", strings.Repeat("a", 63)} {
+		t.NoError(validateFQDN(name), name)
+	}
+
+	tests := []struct {
+		name    string
+		err     error
+		message string
+	}{
+		{name: "", err: apiTypes.ErrNameLength, message: "got 0"},
+		{name: strings.Repeat("a.", 127) + "aa", err: apiTypes.ErrNameLength, message: "got 256"},
+		{name: ".member01", err: apiTypes.ErrNameEmptyLabel},
+		{name: "member01.", err: apiTypes.ErrNameEmptyLabel},
+		{name: "member01..example", err: apiTypes.ErrNameEmptyLabel},
+		{name: strings.Repeat("a", 64), err: apiTypes.ErrNameLabelLength, message: "is 64 characters long"},
+		{name: "-member01", err: apiTypes.ErrNameHyphen, message: `"-member01"`},
+		{name: "member01.example-", err: apiTypes.ErrNameHyphen, message: `"example-"`},
+		{name: "member_01", err: apiTypes.ErrNameCharacter, message: `contains '_'`},
+		{name: "member 01", err: apiTypes.ErrNameCharacter, message: `contains ' '`},
+		{name: "mémber01", err: apiTypes.ErrNameCharacter, message: `contains 'é'`},
+		{name: "1234", err: apiTypes.ErrNameNumeric, message: `"1234"`},
+		{name: "member01.42", err: apiTypes.ErrNameNumeric, message: `"42"`},
+	}
+
+	for _, test := range tests {
+		err := validateFQDN(test.name)
+		t.ErrorIs(err, test.err, test.name)
+		if test.message != "" {
+			t.ErrorContains(err, test.message, test.name)
+		}
+	}
+}
+
+// Ensures an idempotency key is only replayed for the same request, and that finished requests are evicted once
+// expired or too many.
+func (t *controlSuite) Test_startControlOperation() {
+	now := time.Now()
+	operation, started, err := startControlOperation("key-reused", "POST a", now)
+	t.Require().NoError(err)
+	t.True(started)
+
+	_, _, err = startControlOperation("key-reused", "POST b", now)
+	t.True(api.StatusErrorCheck(err, http.StatusUnprocessableEntity))
+
+	replayed, started, err := startControlOperation("key-reused", "POST a", now)
+	t.Require().NoError(err)
+	t.False(started)
+	t.Equal(operation, replayed)
+
+	// Unfinished requests are never evicted.
+	_, started, err = startControlOperation("key-reused", "POST a", now.Add(2*controlOperationExpiry))
+	t.Require().NoError(err)
+	t.False(started)
+
+	operation.finish(nil)
+	_, started, err = startControlOperation("key-reused", "POST a", time.Now().Add(2*controlOperationExpiry))
+	t.Require().NoError(err)
+	t.True(started)
+
+	for i := 0; i < controlOperationsLimit+1; i++ {
+		operation, _, err := startControlOperation(fmt.Sprintf("key-limit-%d", i), "POST a", now)
+		t.Require().NoError(err)
+		operation.finish(nil)
+	}
+
+	_, started, err = startControlOperation("key-limit-0", "POST a", now)
+	t.Require().NoError(err)
+	t.True(started)
+
+	_, started, err = startControlOperation(fmt.Sprintf("key-limit-%d", controlOperationsLimit), "POST a", now)
+	t.Require().NoError(err)
+	t.False(started)
+}
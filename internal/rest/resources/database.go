@@ -1,19 +1,28 @@
 package resources
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
 
+	"github.com/canonical/microcluster/cluster"
+	internalREST "github.com/canonical/microcluster/internal/rest"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/internal/state"
 	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
 )
 
 var databaseCmd = rest.Endpoint{
-	AllowedBeforeInit: true,
-	Path:              "database",
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+	Path:                 "database",
 
 	Post:  rest.EndpointAction{Handler: databasePost},
 	Patch: rest.EndpointAction{Handler: databasePatch},
@@ -37,7 +46,7 @@ func databasePost(state *state.State, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("Missing or invalid upgrade header"))
 	}
 
-	return response.EmptySyncResponse
+	return internalREST.DatabaseUpgrade
 }
 
 func databasePatch(state *state.State, r *http.Request) response.Response {
@@ -58,3 +67,131 @@ func databasePatch(state *state.State, r *http.Request) response.Response {
 
 	return response.EmptySyncResponse
 }
+
+// databaseUpgradeCmd makes a cluster member that is waiting for other members to be upgraded check their versions
+// again right away, instead of at its next periodic check.
+var databaseUpgradeCmd = rest.Endpoint{
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+	Path:                 "database/upgrade",
+
+	Post: rest.EndpointAction{Handler: databaseUpgradePost, AccessHandler: access.AllowAuthenticated},
+}
+
+func databaseUpgradePost(s *state.State, r *http.Request) response.Response {
+	if s.Database.NotifyUpgraded() {
+		logger.Info("Checking the versions of other cluster members again")
+	}
+
+	return response.EmptySyncResponse
+}
+
+// databaseLogCmd reports the position of the last entry in the raft log of this cluster member's dqlite node, which
+// restarting cluster members compare with their own to detect whether their database diverged from the cluster.
+var databaseLogCmd = rest.Endpoint{
+	AllowedWhenDBOffline: true,
+	Path:                 "database/log",
+
+	Get: rest.EndpointAction{Handler: databaseLogGet, AccessHandler: access.AllowAuthenticated},
+}
+
+func databaseLogGet(s *state.State, r *http.Request) response.Response {
+	position, err := s.Database.LogPosition()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, position)
+}
+
+// databaseDiagnosticsCmd reports the state of every dqlite node, to help diagnose replication issues that the cluster
+// member status can't express. It is served by the dqlite leader, and other members forward the request to it.
+//
+// The fields are a best-effort reflection of dqlite internals at the time of the request. In particular, dqlite does not
+// expose the raft term or log indexes of its nodes through its client API, so they can't be reported.
+var databaseDiagnosticsCmd = rest.Endpoint{
+	Path: "database/diagnostics",
+
+	Get: rest.EndpointAction{Handler: databaseDiagnosticsGet, AccessHandler: access.AllowAuthenticated},
+}
+
+func databaseDiagnosticsGet(s *state.State, r *http.Request) response.Response {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	leader, err := s.Database.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	defer leader.Close()
+
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// If we are not the leader, just forward the request.
+	if leaderInfo.Address != s.Address().URL.Host {
+		client, err := s.Leader()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		diagnostics, err := client.GetDatabaseDiagnostics(ctx)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.SyncResponse(true, diagnostics)
+	}
+
+	nodes, err := s.Database.Cluster(ctx, leader)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	names := map[string]string{}
+	err = s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		clusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterMember := range clusterMembers {
+			names[clusterMember.Address] = clusterMember.Name
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	diagnostics := internalTypes.DatabaseDiagnostics{
+		Leader: names[leaderInfo.Address],
+		Nodes:  make([]internalTypes.DatabaseNodeDiagnostics, 0, len(nodes)),
+	}
+
+	for _, node := range nodes {
+		nodeDiagnostics := internalTypes.DatabaseNodeDiagnostics{
+			ID:      node.ID,
+			Name:    names[node.Address],
+			Address: node.Address,
+			Role:    node.Role.String(),
+			Leader:  node.Address == leaderInfo.Address,
+		}
+
+		metadata, err := s.Database.Describe(ctx, node.Address)
+		if err != nil {
+			nodeDiagnostics.Error = err.Error()
+		} else {
+			nodeDiagnostics.FailureDomain = metadata.FailureDomain
+			nodeDiagnostics.Weight = metadata.Weight
+		}
+
+		diagnostics.Nodes = append(diagnostics.Nodes, nodeDiagnostics)
+	}
+
+	return response.SyncResponse(true, diagnostics)
+}
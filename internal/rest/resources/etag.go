@@ -0,0 +1,66 @@
+package resources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/response"
+)
+
+// etag returns a weak ETag for the given value, derived from its JSON encoding. The ETag is weak because the response
+// body may be encoded differently depending on the request.
+func etag(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("Failed to compute ETag: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return fmt.Sprintf("W/%q", hex.EncodeToString(sum[:])), nil
+}
+
+// etagMatch returns whether the If-None-Match header of the request matches the given ETag, using the weak comparison
+// of RFC 9110.
+func etagMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkETag returns the ETag derived from the given version, and an empty 304 Not Modified response if the request's
+// If-None-Match header matches it. The response is nil if the request has to be served in full.
+//
+// The version should be cheap to compute, so that unchanged data is not fetched or computed again only to be thrown
+// away.
+func checkETag(r *http.Request, version any) (string, response.Response) {
+	tag, err := etag(version)
+	if err != nil {
+		return "", response.InternalError(err)
+	}
+
+	if etagMatch(r, tag) {
+		return tag, response.ManualResponse(func(w http.ResponseWriter) error {
+			w.Header().Set("ETag", tag)
+			w.WriteHeader(http.StatusNotModified)
+
+			return nil
+		})
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,89 @@
+package resources
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+var eventsCmd = rest.Endpoint{
+	Path: "events",
+
+	Get: rest.EndpointAction{Handler: eventsGet, AccessHandler: access.AllowAuthenticated},
+}
+
+// eventsGet streams the cluster lifecycle events published by this cluster member over a websocket.
+// The optional "type" query parameter takes a comma separated list of event types to receive.
+// Subscribers that don't keep up with the events are disconnected.
+func eventsGet(s *state.State, r *http.Request) response.Response {
+	eventTypes := []types.EventType{}
+	typesParam := r.URL.Query().Get("type")
+	if typesParam != "" {
+		for _, eventType := range strings.Split(typesParam, ",") {
+			switch types.EventType(eventType) {
+			case types.EventMemberJoined, types.EventMemberRemoved, types.EventLeaderChanged, types.EventConfigUpdated:
+				eventTypes = append(eventTypes, types.EventType(eventType))
+			default:
+				return response.BadRequest(fmt.Errorf("Unknown event type %q", eventType))
+			}
+		}
+	}
+
+	if s.Events == nil {
+		return response.SmartError(fmt.Errorf("Events are not available"))
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		conn, err := changesUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already replied with an error.
+			logger.Error("Failed to upgrade events connection", logger.Ctx{"error": err})
+			return nil
+		}
+
+		defer conn.Close()
+
+		events, unsubscribe := s.Events.Subscribe(eventTypes...)
+		defer unsubscribe()
+
+		// Read from the connection so that we notice when the subscriber goes away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, _, err := conn.NextReader()
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-s.Context.Done():
+				return nil
+			case <-closed:
+				return nil
+			case event, ok := <-events:
+				// The channel is closed if the subscriber was disconnected for not keeping up.
+				if !ok {
+					return nil
+				}
+
+				err := conn.WriteJSON(event)
+				if err != nil {
+					logger.Warn("Failed to send event", logger.Ctx{"type": event.Type, "error": err})
+					return nil
+				}
+			}
+		}
+	})
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
+	dqliteClient "github.com/canonical/go-dqlite/client"
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
 
 	"github.com/canonical/microcluster/client"
@@ -21,7 +24,8 @@ import (
 )
 
 var heartbeatCmd = rest.Endpoint{
-	Path: "heartbeat",
+	AllowedWhenDBOffline: true,
+	Path:                 "heartbeat",
 
 	Post: rest.EndpointAction{Handler: heartbeatPost, AllowUntrusted: true},
 }
@@ -55,7 +59,9 @@ func heartbeatPost(s *state.State, r *http.Request) response.Response {
 	}
 
 	var internalSchemaVersion, externalSchemaVersion uint64
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	var memberNames []string
+	var draining bool
+	err = s.Database.InternalTransaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
 		localClusterMember, err := cluster.GetInternalClusterMember(ctx, tx, s.Name())
 		if err != nil {
 			return err
@@ -64,14 +70,46 @@ func heartbeatPost(s *state.State, r *http.Request) response.Response {
 		internalSchemaVersion = localClusterMember.SchemaInternal
 		externalSchemaVersion = localClusterMember.SchemaExternal
 
-		return nil
+		_, err = cluster.GetInternalClusterMemberDrain(ctx, tx, s.Name())
+		if err == nil {
+			draining = true
+		} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		memberNames, err = joinedMemberNames(ctx, tx)
+
+		return err
 	})
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	// Keep the drain state in sync with the database, so that it survives restarts and cancellations made while
+	// this cluster member was unreachable.
+	if draining != s.Database.IsDraining() {
+		if !draining {
+			s.Drains.CancelHook()
+		}
+
+		s.Database.SetDraining(draining)
+	}
+
+	notifyDrainChanges(s, clusterMemberList)
+
+	// Catch up on membership hooks missed while this cluster member was unreachable.
+	err = reconcileMemberHooks(s, memberNames)
+	if err != nil {
+		logger.Warn("Failed to reconcile cluster membership hooks", logger.Ctx{"error": err})
+	}
+
 	if internalSchemaVersion != hbInfo.MaxSchemaInternal || externalSchemaVersion != hbInfo.MaxSchemaExternal {
-		err := s.Database.Update()
+		var onUpdate func(ctx context.Context) error
+		if state.OnAutoUpdateHook != nil {
+			onUpdate = func(ctx context.Context) error { return state.OnAutoUpdateHook(s) }
+		}
+
+		err := s.Database.Update(s.Context, onUpdate)
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -79,7 +117,71 @@ func heartbeatPost(s *state.State, r *http.Request) response.Response {
 
 	// TODO: If our schema version is behind, we should try to update here.
 
-	return response.EmptySyncResponse
+	return response.SyncResponse(true, heartbeatPayload(s))
+}
+
+// joinedMemberNames returns the names of all cluster members that have finished joining the cluster, including
+// soft-deleted ones as they can still be restored.
+func joinedMemberNames(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	clusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(clusterMembers))
+	for _, clusterMember := range clusterMembers {
+		if !clusterMember.Role.IsPending() {
+			names = append(names, clusterMember.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// maxHeartbeatPayloadEntrySize is the maximum combined size in bytes of a key and value in a heartbeat payload.
+const maxHeartbeatPayloadEntrySize = 1024
+
+// maxHeartbeatPayloadSize is the maximum total size in bytes of a single cluster member's heartbeat payload.
+const maxHeartbeatPayloadSize = 16 * 1024
+
+// heartbeatPayload returns the local cluster member's heartbeat payload, if a payload hook is set.
+func heartbeatPayload(s *state.State) map[string]string {
+	if state.HeartbeatPayloadHook == nil {
+		return map[string]string{}
+	}
+
+	return limitHeartbeatPayload(s.Name(), state.HeartbeatPayloadHook(s))
+}
+
+// limitHeartbeatPayload drops any entries in the payload of the given cluster member that exceed the size limits.
+func limitHeartbeatPayload(name string, payload map[string]string) map[string]string {
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+
+	// Sort the keys so that the same entries are dropped each round.
+	sort.Strings(keys)
+
+	total := 0
+	limited := make(map[string]string, len(payload))
+	for _, key := range keys {
+		size := len(key) + len(payload[key])
+		if size > maxHeartbeatPayloadEntrySize {
+			logger.Warn("Dropping oversized heartbeat payload entry", logger.Ctx{"member": name, "key": key, "size": size})
+			continue
+		}
+
+		if total+size > maxHeartbeatPayloadSize {
+			logger.Warn("Dropping heartbeat payload entry exceeding total payload size", logger.Ctx{"member": name, "key": key, "size": size})
+			continue
+		}
+
+		total += size
+		limited[key] = payload[key]
+	}
+
+	return limited
 }
 
 // beginHeartbeat initiates a heartbeat from the leader node to all other cluster members, if we haven't sent one out
@@ -100,18 +202,43 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Every cluster member checks the leader when it attempts to begin a heartbeat round, so each one notices changes.
+	s.ObserveLeader(leaderInfo.Address)
+
 	if s.Address().URL.Host != leaderInfo.Address {
 		return response.SmartError(fmt.Errorf("Attempt to initiate heartbeat from non-leader"))
 	}
 
 	// Get the database record of cluster members.
 	var clusterMembers []types.ClusterMember
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	removed := map[string]bool{}
+	err = s.Database.InternalTransactionNamed(s.Context, "heartbeat members", func(ctx context.Context, tx *sql.Tx) error {
 		dbClusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
 		if err != nil {
 			return err
 		}
 
+		removals, err := cluster.GetInternalClusterMemberRemovals(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, removal := range removals {
+			removed[removal.Name] = true
+		}
+
+		// Send the drain state of each cluster member with the heartbeat, so that consumers on every member can
+		// avoid scheduling work onto draining ones.
+		drains, err := memberDrains(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		configs, err := cluster.GetMembersConfig(ctx, tx)
+		if err != nil {
+			return err
+		}
+
 		clusterMembers = make([]types.ClusterMember, 0, len(dbClusterMembers))
 		for _, clusterMember := range dbClusterMembers {
 			apiClusterMember, err := clusterMember.ToAPI()
@@ -119,6 +246,8 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 				return err
 			}
 
+			apiClusterMember.Drain = drains[clusterMember.Name]
+			apiClusterMember.Maintenance = cluster.InMaintenance(configs[clusterMember.Name])
 			clusterMembers = append(clusterMembers, *apiClusterMember)
 		}
 
@@ -128,6 +257,8 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	notifyDrainChanges(s, clusterMembers)
+
 	// Get dqlite record of cluster members.
 	dqliteCluster, err := s.Database.Cluster(ctx, leader)
 	if err != nil {
@@ -139,6 +270,12 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.EmptySyncResponse
 	}
 
+	// A leader in maintenance mode hands over leadership to a voter that is not in maintenance mode, which begins the
+	// next heartbeat round. If no such voter took leadership, the round goes ahead as usual so that it never stops.
+	if handOverMaintenanceLeadership(ctx, s, leader, clusterMembers, dqliteCluster, leaderInfo.ID) {
+		return response.EmptySyncResponse
+	}
+
 	dqliteMap := map[string]string{}
 	for _, member := range dqliteCluster {
 		dqliteMap[member.Address] = member.Role.String()
@@ -147,14 +284,25 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 	// Update database with dqlite member roles.
 	clusterMap := map[string]types.ClusterMember{}
 	for _, clusterMember := range clusterMembers {
+		// Soft-deleted cluster members are kept in the trust store so they can be restored, but receive no heartbeats.
+		if removed[clusterMember.Name] {
+			logger.Debug("Skipping heartbeat for soft-deleted cluster member", logger.Ctx{"address": clusterMember.Address})
+			continue
+		}
+
 		role, ok := dqliteMap[clusterMember.Address.String()]
 
 		// If a cluster member is pending and dqlite does not have a record for it yet, then skip it this round.
-		if !ok && clusterMember.Role == string(cluster.Pending) {
+		if !ok && cluster.Role(clusterMember.Role).IsPending() {
 			logger.Debug("Skipping heartbeat for pending cluster member", logger.Ctx{"address": clusterMember.Address})
 			continue
 		}
 
+		// Observers keep their role, as it records the intent of the operator rather than the dqlite role.
+		if cluster.Role(clusterMember.Role).IsObserver() {
+			role = string(cluster.Observer)
+		}
+
 		clusterMember.Role = role
 		clusterMap[clusterMember.Address.String()] = clusterMember
 	}
@@ -213,7 +361,13 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	// Use a lock to handle concurrent access to hbInfo.
+	// Collect the heartbeat payloads of each cluster member, including our own.
+	payloads := map[string]map[string]string{s.Name(): heartbeatPayload(s)}
+
+	// Record the cluster members that answered the heartbeat, or were sent one recently.
+	reachable := map[string]bool{}
+
+	// Use a lock to handle concurrent access to hbInfo, payloads and reachable.
 	mapLock := sync.RWMutex{}
 	// Send heartbeat to non-leader members, updating their local member cache and updating the node.
 	// If we sent a heartbeat to this node within double the request timeout, then we can skip the node this round.
@@ -231,19 +385,27 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		timeSinceLast := time.Since(currentMember.LastHeartbeat)
 		if timeSinceLast < time.Duration(time.Second*internalClient.HeartbeatTimeout*2) {
 			logger.Warnf("Skipping heartbeat, one was sent %q ago", timeSinceLast.String())
+
+			mapLock.Lock()
+			reachable[addr] = true
+			mapLock.Unlock()
+
 			return nil
 		}
 
-		err := c.Heartbeat(ctx, hbInfo)
+		payload, err := c.Heartbeat(ctx, hbInfo)
 		if err != nil {
 			logger.Error("Received error sending heartbeat to cluster member", logger.Ctx{"target": addr, "error": err})
 			return nil
 		}
 
 		currentMember.LastHeartbeat = time.Now()
+		payload = limitHeartbeatPayload(currentMember.Name, payload)
 
 		mapLock.Lock()
 		hbInfo.ClusterMembers[addr] = currentMember
+		payloads[currentMember.Name] = payload
+		reachable[addr] = true
 		mapLock.Unlock()
 
 		return nil
@@ -252,8 +414,10 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	// Having sent a heartbeat to each valid cluster member, update the database record of members.
-	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
+	// Having sent a heartbeat to each valid cluster member, update the database record of members in a single
+	// transaction. Only members whose heartbeat or role changed are written, to keep large clusters from rewriting
+	// every record each round.
+	err = s.Database.InternalTransactionNamed(s.Context, "heartbeat update", func(ctx context.Context, tx *sql.Tx) error {
 		dbClusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
 		if err != nil {
 			return err
@@ -265,6 +429,10 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 				continue
 			}
 
+			if clusterMember.Heartbeat.Equal(heartbeatInfo.LastHeartbeat) && string(clusterMember.Role) == heartbeatInfo.Role {
+				continue
+			}
+
 			clusterMember.Heartbeat = heartbeatInfo.LastHeartbeat
 			clusterMember.Role = cluster.Role(heartbeatInfo.Role)
 			err = cluster.UpdateInternalClusterMember(ctx, tx, clusterMember.Name, clusterMember)
@@ -279,10 +447,89 @@ func beginHeartbeat(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// Finalize or re-demote any soft-deleted cluster members.
+	err = reconcileClusterMemberRemovals(ctx, s, leader, dqliteCluster)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Demote any observers that dqlite promoted while rebalancing its roles.
+	demoteObservers(ctx, leader, clusterMembers, dqliteCluster)
+
+	// Catch up on membership hooks missed while this cluster member was not the leader and unreachable.
+	var memberNames []string
+	for _, clusterMember := range clusterMembers {
+		if !cluster.Role(clusterMember.Role).IsPending() {
+			memberNames = append(memberNames, clusterMember.Name)
+		}
+	}
+
+	err = reconcileMemberHooks(s, memberNames)
+	if err != nil {
+		logger.Warn("Failed to reconcile cluster membership hooks", logger.Ctx{"error": err})
+	}
+
 	err = state.OnHeartbeatHook(s)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	err = state.OnHeartbeatPayloadsHook(s, payloads)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Hand leadership over to a reachable voter with a higher leadership weight, once this round is complete.
+	err = rebalanceLeadership(ctx, s, leader, dqliteCluster, leaderInfo.ID, reachable)
+	if err != nil {
+		logger.Warn("Failed to rebalance dqlite leadership", logger.Ctx{"error": err})
+	}
+
 	return response.EmptySyncResponse
 }
+
+// demoteObservers assigns the dqlite spare role to observers that dqlite has promoted, for example while rebalancing
+// roles after a voter went offline. Observers that are the dqlite leader or the last voter are left alone, as
+// demoting them would leave the cluster without quorum.
+func demoteObservers(ctx context.Context, leader *dqliteClient.Client, clusterMembers []types.ClusterMember, dqliteCluster []dqliteClient.NodeInfo) {
+	leaderInfo, err := leader.Leader(ctx)
+	if err != nil {
+		logger.Error("Failed to get dqlite leader", logger.Ctx{"error": err})
+		return
+	}
+
+	voters := 0
+	for _, node := range dqliteCluster {
+		if node.Role == dqliteClient.Voter {
+			voters++
+		}
+	}
+
+	for _, clusterMember := range clusterMembers {
+		if !cluster.Role(clusterMember.Role).IsObserver() {
+			continue
+		}
+
+		for _, node := range dqliteCluster {
+			if node.Address != clusterMember.Address.String() || node.Role == dqliteClient.Spare || node.ID == leaderInfo.ID {
+				continue
+			}
+
+			if node.Role == dqliteClient.Voter && voters <= 1 {
+				logger.Warn("Not demoting observer as it is the last dqlite voter", logger.Ctx{"member": clusterMember.Name})
+				continue
+			}
+
+			logger.Info("Demoting observer promoted by dqlite", logger.Ctx{"member": clusterMember.Name, "role": node.Role.String()})
+			err = leader.Assign(ctx, node.ID, dqliteClient.Spare)
+			if err != nil {
+				logger.Error("Failed to demote observer", logger.Ctx{"member": clusterMember.Name, "error": err})
+				continue
+			}
+
+			if node.Role == dqliteClient.Voter {
+				voters--
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+)
+
+type heartbeatSuite struct {
+	suite.Suite
+}
+
+func TestHeartbeatSuite(t *testing.T) {
+	suite.Run(t, new(heartbeatSuite))
+}
+
+// Ensures oversized heartbeat payload entries are dropped while the rest are kept.
+func (t *heartbeatSuite) Test_limitHeartbeatPayload() {
+	payload := map[string]string{
+		"small":     "value",
+		"oversized": strings.Repeat("a", maxHeartbeatPayloadEntrySize),
+	}
+
+	limited := limitHeartbeatPayload("member01", payload)
+	t.Equal(map[string]string{"small": "value"}, limited)
+
+	// Entries beyond the total payload size are dropped.
+	payload = map[string]string{}
+	entries := 2 * maxHeartbeatPayloadSize / maxHeartbeatPayloadEntrySize
+	for i := 0; i < entries; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		payload[key] = strings.Repeat("a", maxHeartbeatPayloadEntrySize-len(key))
+	}
+
+	limited = limitHeartbeatPayload("member01", payload)
+	t.Len(limited, maxHeartbeatPayloadSize/maxHeartbeatPayloadEntrySize)
+	t.Contains(limited, "key000")
+	t.NotContains(limited, fmt.Sprintf("key%03d", entries-1))
+}
+
+// Ensures the OnDrainChange hook only runs for cluster members whose drain state changed since the last heartbeat.
+func (t *heartbeatSuite) Test_notifyDrainChanges() {
+	s := &state.State{Drains: state.NewDrainTracker()}
+
+	changes := map[string]bool{}
+	state.OnDrainChangeHook = func(s *state.State, name string, draining bool) error {
+		changes[name] = draining
+		return nil
+	}
+
+	defer func() { state.OnDrainChangeHook = nil }()
+
+	members := []types.ClusterMember{
+		{ClusterMemberLocal: types.ClusterMemberLocal{Name: "member01"}},
+		{ClusterMemberLocal: types.ClusterMemberLocal{Name: "member02"}, Drain: types.MemberDraining},
+	}
+
+	// Draining members are reported on the first heartbeat.
+	notifyDrainChanges(s, members)
+	t.Equal(map[string]bool{"member02": true}, changes)
+
+	// Completing the drain doesn't change whether the member is draining.
+	changes = map[string]bool{}
+	members[1].Drain = types.MemberDrained
+	notifyDrainChanges(s, members)
+	t.Empty(changes)
+
+	changes = map[string]bool{}
+	members[0].Drain = types.MemberDraining
+	members[1].Drain = ""
+	notifyDrainChanges(s, members)
+	t.Equal(map[string]bool{"member01": true, "member02": false}, changes)
+}
@@ -7,12 +7,14 @@ import (
 	"net/url"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
 	"github.com/gorilla/mux"
 
 	"github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/internal/state"
 	"github.com/canonical/microcluster/rest"
 	"github.com/canonical/microcluster/rest/access"
+	apiTypes "github.com/canonical/microcluster/rest/types"
 )
 
 var hooksCmd = rest.Endpoint{
@@ -27,6 +29,14 @@ func hooksPost(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	origin := ""
+	identity := access.RequestIdentity(r)
+	if identity != nil {
+		origin = identity.Name
+	}
+
+	logger.Debug("Running hook", logger.Ctx{"hook": hookTypeStr, "origin": origin})
+
 	switch types.HookType(hookTypeStr) {
 	case types.PreRemove:
 		var req types.HookRemoveMemberOptions
@@ -51,6 +61,12 @@ func hooksPost(s *state.State, r *http.Request) response.Response {
 			return response.SmartError(fmt.Errorf("Failed to execute post-remove hook on cluster member %q: %w", s.Name(), err))
 		}
 
+		if req.Name != "" {
+			recordMemberHook(s, req.Name, false)
+		}
+
+		s.PublishEvent(apiTypes.EventMemberRemoved, map[string]string{"name": req.Name})
+
 	case types.OnNewMember:
 		var req types.HookNewMemberOptions
 		err = json.NewDecoder(r.Body).Decode(&req)
@@ -66,6 +82,9 @@ func hooksPost(s *state.State, r *http.Request) response.Response {
 		if err != nil {
 			return response.SmartError(fmt.Errorf("Failed to run hook after system %q has joined the cluster: %w", req.Name, err))
 		}
+
+		recordMemberHook(s, req.Name, true)
+		s.PublishEvent(apiTypes.EventMemberJoined, map[string]string{"name": req.Name})
 	default:
 		return response.SmartError(fmt.Errorf("No valid hook found for the given type"))
 	}
@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/microcluster/cluster"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// leaderRetryAfter is how long clients are asked to wait before retrying when there is no dqlite leader.
+const leaderRetryAfter = 2 * time.Second
+
+var leaderCmd = rest.Endpoint{
+	Path: "leader",
+
+	Get: rest.EndpointAction{Handler: leaderGet, AccessHandler: access.AllowAuthenticated},
+}
+
+func leaderGet(s *state.State, r *http.Request) response.Response {
+	address, err := dqliteLeaderAddress(s)
+	if err != nil {
+		return leaderUnavailable(err)
+	}
+
+	var leader *internalTypes.ClusterLeader
+	err = s.Database.Transaction(r.Context(), func(ctx context.Context, tx *sql.Tx) error {
+		members, err := cluster.GetInternalClusterMembers(ctx, tx, cluster.InternalClusterMemberFilter{Address: &address})
+		if err != nil {
+			return err
+		}
+
+		leader, err = clusterLeader(address, members)
+
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, leader)
+}
+
+// clusterLeader returns the cluster member among the given ones with the address of the dqlite leader.
+func clusterLeader(address string, members []cluster.InternalClusterMember) (*internalTypes.ClusterLeader, error) {
+	for _, member := range members {
+		if member.Address != address {
+			continue
+		}
+
+		addrPort, err := types.ParseAddrPort(member.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		return &internalTypes.ClusterLeader{Name: member.Name, Address: addrPort}, nil
+	}
+
+	return nil, api.StatusErrorf(http.StatusNotFound, "No cluster member found with the address %q of the dqlite leader", address)
+}
+
+// leaderUnavailable returns a 503 response with a Retry-After header, for when no dqlite leader is elected.
+func leaderUnavailable(err error) response.Response {
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Retry-After", strconv.Itoa(int(leaderRetryAfter.Seconds())))
+
+		return response.Unavailable(err).Render(w)
+	})
+}
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/cluster"
+)
+
+type leaderSuite struct {
+	suite.Suite
+}
+
+func TestLeaderSuite(t *testing.T) {
+	suite.Run(t, new(leaderSuite))
+}
+
+// Ensures the dqlite leader is resolved to its cluster member, and that clients are asked to retry without a leader.
+func (t *leaderSuite) Test_clusterLeader() {
+	members := []cluster.InternalClusterMember{
+		{Name: "member01", Address: "10.0.0.1:9000"},
+		{Name: "member02", Address: "10.0.0.2:9000"},
+	}
+
+	leader, err := clusterLeader("10.0.0.2:9000", members)
+	t.Require().NoError(err)
+	t.Equal("member02", leader.Name)
+	t.Equal("10.0.0.2:9000", leader.Address.String())
+
+	_, err = clusterLeader("10.0.0.3:9000", members)
+	t.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+	recorder := httptest.NewRecorder()
+	err = leaderUnavailable(fmt.Errorf("No dqlite leader found")).Render(recorder)
+	t.Require().NoError(err)
+	t.Equal(http.StatusServiceUnavailable, recorder.Code)
+	t.Equal("2", recorder.Header().Get("Retry-After"))
+}
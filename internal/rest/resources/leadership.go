@@ -0,0 +1,120 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+
+	dqliteClient "github.com/canonical/go-dqlite/client"
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/state"
+)
+
+// leadershipWeights returns the leadership weight of each cluster member, keyed by address. Cluster members in
+// maintenance mode have the lowest possible weight.
+func leadershipWeights(ctx context.Context, s *state.State) (map[string]int, error) {
+	weights := map[string]int{}
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		clusterMembers, err := cluster.GetInternalClusterMembers(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		configs, err := cluster.GetMembersConfig(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterMember := range clusterMembers {
+			weights[clusterMember.Address] = cluster.LeadershipWeight(configs[clusterMember.Name])
+
+			// Cluster members in maintenance mode only get leadership if no other voter can take it.
+			if cluster.InMaintenance(configs[clusterMember.Name]) {
+				weights[clusterMember.Address] = math.MinInt
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return weights, nil
+}
+
+// leadershipTarget returns the dqlite voter other than exclude with the highest leadership weight, picked at random
+// among voters with the same weight. It returns false if there is no such voter.
+func leadershipTarget(dqliteCluster []dqliteClient.NodeInfo, weights map[string]int, exclude uint64) (dqliteClient.NodeInfo, bool) {
+	var candidates []dqliteClient.NodeInfo
+	for _, node := range dqliteCluster {
+		if node.ID == exclude || node.Role != dqliteClient.Voter {
+			continue
+		}
+
+		if len(candidates) > 0 && weights[node.Address] < weights[candidates[0].Address] {
+			continue
+		}
+
+		if len(candidates) > 0 && weights[node.Address] > weights[candidates[0].Address] {
+			candidates = nil
+		}
+
+		candidates = append(candidates, node)
+	}
+
+	if len(candidates) == 0 {
+		return dqliteClient.NodeInfo{}, false
+	}
+
+	return candidates[rand.Intn(len(candidates))], true
+}
+
+// transferLeadership transfers dqlite leadership from the leader with the given ID to the voter with the highest
+// leadership weight.
+func transferLeadership(ctx context.Context, s *state.State, leader *dqliteClient.Client, dqliteCluster []dqliteClient.NodeInfo, leaderID uint64) error {
+	weights, err := leadershipWeights(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	target, ok := leadershipTarget(dqliteCluster, weights, leaderID)
+	if !ok {
+		return fmt.Errorf("No other dqlite voter to transfer leadership to")
+	}
+
+	return leader.Transfer(ctx, target.ID)
+}
+
+// rebalanceLeadership transfers dqlite leadership from the leader with the given ID to the reachable voter with the
+// highest leadership weight, if that weight is higher than the leader's. Voters are reachable if their address is set
+// in reachable.
+func rebalanceLeadership(ctx context.Context, s *state.State, leader *dqliteClient.Client, dqliteCluster []dqliteClient.NodeInfo, leaderID uint64, reachable map[string]bool) error {
+	weights, err := leadershipWeights(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	var leaderAddress string
+	var candidates []dqliteClient.NodeInfo
+	for _, node := range dqliteCluster {
+		if node.ID == leaderID {
+			leaderAddress = node.Address
+		} else if reachable[node.Address] {
+			candidates = append(candidates, node)
+		}
+	}
+
+	target, ok := leadershipTarget(candidates, weights, leaderID)
+	if !ok || weights[target.Address] <= weights[leaderAddress] {
+		return nil
+	}
+
+	logger.Info("Transferring dqlite leadership to voter with a higher leadership weight", logger.Ctx{"address": target.Address, "weight": weights[target.Address]})
+
+	return leader.Transfer(ctx, target.ID)
+}
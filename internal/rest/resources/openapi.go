@@ -0,0 +1,130 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"regexp"
+
+	"github.com/canonical/lxd/lxd/response"
+
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// openAPICmd describes the paths and methods of the REST API, including the resources of extension servers, so that
+// clients can be generated for other languages. The OpenAPI document is sent as is, without the usual response
+// envelope, as expected by OpenAPI tooling.
+var openAPICmd = rest.Endpoint{
+	Path: "openapi",
+
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+
+	Get: rest.EndpointAction{Handler: openAPIGet, AccessHandler: access.AllowAuthenticated},
+}
+
+func openAPIGet(s *state.State, r *http.Request) response.Response {
+	if s.OpenAPI == nil {
+		return response.NotImplemented(nil)
+	}
+
+	document := s.OpenAPI()
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		return json.NewEncoder(w).Encode(document)
+	})
+}
+
+// openAPISecurityScheme is the name of the security scheme of operations that require a trusted client certificate.
+const openAPISecurityScheme = "tls"
+
+// pathVariable matches a mux path variable, along with its optional pattern.
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPI returns an OpenAPI document describing the paths and methods of the core resources and those of the given
+// extension servers. Each operation is tagged with the API it belongs to: "control" for the control socket, "public"
+// and "internal" for the core listener, and "extension" for extension servers. An operation requires authentication
+// unless it allows untrusted requests without a custom access handler.
+func OpenAPI(title string, version string, extensionServers []rest.Server) *internalTypes.OpenAPI {
+	document := &internalTypes.OpenAPI{
+		OpenAPI: "3.1.0",
+		Info:    internalTypes.OpenAPIInfo{Title: title, Version: version},
+		Paths:   map[string]map[string]internalTypes.OpenAPIOperation{},
+		Components: internalTypes.OpenAPIComponents{
+			SecuritySchemes: map[string]map[string]string{
+				openAPISecurityScheme: {"type": "mutualTLS", "description": "Client certificate in the truststore of the daemon"},
+			},
+		},
+	}
+
+	addResources := func(tag string, resources rest.Resources) {
+		for _, e := range resources.Endpoints {
+			addOpenAPIPath(document, tag, string(resources.PathPrefix), e.Path, e)
+			for _, alias := range e.Aliases {
+				addOpenAPIPath(document, tag, string(resources.PathPrefix), alias.Path, e)
+			}
+		}
+	}
+
+	addResources("control", UnixEndpoints)
+	addResources("public", PublicEndpoints)
+	addResources("internal", InternalEndpoints)
+
+	for _, extensionServer := range extensionServers {
+		for _, resources := range extensionServer.Resources {
+			addResources("extension", resources)
+		}
+	}
+
+	return document
+}
+
+// addOpenAPIPath adds the methods of the endpoint to the document, under the given path prefix and path.
+func addOpenAPIPath(document *internalTypes.OpenAPI, tag string, prefix string, path string, e rest.Endpoint) {
+	fullPath := "/" + prefix
+	if path != "" {
+		fullPath = filepath.Join(fullPath, path)
+	}
+
+	var parameters []internalTypes.OpenAPIParameter
+	for _, match := range pathVariable.FindAllStringSubmatch(fullPath, -1) {
+		parameters = append(parameters, internalTypes.OpenAPIParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   map[string]string{"type": "string"},
+		})
+	}
+
+	// OpenAPI path templates don't have patterns.
+	fullPath = pathVariable.ReplaceAllString(fullPath, "{$1}")
+
+	actions := map[string]rest.EndpointAction{"get": e.Get, "put": e.Put, "post": e.Post, "delete": e.Delete, "patch": e.Patch}
+	for method, action := range actions {
+		if action.Handler == nil {
+			continue
+		}
+
+		security := []map[string][]string{}
+		if !action.AllowUntrusted || action.AccessHandler != nil {
+			security = append(security, map[string][]string{openAPISecurityScheme: {}})
+		}
+
+		if document.Paths[fullPath] == nil {
+			document.Paths[fullPath] = map[string]internalTypes.OpenAPIOperation{}
+		}
+
+		document.Paths[fullPath][method] = internalTypes.OpenAPIOperation{
+			Tags:       []string{tag},
+			Parameters: parameters,
+			Security:   security,
+			Responses:  map[string]internalTypes.OpenAPIResponse{"default": {Description: "Standard response envelope"}},
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
 
 	"github.com/canonical/microcluster/internal/state"
 	"github.com/canonical/microcluster/rest"
@@ -12,22 +13,65 @@ import (
 )
 
 var readyCmd = rest.Endpoint{
-	AllowedBeforeInit: true,
-	Path:              "ready",
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+	Path:                 "ready",
 
 	Get: rest.EndpointAction{Handler: getWaitReady, AccessHandler: access.AllowAuthenticated},
 }
 
 func getWaitReady(state *state.State, r *http.Request) response.Response {
+	err := checkReady(state)
+	if err != nil {
+		return response.Unavailable(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// checkReady returns an error if the daemon is shutting down or has not finished starting up.
+func checkReady(state *state.State) error {
 	if state.Context.Err() != nil {
-		return response.Unavailable(fmt.Errorf("Daemon is shutting down"))
+		return fmt.Errorf("Daemon is shutting down")
 	}
 
 	select {
 	case <-state.ReadyCh:
 	default:
-		return response.Unavailable(fmt.Errorf("Daemon is not ready yet"))
+		return fmt.Errorf("Daemon is not ready yet")
 	}
 
-	return response.EmptySyncResponse
+	return nil
+}
+
+// HealthHandler returns the handler of the health listener. It serves only GET /health, which succeeds once the
+// daemon is ready and its database is open, and exposes nothing else about the daemon or cluster.
+func HealthHandler(state *state.State) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var resp response.Response
+		if r.Method != http.MethodGet {
+			resp = response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
+		} else {
+			resp = response.EmptySyncResponse
+
+			err := checkReady(state)
+			if err == nil && !state.Database.IsOpen() {
+				err = fmt.Errorf("Database is not yet open")
+			}
+
+			if err != nil {
+				resp = response.Unavailable(err)
+			}
+		}
+
+		err := resp.Render(w)
+		if err != nil {
+			logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
+		}
+	})
+
+	return mux
 }
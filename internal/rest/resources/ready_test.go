@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/db"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+)
+
+type readySuite struct {
+	suite.Suite
+}
+
+func TestReadySuite(t *testing.T) {
+	suite.Run(t, new(readySuite))
+}
+
+// Ensures the health handler only serves GET /health, and reports unavailable until the daemon is ready and its
+// database is open.
+func (t *readySuite) Test_healthHandler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &state.State{
+		Context:  ctx,
+		ReadyCh:  make(chan struct{}),
+		Database: db.NewDB(ctx, nil, nil, &sys.OS{StateDir: t.T().TempDir()}),
+	}
+
+	handler := HealthHandler(s)
+	request := func(method string, path string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusServiceUnavailable, request("GET", "/health"))
+
+	// The daemon is ready, but the database is not yet open.
+	close(s.ReadyCh)
+	t.Equal(http.StatusServiceUnavailable, request("GET", "/health"))
+
+	t.Equal(http.StatusNotFound, request("POST", "/health"))
+	t.Equal(http.StatusNotFound, request("GET", "/cluster/1.0/cluster"))
+	t.Equal(http.StatusNotFound, request("GET", "/"))
+
+	cancel()
+	t.Equal(http.StatusServiceUnavailable, request("GET", "/health"))
+}
@@ -0,0 +1,78 @@
+package resources
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// reinitializeCmd resets the local cluster member to its state before it was bootstrapped or joined a cluster, so
+// that it can be bootstrapped or join a cluster anew. It is only served on the control socket.
+//
+// The PreRemove hook is run, then the database and listeners are stopped, the state directory is cleared, and the
+// daemon re-execs once this request has returned. A new server certificate is generated when the daemon starts again.
+//
+// Members that still share their truststore with other cluster members are refused unless force is set, as the rest
+// of the cluster would keep counting them as members. Such members should leave the cluster instead. If force is set,
+// a failure of the PreRemove hook or of the reset is ignored.
+var reinitializeCmd = rest.Endpoint{
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+	Path:                 "reinitialize",
+
+	Post: rest.EndpointAction{Handler: reinitializePost, AccessHandler: access.AllowAuthenticated},
+}
+
+func reinitializePost(s *state.State, r *http.Request) response.Response {
+	force := r.URL.Query().Get("force") == "1"
+
+	remotes := s.Remotes().RemotesByName()
+	others := len(remotes)
+	_, ok := remotes[s.Name()]
+	if ok {
+		others--
+	}
+
+	if others > 0 && !force {
+		return response.SmartError(api.StatusErrorf(http.StatusConflict, "Cluster member %q is still part of a cluster with %d other members. Leave the cluster first, or use force", s.Name(), others))
+	}
+
+	logger.Info("Reinitializing cluster member", logger.Ctx{"member": s.Name(), "force": force})
+
+	if state.PreRemoveHook != nil {
+		err := state.PreRemoveHook(s, force)
+		if err != nil && !force {
+			return response.SmartError(fmt.Errorf("Failed to run PreRemove hook: %w", err))
+		}
+	}
+
+	reExec, err := resetClusterMember(r.Context(), s, force)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	go reExec()
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		err := response.EmptySyncResponse.Render(w)
+		if err != nil {
+			return err
+		}
+
+		// Send the response before replacing the daemon process.
+		f, ok := w.(http.Flusher)
+		if !ok {
+			return fmt.Errorf("ResponseWriter is not type http.Flusher")
+		}
+
+		f.Flush()
+		return nil
+	})
+}
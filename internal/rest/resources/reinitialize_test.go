@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/db"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type reinitializeSuite struct {
+	suite.Suite
+}
+
+func TestReinitializeSuite(t *testing.T) {
+	suite.Run(t, new(reinitializeSuite))
+}
+
+// Ensures a member that still shares its truststore with other members is only reinitialized when forced, even if its
+// own entry is missing, and that a forced reinitialization goes ahead despite a failing PreRemove hook.
+func (t *reinitializeSuite) Test_reinitializePost() {
+	cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	preRemoveHook, stopListeners := state.PreRemoveHook, state.StopListeners
+	defer func() { state.PreRemoveHook, state.StopListeners = preRemoveHook, stopListeners }()
+
+	hookCalls := 0
+	state.PreRemoveHook = func(s *state.State, force bool) error {
+		hookCalls++
+		return fmt.Errorf("Failed to run hook")
+	}
+
+	state.StopListeners = func() error { return nil }
+
+	reinitialize := func(force bool, names ...string) (int, string) {
+		members := make([]internalTypes.ClusterMember, 0, len(names))
+		for i, name := range names {
+			address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9000", i+1))
+			t.Require().NoError(err)
+
+			members = append(members, internalTypes.ClusterMember{ClusterMemberLocal: internalTypes.ClusterMemberLocal{
+				Name:        name,
+				Address:     address,
+				Certificate: types.X509Certificate{Certificate: x509Cert},
+			}})
+		}
+
+		stateDir := t.T().TempDir()
+		trustDir := filepath.Join(stateDir, "truststore")
+		t.Require().NoError(os.Mkdir(trustDir, 0700))
+
+		remotes := &trust.Remotes{}
+		t.Require().NoError(remotes.Replace(trustDir, members...))
+
+		s := &state.State{
+			Context:  context.Background(),
+			OS:       &sys.OS{StateDir: stateDir, TrustDir: trustDir},
+			Name:     func() string { return "member01" },
+			Remotes:  func() *trust.Remotes { return remotes },
+			Database: db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: stateDir}),
+		}
+
+		target := "/cluster/control/reinitialize"
+		if force {
+			target += "?force=1"
+		}
+
+		// The daemon is only re-executed once the request context is done, which never happens here.
+		recorder := httptest.NewRecorder()
+		err := reinitializePost(s, httptest.NewRequest("POST", target, nil)).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code, stateDir
+	}
+
+	code, stateDir := reinitialize(false, "member01", "member02")
+	t.Equal(http.StatusConflict, code)
+	t.DirExists(stateDir)
+	t.Equal(0, hookCalls)
+
+	// A member missing from its own truststore still counts the others.
+	code, stateDir = reinitialize(false, "member02")
+	t.Equal(http.StatusConflict, code)
+	t.DirExists(stateDir)
+	t.Equal(0, hookCalls)
+
+	code, stateDir = reinitialize(true, "member01", "member02")
+	t.Equal(http.StatusOK, code)
+	t.NoDirExists(stateDir)
+	t.Equal(1, hookCalls)
+
+	code, stateDir = reinitialize(true, "member02")
+	t.Equal(http.StatusOK, code)
+	t.NoDirExists(stateDir)
+	t.Equal(2, hookCalls)
+}
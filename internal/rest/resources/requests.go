@@ -0,0 +1,59 @@
+package resources
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/logger"
+	"github.com/gorilla/mux"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+// requestsCmd lists the API requests that the daemon is still serving, to help diagnose stuck operations and decide
+// whether to wait for them during shutdown.
+var requestsCmd = rest.Endpoint{
+	AllowedBeforeInit:     true,
+	AllowedDuringShutdown: true,
+	AllowedWhenDBOffline:  true,
+	Path:                  "requests",
+
+	Get: rest.EndpointAction{Handler: requestsGet, AccessHandler: access.AllowAuthenticated},
+}
+
+// requestCmd cancels the context of an API request that the daemon is still serving.
+var requestCmd = rest.Endpoint{
+	AllowedBeforeInit:     true,
+	AllowedDuringShutdown: true,
+	AllowedWhenDBOffline:  true,
+	Path:                  "requests/{id}",
+
+	Delete: rest.EndpointAction{Handler: requestDelete, AccessHandler: access.AllowAuthenticated},
+}
+
+func requestsGet(state *state.State, r *http.Request) response.Response {
+	return response.SyncResponse(true, state.ActiveRequests())
+}
+
+func requestDelete(state *state.State, r *http.Request) response.Response {
+	id, err := url.PathUnescape(mux.Vars(r)["id"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if state.Requests == nil {
+		return response.NotFound(nil)
+	}
+
+	err = state.Requests.Cancel(id)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	logger.Info("Cancelled API request", logger.Ctx{"id": id})
+
+	return response.EmptySyncResponse
+}
@@ -13,7 +13,18 @@ var UnixEndpoints = rest.Resources{
 	PathPrefix: types.ControlEndpoint,
 	Endpoints: []rest.Endpoint{
 		controlCmd,
+		preInitAddressCmd,
 		shutdownCmd,
+		connectionsCmd,
+		listenersCmd,
+		requestsCmd,
+		requestCmd,
+		trustBundleCmd,
+		trustRefreshCmd,
+		clusterMemberAddressCmd,
+		clusterMemberNameCmd,
+		clusterLeaveCmd,
+		reinitializeCmd,
 	},
 }
 
@@ -24,8 +35,17 @@ var PublicEndpoints = rest.Resources{
 		api10Cmd,
 		clusterCmd,
 		clusterMemberCmd,
+		clusterMemberRemovalCmd,
+		clusterMemberDrainCmd,
+		clusterMemberMaintenanceCmd,
+		leaderCmd,
 		tokensCmd,
+		tokensBatchCmd,
 		readyCmd,
+		changesCmd,
+		eventsCmd,
+		openAPICmd,
+		trustPEMCmd,
 	},
 }
 
@@ -34,8 +54,12 @@ var InternalEndpoints = rest.Resources{
 	PathPrefix: types.InternalEndpoint,
 	Endpoints: []rest.Endpoint{
 		databaseCmd,
+		databaseDiagnosticsCmd,
+		databaseUpgradeCmd,
+		databaseLogCmd,
 		clusterCertificatesCmd,
 		sqlCmd,
+		databaseBackupCmd,
 		tokenCmd,
 		heartbeatCmd,
 		trustCmd,
@@ -44,6 +68,44 @@ var InternalEndpoints = rest.Resources{
 	},
 }
 
+// DebugEndpoints are the endpoints available over the debug http listener, with only their GET actions. Its clients
+// present no certificate, so only endpoints revealing no secrets and holding no long-lived connections are included.
+// In particular, join tokens, the change and event feeds, and the database are left out.
+var DebugEndpoints = readOnlyEndpoints(rest.Resources{
+	PathPrefix: types.PublicEndpoint,
+	Endpoints: []rest.Endpoint{
+		api10Cmd,
+		clusterCmd,
+		leaderCmd,
+		readyCmd,
+		openAPICmd,
+	},
+})
+
+// readOnlyEndpoints returns a copy of the given resources with only their GET actions, leaving out the endpoints that
+// have none.
+func readOnlyEndpoints(resources rest.Resources) rest.Resources {
+	readOnly := rest.Resources{PathPrefix: resources.PathPrefix}
+	for _, e := range resources.Endpoints {
+		if e.Get.Handler == nil {
+			continue
+		}
+
+		readOnly.Endpoints = append(readOnly.Endpoints, rest.Endpoint{
+			Name:    e.Name,
+			Path:    e.Path,
+			Aliases: e.Aliases,
+			Get:     e.Get,
+
+			AllowedDuringShutdown: e.AllowedDuringShutdown,
+			AllowedBeforeInit:     e.AllowedBeforeInit,
+			AllowedWhenDBOffline:  e.AllowedWhenDBOffline,
+		})
+	}
+
+	return readOnly
+}
+
 // checkInternalEndpointsConflict checks if any endpoints defined in extensionServers conflict with internal endpoints.
 func checkInternalEndpointsConflict(extensionServerEndpoints rest.Resources) error {
 	allExistingEndpoints := []rest.Resources{UnixEndpoints, PublicEndpoints, InternalEndpoints}
@@ -102,6 +164,10 @@ func GetAndValidateCoreEndpoints(extensionServers []rest.Server) ([]rest.Resourc
 				return nil, err
 			}
 
+			if extensionServer.PreInit {
+				endpoints = allowBeforeInit(endpoints)
+			}
+
 			coreEndpoints = append(coreEndpoints, endpoints)
 			seen[string(endpoints.PathPrefix)] = true
 		}
@@ -109,3 +175,121 @@ func GetAndValidateCoreEndpoints(extensionServers []rest.Server) ([]rest.Resourc
 
 	return coreEndpoints, nil
 }
+
+// GetAndValidateUnixEndpoints extracts all endpoints from extensionServers with ServeUnix set, which should be
+// added to the control socket alongside those of the core API server.
+// It also performs the following validations:
+// 1. Server configurations are properly set.
+// 2. Path prefixes are not duplicated, either between these servers or with the core API server.
+// 3. Endpoints do not conflict with internal endpoints.
+func GetAndValidateUnixEndpoints(extensionServers []rest.Server) ([]rest.Resources, error) {
+	var unixEndpoints []rest.Resources
+
+	seen := make(map[string]bool)
+	for _, extensionServer := range extensionServers {
+		if extensionServer.CoreAPI {
+			for _, endpoints := range extensionServer.Resources {
+				seen[string(endpoints.PathPrefix)] = true
+			}
+		}
+	}
+
+	for _, extensionServer := range extensionServers {
+		if !extensionServer.ServeUnix {
+			// Catch standalone servers that expect to be reachable before initialization.
+			if extensionServer.PreInit && !extensionServer.CoreAPI {
+				err := extensionServer.ValidateServerConfigs()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			continue
+		}
+
+		err := extensionServer.ValidateServerConfigs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, endpoints := range extensionServer.Resources {
+			if seen[string(endpoints.PathPrefix)] {
+				return nil, fmt.Errorf("Path prefix %q is duplicated on the control socket", endpoints.PathPrefix)
+			}
+
+			err = checkInternalEndpointsConflict(endpoints)
+			if err != nil {
+				return nil, err
+			}
+
+			if extensionServer.PreInit {
+				endpoints = allowBeforeInit(endpoints)
+			}
+
+			unixEndpoints = append(unixEndpoints, endpoints)
+			seen[string(endpoints.PathPrefix)] = true
+		}
+	}
+
+	return unixEndpoints, nil
+}
+
+// GetAndValidateSocketServers returns the extensionServers with SocketPath set, which should each be served on their
+// own unix socket. The resources of PreInit servers are marked as AllowedBeforeInit.
+// It also performs the following validations:
+// 1. Server configurations are properly set.
+// 2. Socket paths are not duplicated, either between these servers or with the control socket.
+// 3. Path prefixes for endpoints belonging to a single server are not duplicated.
+func GetAndValidateSocketServers(extensionServers []rest.Server, controlSocketPath string) ([]rest.Server, error) {
+	var socketServers []rest.Server
+
+	seenPaths := map[string]bool{filepath.Clean(controlSocketPath): true}
+	for _, extensionServer := range extensionServers {
+		if extensionServer.SocketPath == "" {
+			continue
+		}
+
+		err := extensionServer.ValidateServerConfigs()
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Clean(extensionServer.SocketPath)
+		if seenPaths[path] {
+			return nil, fmt.Errorf("Unix socket path %q is already in use", extensionServer.SocketPath)
+		}
+
+		seenPaths[path] = true
+
+		seen := make(map[string]bool)
+		resources := make([]rest.Resources, 0, len(extensionServer.Resources))
+		for _, endpoints := range extensionServer.Resources {
+			if seen[string(endpoints.PathPrefix)] {
+				return nil, fmt.Errorf("Path prefix %q is duplicated in server configuration", endpoints.PathPrefix)
+			}
+
+			if extensionServer.PreInit {
+				endpoints = allowBeforeInit(endpoints)
+			}
+
+			resources = append(resources, endpoints)
+			seen[string(endpoints.PathPrefix)] = true
+		}
+
+		extensionServer.Resources = resources
+		socketServers = append(socketServers, extensionServer)
+	}
+
+	return socketServers, nil
+}
+
+// allowBeforeInit returns a copy of the resources with AllowedBeforeInit set on every endpoint.
+func allowBeforeInit(resources rest.Resources) rest.Resources {
+	endpoints := make([]rest.Endpoint, 0, len(resources.Endpoints))
+	for _, e := range resources.Endpoints {
+		e.AllowedBeforeInit = true
+		endpoints = append(endpoints, e)
+	}
+
+	return rest.Resources{PathPrefix: resources.PathPrefix, Endpoints: endpoints}
+}
@@ -0,0 +1,190 @@
+package resources
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/types"
+	"github.com/canonical/microcluster/state"
+)
+
+type resourcesSuite struct {
+	suite.Suite
+}
+
+func TestResourcesSuite(t *testing.T) {
+	suite.Run(t, new(resourcesSuite))
+}
+
+// Ensures the CoreAPI, ServeUnix, SocketPath and PreInit flags place extension server endpoints on the expected listeners.
+func (t *resourcesSuite) Test_extensionServerFlags() {
+	handler := func(s *state.State, r *http.Request) response.Response { return response.EmptySyncResponse }
+	resources := func(prefix string) []rest.Resources {
+		return []rest.Resources{{
+			PathPrefix: types.EndpointPrefix(prefix),
+			Endpoints:  []rest.Endpoint{{Path: "config", Get: rest.EndpointAction{Handler: handler}}},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		server    rest.Server
+		expectErr bool
+		onCore    bool
+		onUnix    bool
+		onSocket  bool
+		preInit   bool
+	}{
+		{
+			name:   "Standalone server",
+			server: rest.Server{Resources: resources("standalone")},
+		},
+		{
+			name:   "Core API server",
+			server: rest.Server{CoreAPI: true, Resources: resources("core")},
+			onCore: true,
+		},
+		{
+			name:    "Core API server before init",
+			server:  rest.Server{CoreAPI: true, PreInit: true, Resources: resources("core")},
+			onCore:  true,
+			preInit: true,
+		},
+		{
+			name:   "Unix server",
+			server: rest.Server{ServeUnix: true, Resources: resources("unix")},
+			onUnix: true,
+		},
+		{
+			name:    "Unix server before init",
+			server:  rest.Server{ServeUnix: true, PreInit: true, Resources: resources("unix")},
+			onUnix:  true,
+			preInit: true,
+		},
+		{
+			name:     "Socket server",
+			server:   rest.Server{SocketPath: "/run/admin.socket", SocketGroup: "root", Resources: resources("admin")},
+			onSocket: true,
+		},
+		{
+			name:     "Socket server before init",
+			server:   rest.Server{SocketPath: "/run/admin.socket", PreInit: true, Resources: resources("admin")},
+			onSocket: true,
+			preInit:  true,
+		},
+		{
+			name:      "Socket server with ServeUnix",
+			server:    rest.Server{ServeUnix: true, SocketPath: "/run/admin.socket", Resources: resources("admin")},
+			expectErr: true,
+		},
+		{
+			name:      "Socket server with relative path",
+			server:    rest.Server{SocketPath: "admin.socket", Resources: resources("admin")},
+			expectErr: true,
+		},
+		{
+			name:      "Core API server with ServeUnix",
+			server:    rest.Server{CoreAPI: true, ServeUnix: true, Resources: resources("core")},
+			expectErr: true,
+		},
+		{
+			name:      "Standalone server before init",
+			server:    rest.Server{PreInit: true, Resources: resources("standalone")},
+			expectErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		t.T().Logf("%s (case %d)", test.name, i)
+
+		coreEndpoints, coreErr := GetAndValidateCoreEndpoints([]rest.Server{test.server})
+		unixEndpoints, unixErr := GetAndValidateUnixEndpoints([]rest.Server{test.server})
+		socketServers, socketErr := GetAndValidateSocketServers([]rest.Server{test.server}, "/run/control.socket")
+		validateErr := test.server.ValidateServerConfigs()
+		if test.expectErr {
+			t.Error(validateErr)
+			t.True(coreErr != nil || unixErr != nil || socketErr != nil)
+			continue
+		}
+
+		t.NoError(validateErr)
+		t.NoError(coreErr)
+		t.NoError(unixErr)
+		t.NoError(socketErr)
+
+		if test.onCore {
+			t.Len(coreEndpoints, 1)
+			t.Equal(test.preInit, coreEndpoints[0].Endpoints[0].AllowedBeforeInit)
+		} else {
+			t.Len(coreEndpoints, 0)
+		}
+
+		if test.onUnix {
+			t.Len(unixEndpoints, 1)
+			t.Equal(test.preInit, unixEndpoints[0].Endpoints[0].AllowedBeforeInit)
+		} else {
+			t.Len(unixEndpoints, 0)
+		}
+
+		if test.onSocket {
+			t.Len(socketServers, 1)
+			t.Equal(test.preInit, socketServers[0].Resources[0].Endpoints[0].AllowedBeforeInit)
+		} else {
+			t.Len(socketServers, 0)
+		}
+	}
+
+	// A unix server can't reuse the path prefix of the core API server.
+	_, err := GetAndValidateUnixEndpoints([]rest.Server{
+		{CoreAPI: true, Resources: resources("shared")},
+		{ServeUnix: true, Resources: resources("shared")},
+	})
+	t.Error(err)
+
+	// A socket server can't reuse the path of the control socket or of another socket server.
+	_, err = GetAndValidateSocketServers([]rest.Server{{SocketPath: "/run/control.socket", Resources: resources("admin")}}, "/run/control.socket")
+	t.Error(err)
+
+	_, err = GetAndValidateSocketServers([]rest.Server{
+		{SocketPath: "/run/admin.socket", Resources: resources("admin")},
+		{SocketPath: "/run/admin.socket", Resources: resources("other")},
+	}, "/run/control.socket")
+	t.Error(err)
+}
+
+// Ensures the OpenAPI document lists the methods of core and extension endpoints, with their path parameters and
+// whether they require authentication.
+func (t *resourcesSuite) Test_openAPI() {
+	handler := func(s *state.State, r *http.Request) response.Response { return response.EmptySyncResponse }
+	server := rest.Server{
+		Resources: []rest.Resources{{
+			PathPrefix: "1.0",
+			Endpoints: []rest.Endpoint{{
+				Path:    "services/{name:[a-z]+}",
+				Aliases: []rest.EndpointAlias{{Name: "service", Path: "service/{name}"}},
+				Get:     rest.EndpointAction{Handler: handler, AllowUntrusted: true},
+				Put:     rest.EndpointAction{Handler: handler},
+			}},
+		}},
+	}
+
+	document := OpenAPI("microd", "1.0", []rest.Server{server})
+	t.Equal("3.1.0", document.OpenAPI)
+
+	operations := document.Paths["/1.0/services/{name}"]
+	t.Len(operations, 2)
+	t.Equal([]string{"extension"}, operations["get"].Tags)
+	t.Empty(operations["get"].Security)
+	t.NotEmpty(operations["put"].Security)
+	t.Require().Len(operations["put"].Parameters, 1)
+	t.Equal("name", operations["put"].Parameters[0].Name)
+	t.Contains(document.Paths, "/1.0/service/{name}")
+
+	// Core endpoints are described as well.
+	t.Contains(document.Paths["/cluster/1.0/cluster/{name}"], "get")
+	t.Equal([]string{"control"}, document.Paths["/cluster/control/requests"]["get"].Tags)
+}
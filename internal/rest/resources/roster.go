@@ -0,0 +1,231 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/canonical/lxd/shared/logger"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// roster tracks the cluster members for which this cluster member has run the OnNewMember and PostRemove hooks. It is
+// persisted in the state directory, so that a member which was offline during a membership change can replay the
+// hooks it missed once it hears from the cluster again.
+//
+// A replayed hook may duplicate one that is also delivered normally, for example if the member crashes between
+// running a hook and recording it, or if the regular notification arrives long after the member joined or left.
+// Consumers must therefore make their OnNewMember and PostRemove hooks idempotent. To limit duplicates, drift is only
+// acted upon once it has been seen by two consecutive checks, which leaves time for the regular notifications.
+var roster struct {
+	mu sync.Mutex
+
+	// drift holds the changes seen by the previous check, keyed by cluster member name, with true for a new member.
+	drift map[string]bool
+}
+
+// rosterFile is the format of the roster in the state directory.
+type rosterFile struct {
+	Members []string `yaml:"members"`
+}
+
+// loadRoster reads the roster from the given path. It returns nil if no roster has been recorded yet.
+func loadRoster(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read cluster member roster: %w", err)
+	}
+
+	var file rosterFile
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse cluster member roster: %w", err)
+	}
+
+	members := make(map[string]bool, len(file.Members))
+	for _, name := range file.Members {
+		members[name] = true
+	}
+
+	return members, nil
+}
+
+// saveRoster writes the roster to the given path.
+func saveRoster(path string, members map[string]bool) error {
+	file := rosterFile{Members: make([]string, 0, len(members))}
+	for name := range members {
+		file.Members = append(file.Members, name)
+	}
+
+	sort.Strings(file.Members)
+
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write cluster member roster: %w", err)
+	}
+
+	return nil
+}
+
+// rosterDrift returns the names of the current cluster members missing from the roster, and of the roster members
+// no longer in the cluster, both sorted.
+func rosterDrift(members map[string]bool, current []string) (added []string, removed []string) {
+	currentMap := make(map[string]bool, len(current))
+	for _, name := range current {
+		currentMap[name] = true
+		if !members[name] {
+			added = append(added, name)
+		}
+	}
+
+	for name := range members {
+		if !currentMap[name] {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+// recordMemberHook records in the roster that the OnNewMember or PostRemove hook has been run for the given cluster
+// member. Failures are only logged, as the hook itself has already run.
+func recordMemberHook(s *state.State, name string, joined bool) {
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	members, err := loadRoster(s.OS.RosterPath())
+	if err != nil || members == nil {
+		if err != nil {
+			logger.Warn("Failed to record cluster member hook", logger.Ctx{"member": name, "error": err})
+		}
+
+		return
+	}
+
+	if joined {
+		members[name] = true
+	} else {
+		delete(members, name)
+	}
+
+	delete(roster.drift, name)
+
+	err = saveRoster(s.OS.RosterPath(), members)
+	if err != nil {
+		logger.Warn("Failed to record cluster member hook", logger.Ctx{"member": name, "error": err})
+	}
+}
+
+// renameRosterMember renames a cluster member in the roster, without running any hooks. Failures are only logged, as
+// they at worst cause the hooks to be replayed.
+func renameRosterMember(s *state.State, name string, newName string) {
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	members, err := loadRoster(s.OS.RosterPath())
+	if err != nil || members == nil || !members[name] {
+		if err != nil {
+			logger.Warn("Failed to rename cluster member in roster", logger.Ctx{"member": name, "error": err})
+		}
+
+		return
+	}
+
+	delete(members, name)
+	members[newName] = true
+	delete(roster.drift, name)
+	delete(roster.drift, newName)
+
+	err = saveRoster(s.OS.RosterPath(), members)
+	if err != nil {
+		logger.Warn("Failed to rename cluster member in roster", logger.Ctx{"member": name, "error": err})
+	}
+}
+
+// reconcileMemberHooks compares the roster with the given names of the current cluster members, and replays the
+// OnNewMember and PostRemove hooks for changes this cluster member missed. If no roster has been recorded yet, the
+// current cluster members are recorded without running any hooks.
+func reconcileMemberHooks(s *state.State, current []string) error {
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	path := s.OS.RosterPath()
+	members, err := loadRoster(path)
+	if err != nil {
+		return err
+	}
+
+	if members == nil {
+		members = make(map[string]bool, len(current))
+		for _, name := range current {
+			members[name] = true
+		}
+
+		return saveRoster(path, members)
+	}
+
+	added, removed := rosterDrift(members, current)
+	previous := roster.drift
+	roster.drift = make(map[string]bool, len(added)+len(removed))
+
+	for _, name := range added {
+		joined, seen := previous[name]
+		if !seen || !joined {
+			roster.drift[name] = true
+			continue
+		}
+
+		logger.Info("Replaying missed OnNewMember hook", logger.Ctx{"member": name})
+		err = state.OnNewMemberHook(s)
+		if err != nil {
+			return fmt.Errorf("Failed to replay hook for new cluster member %q: %w", name, err)
+		}
+
+		members[name] = true
+		err = saveRoster(path, members)
+		if err != nil {
+			return err
+		}
+
+		s.PublishEvent(types.EventMemberJoined, map[string]string{"name": name})
+	}
+
+	for _, name := range removed {
+		joined, seen := previous[name]
+		if !seen || joined {
+			roster.drift[name] = false
+			continue
+		}
+
+		logger.Info("Replaying missed PostRemove hook", logger.Ctx{"member": name})
+		err = state.PostRemoveHook(s, false)
+		if err != nil {
+			return fmt.Errorf("Failed to replay hook for removed cluster member %q: %w", name, err)
+		}
+
+		delete(members, name)
+		err = saveRoster(path, members)
+		if err != nil {
+			return err
+		}
+
+		s.PublishEvent(types.EventMemberRemoved, map[string]string{"name": name})
+	}
+
+	return nil
+}
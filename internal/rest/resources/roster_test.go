@@ -0,0 +1,78 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+)
+
+type rosterSuite struct {
+	suite.Suite
+}
+
+func TestRosterSuite(t *testing.T) {
+	suite.Run(t, new(rosterSuite))
+}
+
+// Ensures missed membership hooks are only replayed once drift has been seen by two consecutive checks.
+func (t *rosterSuite) Test_reconcileMemberHooks() {
+	s := &state.State{OS: &sys.OS{StateDir: t.T().TempDir()}}
+
+	var newMember, postRemove int
+	oldNewMember, oldPostRemove := state.OnNewMemberHook, state.PostRemoveHook
+	defer func() { state.OnNewMemberHook, state.PostRemoveHook = oldNewMember, oldPostRemove }()
+	state.OnNewMemberHook = func(s *state.State) error { newMember++; return nil }
+	state.PostRemoveHook = func(s *state.State, force bool) error { postRemove++; return nil }
+
+	// The first check records the roster without running any hooks.
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member02"}))
+	members, err := loadRoster(s.OS.RosterPath())
+	t.NoError(err)
+	t.Equal(map[string]bool{"member01": true, "member02": true}, members)
+
+	// member03 joined and member02 left while this member was unreachable.
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03"}))
+	t.Equal(0, newMember)
+	t.Equal(0, postRemove)
+
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03"}))
+	t.Equal(1, newMember)
+	t.Equal(1, postRemove)
+
+	members, err = loadRoster(s.OS.RosterPath())
+	t.NoError(err)
+	t.Equal(map[string]bool{"member01": true, "member03": true}, members)
+
+	// Hooks delivered normally are recorded, so they aren't replayed.
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03", "member04"}))
+	recordMemberHook(s, "member04", true)
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03", "member04"}))
+	t.Equal(1, newMember)
+}
+
+// Ensures a renamed cluster member isn't mistaken for one member leaving and another joining.
+func (t *rosterSuite) Test_renameRosterMember() {
+	s := &state.State{OS: &sys.OS{StateDir: t.T().TempDir()}}
+
+	var newMember, postRemove int
+	oldNewMember, oldPostRemove := state.OnNewMemberHook, state.PostRemoveHook
+	defer func() { state.OnNewMemberHook, state.PostRemoveHook = oldNewMember, oldPostRemove }()
+	state.OnNewMemberHook = func(s *state.State) error { newMember++; return nil }
+	state.PostRemoveHook = func(s *state.State, force bool) error { postRemove++; return nil }
+
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member02"}))
+
+	// The rename is seen by a check before it is recorded.
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03"}))
+	renameRosterMember(s, "member02", "member03")
+	t.NoError(reconcileMemberHooks(s, []string{"member01", "member03"}))
+	t.Equal(0, newMember)
+	t.Equal(0, postRemove)
+
+	members, err := loadRoster(s.OS.RosterPath())
+	t.NoError(err)
+	t.Equal(map[string]bool{"member01": true, "member03": true}, members)
+}
@@ -12,8 +12,9 @@ import (
 )
 
 var shutdownCmd = rest.Endpoint{
-	AllowedBeforeInit: true,
-	Path:              "shutdown",
+	AllowedBeforeInit:    true,
+	AllowedWhenDBOffline: true,
+	Path:                 "shutdown",
 
 	Post: rest.EndpointAction{Handler: shutdownPost, AccessHandler: access.AllowAuthenticated},
 }
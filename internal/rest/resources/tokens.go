@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/shared"
@@ -13,6 +15,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/db"
 	internalTypes "github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/internal/state"
 	"github.com/canonical/microcluster/rest"
@@ -27,6 +30,12 @@ var tokensCmd = rest.Endpoint{
 	Get:  rest.EndpointAction{Handler: tokensGet, AccessHandler: access.AllowAuthenticated},
 }
 
+var tokensBatchCmd = rest.Endpoint{
+	Path: "tokens/batch",
+
+	Post: rest.EndpointAction{Handler: tokensBatchPost, AccessHandler: access.AllowAuthenticated},
+}
+
 var tokenCmd = rest.Endpoint{
 	Path: "tokens/{name}",
 
@@ -42,16 +51,114 @@ func tokensPost(state *state.State, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	// Generate join token for new member. This will be stored alongside the join
-	// address and cluster certificate to simplify setup.
-	tokenKey, err := shared.RandomCryptoString()
+	err = validateFQDN(req.Name)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Invalid join token name %q: %w", req.Name, err))
+	}
+
+	token, err := newJoinToken(state)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	tokenString, err := token.String()
 	if err != nil {
 		return response.InternalError(err)
 	}
 
+	err = state.Database.InternalTransaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
+		_, err = cluster.CreateInternalTokenRecord(ctx, tx, cluster.InternalTokenRecord{Name: req.Name, Secret: token.Secret})
+		if err != nil {
+			return err
+		}
+
+		state.Database.RecordChange(ctx, db.TokenRecordsTable, types.ChangeCreate, req.Name)
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, tokenString)
+}
+
+// tokensBatchPost creates a join token for each requested name. Either all of the tokens are created, or none are.
+func tokensBatchPost(state *state.State, r *http.Request) response.Response {
+	req := []internalTypes.TokenRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if len(req) == 0 {
+		return response.BadRequest(fmt.Errorf("No join tokens requested"))
+	}
+
+	dbRecords := make([]cluster.InternalTokenRecord, 0, len(req))
+	records := make([]internalTypes.TokenRecord, 0, len(req))
+	for _, tokenReq := range req {
+		err := validateFQDN(tokenReq.Name)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Invalid join token name %q: %w", tokenReq.Name, err))
+		}
+
+		if tokenReq.ExpireAfter < 0 {
+			return response.BadRequest(fmt.Errorf("Invalid expiry for join token %q: %s", tokenReq.Name, tokenReq.ExpireAfter))
+		}
+
+		token, err := newJoinToken(state)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		tokenString, err := token.String()
+		if err != nil {
+			return response.InternalError(err)
+		}
+
+		dbRecord := cluster.InternalTokenRecord{Name: tokenReq.Name, Secret: token.Secret}
+		record := internalTypes.TokenRecord{Name: tokenReq.Name, Token: tokenString}
+		if tokenReq.ExpireAfter > 0 {
+			expiryDate := time.Now().UTC().Add(tokenReq.ExpireAfter)
+			dbRecord.ExpiryDate = sql.NullTime{Time: expiryDate, Valid: true}
+			record.ExpiryDate = expiryDate
+		}
+
+		dbRecords = append(dbRecords, dbRecord)
+		records = append(records, record)
+	}
+
+	err = state.Database.InternalTransaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.CreateInternalTokenRecords(ctx, tx, dbRecords...)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range dbRecords {
+			state.Database.RecordChange(ctx, db.TokenRecordsTable, types.ChangeCreate, record.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, records)
+}
+
+// newJoinToken generates a join token with a new secret. This will be stored alongside the join address and cluster
+// certificate to simplify setup.
+func newJoinToken(state *state.State) (*internalTypes.Token, error) {
+	tokenKey, err := shared.RandomCryptoString()
+	if err != nil {
+		return nil, err
+	}
+
 	clusterCert, err := state.ClusterCert().PublicKeyX509()
 	if err != nil {
-		return response.InternalError(err)
+		return nil, err
 	}
 
 	joinAddresses := []types.AddrPort{}
@@ -63,30 +170,15 @@ func tokensPost(state *state.State, r *http.Request) response.Response {
 		logger.Warnf("Failed to check trust store for eligible join addresses. Issuing token with join address %q", state.Address().URL.Host)
 		joinAddresses, err = types.ParseAddrPorts([]string{state.Address().URL.Host})
 		if err != nil {
-			return response.SmartError(err)
+			return nil, err
 		}
 	}
 
-	token := internalTypes.Token{
+	return &internalTypes.Token{
 		Secret:        tokenKey,
 		Fingerprint:   shared.CertFingerprint(clusterCert),
 		JoinAddresses: joinAddresses,
-	}
-
-	tokenString, err := token.String()
-	if err != nil {
-		return response.InternalError(err)
-	}
-
-	err = state.Database.Transaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
-		_, err = cluster.CreateInternalTokenRecord(ctx, tx, cluster.InternalTokenRecord{Name: req.Name, Secret: tokenKey})
-		return err
-	})
-	if err != nil {
-		return response.SmartError(err)
-	}
-
-	return response.SyncResponse(true, tokenString)
+	}, nil
 }
 
 func tokensGet(state *state.State, r *http.Request) response.Response {
@@ -100,8 +192,10 @@ func tokensGet(state *state.State, r *http.Request) response.Response {
 		joinAddresses = append(joinAddresses, addr)
 	}
 
+	serverTime := time.Now().UTC()
+
 	var records []internalTypes.TokenRecord
-	err = state.Database.Transaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
+	err = state.Database.InternalTransaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
 		var err error
 		tokens, err := cluster.GetInternalTokenRecords(ctx, tx)
 		if err != nil {
@@ -115,6 +209,7 @@ func tokensGet(state *state.State, r *http.Request) response.Response {
 				return err
 			}
 
+			apiToken.ServerTime = serverTime
 			records = append(records, *apiToken)
 		}
 
@@ -133,8 +228,15 @@ func tokenDelete(state *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	err = state.Database.Transaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
-		return cluster.DeleteInternalTokenRecord(ctx, tx, name)
+	err = state.Database.InternalTransaction(state.Context, func(ctx context.Context, tx *sql.Tx) error {
+		err := cluster.DeleteInternalTokenRecord(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		state.Database.RecordChange(ctx, db.TokenRecordsTable, types.ChangeDelete, name)
+
+		return nil
 	})
 	if err != nil {
 		return response.SmartError(err)
@@ -0,0 +1,40 @@
+package resources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/internal/state"
+)
+
+type tokensSuite struct {
+	suite.Suite
+}
+
+func TestTokensSuite(t *testing.T) {
+	suite.Run(t, new(tokensSuite))
+}
+
+// Ensures join tokens aren't created for names that aren't valid FQDNs, whether requested alone or in a batch.
+func (t *tokensSuite) Test_tokensPostInvalidName() {
+	tests := []struct {
+		handler func(*state.State, *http.Request) response.Response
+		body    string
+	}{
+		{handler: tokensPost, body: `{"name": "member_01"}`},
+		{handler: tokensPost, body: `{"name": ""}`},
+		{handler: tokensBatchPost, body: `[{"name": "member01"}, {"name": "-member02"}]`},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		err := test.handler(&state.State{}, httptest.NewRequest("POST", "/cluster/1.0/tokens", strings.NewReader(test.body))).Render(recorder)
+		t.Require().NoError(err)
+		t.Equal(http.StatusBadRequest, recorder.Code, test.body)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
 	"github.com/gorilla/mux"
 
 	"github.com/canonical/microcluster/client"
@@ -18,6 +19,7 @@ import (
 	"github.com/canonical/microcluster/internal/trust"
 	"github.com/canonical/microcluster/rest"
 	"github.com/canonical/microcluster/rest/access"
+	"github.com/canonical/microcluster/rest/types"
 )
 
 var trustCmd = rest.Endpoint{
@@ -27,10 +29,33 @@ var trustCmd = rest.Endpoint{
 	Post: rest.EndpointAction{Handler: trustPost, AccessHandler: access.AllowAuthenticated},
 }
 
+// trustBundleCmd exports and imports the truststore as a bundle signed with the cluster keypair.
+var trustBundleCmd = rest.Endpoint{
+	Path: "truststore",
+
+	Get:  rest.EndpointAction{Handler: trustBundleGet, AccessHandler: access.AllowAuthenticated},
+	Post: rest.EndpointAction{Handler: trustBundlePost, AccessHandler: access.AllowAuthenticated},
+}
+
+// trustPEMCmd returns the public certificates that make up the cluster's trust as PEM, for configuring external load
+// balancers or monitoring. Unlike the signed bundle of trustBundleCmd, it can't be imported back.
+var trustPEMCmd = rest.Endpoint{
+	Path: "truststore/pem",
+
+	Get: rest.EndpointAction{Handler: trustPEMGet, AccessHandler: access.AllowAuthenticated},
+}
+
+var trustRefreshCmd = rest.Endpoint{
+	Path: "truststore/refresh",
+
+	Post: rest.EndpointAction{Handler: trustRefreshPost, AccessHandler: access.AllowAuthenticated},
+}
+
 var trustEntryCmd = rest.Endpoint{
 	Path:              "truststore/{name}",
 	AllowedBeforeInit: true,
 
+	Put:    rest.EndpointAction{Handler: trustPut, AccessHandler: access.AllowAuthenticated},
 	Delete: rest.EndpointAction{Handler: trustDelete, AccessHandler: access.AllowAuthenticated},
 }
 
@@ -44,11 +69,12 @@ func trustPost(s *state.State, r *http.Request) response.Response {
 	}
 
 	newRemote := trust.Remote{
-		Location:    trust.Location{Name: req.Name, Address: req.Address},
-		Certificate: req.Certificate,
+		Location:          trust.Location{Name: req.Name, Address: req.Address},
+		Certificate:       req.Certificate,
+		ClientCertificate: req.ClientCertificate,
 	}
 
-	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	ctx, cancel := context.WithTimeout(internalClient.WithRequestID(s.Context, internalClient.RequestID(r.Context())), 30*time.Second)
 	defer cancel()
 
 	if !client.IsNotification(r) {
@@ -89,7 +115,7 @@ func trustDelete(s *state.State, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	ctx, cancel := context.WithTimeout(internalClient.WithRequestID(s.Context, internalClient.RequestID(r.Context())), 30*time.Second)
 	defer cancel()
 
 	remotesMap := s.Remotes().RemotesByName()
@@ -125,9 +151,10 @@ func trustDelete(s *state.State, r *http.Request) response.Response {
 	for _, remote := range remotesMap {
 		newRemote := internalTypes.ClusterMember{
 			ClusterMemberLocal: internalTypes.ClusterMemberLocal{
-				Name:        remote.Name,
-				Address:     remote.Address,
-				Certificate: remote.Certificate,
+				Name:              remote.Name,
+				Address:           remote.Address,
+				Certificate:       remote.Certificate,
+				ClientCertificate: remote.ClientCertificate,
 			},
 		}
 
@@ -141,3 +168,185 @@ func trustDelete(s *state.State, r *http.Request) response.Response {
 
 	return response.EmptySyncResponse
 }
+
+// trustPut renames the truststore entry of a cluster member, keeping its address, and its certificate unless a new one
+// is given.
+func trustPut(s *state.State, r *http.Request) response.Response {
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := internalTypes.ClusterMemberName{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = validateFQDN(req.Name)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Invalid cluster member name %q: %w", req.Name, err))
+	}
+
+	ctx, cancel := context.WithTimeout(internalClient.WithRequestID(s.Context, internalClient.RequestID(r.Context())), 30*time.Second)
+	defer cancel()
+
+	if !client.IsNotification(r) {
+		cluster, err := s.Cluster(true)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = cluster.Query(ctx, true, func(ctx context.Context, c *client.Client) error {
+			// No need to send a request to ourselves.
+			if s.Address().URL.Host == c.URL().URL.Host {
+				return nil
+			}
+
+			return internalClient.RenameTrustStoreEntry(ctx, &c.Client, name, req.Name, req.Certificate)
+		})
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	err = renameTrustStoreEntry(s, name, req.Name, req.Certificate)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// renameTrustStoreEntry renames the local truststore entry of a cluster member, and its record in the roster so that
+// the rename isn't mistaken for one member leaving and another joining. If cert is set, it replaces the certificate of
+// the entry. Renaming an entry that was already renamed, for example by a heartbeat, only updates the roster. An
+// entry with the new name that has a different certificate belongs to another cluster member, and is a conflict.
+func renameTrustStoreEntry(s *state.State, name string, newName string, cert *types.X509Certificate) error {
+	remotes := s.Remotes()
+	remotesMap := remotes.RemotesByName()
+	oldRemote, hasOld := remotesMap[name]
+	existing, hasNew := remotesMap[newName]
+	if !hasOld && !hasNew {
+		return api.StatusErrorf(http.StatusNotFound, "No truststore entry found for node with name %q", name)
+	}
+
+	if hasNew {
+		expected := cert
+		if expected == nil && hasOld {
+			expected = &oldRemote.Certificate
+		}
+
+		if expected != nil && !existing.Certificate.Equal(expected.Certificate) {
+			return api.StatusErrorf(http.StatusConflict, "Truststore entry with name %q belongs to another cluster member", newName)
+		}
+	}
+
+	if hasOld && !hasNew {
+		newRemotes := make([]internalTypes.ClusterMember, 0, len(remotesMap))
+		for _, remote := range remotesMap {
+			if remote.Name == name {
+				remote.Name = newName
+				if cert != nil {
+					remote.Certificate = *cert
+				}
+			}
+
+			newRemote := internalTypes.ClusterMember{
+				ClusterMemberLocal: internalTypes.ClusterMemberLocal{
+					Name:              remote.Name,
+					Address:           remote.Address,
+					Certificate:       remote.Certificate,
+					ClientCertificate: remote.ClientCertificate,
+				},
+			}
+
+			newRemotes = append(newRemotes, newRemote)
+		}
+
+		err := remotes.Replace(s.OS.TrustDir, newRemotes...)
+		if err != nil {
+			return fmt.Errorf("Failed to rename truststore entry for node with name %q: %w", name, err)
+		}
+	}
+
+	renameRosterMember(s, name, newName)
+
+	return nil
+}
+
+// trustBundleGet exports the truststore as a bundle signed with the cluster keypair.
+func trustBundleGet(s *state.State, r *http.Request) response.Response {
+	bundle, err := s.Remotes().Export(s.ClusterCert())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, bundle)
+}
+
+// trustPEMGet returns the cluster certificate, its CA and the certificate of every cluster member as a PEM bundle.
+func trustPEMGet(s *state.State, r *http.Request) response.Response {
+	bundle, err := s.Remotes().PEMBundle(s.ClusterCert())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, string(bundle))
+}
+
+// trustBundlePost imports all entries of a bundle signed with the cluster keypair into the local truststore.
+func trustBundlePost(s *state.State, r *http.Request) response.Response {
+	req := internalTypes.TrustBundle{}
+
+	// Parse the request.
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	publicKey, err := s.ClusterCert().PublicKeyX509()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	added, err := s.Remotes().Import(s.OS.TrustDir, publicKey, req)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to import truststore bundle: %w", err))
+	}
+
+	err = s.RefreshTrust(r.Context())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if len(added) == 0 {
+		return response.EmptySyncResponse
+	}
+
+	addrs := make([]types.AddrPort, 0, len(added))
+	for _, remote := range added {
+		addrs = append(addrs, remote.Address)
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context, 30*time.Second)
+	defer cancel()
+
+	err = s.Database.AddKnownAddresses(ctx, addrs...)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// trustRefreshPost reloads the truststore from the state directory, so that trust material written out of band is
+// active once the request returns.
+func trustRefreshPost(s *state.State, r *http.Request) response.Response {
+	err := s.RefreshTrust(r.Context())
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to refresh truststore: %w", err))
+	}
+
+	return response.EmptySyncResponse
+}
@@ -1,10 +1,13 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -44,6 +47,10 @@ func handleAPIRequest(action rest.EndpointAction, state *state.State, w http.Res
 		}
 	}
 
+	if action.ForwardToLeader && state.Database.IsReadOnly() {
+		return forwardToLeader(action, state, r)
+	}
+
 	if action.ProxyTarget {
 		return proxyTarget(action, state, r)
 	}
@@ -51,6 +58,71 @@ func handleAPIRequest(action rest.EndpointAction, state *state.State, w http.Res
 	return action.Handler(state, r)
 }
 
+// forwardToLeader sends the request to the current dqlite leader, so that read-only cluster members can serve actions
+// that modify the database. The request is handled locally if this member is the leader.
+//
+// The leader is resolved through dqlite for every attempt. If the request can't be sent, for example because the
+// leader went away, the leader is resolved again and the request is sent once more. Error responses from the leader
+// are returned as is, as the request may already have been applied. Requests already forwarded by this member are
+// rejected, to break forwarding loops while cluster members disagree on the leader.
+func forwardToLeader(action rest.EndpointAction, s *state.State, r *http.Request) response.Response {
+	// Keep the body, so that it can be sent again.
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Failed to read request body: %w", err))
+		}
+	}
+
+	requestID := client.RequestID(r.Context())
+	forwardedBy := client.ForwardedBy(r)
+	forward := func() (*api.Response, bool, error) {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		leader, err := s.Leader()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if leader.URL().URL.Host == s.Address().URL.Host {
+			return nil, true, nil
+		}
+
+		err = client.SetForwardedBy(r, forwardedBy, s.Name())
+		if err != nil {
+			return nil, false, err
+		}
+
+		r.RequestURI = ""
+		r.URL.Scheme = leader.URL().URL.Scheme
+		r.URL.Host = leader.URL().URL.Host
+		r.Host = leader.URL().URL.Host
+
+		logger.Info("Forwarding request to leader", logger.Ctx{"source": s.Name(), "leader": r.Host, "request_id": requestID})
+		resp, err := leader.MakeRequest(r)
+
+		return resp, false, err
+	}
+
+	resp, local, err := forward()
+	if err != nil && !api.StatusErrorCheck(err) {
+		logger.Warn("Failed to forward request to leader, retrying", logger.Ctx{"request_id": requestID, "error": err})
+		resp, local, err = forward()
+	}
+
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed to forward request to leader: %w", err))
+	}
+
+	if local {
+		return action.Handler(s, r)
+	}
+
+	return response.SyncResponse(true, resp.Metadata)
+}
+
 func proxyTarget(action rest.EndpointAction, s *state.State, r *http.Request) response.Response {
 	if r.URL == nil {
 		return action.Handler(s, r)
@@ -70,6 +142,12 @@ func proxyTarget(action rest.EndpointAction, s *state.State, r *http.Request) re
 		return action.Handler(s, r)
 	}
 
+	// Refuse to forward a request that this member already forwarded.
+	err = client.SetForwardedBy(r, client.ForwardedBy(r), s.Name())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	var targetURL *api.URL
 	err = s.Database.Transaction(s.Context, func(ctx context.Context, tx *sql.Tx) error {
 		clusterMember, err := cluster.GetInternalClusterMember(ctx, tx, target)
@@ -85,12 +163,13 @@ func proxyTarget(action rest.EndpointAction, s *state.State, r *http.Request) re
 		return response.BadRequest(err)
 	}
 
+	requestID := client.RequestID(r.Context())
 	clusterCert, err := s.ClusterCert().PublicKeyX509()
 	if err != nil {
 		return response.InternalError(fmt.Errorf("Failed to parse cluster certificate for request: %w", err))
 	}
 
-	client, err := client.New(*targetURL, s.ServerCert(), clusterCert, false)
+	client, err := client.New(*targetURL, s.ClientCert(), clusterCert, false, s.TrustedClusterCerts.Certs()...)
 	if err != nil {
 		return response.InternalError(fmt.Errorf("Failed to get a client for the target %q at address %q: %w", target, targetURL.String(), err))
 	}
@@ -101,7 +180,7 @@ func proxyTarget(action rest.EndpointAction, s *state.State, r *http.Request) re
 	r.URL.Host = targetURL.URL.Host
 	r.Host = targetURL.URL.Host
 
-	logger.Info("Forwarding request to specified target", logger.Ctx{"source": s.Name(), "target": target})
+	logger.Info("Forwarding request to specified target", logger.Ctx{"source": s.Name(), "target": target, "request_id": requestID})
 	resp, err := client.MakeRequest(r)
 	if err != nil {
 		return response.SmartError(fmt.Errorf("Failed to send request to target %q: %w", target, err))
@@ -110,6 +189,22 @@ func proxyTarget(action rest.EndpointAction, s *state.State, r *http.Request) re
 	return response.SyncResponse(true, resp.Metadata)
 }
 
+// DatabaseUpgrade is returned by the handler of the database endpoint to accept an inbound dqlite connection. Instead
+// of rendering a response, the connection is then hijacked and handed over to dqlite.
+var DatabaseUpgrade response.Response = &databaseUpgradeResponse{}
+
+type databaseUpgradeResponse struct{}
+
+// Render renders an empty response, in case the connection is not hijacked.
+func (r *databaseUpgradeResponse) Render(w http.ResponseWriter) error {
+	return response.EmptySyncResponse.Render(w)
+}
+
+// String returns a description of the response.
+func (r *databaseUpgradeResponse) String() string {
+	return "database upgrade"
+}
+
 func handleDatabaseRequest(action rest.EndpointAction, state *state.State, w http.ResponseWriter, r *http.Request) response.Response {
 	trusted := r.Context().Value(request.CtxAccess)
 	if trusted == nil {
@@ -129,22 +224,67 @@ func handleDatabaseRequest(action rest.EndpointAction, state *state.State, w htt
 		return response.NotImplemented(nil)
 	}
 
-	// If the request is a POST, then it is likely from the dqlite dial function, so hijack the connection.
-	if r.Method == "POST" {
+	resp := action.Handler(state, r)
+
+	// Hijack the connection for dqlite once the handler has accepted the upgrade. Rejected requests, such as those
+	// beyond the connection limits, get an error response before the upgrade.
+	_, upgrade := resp.(*databaseUpgradeResponse)
+	if upgrade {
+		peer := r.RemoteAddr
+		identity := access.PeerIdentity(r)
+		if identity != nil {
+			peer = identity.Fingerprint
+		} else {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err == nil {
+				peer = host
+			}
+		}
+
+		release, err := state.Database.ReserveConnection(peer)
+		if err != nil {
+			logger.Warn("Rejecting dqlite connection", logger.Ctx{"address": r.RemoteAddr, "error": err})
+			return response.SmartError(err)
+		}
+
 		hijacker, ok := w.(http.Hijacker)
 		if !ok {
+			release()
 			return response.InternalError(fmt.Errorf("Webserver does not support hijacking"))
 		}
 
 		conn, _, err := hijacker.Hijack()
 		if err != nil {
+			release()
 			return response.InternalError(fmt.Errorf("Failed to hijack connection: %w", err))
 		}
 
-		state.Database.Accept(conn)
+		state.Database.Accept(conn, release)
+
+		return nil
 	}
 
-	return action.Handler(state, r)
+	return resp
+}
+
+// requestIdentity returns the identity of the caller based on its TLS peer certificate, named after the cluster member
+// it matches in the truststore. Requests over the unix socket are attributed to the local peer.
+func requestIdentity(state *state.State, r *http.Request) *internalAccess.Identity {
+	if r.RemoteAddr == "@" {
+		return &internalAccess.Identity{Name: internalAccess.LocalPeerName}
+	}
+
+	identity := access.PeerIdentity(r)
+	if identity == nil {
+		return nil
+	}
+
+	remote := state.Remotes().RemoteByCertificateFingerprint(identity.Fingerprint)
+	if remote != nil {
+		identity.Name = remote.Name
+	}
+
+	return identity
 }
 
 // HandleEndpoint adds the endpoint to the mux router. A function variable is used to implement common logic
@@ -158,6 +298,17 @@ func HandleEndpoint(state *state.State, mux *mux.Router, version string, e rest.
 	route := mux.HandleFunc(url, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
+		// Reuse the request ID of the caller so that requests made on its behalf can be correlated, and report it back.
+		// The caller may not be authenticated yet, so a fresh ID is used instead of one that is unsafe to log.
+		requestID := r.Header.Get(client.RequestIDHeader)
+		if !client.ValidRequestID(requestID) {
+			requestID = client.NewRequestID()
+		}
+
+		r = r.WithContext(client.WithRequestID(r.Context(), requestID))
+		w.Header().Set(client.RequestIDHeader, requestID)
+		logger.Debug("Handling API request", logger.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "request_id": requestID})
+
 		// Actually process the request.
 		var resp response.Response
 
@@ -165,7 +316,7 @@ func HandleEndpoint(state *state.State, mux *mux.Router, version string, e rest.
 		if state.Context.Err() == context.Canceled && !e.AllowedDuringShutdown {
 			err := response.Unavailable(fmt.Errorf("Daemon is shutting down")).Render(w)
 			if err != nil {
-				logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
+				logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err, "request_id": requestID})
 			}
 
 			return
@@ -175,13 +326,23 @@ func HandleEndpoint(state *state.State, mux *mux.Router, version string, e rest.
 			if !state.Database.IsOpen() {
 				err := response.Unavailable(fmt.Errorf("Daemon not yet initialized")).Render(w)
 				if err != nil {
-					logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err})
+					logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err, "request_id": requestID})
 				}
 
 				return
 			}
 		}
 
+		// Return Unavailable Error (503) if the database can't be reached, except for endpoints with AllowedWhenDBOffline.
+		if !e.AllowedWhenDBOffline && state.Database.IsOffline() {
+			err := response.Unavailable(fmt.Errorf("Database is offline")).Render(w)
+			if err != nil {
+				logger.Error("Failed to write HTTP response", logger.Ctx{"url": r.URL, "err": err, "request_id": requestID})
+			}
+
+			return
+		}
+
 		// If the request is a database request, the connection should be hijacked.
 		handleRequest := handleAPIRequest
 		if e.Path == "database" {
@@ -189,10 +350,27 @@ func HandleEndpoint(state *state.State, mux *mux.Router, version string, e rest.
 		}
 
 		trusted, err := access.Authenticate(state, r, state.Address().URL.Host, state.Remotes().CertificatesNative())
+		if isDebugHTTP(r) {
+			trusted, err = r.Method == http.MethodGet, nil
+		}
+
 		if err != nil && !errors.As(err, &access.ErrInvalidHost{}) {
 			resp = response.Forbidden(fmt.Errorf("Failed to authenticate request: %w", err))
 		} else {
-			r = internalAccess.SetRequestAuthentication(r, trusted)
+			identity := requestIdentity(state, r)
+			r = internalAccess.SetRequestAuthentication(r, trusted, identity)
+
+			// Track the request until it has been served, so that it can be listed and cancelled.
+			if state.Requests != nil {
+				peerName := ""
+				if identity != nil {
+					peerName = identity.Name
+				}
+
+				var done func()
+				r, done = state.Requests.Track(r, requestID, peerName)
+				defer done()
+			}
 
 			switch r.Method {
 			case "GET":
@@ -210,13 +388,13 @@ func HandleEndpoint(state *state.State, mux *mux.Router, version string, e rest.
 			}
 		}
 
-		// Handle errors.
-		if e.Path != "database" {
+		// Handle errors. Database requests have no response once their connection has been hijacked.
+		if resp != nil {
 			err := resp.Render(w)
 			if err != nil {
 				err := response.InternalError(err).Render(w)
 				if err != nil {
-					logger.Error("Failed writing error for HTTP response", logger.Ctx{"url": url, "error": err})
+					logger.Error("Failed writing error for HTTP response", logger.Ctx{"url": url, "error": err, "request_id": requestID})
 				}
 			}
 		}
@@ -0,0 +1,353 @@
+package rest
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/microcluster/internal/db"
+	internalAccess "github.com/canonical/microcluster/internal/rest/access"
+	"github.com/canonical/microcluster/internal/rest/client"
+	"github.com/canonical/microcluster/internal/state"
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest"
+	"github.com/canonical/microcluster/rest/access"
+)
+
+type restSuite struct {
+	suite.Suite
+}
+
+func TestRestSuite(t *testing.T) {
+	suite.Run(t, new(restSuite))
+}
+
+// Ensures only endpoints with AllowedWhenDBOffline are served while the database is offline.
+func (t *restSuite) Test_allowedWhenDBOffline() {
+	database := db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: t.T().TempDir()})
+	database.SetOffline(true)
+
+	s := &state.State{
+		Context:  context.Background(),
+		Address:  func() *api.URL { return api.NewURL() },
+		Remotes:  func() *trust.Remotes { return &trust.Remotes{} },
+		Database: database,
+	}
+
+	handler := func(s *state.State, r *http.Request) response.Response { return response.EmptySyncResponse }
+
+	router := mux.NewRouter()
+	HandleEndpoint(s, router, "1.0", rest.Endpoint{
+		Path:                 "status",
+		AllowedBeforeInit:    true,
+		AllowedWhenDBOffline: true,
+		Get:                  rest.EndpointAction{Handler: handler, AllowUntrusted: true},
+	})
+
+	HandleEndpoint(s, router, "1.0", rest.Endpoint{
+		Path:              "data",
+		AllowedBeforeInit: true,
+		Get:               rest.EndpointAction{Handler: handler, AllowUntrusted: true},
+	})
+
+	get := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "@"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusOK, get("/1.0/status"))
+	t.Equal(http.StatusServiceUnavailable, get("/1.0/data"))
+
+	// Both endpoints are served once the database is back online.
+	database.SetOffline(false)
+	t.Equal(http.StatusOK, get("/1.0/status"))
+	t.Equal(http.StatusOK, get("/1.0/data"))
+}
+
+// Ensures requests over the unix socket are attributed to the local peer, and that unknown callers have no identity.
+func (t *restSuite) Test_requestIdentity() {
+	s := &state.State{
+		Context:  context.Background(),
+		Address:  func() *api.URL { return api.NewURL() },
+		Remotes:  func() *trust.Remotes { return &trust.Remotes{} },
+		Database: db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: t.T().TempDir()}),
+	}
+
+	var identity *access.Identity
+	router := mux.NewRouter()
+	HandleEndpoint(s, router, "1.0", rest.Endpoint{
+		Path:                 "peer",
+		AllowedBeforeInit:    true,
+		AllowedWhenDBOffline: true,
+		Get: rest.EndpointAction{AllowUntrusted: true, Handler: func(s *state.State, r *http.Request) response.Response {
+			identity = access.RequestIdentity(r)
+
+			return response.EmptySyncResponse
+		}},
+	})
+
+	req := httptest.NewRequest("GET", "/1.0/peer", nil)
+	req.RemoteAddr = "@"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	t.Require().NotNil(identity)
+	t.Equal(access.LocalPeerName, identity.Name)
+	t.Empty(identity.Fingerprint)
+
+	req = httptest.NewRequest("GET", "/1.0/peer", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	t.Nil(identity)
+}
+
+// Ensures the request ID of the caller is only reused if it is safe to log, and replaced by a fresh one otherwise.
+func (t *restSuite) Test_requestID() {
+	s := &state.State{
+		Context:  context.Background(),
+		Address:  func() *api.URL { return api.NewURL() },
+		Remotes:  func() *trust.Remotes { return &trust.Remotes{} },
+		Database: db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: t.T().TempDir()}),
+	}
+
+	router := mux.NewRouter()
+	HandleEndpoint(s, router, "1.0", rest.Endpoint{
+		Path:                 "status",
+		AllowedBeforeInit:    true,
+		AllowedWhenDBOffline: true,
+		Get: rest.EndpointAction{AllowUntrusted: true, Handler: func(s *state.State, r *http.Request) response.Response {
+			return response.EmptySyncResponse
+		}},
+	})
+
+	get := func(requestID string) string {
+		req := httptest.NewRequest("GET", "/1.0/status", nil)
+		req.Header.Set(client.RequestIDHeader, requestID)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder.Header().Get(client.RequestIDHeader)
+	}
+
+	t.Equal("member01:3f2a-b.c_d", get("member01:3f2a-b.c_d"))
+
+	for _, requestID := range []string{"", "bad id", "bad\nid", "bad\"id", strings.Repeat("a", 129)} {
+		fresh := get(requestID)
+		t.NotEqual(requestID, fresh)
+		t.True(client.ValidRequestID(fresh), fresh)
+	}
+}
+
+// Ensures the connection of a database request is only hijacked if the handler explicitly accepted the upgrade.
+func (t *restSuite) Test_handleDatabaseRequest() {
+	s := &state.State{
+		Context:  context.Background(),
+		Database: db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: t.T().TempDir()}),
+	}
+
+	handle := func(resp response.Response) int {
+		action := rest.EndpointAction{Handler: func(s *state.State, r *http.Request) response.Response { return resp }}
+		req := internalAccess.SetRequestAuthentication(httptest.NewRequest("POST", "/1.0/database", nil), true, nil)
+
+		// The recorder can't be hijacked, so an accepted upgrade fails with an internal error.
+		recorder := httptest.NewRecorder()
+		err := handleDatabaseRequest(action, s, recorder, req).Render(recorder)
+		t.Require().NoError(err)
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusInternalServerError, handle(DatabaseUpgrade))
+	t.Equal(http.StatusOK, handle(response.EmptySyncResponse))
+	t.Equal(http.StatusOK, handle(response.SyncResponse(true, nil)))
+	t.Equal(http.StatusTooManyRequests, handle(response.SmartError(api.StatusErrorf(http.StatusTooManyRequests, "Too many"))))
+}
+
+// Ensures a request is not forwarded again by a cluster member that already forwarded it, but can still be handled there.
+func (t *restSuite) Test_forwardingLoop() {
+	s := &state.State{
+		Context:  context.Background(),
+		Address:  func() *api.URL { return api.NewURL() },
+		Name:     func() string { return "member01" },
+		Remotes:  func() *trust.Remotes { return &trust.Remotes{} },
+		Database: db.NewDB(context.Background(), nil, nil, &sys.OS{StateDir: t.T().TempDir()}),
+	}
+
+	router := mux.NewRouter()
+	HandleEndpoint(s, router, "1.0", rest.Endpoint{
+		Path:                 "hooks",
+		AllowedBeforeInit:    true,
+		AllowedWhenDBOffline: true,
+		Post: rest.EndpointAction{AllowUntrusted: true, ProxyTarget: true, Handler: func(s *state.State, r *http.Request) response.Response {
+			return response.EmptySyncResponse
+		}},
+	})
+
+	post := func(target string, forwardedBy string) int {
+		req := httptest.NewRequest("POST", "/1.0/hooks?target="+target, nil)
+		req.RemoteAddr = "@"
+		req.Header.Set(client.ForwardedByHeader, forwardedBy)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder.Code
+	}
+
+	t.Equal(http.StatusLoopDetected, post("member02", "member02,member01"))
+	t.Equal(http.StatusOK, post("member01", "member02,member01"))
+
+	// Each forwarding member is appended to the chain.
+	req := httptest.NewRequest("POST", "/1.0/hooks", nil)
+	t.NoError(client.SetForwardedBy(req, client.ForwardedBy(req), "member01"))
+	t.NoError(client.SetForwardedBy(req, client.ForwardedBy(req), "member02"))
+	t.Equal([]string{"member01", "member02"}, client.ForwardedBy(req))
+	t.Error(client.SetForwardedBy(req, client.ForwardedBy(req), "member01"))
+}
+
+// Ensures the access log records the status written by the handler without altering the response.
+func (t *restSuite) Test_accessLog() {
+	s := &state.State{
+		Context: context.Background(),
+		Remotes: func() *trust.Remotes { return &trust.Remotes{} },
+	}
+
+	var writer *accessLogWriter
+	handler := AccessLog(s, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer = w.(*accessLogWriter)
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/1.0", nil))
+	t.Equal(http.StatusTeapot, recorder.Code)
+	t.Equal("body", recorder.Body.String())
+	t.Require().NotNil(writer)
+	t.Equal(http.StatusTeapot, writer.status)
+
+	// Hijacking fails cleanly if the underlying writer doesn't support it.
+	_, _, err := writer.Hijack()
+	t.Error(err)
+	t.False(writer.hijacked)
+
+	// Requests to excluded paths are served without being logged.
+	var logged bool
+	handler = AccessLog(s, DefaultAccessLogExcludedPaths, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, logged = w.(*accessLogWriter)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/cluster/internal/heartbeat", nil))
+	t.Equal(http.StatusOK, recorder.Code)
+	t.False(logged)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/cluster/1.0/cluster", nil))
+	t.True(logged)
+}
+
+// Ensures large JSON responses are compressed for clients that accept gzip, and small ones are sent as is.
+func (t *restSuite) Test_compress() {
+	var items []string
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := response.SyncResponse(true, items).Render(w)
+		t.NoError(err)
+	}))
+
+	get := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/1.0", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	for i := 0; i < 200; i++ {
+		items = append(items, fmt.Sprintf("item-%03d", i))
+	}
+
+	recorder := get("gzip, deflate")
+	t.Equal(http.StatusOK, recorder.Code)
+	t.Equal("gzip", recorder.Header().Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(recorder.Body)
+	t.Require().NoError(err)
+	body, err := io.ReadAll(reader)
+	t.Require().NoError(err)
+
+	var resp struct {
+		Metadata []string `json:"metadata"`
+	}
+
+	t.Require().NoError(json.Unmarshal(body, &resp))
+	t.Equal(items, resp.Metadata)
+
+	// Clients that don't accept gzip get the plain response.
+	recorder = get("")
+	t.Empty(recorder.Header().Get("Content-Encoding"))
+	t.True(json.Valid(recorder.Body.Bytes()))
+
+	// Small responses aren't worth compressing.
+	items = items[:1]
+	recorder = get("gzip")
+	t.Empty(recorder.Header().Get("Content-Encoding"))
+	t.True(json.Valid(recorder.Body.Bytes()))
+}
+
+// Ensures responses are re-encoded in a registered format when the client asks for it, and fall back to JSON.
+func (t *restSuite) Test_encode() {
+	var resp response.Response
+	handler := Encode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.NoError(resp.Render(w))
+	}))
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/1.0", nil)
+		req.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	resp = response.SyncResponse(true, map[string]any{"name": "member01", "port": 9000})
+	recorder := get("application/yaml")
+	t.Equal(http.StatusOK, recorder.Code)
+	t.Equal("application/yaml", recorder.Header().Get("Content-Type"))
+
+	var envelope map[string]any
+	t.Require().NoError(yaml.Unmarshal(recorder.Body.Bytes(), &envelope))
+	t.Equal("sync", envelope["type"])
+	t.Equal(http.StatusOK, envelope["status_code"])
+	t.Equal(map[any]any{"name": "member01", "port": 9000}, envelope["metadata"])
+
+	// Errors keep their status code and envelope.
+	resp = response.NotFound(fmt.Errorf("Not here"))
+	recorder = get("application/xml;q=1, application/yaml;q=0.5")
+	t.Equal(http.StatusNotFound, recorder.Code)
+	t.Require().NoError(yaml.Unmarshal(recorder.Body.Bytes(), &envelope))
+	t.Equal("Not here", envelope["error"])
+	t.Equal(http.StatusNotFound, envelope["error_code"])
+
+	// Unknown and JSON formats get JSON.
+	for _, accept := range []string{"application/xml", "application/json, application/yaml;q=0.5", "*/*"} {
+		recorder = get(accept)
+		t.True(json.Valid(recorder.Body.Bytes()), accept)
+	}
+}
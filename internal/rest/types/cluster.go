@@ -17,6 +17,17 @@ type ClusterMember struct {
 	Status                MemberStatus          `json:"status" yaml:"status"`
 	Extensions            extensions.Extensions `json:"extensions" yaml:"extensions"`
 	Secret                string                `json:"secret" yaml:"secret"`
+	Leader                bool                  `json:"leader" yaml:"leader"`
+	Config                map[string]string     `json:"config,omitempty" yaml:"config,omitempty"`
+
+	// Observer requests that the joining cluster member is kept as a dqlite spare and never promoted.
+	Observer bool `json:"observer,omitempty" yaml:"observer,omitempty"`
+
+	// Drain is the drain state of the cluster member, if it is being drained before its removal.
+	Drain MemberDrain `json:"drain,omitempty" yaml:"drain,omitempty"`
+
+	// Maintenance is set while the cluster member is in maintenance mode.
+	Maintenance bool `json:"maintenance,omitempty" yaml:"maintenance,omitempty"`
 }
 
 // ClusterMemberLocal represents local information about a new cluster member.
@@ -24,6 +35,65 @@ type ClusterMemberLocal struct {
 	Name        string                `json:"name" yaml:"name"`
 	Address     types.AddrPort        `json:"address" yaml:"address"`
 	Certificate types.X509Certificate `json:"certificate" yaml:"certificate"`
+
+	// ClientCertificate is the certificate the member presents when querying other cluster members.
+	// It is unset if the member uses its server certificate for this.
+	ClientCertificate *types.X509Certificate `json:"client_certificate,omitempty" yaml:"client_certificate,omitempty"`
+}
+
+// ClusterMemberPatch represents a partial update of a cluster member. Only fields that are set are applied.
+// Roles, schema versions and API extensions are managed by the cluster and can't be changed this way. Neither can the
+// address, which also has to be changed in dqlite and on the member itself through ClusterMemberAddress.
+type ClusterMemberPatch struct {
+	Certificate *types.X509Certificate `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+}
+
+// ClusterMemberAddress represents a request to change the address of a cluster member.
+type ClusterMemberAddress struct {
+	Address types.AddrPort `json:"address" yaml:"address"`
+}
+
+// ClusterMemberName represents a request to rename a cluster member.
+type ClusterMemberName struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Certificate replaces the server certificate of the renamed cluster member in the truststore, if set.
+	Certificate *types.X509Certificate `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+}
+
+// ClusterLeader represents the cluster member that is currently the dqlite leader.
+type ClusterLeader struct {
+	Name    string         `json:"name" yaml:"name"`
+	Address types.AddrPort `json:"address" yaml:"address"`
+}
+
+// ClusterMembersRemoval represents a request to remove several cluster members at once.
+type ClusterMembersRemoval struct {
+	Names []string `json:"names" yaml:"names"`
+}
+
+// ClusterMembersFilter selects a page of cluster members, ordered by name.
+type ClusterMembersFilter struct {
+	// Limit is the maximum number of cluster members to return. All remaining cluster members are returned if 0.
+	Limit int `json:"limit" yaml:"limit"`
+
+	// Offset is the number of matching cluster members to skip.
+	Offset int `json:"offset" yaml:"offset"`
+
+	// Role only matches cluster members with the given role, if set.
+	Role string `json:"role" yaml:"role"`
+
+	// Status only matches cluster members with the given status, if set.
+	Status MemberStatus `json:"status" yaml:"status"`
+}
+
+// ClusterMembersPage is a page of cluster members.
+type ClusterMembersPage struct {
+	// Members on this page, ordered by name.
+	Members []ClusterMember `json:"members" yaml:"members"`
+
+	// Total is the number of cluster members matching the filter across all pages.
+	Total int `json:"total" yaml:"total"`
 }
 
 // MemberStatus represents the online status of a cluster member.
@@ -41,4 +111,50 @@ const (
 
 	// MemberNotFound should be the MemberStatus when the node was not found in dqlite.
 	MemberNotFound MemberStatus = "NOT FOUND"
+
+	// MemberRemoved should be the MemberStatus when the node has been soft-deleted and can still be restored.
+	MemberRemoved MemberStatus = "REMOVED"
+)
+
+// MemberDrain represents the progress of draining a cluster member before its removal.
+type MemberDrain string
+
+const (
+	// MemberDraining should be the MemberDrain while the OnDrain hook of the member has not completed.
+	MemberDraining MemberDrain = "DRAINING"
+
+	// MemberDrained should be the MemberDrain once the OnDrain hook of the member has completed, and the member can
+	// be removed.
+	MemberDrained MemberDrain = "DRAINED"
 )
+
+// ClusterMemberRemoval represents a request to soft-delete a cluster member.
+type ClusterMemberRemoval struct {
+	// GracePeriod is how long the member can still be restored before its removal is finalized.
+	GracePeriod time.Duration `json:"grace_period" yaml:"grace_period"`
+}
+
+// TrustBundle is a signed export of the entries in a cluster member's truststore.
+type TrustBundle struct {
+	Members   []ClusterMemberLocal `json:"members" yaml:"members"`
+	Signature string               `json:"signature" yaml:"signature"`
+}
+
+// ClusterStatus summarizes the health of the cluster.
+type ClusterStatus struct {
+	// Leader is the name of the dqlite leader, or empty if it could not be determined.
+	Leader string `json:"leader" yaml:"leader"`
+
+	// HasQuorum is true if more than half of the dqlite voters are online.
+	HasQuorum    bool `json:"has_quorum" yaml:"has_quorum"`
+	Voters       int  `json:"voters" yaml:"voters"`
+	OnlineVoters int  `json:"online_voters" yaml:"online_voters"`
+
+	// Behind lists the members whose schema or API extensions are older than those of the most up to date member.
+	Behind []string `json:"behind" yaml:"behind"`
+
+	// Offline lists the members that could not be reached, or are not trusted.
+	Offline []string `json:"offline" yaml:"offline"`
+
+	Members []ClusterMember `json:"members" yaml:"members"`
+}
@@ -11,4 +11,10 @@ type Control struct {
 	JoinToken  string            `json:"join_token" yaml:"join_token"`
 	Address    types.AddrPort    `json:"address" yaml:"address"`
 	Name       string            `json:"name" yaml:"name"`
+	Observer   bool              `json:"observer" yaml:"observer"`
+}
+
+// PreInitAddress represents a request to move the network listener that serves the API before initialization.
+type PreInitAddress struct {
+	Address types.AddrPort `json:"address" yaml:"address"`
 }
@@ -0,0 +1,26 @@
+package types
+
+// DatabaseDiagnostics is a best-effort snapshot of the dqlite cluster, as seen by its leader.
+type DatabaseDiagnostics struct {
+	Leader string                    `json:"leader" yaml:"leader"`
+	Nodes  []DatabaseNodeDiagnostics `json:"nodes" yaml:"nodes"`
+}
+
+// DatabaseNodeDiagnostics is the state of a single dqlite node. FailureDomain and Weight are reported by the node
+// itself, and are left unset along with an Error if it can't be reached.
+type DatabaseNodeDiagnostics struct {
+	ID            uint64 `json:"id" yaml:"id"`
+	Name          string `json:"name" yaml:"name"`
+	Address       string `json:"address" yaml:"address"`
+	Role          string `json:"role" yaml:"role"`
+	Leader        bool   `json:"leader" yaml:"leader"`
+	FailureDomain uint64 `json:"failure_domain" yaml:"failure_domain"`
+	Weight        uint64 `json:"weight" yaml:"weight"`
+	Error         string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// DatabaseLogPosition is the term and index of the last entry in the raft log of a dqlite node.
+type DatabaseLogPosition struct {
+	Term  uint64 `json:"term" yaml:"term"`
+	Index uint64 `json:"index" yaml:"index"`
+}
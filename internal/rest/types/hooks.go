@@ -38,6 +38,9 @@ const (
 type HookRemoveMemberOptions struct {
 	// Force represents whether to run the hook with the `force` option.
 	Force bool `json:"force" yaml:"force"`
+
+	// Name is the name of the cluster member being removed.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 }
 
 // HookNewMemberOptions holds configuration pertaining to the OnNewMember hook.
@@ -0,0 +1,43 @@
+package types
+
+// OpenAPI is an OpenAPI 3.1 document describing the paths and methods of the REST API of the daemon.
+// Request and response bodies are not described.
+type OpenAPI struct {
+	OpenAPI    string                                 `json:"openapi"    yaml:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"       yaml:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"      yaml:"paths"`
+	Components OpenAPIComponents                      `json:"components" yaml:"components"`
+}
+
+// OpenAPIInfo holds the title and version of the API.
+type OpenAPIInfo struct {
+	Title   string `json:"title"   yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// OpenAPIOperation describes a method of a path. An empty Security list means the operation requires no
+// authentication.
+type OpenAPIOperation struct {
+	Tags       []string                   `json:"tags,omitempty"       yaml:"tags,omitempty"`
+	Parameters []OpenAPIParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Security   []map[string][]string      `json:"security"             yaml:"security"`
+	Responses  map[string]OpenAPIResponse `json:"responses"            yaml:"responses"`
+}
+
+// OpenAPIParameter describes a path parameter.
+type OpenAPIParameter struct {
+	Name     string            `json:"name"     yaml:"name"`
+	In       string            `json:"in"       yaml:"in"`
+	Required bool              `json:"required" yaml:"required"`
+	Schema   map[string]string `json:"schema"   yaml:"schema"`
+}
+
+// OpenAPIResponse describes a response of an operation.
+type OpenAPIResponse struct {
+	Description string `json:"description" yaml:"description"`
+}
+
+// OpenAPIComponents holds the security schemes referred to by operations.
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]map[string]string `json:"securitySchemes" yaml:"securitySchemes"`
+}
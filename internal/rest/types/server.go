@@ -1,14 +1,44 @@
 package types
 
 import (
+	"time"
+
+	"github.com/canonical/microcluster/internal/extensions"
 	"github.com/canonical/microcluster/rest/types"
 )
 
 // Server represents server status information.
 type Server struct {
-	Name    string         `json:"name"    yaml:"name"`
-	Address types.AddrPort `json:"address" yaml:"address"`
-	Ready   bool           `json:"ready"   yaml:"ready"`
+	Name       string                `json:"name"       yaml:"name"`
+	Address    types.AddrPort        `json:"address"    yaml:"address"`
+	Ready      bool                  `json:"ready"      yaml:"ready"`
+	Version    string                `json:"version"    yaml:"version"`
+	Extensions extensions.Extensions `json:"extensions" yaml:"extensions"`
+
+	// CertificateExpiry holds the expiry dates of the certificates of the daemon, keyed by certificate name.
+	CertificateExpiry map[string]time.Time `json:"certificate_expiry,omitempty" yaml:"certificate_expiry,omitempty"`
+}
+
+// Connections represents the connections open to the daemon's listeners.
+type Connections struct {
+	// Active is the number of open connections, including the one used to make this request.
+	Active int64 `json:"active" yaml:"active"`
+}
+
+// ListenerInfo represents a listener of the daemon.
+type ListenerInfo struct {
+	// Type is the type of the listener: "control socket", "https socket" or "health socket".
+	Type string `json:"type" yaml:"type"`
+
+	// Address is the address the listener is bound to, or the path of a unix socket.
+	Address string `json:"address" yaml:"address"`
+
+	// Fingerprint is the fingerprint of the certificate served by an https listener.
+	Fingerprint string `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+
+	// DedicatedCertificate is set on https listeners of extension servers that serve their own certificate rather
+	// than the cluster certificate.
+	DedicatedCertificate bool `json:"dedicated_certificate" yaml:"dedicated_certificate"`
 }
 
 const (
@@ -21,3 +51,21 @@ const (
 	// ControlEndpoint - all endpoints available on the local unix socket.
 	ControlEndpoint types.EndpointPrefix = "cluster/control"
 )
+
+// ActiveRequest represents an API request that the daemon is still serving.
+type ActiveRequest struct {
+	// ID identifies the request on this cluster member, and is used to cancel it.
+	ID string `json:"id" yaml:"id"`
+
+	// RequestID correlates the request with the requests it caused on other cluster members.
+	RequestID string `json:"request_id" yaml:"request_id"`
+
+	Method string `json:"method" yaml:"method"`
+	Path   string `json:"path"   yaml:"path"`
+
+	// Peer is the name of the cluster member that made the request, or empty for other clients.
+	Peer string `json:"peer" yaml:"peer"`
+
+	Started time.Time     `json:"started" yaml:"started"`
+	Age     time.Duration `json:"age"     yaml:"age"`
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/base64"
 	"encoding/json"
+	"time"
 
 	"github.com/canonical/microcluster/rest/types"
 )
@@ -11,6 +12,23 @@ import (
 type TokenRecord struct {
 	Name  string `json:"name" yaml:"name"`
 	Token string `json:"token" yaml:"token"`
+
+	// ExpiryDate is when the token expires, in UTC. The token never expires if it is the zero value.
+	ExpiryDate time.Time `json:"expiry_date" yaml:"expiry_date"`
+
+	// ServerTime is the current time, in UTC, of the cluster member that listed the token.
+	// Comparing it with the local time shows how far the clocks are skewed. Clients reusing a cached response after a
+	// 304 Not Modified response get the server time of the cached response.
+	ServerTime time.Time `json:"server_time" yaml:"server_time"`
+}
+
+// TokenRequest holds the information for requesting one of several join tokens at once.
+type TokenRequest struct {
+	// Name of the member that will join with the token.
+	Name string `json:"name" yaml:"name"`
+
+	// ExpireAfter is how long the token is valid for. The token never expires if it is zero.
+	ExpireAfter time.Duration `json:"expire_after" yaml:"expire_after"`
 }
 
 // TokenResponse holds the information for connecting to a cluster by a node with a valid join token.
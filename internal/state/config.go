@@ -0,0 +1,90 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// ConfigGet returns the value of the given key in the cluster-wide configuration store.
+func (s *State) ConfigGet(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		config, err := cluster.GetCoreConfig(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		value = config.Value
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// ConfigSet sets the given key to the given value in the cluster-wide configuration store.
+func (s *State) ConfigSet(ctx context.Context, key string, value string) error {
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		config := cluster.CoreConfig{Key: key, Value: value}
+		err := cluster.UpdateCoreConfig(ctx, tx, key, config)
+		if err == nil || !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		_, err = cluster.CreateCoreConfig(ctx, tx, config)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.PublishEvent(types.EventConfigUpdated, map[string]string{"key": key})
+
+	return nil
+}
+
+// ConfigDelete removes the given key from the cluster-wide configuration store.
+func (s *State) ConfigDelete(ctx context.Context, key string) error {
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.DeleteCoreConfig(ctx, tx, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.PublishEvent(types.EventConfigUpdated, map[string]string{"key": key})
+
+	return nil
+}
+
+// ConfigAll returns all keys and values in the cluster-wide configuration store.
+func (s *State) ConfigAll(ctx context.Context) (map[string]string, error) {
+	values := map[string]string{}
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		configs, err := cluster.GetCoreConfigs(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, config := range configs {
+			values[config.Key] = config.Value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
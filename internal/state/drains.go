@@ -0,0 +1,71 @@
+package state
+
+import (
+	"context"
+	"sync"
+)
+
+// DrainTracker keeps track of the drain of cluster members as seen by this cluster member: whether its own OnDrain
+// hook is running, and whether each cluster member was draining in the last heartbeat it received.
+type DrainTracker struct {
+	mu       sync.Mutex
+	run      uint64             // Incremented for each run of the OnDrain hook.
+	cancel   context.CancelFunc // Cancels the OnDrain hook while it runs.
+	draining map[string]bool
+}
+
+// NewDrainTracker returns a drain tracker with no OnDrain hook running, and no drains seen yet.
+func NewDrainTracker() *DrainTracker {
+	return &DrainTracker{}
+}
+
+// StartHook returns the context of a new run of the OnDrain hook, derived from ctx, and a function that must be called
+// once the run returns. It returns false if the hook is already running.
+func (t *DrainTracker) StartHook(ctx context.Context) (context.Context, func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.run++
+	run := t.run
+	t.cancel = cancel
+
+	done := func() {
+		t.mu.Lock()
+		if t.run == run {
+			t.cancel = nil
+		}
+
+		t.mu.Unlock()
+		cancel()
+	}
+
+	return ctx, done, true
+}
+
+// CancelHook cancels the OnDrain hook if it is running.
+func (t *DrainTracker) CancelHook() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+// SwapDraining records whether each cluster member is draining, keyed by cluster member name, and returns what was
+// recorded before.
+func (t *DrainTracker) SwapDraining(draining map[string]bool) map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous := t.draining
+	t.draining = draining
+
+	return previous
+}
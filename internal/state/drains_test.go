@@ -0,0 +1,58 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type drainsSuite struct {
+	suite.Suite
+}
+
+func TestDrainsSuite(t *testing.T) {
+	suite.Run(t, new(drainsSuite))
+}
+
+// Ensures only one run of the OnDrain hook is tracked at a time, and that a cancelled run doesn't clear the next one.
+func (t *drainsSuite) Test_drainTrackerHook() {
+	tracker := NewDrainTracker()
+
+	first, doneFirst, ok := tracker.StartHook(context.Background())
+	t.Require().True(ok)
+
+	_, _, ok = tracker.StartHook(context.Background())
+	t.False(ok)
+
+	tracker.CancelHook()
+	t.Error(first.Err())
+
+	second, doneSecond, ok := tracker.StartHook(context.Background())
+	t.Require().True(ok)
+
+	// The cancelled run returning doesn't clear the run that replaced it.
+	doneFirst()
+	t.NoError(second.Err())
+	_, _, ok = tracker.StartHook(context.Background())
+	t.False(ok)
+
+	doneSecond()
+	t.Error(second.Err())
+	_, doneThird, ok := tracker.StartHook(context.Background())
+	t.True(ok)
+	doneThird()
+
+	// Trackers don't share state.
+	_, doneOther, ok := NewDrainTracker().StartHook(context.Background())
+	t.True(ok)
+	doneOther()
+}
+
+// Ensures the drain states seen last are returned when new ones are recorded.
+func (t *drainsSuite) Test_drainTrackerSwapDraining() {
+	tracker := NewDrainTracker()
+	t.Nil(tracker.SwapDraining(map[string]bool{"member01": true}))
+	t.Equal(map[string]bool{"member01": true}, tracker.SwapDraining(map[string]bool{"member01": false}))
+	t.Equal(map[string]bool{"member01": false}, tracker.SwapDraining(nil))
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/logger"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// eventSubscriberBuffer is the number of events buffered for each subscriber before it is disconnected.
+const eventSubscriberBuffer = 64
+
+// EventBus fans out cluster lifecycle events to their subscribers.
+// Subscribers that are not keeping up are disconnected, so that they never hold up the publisher.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan types.Event]map[types.EventType]bool
+
+	leaderMu sync.Mutex
+	leader   string // Address of the dqlite leader last seen by this cluster member.
+}
+
+// NewEventBus returns an event bus with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[chan types.Event]map[types.EventType]bool{}}
+}
+
+// Subscribe returns a channel receiving the published events of the given types, or of all types if none are given,
+// and a function to unsubscribe. The channel is closed once the subscriber is unsubscribed, or disconnected for not
+// keeping up.
+func (b *EventBus) Subscribe(eventTypes ...types.EventType) (<-chan types.Event, func()) {
+	filter := make(map[types.EventType]bool, len(eventTypes))
+	for _, eventType := range eventTypes {
+		filter[eventType] = true
+	}
+
+	ch := make(chan types.Event, eventSubscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = filter
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.remove(ch)
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends the event to each subscriber of its type.
+func (b *EventBus) Publish(event types.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, filter := range b.subscribers {
+		if len(filter) > 0 && !filter[event.Type] {
+			continue
+		}
+
+		select {
+		case ch <- event:
+		default:
+			logger.Warn("Disconnecting slow event subscriber", logger.Ctx{"type": event.Type})
+			b.remove(ch)
+		}
+	}
+}
+
+// remove closes the channel of the subscriber and stops sending it events. It must be called with the lock held.
+func (b *EventBus) remove(ch chan types.Event) {
+	_, ok := b.subscribers[ch]
+	if !ok {
+		return
+	}
+
+	delete(b.subscribers, ch)
+	close(ch)
+}
+
+// PublishEvent publishes a cluster lifecycle event from this cluster member.
+func (s *State) PublishEvent(eventType types.EventType, metadata map[string]string) {
+	if s.Events == nil {
+		return
+	}
+
+	location := ""
+	if s.Name != nil {
+		location = s.Name()
+	}
+
+	s.Events.Publish(types.Event{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Location:  location,
+		Metadata:  metadata,
+	})
+}
+
+// ObserveLeader records the address of the dqlite leader seen by this cluster member, and publishes an
+// EventLeaderChanged event if it differs from the one it last saw.
+func (s *State) ObserveLeader(address string) {
+	if s.Events == nil {
+		return
+	}
+
+	s.Events.leaderMu.Lock()
+	previous := s.Events.leader
+	s.Events.leader = address
+	s.Events.leaderMu.Unlock()
+
+	if previous != "" && previous != address {
+		s.PublishEvent(types.EventLeaderChanged, map[string]string{"address": address, "previous": previous})
+	}
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type eventsSuite struct {
+	suite.Suite
+}
+
+func TestEventsSuite(t *testing.T) {
+	suite.Run(t, new(eventsSuite))
+}
+
+// Ensures subscribers only receive events of their types, and are disconnected once they fall behind.
+func (t *eventsSuite) Test_eventBus() {
+	bus := NewEventBus()
+
+	all, unsubscribeAll := bus.Subscribe()
+	joined, unsubscribeJoined := bus.Subscribe(types.EventMemberJoined)
+	defer unsubscribeJoined()
+
+	bus.Publish(types.Event{Type: types.EventConfigUpdated})
+	bus.Publish(types.Event{Type: types.EventMemberJoined})
+
+	t.Equal(types.EventConfigUpdated, (<-all).Type)
+	t.Equal(types.EventMemberJoined, (<-all).Type)
+	t.Equal(types.EventMemberJoined, (<-joined).Type)
+	t.Len(joined, 0)
+
+	// Unsubscribing closes the channel, and unsubscribing again is a no-op.
+	unsubscribeAll()
+	unsubscribeAll()
+	_, ok := <-all
+	t.False(ok)
+
+	// A subscriber that doesn't drain its channel is disconnected once the buffer is full.
+	for i := 0; i <= eventSubscriberBuffer; i++ {
+		bus.Publish(types.Event{Type: types.EventMemberJoined})
+	}
+
+	received := 0
+	for range joined {
+		received++
+	}
+
+	t.Equal(eventSubscriberBuffer, received)
+}
+
+// Ensures a leader change is published once the dqlite leader differs from the one last seen, and that the last seen
+// leader is tracked by each cluster member separately.
+func (t *eventsSuite) Test_observeLeader() {
+	s := &State{Events: NewEventBus(), Name: func() string { return "member01" }}
+	other := &State{Events: NewEventBus(), Name: func() string { return "member02" }}
+
+	events, unsubscribe := s.Events.Subscribe(types.EventLeaderChanged)
+	defer unsubscribe()
+
+	s.ObserveLeader("10.0.0.1:9000")
+	s.ObserveLeader("10.0.0.1:9000")
+	t.Len(events, 0)
+
+	other.ObserveLeader("10.0.0.2:9000")
+	s.ObserveLeader("10.0.0.2:9000")
+
+	event := <-events
+	t.Equal(map[string]string{"address": "10.0.0.2:9000", "previous": "10.0.0.1:9000"}, event.Metadata)
+	t.Equal("member01", event.Location)
+	t.Len(events, 0)
+}
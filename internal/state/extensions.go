@@ -0,0 +1,40 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/canonical/microcluster/cluster"
+)
+
+// ClusterSupportsExtension reports whether every cluster member has the given API extension, so that new behaviour can
+// be gated on support across the whole cluster. It also returns the names of the cluster members that lack it, so that
+// callers can report the progress of an upgrade.
+func (s *State) ClusterSupportsExtension(ctx context.Context, name string) (bool, []string, error) {
+	var clusterMembers []cluster.InternalClusterMember
+	err := s.Database.Transaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		clusterMembers, err = cluster.GetInternalClusterMembers(ctx, tx)
+
+		return err
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	missing := membersMissingExtension(clusterMembers, name)
+
+	return len(missing) == 0, missing, nil
+}
+
+// membersMissingExtension returns the names of the given cluster members that don't have the given API extension.
+func membersMissingExtension(clusterMembers []cluster.InternalClusterMember, name string) []string {
+	missing := []string{}
+	for _, clusterMember := range clusterMembers {
+		if !clusterMember.APIExtensions.HasExtension(name) {
+			missing = append(missing, clusterMember.Name)
+		}
+	}
+
+	return missing
+}
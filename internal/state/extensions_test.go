@@ -0,0 +1,34 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/cluster"
+	"github.com/canonical/microcluster/internal/extensions"
+)
+
+type extensionsSuite struct {
+	suite.Suite
+}
+
+func TestExtensionsSuite(t *testing.T) {
+	suite.Run(t, new(extensionsSuite))
+}
+
+// Ensures an extension is only supported once every cluster member has it, and members lacking it are reported.
+func (t *extensionsSuite) Test_membersMissingExtension() {
+	upgraded := extensions.Extensions{"internal:runtime_extension_v1", "feature_a", "feature_b"}
+	outdated := extensions.Extensions{"internal:runtime_extension_v1", "feature_a"}
+
+	clusterMembers := []cluster.InternalClusterMember{
+		{Name: "member01", APIExtensions: upgraded},
+		{Name: "member02", APIExtensions: outdated},
+		{Name: "member03", APIExtensions: upgraded},
+	}
+
+	t.Empty(membersMissingExtension(clusterMembers, "feature_a"))
+	t.Equal([]string{"member02"}, membersMissingExtension(clusterMembers, "feature_b"))
+	t.Equal([]string{"member01", "member02", "member03"}, membersMissingExtension(clusterMembers, "feature_c"))
+}
@@ -0,0 +1,22 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/canonical/microcluster/cluster"
+)
+
+// SetMaintenance puts this cluster member into maintenance mode, or takes it out of it. The mode is recorded in the
+// configuration of the cluster member under the reserved cluster.MaintenanceKey, so it survives restarts and every
+// cluster member knows about it.
+//
+// While in maintenance mode, the cluster member stays in the cluster, keeps serving requests and receiving heartbeats.
+// If it is the dqlite leader, it hands leadership over to a voter that is not in maintenance mode instead of beginning
+// heartbeat rounds. If there is no such voter, it keeps beginning heartbeat rounds itself. Once maintenance mode is
+// cleared, it takes part in heartbeat rounds and leadership elections as before.
+func (s *State) SetMaintenance(ctx context.Context, maintenance bool) error {
+	return s.Database.InternalTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return cluster.SetMemberMaintenance(ctx, tx, s.Name(), maintenance)
+	})
+}
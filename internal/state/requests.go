@@ -0,0 +1,112 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+)
+
+// trackedRequest is an API request that is still being served, and the function cancelling its context.
+type trackedRequest struct {
+	request internalTypes.ActiveRequest
+	cancel  context.CancelFunc
+}
+
+// RequestTracker keeps track of the API requests that the daemon is serving, so that they can be listed and
+// cancelled.
+type RequestTracker struct {
+	mu       sync.Mutex
+	lastID   uint64
+	requests map[string]trackedRequest
+}
+
+// NewRequestTracker returns a request tracker with no active requests.
+func NewRequestTracker() *RequestTracker {
+	return &RequestTracker{requests: map[string]trackedRequest{}}
+}
+
+// Track records the request as active until the returned function is called, which must happen once the request has
+// been served. The returned request is bound to a context that is cancelled when the request is cancelled through
+// the tracker.
+func (t *RequestTracker) Track(r *http.Request, requestID string, peer string) (*http.Request, func()) {
+	ctx, cancel := context.WithCancel(r.Context())
+
+	t.mu.Lock()
+	t.lastID++
+	id := strconv.FormatUint(t.lastID, 10)
+	t.requests[id] = trackedRequest{
+		cancel: cancel,
+		request: internalTypes.ActiveRequest{
+			ID:        id,
+			RequestID: requestID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Peer:      peer,
+			Started:   time.Now(),
+		},
+	}
+
+	t.mu.Unlock()
+
+	done := func() {
+		t.mu.Lock()
+		delete(t.requests, id)
+		t.mu.Unlock()
+
+		cancel()
+	}
+
+	return r.WithContext(ctx), done
+}
+
+// Active returns the requests that are still being served, oldest first.
+func (t *RequestTracker) Active() []internalTypes.ActiveRequest {
+	t.mu.Lock()
+	requests := make([]internalTypes.ActiveRequest, 0, len(t.requests))
+	for _, tracked := range t.requests {
+		requests = append(requests, tracked.request)
+	}
+
+	t.mu.Unlock()
+
+	now := time.Now()
+	for i := range requests {
+		requests[i].Age = now.Sub(requests[i].Started)
+	}
+
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Started.Before(requests[j].Started) })
+
+	return requests
+}
+
+// Cancel cancels the context of the active request with the given ID. The handler serving the request decides how
+// quickly it stops.
+func (t *RequestTracker) Cancel(id string) error {
+	t.mu.Lock()
+	tracked, ok := t.requests[id]
+	t.mu.Unlock()
+
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "No active request with ID %q", id)
+	}
+
+	tracked.cancel()
+
+	return nil
+}
+
+// ActiveRequests returns the API requests that the daemon is still serving, oldest first.
+func (s *State) ActiveRequests() []internalTypes.ActiveRequest {
+	if s.Requests == nil {
+		return nil
+	}
+
+	return s.Requests.Active()
+}
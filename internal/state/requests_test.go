@@ -0,0 +1,56 @@
+package state
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+	"github.com/stretchr/testify/suite"
+)
+
+type requestsSuite struct {
+	suite.Suite
+}
+
+func TestRequestsSuite(t *testing.T) {
+	suite.Run(t, new(requestsSuite))
+}
+
+// Ensures requests are listed while they are served, removed once completed, and can be cancelled.
+func (t *requestsSuite) Test_requestTracker() {
+	tracker := NewRequestTracker()
+	s := &State{Requests: tracker}
+
+	first, doneFirst := tracker.Track(httptest.NewRequest("GET", "/cluster/1.0/cluster", nil), "request-1", "member01")
+	second, doneSecond := tracker.Track(httptest.NewRequest("POST", "/cluster/1.0/tokens", nil), "request-2", "")
+
+	active := s.ActiveRequests()
+	t.Require().Len(active, 2)
+	t.Equal("GET", active[0].Method)
+	t.Equal("/cluster/1.0/cluster", active[0].Path)
+	t.Equal("member01", active[0].Peer)
+	t.Equal("request-1", active[0].RequestID)
+	t.Equal("POST", active[1].Method)
+
+	// A completed request is removed from the set, and cancels its context.
+	doneFirst()
+	active = s.ActiveRequests()
+	t.Require().Len(active, 1)
+	t.Equal("request-2", active[0].RequestID)
+	t.Error(first.Context().Err())
+
+	// Cancelling a request cancels its context but keeps it listed until its handler returns.
+	t.NoError(tracker.Cancel(active[0].ID))
+	t.Error(second.Context().Err())
+	t.Len(s.ActiveRequests(), 1)
+
+	doneSecond()
+	t.Empty(s.ActiveRequests())
+
+	err := tracker.Cancel(active[0].ID)
+	t.True(api.StatusErrorCheck(err, http.StatusNotFound))
+
+	// A state without a tracker has no active requests.
+	t.Empty((&State{}).ActiveRequests())
+}
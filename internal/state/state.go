@@ -2,8 +2,10 @@ package state
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	dqliteClient "github.com/canonical/go-dqlite/client"
 	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
 
@@ -12,8 +14,10 @@ import (
 	"github.com/canonical/microcluster/internal/endpoints"
 	"github.com/canonical/microcluster/internal/extensions"
 	internalClient "github.com/canonical/microcluster/internal/rest/client"
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
 	"github.com/canonical/microcluster/internal/sys"
 	"github.com/canonical/microcluster/internal/trust"
+	"github.com/canonical/microcluster/rest/types"
 )
 
 // State is a gateway to the stateful components of the microcluster daemon.
@@ -42,9 +46,16 @@ type State struct {
 	// Server certificate is used for server-to-server connection.
 	ServerCert func() *shared.CertInfo
 
+	// Client certificate is presented when querying the API of other cluster members.
+	// It is the server certificate unless a distinct client certificate is configured.
+	ClientCert func() *shared.CertInfo
+
 	// Cluster certificate is used for downstream connections within a cluster.
 	ClusterCert func() *shared.CertInfo
 
+	// TrustedClusterCerts are trusted alongside the cluster certificate while it is rotated.
+	TrustedClusterCerts *internalClient.TrustedCerts
+
 	// Database.
 	Database *db.DB
 
@@ -52,13 +63,49 @@ type State struct {
 	Remotes func() *trust.Remotes
 
 	// Initialize APIs and bootstrap/join database.
-	StartAPI func(bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error
+	StartAPI func(ctx context.Context, bootstrap bool, initConfig map[string]string, newConfig *trust.Location, joinAddresses ...string) error
+
+	// WatchFile runs the callback whenever the file at the given path in the state directory changes.
+	WatchFile func(path string, cb func()) (cancel func(), err error)
+
+	// Events publishes cluster lifecycle events to subscribers of the events endpoint.
+	Events *EventBus
+
+	// Requests tracks the API requests that the daemon is serving.
+	Requests *RequestTracker
+
+	// Drains tracks the OnDrain hook of this cluster member, and the drains of cluster members seen in heartbeats.
+	Drains *DrainTracker
 
 	// Stop fully stops the daemon, its database, and all listeners.
 	Stop func() (exit func(), stopErr error)
 
 	// Runtime extensions.
 	Extensions extensions.Extensions
+
+	// RegisterExtensions adds API extensions to the running daemon without a restart, and keeps them registered
+	// across restarts. Extensions can only be added, and it fails if any of them is already registered or invalid.
+	RegisterExtensions func(ctx context.Context, extensions []string) error
+
+	// Version of the consumer of microcluster.
+	Version string
+
+	// TokenExpirySkew is how long past their expiry date join tokens are still accepted, to tolerate clock skew
+	// between cluster members.
+	TokenExpirySkew time.Duration
+
+	// CertificateExpiry returns the expiry dates of the server certificate, the cluster certificate once the daemon
+	// is initialized, and the dedicated certificates of extension servers, keyed by "server", "cluster" and
+	// "extension:" followed by the interface and address of the extension server.
+	CertificateExpiry func() map[string]time.Time
+
+	// OpenAPI returns an OpenAPI document describing the paths and methods of the REST API, including the resources
+	// of extension servers.
+	OpenAPI func() *internalTypes.OpenAPI
+
+	// MinVoters is the minimum number of dqlite voters that must remain after removing cluster members, unless the
+	// removal is forced.
+	MinVoters int
 }
 
 // StopListeners stops the network listeners and the fsnotify listener.
@@ -73,12 +120,52 @@ var PreRemoveHook func(state *State, force bool) error
 // OnHeartbeatHook is a post-action hook that is run on the leader after a successful heartbeat round.
 var OnHeartbeatHook func(state *State) error
 
+// OnHeartbeatPayloadsHook is a post-action hook that is run on the leader after a successful heartbeat round, with the
+// heartbeat payloads of each cluster member.
+var OnHeartbeatPayloadsHook func(state *State, payloads map[string]map[string]string) error
+
+// HeartbeatPayloadHook returns the consumer payload that this cluster member reports to the leader during a heartbeat.
+var HeartbeatPayloadHook func(state *State) map[string]string
+
 // OnNewMemberHook is a post-action hook that is run on all cluster members when a new cluster member joins the cluster.
 var OnNewMemberHook func(state *State) error
 
+// OnAutoUpdateHook is run when this cluster member's schema version is behind the rest of the cluster.
+// If unset, the executable specified by the SCHEMA_UPDATE variable is run instead.
+var OnAutoUpdateHook func(state *State) error
+
+// OnDrainHook is run on a cluster member after it starts draining before its removal.
+var OnDrainHook func(ctx context.Context, state *State) error
+
+// OnDrainChangeHook is run on each cluster member when a heartbeat shows that a cluster member started draining, or
+// that its drain was cancelled.
+var OnDrainChangeHook func(state *State, name string, draining bool) error
+
 // ReloadClusterCert reloads the cluster keypair from the state directory.
 var ReloadClusterCert func() error
 
+// ReloadServerCert reloads the server keypair from the state directory.
+var ReloadServerCert func() error
+
+// NewServerCert returns a new PEM encoded server certificate and key named after the given cluster member name, as
+// customized by the CertificateCustomizer of the daemon.
+var NewServerCert func(name string) (certPEM []byte, keyPEM []byte, err error)
+
+// RefreshTrustStore reloads the truststore from the state directory.
+var RefreshTrustStore func() error
+
+// ValidateDaemonConfigHook is run before a new daemon configuration is applied, and can reject it.
+var ValidateDaemonConfigHook func(ctx context.Context, s *State, current trust.Location, new trust.Location) error
+
+// SetPreInitAddress moves the network listener serving the API before initialization to a new address.
+var SetPreInitAddress func(address types.AddrPort) error
+
+// UpdateDaemonAddress records a new address for this cluster member in the daemon configuration.
+var UpdateDaemonAddress func(address types.AddrPort) error
+
+// UpdateDaemonName records a new name for this cluster member in the daemon configuration.
+var UpdateDaemonName func(name string) error
+
 // Cluster returns a client for every member of a cluster, except
 // this one.
 // All requests made by the client will have the UserAgentNotifier header set
@@ -105,8 +192,7 @@ func (s *State) Cluster(isNotification bool) (client.Cluster, error) {
 			return nil, err
 		}
 
-		url := api.NewURL().Scheme("https").Host(clusterMember.Address.String())
-		c, err := internalClient.New(*url, s.ServerCert(), publicKey, isNotification)
+		c, err := s.Remotes().Client(clusterMember.Address, isNotification, s.ClientCert(), publicKey)
 		if err != nil {
 			return nil, err
 		}
@@ -117,6 +203,70 @@ func (s *State) Cluster(isNotification bool) (client.Cluster, error) {
 	return clients, nil
 }
 
+// DatabaseNodeInfo returns the dqlite ID, address, and role of this cluster member's dqlite node.
+func (s *State) DatabaseNodeInfo(ctx context.Context) (*dqliteClient.NodeInfo, error) {
+	return s.Database.NodeInfo(ctx)
+}
+
+// RefreshTrust synchronously reloads the truststore from the state directory, without waiting for the file watcher
+// to pick up changes made to it.
+func (s *State) RefreshTrust(ctx context.Context) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if RefreshTrustStore == nil {
+		return fmt.Errorf("Truststore is not yet initialized")
+	}
+
+	return RefreshTrustStore()
+}
+
+// ActiveConnections returns the number of connections still open across all of the daemon's listeners.
+// During shutdown this reports whether the daemon has finished draining its connections.
+func (s *State) ActiveConnections() int64 {
+	if s.Endpoints == nil {
+		return 0
+	}
+
+	return s.Endpoints.ActiveConnections()
+}
+
+// Listeners returns the listeners of the daemon that are currently up. Each https listener is reported with the
+// fingerprint of the certificate it serves, and whether that is a dedicated certificate or the cluster certificate.
+func (s *State) Listeners() []internalTypes.ListenerInfo {
+	if s.Endpoints == nil {
+		return nil
+	}
+
+	listeners := []internalTypes.ListenerInfo{}
+	for _, endpoint := range s.Endpoints.List() {
+		listener := internalTypes.ListenerInfo{Type: endpoint.Type().String()}
+		switch e := endpoint.(type) {
+		case *endpoints.Network:
+			listener.Address = e.Address()
+			listener.DedicatedCertificate = e.DedicatedCert
+
+			cert := e.TLS()
+			if cert != nil {
+				listener.Fingerprint = cert.Fingerprint()
+			}
+
+		case *endpoints.Socket:
+			listener.Address = e.Path
+		case *endpoints.Health:
+			listener.Address = e.Address()
+		case *endpoints.DebugHTTP:
+			listener.Address = e.Address()
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners
+}
+
 // Leader returns a client connected to the dqlite leader.
 func (s *State) Leader() (*client.Client, error) {
 	ctx, cancel := context.WithTimeout(s.Context, time.Second*30)
@@ -138,7 +288,7 @@ func (s *State) Leader() (*client.Client, error) {
 	}
 
 	url := api.NewURL().Scheme("https").Host(leaderInfo.Address)
-	c, err := internalClient.New(*url, s.ServerCert(), publicKey, false)
+	c, err := internalClient.New(*url, s.ClientCert(), publicKey, false, s.TrustedClusterCerts.Certs()...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,113 @@
+package sys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+// certificateValidity is how long generated certificates are valid for.
+const certificateValidity = 10 * 365 * 24 * time.Hour
+
+// GenerateCert writes a new keypair with the given prefix to dir, unless a certificate with that prefix already
+// exists. The certificate is named after the hostname, and customize can change its subject and names before it is
+// generated.
+func GenerateCert(dir string, prefix string, customize func(*types.CertificateOptions)) error {
+	certFile := filepath.Join(dir, prefix+".crt")
+	keyFile := filepath.Join(dir, prefix+".key")
+	if shared.PathExists(certFile) {
+		return nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("Failed to get hostname: %w", err)
+	}
+
+	certPEM, keyPEM, err := NewNamedCertificate(hostname, customize)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s certificate: %w", prefix, err)
+	}
+
+	err = os.WriteFile(keyFile, keyPEM, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s key: %w", prefix, err)
+	}
+
+	err = os.WriteFile(certFile, certPEM, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s certificate: %w", prefix, err)
+	}
+
+	return nil
+}
+
+// NewNamedCertificate returns a new PEM encoded self-signed certificate and key named after the given name, as
+// GenerateCert names them after the hostname. customize can change its subject and names before it is generated.
+func NewNamedCertificate(name string, customize func(*types.CertificateOptions)) (certPEM []byte, keyPEM []byte, err error) {
+	options := types.CertificateOptions{
+		CommonName:   "root@" + name,
+		Organization: "microcluster",
+		DNSNames:     []string{name},
+	}
+
+	if customize != nil {
+		customize(&options)
+	}
+
+	return NewCertificate(options, certificateValidity)
+}
+
+// NewCertificate returns a new PEM encoded self-signed certificate and key with the given subject and names, valid for
+// the given duration.
+func NewCertificate(options types.CertificateOptions, validity time.Duration) (certPEM []byte, keyPEM []byte, err error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: options.CommonName, Organization: []string{options.Organization}},
+		DNSNames:              options.DNSNames,
+		IPAddresses:           options.IPAddresses,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+
+	return certPEM, keyPEM, nil
+}
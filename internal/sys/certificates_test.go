@@ -0,0 +1,99 @@
+package sys
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type certificatesSuite struct {
+	suite.Suite
+}
+
+func TestCertificatesSuite(t *testing.T) {
+	suite.Run(t, new(certificatesSuite))
+}
+
+// Ensures names and subjects injected by the customizer appear in the generated certificate, and that existing
+// certificates are left alone.
+func (t *certificatesSuite) Test_generateCert() {
+	dir := t.T().TempDir()
+	err := GenerateCert(dir, "server", func(options *types.CertificateOptions) {
+		options.Organization = "example"
+		options.DNSNames = append(options.DNSNames, "proxy.example.com")
+		options.IPAddresses = append(options.IPAddresses, net.ParseIP("10.0.0.1"))
+	})
+	t.Require().NoError(err)
+
+	cert, err := shared.KeyPairAndCA(dir, "server", shared.CertServer, false)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	hostname, err := os.Hostname()
+	t.Require().NoError(err)
+
+	t.Equal([]string{"example"}, x509Cert.Subject.Organization)
+	t.Equal("root@"+hostname, x509Cert.Subject.CommonName)
+	t.Equal([]string{hostname, "proxy.example.com"}, x509Cert.DNSNames)
+	t.Require().Len(x509Cert.IPAddresses, 1)
+	t.True(x509Cert.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+	t.NoError(x509Cert.VerifyHostname("proxy.example.com"))
+
+	info, err := os.Stat(filepath.Join(dir, "server.key"))
+	t.Require().NoError(err)
+	t.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// An existing certificate is not regenerated.
+	called := false
+	err = GenerateCert(dir, "server", func(options *types.CertificateOptions) { called = true })
+	t.NoError(err)
+	t.False(called)
+
+	reloaded, err := shared.KeyPairAndCA(dir, "server", shared.CertServer, false)
+	t.Require().NoError(err)
+	t.Equal(cert.Fingerprint(), reloaded.Fingerprint())
+}
+
+// Ensures new certificates carry the requested subject and names, and expire after the requested validity.
+func (t *certificatesSuite) Test_newCertificate() {
+	certPEM, keyPEM, err := NewCertificate(types.CertificateOptions{CommonName: "cluster", Organization: "example", DNSNames: []string{"cluster.example.com"}}, time.Hour)
+	t.Require().NoError(err)
+
+	cert, err := shared.KeyPairFromRaw(certPEM, keyPEM)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	t.Equal("cluster", x509Cert.Subject.CommonName)
+	t.Equal([]string{"example"}, x509Cert.Subject.Organization)
+	t.NoError(x509Cert.VerifyHostname("cluster.example.com"))
+	t.WithinDuration(time.Now().Add(time.Hour), x509Cert.NotAfter, time.Minute)
+}
+
+// Ensures named certificates are named like generated ones, after the given name instead of the hostname.
+func (t *certificatesSuite) Test_newNamedCertificate() {
+	certPEM, keyPEM, err := NewNamedCertificate("member01", func(options *types.CertificateOptions) {
+		options.DNSNames = append(options.DNSNames, "proxy.example.com")
+	})
+	t.Require().NoError(err)
+
+	cert, err := shared.KeyPairFromRaw(certPEM, keyPEM)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	t.Equal("root@member01", x509Cert.Subject.CommonName)
+	t.Equal([]string{"microcluster"}, x509Cert.Subject.Organization)
+	t.Equal([]string{"member01", "proxy.example.com"}, x509Cert.DNSNames)
+}
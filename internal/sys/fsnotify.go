@@ -21,6 +21,9 @@ type Watcher struct {
 
 	watching map[string]func(string, fsnotify.Op) error
 	root     string
+
+	files      map[string]map[uint64]func()
+	nextFileID uint64
 }
 
 // NewWatcher returns a watcher listening for fsnotify events down the given dir.
@@ -33,6 +36,7 @@ func NewWatcher(ctx context.Context, root string) (*Watcher, error) {
 	watcher := &Watcher{
 		Watcher:  fsWatcher,
 		watching: map[string]func(string, fsnotify.Op) error{},
+		files:    map[string]map[uint64]func(){},
 		root:     root,
 	}
 
@@ -110,6 +114,12 @@ func (w *Watcher) handleEvents(ctx context.Context) {
 					logger.Errorf("Error executing action on fsnotify event %q for path %q: %v", event.Op.String(), event.Name, err)
 				}
 			}
+
+			// Run file callbacks in their own goroutines so they can't block or crash the watcher.
+			for _, f := range w.files[event.Name] {
+				go runFileCallback(event.Name, f)
+			}
+
 			w.mu.Unlock()
 		}
 	}
@@ -135,3 +145,61 @@ func (w *Watcher) Watch(path string, fileExt string, f func(path string, event f
 
 	w.watching[path] = fileExtHook
 }
+
+// WatchFile registers a callback to be run whenever the file at the given path is created, written to, or removed.
+// Relative paths are taken from the watcher root. The returned function unregisters the callback.
+func (w *Watcher) WatchFile(path string, f func()) (cancel func(), err error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.root, path)
+	}
+
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("Path %q does not exist on watcher root path %q", path, w.root)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Files in new directories are not otherwise picked up, so make sure the parent directory is watched.
+	if shared.PathExists(filepath.Dir(path)) {
+		err := w.Add(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to watch path %q: %w", filepath.Dir(path), err)
+		}
+	}
+
+	id := w.nextFileID
+	w.nextFileID++
+
+	if w.files[path] == nil {
+		w.files[path] = map[uint64]func(){}
+	}
+
+	w.files[path][id] = f
+
+	cancel = func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.files[path], id)
+		if len(w.files[path]) == 0 {
+			delete(w.files, path)
+		}
+	}
+
+	return cancel, nil
+}
+
+// runFileCallback runs a file callback, recovering from any panic so that it does not take down the daemon.
+func runFileCallback(path string, f func()) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			logger.Error("Recovered from panic in file watcher callback", logger.Ctx{"path": path, "panic": r})
+		}
+	}()
+
+	f()
+}
@@ -0,0 +1,66 @@
+package sys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type fsnotifySuite struct {
+	suite.Suite
+}
+
+func TestFsnotifySuite(t *testing.T) {
+	suite.Run(t, new(fsnotifySuite))
+}
+
+// Ensures file callbacks are run on changes, survive a panicking callback, and stop once cancelled.
+func (t *fsnotifySuite) Test_watchFile() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root := t.T().TempDir()
+	watcher, err := NewWatcher(ctx, root)
+	t.Require().NoError(err)
+
+	called := make(chan struct{}, 10)
+	stop, err := watcher.WatchFile("custom.yaml", func() { called <- struct{}{} })
+	t.Require().NoError(err)
+
+	_, err = watcher.WatchFile(filepath.Join(root, "custom.yaml"), func() { panic("bad callback") })
+	t.Require().NoError(err)
+
+	for _, path := range []string{"/outside/root", root + "-evil/custom.yaml", "../custom.yaml", root + "/../custom.yaml"} {
+		_, err = watcher.WatchFile(path, func() {})
+		t.Error(err, path)
+	}
+
+	err = os.WriteFile(filepath.Join(root, "custom.yaml"), []byte("key: value"), 0644)
+	t.Require().NoError(err)
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fail("Timed out waiting for file callback")
+	}
+
+	// Let callbacks for any outstanding events finish before cancelling.
+	time.Sleep(200 * time.Millisecond)
+	stop()
+	for len(called) > 0 {
+		<-called
+	}
+
+	err = os.WriteFile(filepath.Join(root, "custom.yaml"), []byte("key: other"), 0644)
+	t.Require().NoError(err)
+
+	select {
+	case <-called:
+		t.Fail("Callback ran after being cancelled")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -17,6 +17,9 @@ type OS struct {
 	TrustDir    string
 	LogFile     string
 	SocketGroup string
+
+	// ControlSocketFile overrides the default path of the control socket under StateDir, if set.
+	ControlSocketFile string
 }
 
 // DefaultOS returns a fresh uninitialized OS instance with default values.
@@ -106,16 +109,37 @@ func (s *OS) ControlSocket() api.URL {
 	return *api.NewURL().Scheme("http").Host(s.ControlSocketPath())
 }
 
-// ControlSocketPath returns the filesystem path to the control socket.
+// ControlSocketPath returns the filesystem path to the control socket, which is under StateDir unless
+// ControlSocketFile is set.
 func (s *OS) ControlSocketPath() string {
+	if s.ControlSocketFile != "" {
+		return s.ControlSocketFile
+	}
+
 	return filepath.Join(s.StateDir, "control.socket")
 }
 
+// RosterPath returns the path of the file recording the cluster members whose membership hooks have been run.
+func (s *OS) RosterPath() string {
+	return filepath.Join(s.StateDir, "roster.yaml")
+}
+
+// RuntimeExtensionsPath returns the path of the file recording the API extensions registered at runtime.
+func (s *OS) RuntimeExtensionsPath() string {
+	return filepath.Join(s.StateDir, "extensions.yaml")
+}
+
 // DatabasePath returns the path of the database file managed by dqlite.
 func (s *OS) DatabasePath() string {
 	return filepath.Join(s.DatabaseDir, "db.bin")
 }
 
+// DatabaseResyncPath returns the path of the file that confirms that the local database may be resynced from the
+// cluster, if it diverged from it.
+func (s *OS) DatabaseResyncPath() string {
+	return filepath.Join(s.StateDir, "resync-database")
+}
+
 // ServerCert gets the local server certificate from the state directory.
 func (s *OS) ServerCert() (*shared.CertInfo, error) {
 	if !shared.PathExists(filepath.Join(s.StateDir, "server.crt")) {
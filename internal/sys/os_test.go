@@ -0,0 +1,45 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type osSuite struct {
+	suite.Suite
+}
+
+func TestOSSuite(t *testing.T) {
+	suite.Run(t, new(osSuite))
+}
+
+// Ensures the control socket is looked up at the overridden path, both for clients and for detecting a running daemon.
+func (t *osSuite) Test_controlSocketPath() {
+	stateDir := t.T().TempDir()
+	fs, err := DefaultOS(stateDir, "", true)
+	t.Require().NoError(err)
+	t.Equal(filepath.Join(stateDir, "control.socket"), fs.ControlSocketPath())
+
+	socketPath := filepath.Join(t.T().TempDir(), "custom.socket")
+	fs.ControlSocketFile = socketPath
+	t.Equal(socketPath, fs.ControlSocketPath())
+	t.Equal(socketPath, fs.ControlSocket().URL.Host)
+
+	present, err := fs.IsControlSocketPresent()
+	t.NoError(err)
+	t.False(present)
+
+	// A socket at the default path is ignored once the path is overridden.
+	t.Require().NoError(os.WriteFile(filepath.Join(stateDir, "control.socket"), nil, 0600))
+	present, err = fs.IsControlSocketPresent()
+	t.NoError(err)
+	t.False(present)
+
+	t.Require().NoError(os.WriteFile(socketPath, nil, 0600))
+	present, err = fs.IsControlSocketPresent()
+	t.NoError(err)
+	t.True(present)
+}
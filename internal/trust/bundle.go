@@ -0,0 +1,257 @@
+package trust
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/google/renameio"
+	"gopkg.in/yaml.v2"
+
+	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+)
+
+// Export returns all remotes as a bundle signed with the given keypair.
+func (r *Remotes) Export(cert *shared.CertInfo) (*internalTypes.TrustBundle, error) {
+	r.updateMu.RLock()
+	members := make([]internalTypes.ClusterMemberLocal, 0, len(r.data))
+	for _, remote := range r.data {
+		members = append(members, internalTypes.ClusterMemberLocal{
+			Name:              remote.Name,
+			Address:           remote.Address,
+			Certificate:       remote.Certificate,
+			ClientCertificate: remote.ClientCertificate,
+		})
+	}
+
+	r.updateMu.RUnlock()
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+	digest, err := bundleDigest(members)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := cert.KeyPair().PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported private key type %T", cert.KeyPair().PrivateKey)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to sign truststore bundle: %w", err)
+	}
+
+	return &internalTypes.TrustBundle{Members: members, Signature: base64.StdEncoding.EncodeToString(signature)}, nil
+}
+
+// PEMBundle returns the given cluster certificate, its CA if it has one, and the certificate of every remote ordered by
+// name, as a bundle of PEM blocks. Only public certificates are included, never private keys.
+func (r *Remotes) PEMBundle(clusterCert *shared.CertInfo) ([]byte, error) {
+	publicKey, err := clusterCert.PublicKeyX509()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse cluster certificate: %w", err)
+	}
+
+	certs := []*x509.Certificate{publicKey}
+	if clusterCert.CA() != nil {
+		certs = append(certs, clusterCert.CA())
+	}
+
+	r.updateMu.RLock()
+	remotes := make([]Remote, 0, len(r.data))
+	for _, remote := range r.data {
+		remotes = append(remotes, remote)
+	}
+
+	r.updateMu.RUnlock()
+
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	for _, remote := range remotes {
+		certs = append(certs, remote.Certificate.Certificate)
+	}
+
+	var bundle bytes.Buffer
+	for _, cert := range certs {
+		err := pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode certificate: %w", err)
+		}
+	}
+
+	return bundle.Bytes(), nil
+}
+
+// Import verifies the bundle's signature against the given certificate and adds its entries to the remotes.
+// Every entry is validated before any is written, and if writing any entry fails, those already written are
+// removed again. Entries that already exist with the same address and certificate are skipped.
+func (r *Remotes) Import(dir string, publicKey *x509.Certificate, bundle internalTypes.TrustBundle) ([]Remote, error) {
+	err := verifyBundle(publicKey, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	names := map[string]bool{}
+	addresses := map[string]bool{}
+	newRemotes := make([]Remote, 0, len(bundle.Members))
+	for _, member := range bundle.Members {
+		err := validateBundleMember(member)
+		if err != nil {
+			return nil, err
+		}
+
+		if names[member.Name] || addresses[member.Address.String()] {
+			return nil, fmt.Errorf("Truststore bundle contains duplicate entries for %q", member.Name)
+		}
+
+		names[member.Name] = true
+		addresses[member.Address.String()] = true
+
+		existing, ok := r.data[member.Name]
+		if ok {
+			if existing.Address.String() != member.Address.String() || !existing.Certificate.Certificate.Equal(member.Certificate.Certificate) {
+				return nil, fmt.Errorf("A different remote with name %q already exists", member.Name)
+			}
+
+			continue
+		}
+
+		for _, remote := range r.data {
+			if remote.Address.String() == member.Address.String() {
+				return nil, fmt.Errorf("Remote %q already exists with address %q", remote.Name, member.Address.String())
+			}
+		}
+
+		newRemotes = append(newRemotes, Remote{
+			Location:          Location{Name: member.Name, Address: member.Address},
+			Certificate:       member.Certificate,
+			ClientCertificate: member.ClientCertificate,
+		})
+	}
+
+	written := make([]string, 0, len(newRemotes))
+	cleanup := func() {
+		for _, path := range written {
+			_ = os.Remove(path)
+		}
+	}
+
+	for _, remote := range newRemotes {
+		bytes, err := yaml.Marshal(remote)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("Failed to parse remote %q to yaml: %w", remote.Name, err)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%s.yaml", remote.Name))
+		_, err = os.Stat(path)
+		if err == nil {
+			cleanup()
+			return nil, fmt.Errorf("Remote at %q already exists", path)
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			cleanup()
+			return nil, fmt.Errorf("Failed to check remote path %q: %w", path, err)
+		}
+
+		err = renameio.WriteFile(path, bytes, 0644)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("Failed to write %q: %w", path, err)
+		}
+
+		written = append(written, path)
+	}
+
+	// Add the remotes manually so we can use them right away without waiting for inotify.
+	for _, remote := range newRemotes {
+		r.data[remote.Name] = remote
+	}
+
+	return newRemotes, nil
+}
+
+// validateBundleMember checks that a truststore bundle entry is complete and its certificate is currently valid.
+func validateBundleMember(member internalTypes.ClusterMemberLocal) error {
+	if member.Name == "" {
+		return fmt.Errorf("Truststore bundle contains an entry with no name")
+	}
+
+	if !member.Address.IsValid() {
+		return fmt.Errorf("Truststore bundle entry %q has an invalid address", member.Name)
+	}
+
+	cert := member.Certificate.Certificate
+	if cert == nil {
+		return fmt.Errorf("Failed to parse bundle entry %q. Found empty certificate", member.Name)
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("Certificate of truststore bundle entry %q is not valid at this time", member.Name)
+	}
+
+	return nil
+}
+
+// verifyBundle checks the bundle's signature against the public key of the given certificate.
+func verifyBundle(publicKey *x509.Certificate, bundle internalTypes.TrustBundle) error {
+	signature, err := base64.StdEncoding.DecodeString(bundle.Signature)
+	if err != nil {
+		return fmt.Errorf("Failed to decode truststore bundle signature: %w", err)
+	}
+
+	digest, err := bundleDigest(bundle.Members)
+	if err != nil {
+		return err
+	}
+
+	switch key := publicKey.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return fmt.Errorf("Invalid truststore bundle signature")
+		}
+
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature)
+		if err != nil {
+			return fmt.Errorf("Invalid truststore bundle signature: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("Unsupported public key type %T", publicKey.PublicKey)
+	}
+
+	return nil
+}
+
+// bundleDigest returns the SHA-256 digest of the JSON encoding of the bundle members.
+func bundleDigest(members []internalTypes.ClusterMemberLocal) ([]byte, error) {
+	data, err := json.Marshal(members)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode truststore bundle: %w", err)
+	}
+
+	digest := sha256.Sum256(data)
+
+	return digest[:], nil
+}
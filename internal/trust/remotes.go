@@ -26,12 +26,18 @@ import (
 type Remotes struct {
 	data     map[string]Remote
 	updateMu sync.RWMutex
+
+	pool *internalClient.Pool // Clients to remotes, reused across calls to Cluster.
 }
 
 // Remote represents a yaml file with credentials to be read by the daemon.
 type Remote struct {
 	Location    `yaml:",inline"`
 	Certificate types.X509Certificate `yaml:"certificate"`
+
+	// ClientCertificate is the certificate the remote presents when querying other cluster members, if it is
+	// distinct from its server certificate.
+	ClientCertificate *types.X509Certificate `yaml:"client_certificate,omitempty"`
 }
 
 // Location represents configurable identifying information about a remote.
@@ -83,11 +89,29 @@ func (r *Remotes) Load(dir string) error {
 		return nil
 	}
 
+	r.evictChanged(remoteData)
 	r.data = remoteData
 
 	return nil
 }
 
+// evictChanged removes pooled clients to any remote that is no longer present in the given data, or has changed.
+// Must be called with updateMu held.
+func (r *Remotes) evictChanged(newData map[string]Remote) {
+	if r.pool == nil {
+		return
+	}
+
+	for name, remote := range r.data {
+		newRemote, ok := newData[name]
+		if ok && newRemote.Address == remote.Address && newRemote.Certificate.Certificate.Equal(remote.Certificate.Certificate) && newRemote.clientCertificateEqual(remote) {
+			continue
+		}
+
+		r.pool.EvictAddress(remote.Address.String())
+	}
+}
+
 // Add adds a new local cluster member record for the remotes.
 func (r *Remotes) Add(dir string, remotes ...Remote) error {
 	r.updateMu.Lock()
@@ -142,8 +166,9 @@ func (r *Remotes) Replace(dir string, newRemotes ...internalTypes.ClusterMember)
 	remoteData := map[string]Remote{}
 	for _, remote := range newRemotes {
 		newRemote := Remote{
-			Location:    Location{Name: remote.Name, Address: remote.Address},
-			Certificate: remote.Certificate,
+			Location:          Location{Name: remote.Name, Address: remote.Address},
+			Certificate:       remote.Certificate,
+			ClientCertificate: remote.ClientCertificate,
 		}
 
 		if remote.Certificate.Certificate == nil {
@@ -187,6 +212,7 @@ func (r *Remotes) Replace(dir string, newRemotes ...internalTypes.ClusterMember)
 		return fmt.Errorf("Failed to parse new remotes")
 	}
 
+	r.evictChanged(remoteData)
 	r.data = remoteData
 
 	return nil
@@ -218,12 +244,22 @@ func (r *Remotes) Addresses() map[string]types.AddrPort {
 	return addrs
 }
 
+// Client returns a client for the cluster member at the given address.
+// Clients are reused from the pool of the truststore, if it has one.
+func (r *Remotes) Client(addr types.AddrPort, isNotification bool, serverCert *shared.CertInfo, publicKey *x509.Certificate) (*internalClient.Client, error) {
+	url := api.NewURL().Scheme("https").Host(addr.String())
+	if r.pool != nil {
+		return r.pool.Get(*url, serverCert, publicKey, isNotification)
+	}
+
+	return internalClient.New(*url, serverCert, publicKey, isNotification)
+}
+
 // Cluster returns a set of clients for every remote, which can be concurrently queried.
 func (r *Remotes) Cluster(isNotification bool, serverCert *shared.CertInfo, publicKey *x509.Certificate) (client.Cluster, error) {
 	cluster := make(client.Cluster, 0, r.Count()-1)
 	for _, addr := range r.Addresses() {
-		url := api.NewURL().Scheme("https").Host(addr.String())
-		c, err := internalClient.New(*url, serverCert, publicKey, isNotification)
+		c, err := r.Client(addr, isNotification, serverCert, publicKey)
 		if err != nil {
 			return nil, err
 		}
@@ -254,8 +290,10 @@ func (r *Remotes) RemoteByCertificateFingerprint(fingerprint string) *Remote {
 	defer r.updateMu.RUnlock()
 
 	for _, remote := range r.data {
-		if fingerprint == shared.CertFingerprint(remote.Certificate.Certificate) {
-			return &remote
+		for _, cert := range remote.certificates() {
+			if fingerprint == shared.CertFingerprint(cert) {
+				return &remote
+			}
 		}
 	}
 
@@ -282,7 +320,9 @@ func (r *Remotes) CertificatesNative() map[string]x509.Certificate {
 
 	certMap := map[string]x509.Certificate{}
 	for _, remote := range r.data {
-		certMap[shared.CertFingerprint(remote.Certificate.Certificate)] = *remote.Certificate.Certificate
+		for _, cert := range remote.certificates() {
+			certMap[shared.CertFingerprint(cert)] = *cert
+		}
 	}
 
 	return certMap
@@ -309,6 +349,52 @@ func (r *Remotes) RemotesByName() map[string]Remote {
 	return remoteData
 }
 
+// ClientCertificate returns the public key of clientCert to record in the truststore alongside serverCert, or nil if
+// clientCert is unset or is the server certificate itself.
+func ClientCertificate(serverCert *shared.CertInfo, clientCert *shared.CertInfo) (*types.X509Certificate, error) {
+	if clientCert == nil || clientCert == serverCert {
+		return nil, nil
+	}
+
+	cert, err := clientCert.PublicKeyX509()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse client certificate: %w", err)
+	}
+
+	if serverCert != nil {
+		server, err := serverCert.PublicKeyX509()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse server certificate: %w", err)
+		}
+
+		if cert.Equal(server) {
+			return nil, nil
+		}
+	}
+
+	return &types.X509Certificate{Certificate: cert}, nil
+}
+
+// certificates returns every certificate the remote may authenticate with: its server certificate, and its client
+// certificate if it has a distinct one.
+func (r *Remote) certificates() []*x509.Certificate {
+	certs := []*x509.Certificate{r.Certificate.Certificate}
+	if r.ClientCertificate != nil && r.ClientCertificate.Certificate != nil {
+		certs = append(certs, r.ClientCertificate.Certificate)
+	}
+
+	return certs
+}
+
+// clientCertificateEqual returns whether both remotes have the same client certificate.
+func (r *Remote) clientCertificateEqual(other Remote) bool {
+	if r.ClientCertificate == nil || other.ClientCertificate == nil {
+		return r.ClientCertificate == nil && other.ClientCertificate == nil
+	}
+
+	return r.ClientCertificate.Certificate.Equal(other.ClientCertificate.Certificate)
+}
+
 // URL returns the parsed URL of the Remote.
 func (r *Remote) URL() api.URL {
 	return *api.NewURL().Scheme("https").Host(r.Address.String())
@@ -6,6 +6,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 
+	internalClient "github.com/canonical/microcluster/internal/rest/client"
 	"github.com/canonical/microcluster/internal/sys"
 )
 
@@ -18,9 +19,9 @@ type Store struct {
 }
 
 // Init initializes the remotes in the truststore, seeds the rand package for selecting remotes at random, and watches
-// the truststore directory for updates.
-func Init(watcher *sys.Watcher, onUpdate func(oldRemotes, newRemotes Remotes) error, dir string) (*Store, error) {
-	ts := &Store{remotes: &Remotes{}}
+// the truststore directory for updates. Clients to the remotes also trust the given cluster certificates.
+func Init(watcher *sys.Watcher, onUpdate func(oldRemotes, newRemotes Remotes) error, dir string, trusted *internalClient.TrustedCerts) (*Store, error) {
+	ts := &Store{remotes: &Remotes{pool: internalClient.NewPool(trusted)}}
 	ts.remotesMu.Lock()
 	defer ts.remotesMu.Unlock()
 
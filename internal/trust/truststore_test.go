@@ -0,0 +1,252 @@
+package trust
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v2"
+
+	"github.com/canonical/microcluster/internal/sys"
+	"github.com/canonical/microcluster/rest/types"
+)
+
+type truststoreSuite struct {
+	suite.Suite
+}
+
+func TestTruststoreSuite(t *testing.T) {
+	suite.Run(t, new(truststoreSuite))
+}
+
+// Ensures a remote written to the truststore directory out of band is trusted once the truststore is refreshed, even
+// if the file watcher misses the change, and that concurrent refreshes are safe.
+func (t *truststoreSuite) Test_refresh() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Root the watcher elsewhere, so that it never sees changes to the truststore directory.
+	watcher, err := sys.NewWatcher(ctx, t.T().TempDir())
+	t.Require().NoError(err)
+
+	dir := t.T().TempDir()
+	store, err := Init(watcher, nil, dir, nil)
+	t.Require().NoError(err)
+	t.Equal(0, store.Remotes().Count())
+
+	cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	x509Cert, err := cert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	address, err := types.ParseAddrPort("10.0.0.1:9443")
+	t.Require().NoError(err)
+
+	remote := Remote{Location: Location{Name: "member01", Address: address}, Certificate: types.X509Certificate{Certificate: x509Cert}}
+	data, err := yaml.Marshal(remote)
+	t.Require().NoError(err)
+	t.Require().NoError(os.WriteFile(filepath.Join(dir, "member01.yaml"), data, 0644))
+
+	// The remote is not trusted until the truststore is refreshed.
+	t.Nil(store.Remotes().RemoteByCertificateFingerprint(cert.Fingerprint()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.NoError(store.Refresh())
+		}()
+	}
+
+	wg.Wait()
+
+	trusted := store.Remotes().RemoteByCertificateFingerprint(cert.Fingerprint())
+	t.Require().NotNil(trusted)
+	t.Equal("member01", trusted.Name)
+	t.Equal(1, store.Remotes().Count())
+
+	// Refreshing again without changes keeps the same remotes.
+	t.NoError(store.Refresh())
+	t.Equal(1, store.Remotes().Count())
+}
+
+// Ensures the PEM bundle holds exactly the cluster certificate and the certificate of every remote, without any
+// private keys.
+func (t *truststoreSuite) Test_pemBundle() {
+	clusterCert, err := shared.KeyPairAndCA(t.T().TempDir(), "cluster", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	clusterX509, err := clusterCert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	remotes := &Remotes{data: map[string]Remote{}}
+	expected := []*x509.Certificate{clusterX509}
+	for i, name := range []string{"member01", "member02"} {
+		cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+		t.Require().NoError(err)
+
+		x509Cert, err := cert.PublicKeyX509()
+		t.Require().NoError(err)
+
+		address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9443", i+1))
+		t.Require().NoError(err)
+
+		remotes.data[name] = Remote{Location: Location{Name: name, Address: address}, Certificate: types.X509Certificate{Certificate: x509Cert}}
+		expected = append(expected, x509Cert)
+	}
+
+	bundle, err := remotes.PEMBundle(clusterCert)
+	t.Require().NoError(err)
+	t.NotContains(string(bundle), "PRIVATE KEY")
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+
+		t.Equal("CERTIFICATE", block.Type)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		t.Require().NoError(err)
+		certs = append(certs, cert)
+	}
+
+	t.Empty(bundle)
+	t.Require().Len(certs, len(expected))
+	for i := range expected {
+		t.True(expected[i].Equal(certs[i]))
+	}
+}
+
+// Ensures a remote is trusted by both its server and client certificates, including after being loaded from disk, and
+// that a client certificate is only recorded if it differs from the server certificate.
+func (t *truststoreSuite) Test_clientCertificate() {
+	serverCert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	clientCert, err := shared.KeyPairAndCA(t.T().TempDir(), "client", shared.CertClient, true)
+	t.Require().NoError(err)
+
+	serverX509, err := serverCert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	recorded, err := ClientCertificate(serverCert, nil)
+	t.NoError(err)
+	t.Nil(recorded)
+
+	recorded, err = ClientCertificate(serverCert, serverCert)
+	t.NoError(err)
+	t.Nil(recorded)
+
+	recorded, err = ClientCertificate(serverCert, clientCert)
+	t.Require().NoError(err)
+	t.Require().NotNil(recorded)
+	t.Equal(clientCert.Fingerprint(), shared.CertFingerprint(recorded.Certificate))
+
+	address, err := types.ParseAddrPort("10.0.0.1:9443")
+	t.Require().NoError(err)
+
+	dir := t.T().TempDir()
+	remote := Remote{Location: Location{Name: "member01", Address: address}, Certificate: types.X509Certificate{Certificate: serverX509}, ClientCertificate: recorded}
+	t.Require().NoError((&Remotes{data: map[string]Remote{}}).Add(dir, remote))
+
+	remotes := &Remotes{}
+	t.Require().NoError(remotes.Load(dir))
+
+	certs := remotes.CertificatesNative()
+	t.Len(certs, 2)
+	for _, fingerprint := range []string{serverCert.Fingerprint(), clientCert.Fingerprint()} {
+		t.Contains(certs, fingerprint)
+
+		trusted := remotes.RemoteByCertificateFingerprint(fingerprint)
+		t.Require().NotNil(trusted)
+		t.Equal("member01", trusted.Name)
+	}
+}
+
+// Ensures an exported bundle is imported into another truststore as a whole, and that bundles with an invalid
+// signature or conflicting entries are rejected without writing anything.
+func (t *truststoreSuite) Test_bundle() {
+	clusterCert, err := shared.KeyPairAndCA(t.T().TempDir(), "cluster", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	clusterX509, err := clusterCert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	remotes := &Remotes{data: map[string]Remote{}}
+	for i, name := range []string{"member01", "member02"} {
+		cert, err := shared.KeyPairAndCA(t.T().TempDir(), "server", shared.CertServer, true)
+		t.Require().NoError(err)
+
+		x509Cert, err := cert.PublicKeyX509()
+		t.Require().NoError(err)
+
+		address, err := types.ParseAddrPort(fmt.Sprintf("10.0.0.%d:9443", i+1))
+		t.Require().NoError(err)
+
+		remotes.data[name] = Remote{Location: Location{Name: name, Address: address}, Certificate: types.X509Certificate{Certificate: x509Cert}}
+	}
+
+	bundle, err := remotes.Export(clusterCert)
+	t.Require().NoError(err)
+	t.Require().Len(bundle.Members, 2)
+	t.Equal("member01", bundle.Members[0].Name)
+	t.Equal("member02", bundle.Members[1].Name)
+
+	// A bundle signed with another keypair is rejected.
+	otherCert, err := shared.KeyPairAndCA(t.T().TempDir(), "cluster", shared.CertServer, true)
+	t.Require().NoError(err)
+
+	otherX509, err := otherCert.PublicKeyX509()
+	t.Require().NoError(err)
+
+	dir := t.T().TempDir()
+	imported := &Remotes{data: map[string]Remote{}}
+	_, err = imported.Import(dir, otherX509, *bundle)
+	t.Error(err)
+
+	// So is a bundle whose entries were changed after signing.
+	tampered := *bundle
+	tampered.Members = append(tampered.Members[:1:1], tampered.Members[1])
+	tampered.Members[1].Name = "member03"
+	_, err = imported.Import(dir, clusterX509, tampered)
+	t.Error(err)
+
+	// A conflicting entry fails the whole import.
+	conflicting := remotes.data["member02"]
+	conflicting.Name = "other"
+	imported.data["other"] = conflicting
+	_, err = imported.Import(dir, clusterX509, *bundle)
+	t.Error(err)
+
+	files, err := os.ReadDir(dir)
+	t.Require().NoError(err)
+	t.Empty(files)
+
+	delete(imported.data, "other")
+	added, err := imported.Import(dir, clusterX509, *bundle)
+	t.Require().NoError(err)
+	t.Len(added, 2)
+	t.Equal(2, imported.Count())
+
+	loaded := &Remotes{}
+	t.Require().NoError(loaded.Load(dir))
+	t.Equal(2, loaded.Count())
+	t.Equal(remotes.data["member02"].Address, loaded.RemotesByName()["member02"].Address)
+
+	// Importing the same bundle again adds nothing.
+	added, err = imported.Import(dir, clusterX509, *bundle)
+	t.Require().NoError(err)
+	t.Empty(added)
+}
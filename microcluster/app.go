@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/canonical/lxd/lxd/db/schema"
+	"github.com/canonical/lxd/lxd/response"
+	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/logger"
 	"golang.org/x/sys/unix"
@@ -21,8 +23,10 @@ import (
 	"github.com/canonical/microcluster/cluster"
 	"github.com/canonical/microcluster/config"
 	"github.com/canonical/microcluster/internal/daemon"
+	"github.com/canonical/microcluster/internal/endpoints"
 	internalClient "github.com/canonical/microcluster/internal/rest/client"
 	internalTypes "github.com/canonical/microcluster/internal/rest/types"
+	"github.com/canonical/microcluster/internal/state"
 	"github.com/canonical/microcluster/internal/sys"
 	"github.com/canonical/microcluster/rest"
 	"github.com/canonical/microcluster/rest/types"
@@ -47,6 +51,137 @@ type Args struct {
 	Proxy      func(*http.Request) (*url.URL, error)
 
 	ExtensionServers []rest.Server
+
+	// LogMemberContext adds the member name and address to every log line once the daemon is initialized.
+	// The context is set on the process-wide logger, so it should only be used with one daemon per process: with
+	// several, such as in a test cluster, every log line is tagged with the member that was initialized last.
+	LogMemberContext bool
+
+	// TLS holds optional hardening parameters, such as the minimum TLS version, for the core network listener.
+	TLS types.TLSOptions
+
+	// Version is the version of the application, reported to clients along with its API extensions.
+	Version string
+
+	// ListenInterface restricts the listener on ListenPort to the address of the given network interface.
+	ListenInterface string
+
+	// HeartbeatJitter is the fraction of the heartbeat interval by which each member randomly shifts its own
+	// heartbeat interval, so that members don't send heartbeats in step. Defaults to 0.1 (±10%) if unset.
+	HeartbeatJitter float64
+
+	// DrainConnectionsTimeout is how long the network listeners wait for in-flight API requests to complete on
+	// shutdown before closing their connections. If unset, connections are left to finish on their own.
+	DrainConnectionsTimeout time.Duration
+
+	// DrainStreamsTimeout is how long the network listeners wait on shutdown for long-lived connections, such as
+	// websockets and dqlite connections, to be closed before closing them. It is independent of
+	// DrainConnectionsTimeout, so that streams can be given more grace without holding up regular requests.
+	// If unset, streams are left to finish on their own.
+	DrainStreamsTimeout time.Duration
+
+	// TokenExpirySkew is how long past their expiry date join tokens are still accepted, to tolerate clock skew
+	// between cluster members. Defaults to 30 seconds if unset.
+	TokenExpirySkew time.Duration
+
+	// HealthAddress is the address, such as "0.0.0.0:9000", of an optional HTTP listener for load balancer health
+	// checks. It requires no client certificate, and serves only GET /health, which returns 200 once the daemon is
+	// ready and its database is open, and 503 otherwise. If unset, no health listener is started.
+	HealthAddress string
+
+	// AccessLog logs the method, path, status, duration, peer name and client certificate fingerprint of every API
+	// request. Requests from other cluster members are logged at debug level, and all others at info level.
+	AccessLog bool
+
+	// AccessLogExcludedPaths are the request paths, such as "/cluster/1.0/ready", left out of the access log.
+	// Defaults to the dqlite connection and heartbeat paths if nil. An empty slice logs every request.
+	AccessLogExcludedPaths []string
+
+	// SlowTransactionThreshold is how long a database transaction may take before a warning is logged with its name,
+	// duration and whether it was retried. Defaults to 5 seconds if unset. A negative value disables the warning.
+	SlowTransactionThreshold time.Duration
+
+	// MinVoters is the minimum number of dqlite voters that must remain after removing cluster members. Removals
+	// that would leave fewer voters are refused unless forced. Defaults to 1 if unset.
+	MinVoters int
+
+	// DqliteTCPUserTimeout is how long data sent on a dqlite connection to another member may remain unacknowledged
+	// before the connection is dropped, and DqliteTCPKeepAlivePeriod is the TCP keepalive period of those connections.
+	// Lower values detect dead members sooner, which speeds up leader failover, at the cost of dropping connections
+	// that only stalled during a brief network blip. Default to 2 minutes and 3 seconds if unset.
+	DqliteTCPUserTimeout     time.Duration
+	DqliteTCPKeepAlivePeriod time.Duration
+
+	// CertExpiryWarning is how long before the server, cluster or extension server certificates expire that warnings
+	// are logged and the OnCertExpiring hook is run. Defaults to 30 days if unset. A negative value disables the warnings.
+	CertExpiryWarning time.Duration
+
+	// StrictSocketGroup makes the daemon fail to start if SocketGroup, or the SocketGroup of an extension server,
+	// doesn't exist. By default, a warning is logged and the socket is owned by the process group instead.
+	StrictSocketGroup bool
+
+	// ReadOnly makes this a read-only cluster member. Database transactions that modify any rows fail with
+	// types.ErrReadOnlyMember, and endpoint actions with ForwardToLeader are sent to the dqlite leader instead.
+	// The member still takes part in heartbeats and membership changes.
+	ReadOnly bool
+
+	// SchemaHooks are run when the schema extension of the version they are keyed by is applied, in the same
+	// transaction, right after the update itself. Versions start at 1 for the first schema extension passed to Start.
+	// They run before the daemon is ready, for example to fill a new column from existing data. The schema version
+	// check against the other cluster members runs before any update, so hooks only run once all members agree to
+	// upgrade.
+	SchemaHooks map[int]schema.Hook
+
+	// ControlSocketPath overrides the path of the control socket, which defaults to control.socket in the state
+	// directory. It must be absolute, and its parent directory must exist. A socket already at that path is handled
+	// as it would be at the default path.
+	ControlSocketPath string
+
+	// CertificateCustomizer is called before the server certificate, and the cluster certificate when bootstrapping
+	// or rotating it, are generated, to change their subject or add names, for example when the daemon is reached
+	// through a proxy or under several hostnames. It has no effect on certificates that already exist in the state
+	// directory, or on the cluster certificate that joining members receive from the cluster.
+	CertificateCustomizer func(*types.CertificateOptions)
+
+	// DqliteMaxConnections and DqliteMaxConnectionsPerPeer limit the number of concurrent inbound dqlite connections
+	// from all peers and from a single peer. Connections beyond either limit are rejected with a 429 error before
+	// being upgraded. Default to 1024 and 256 if unset. A negative value disables the limit.
+	DqliteMaxConnections        int
+	DqliteMaxConnectionsPerPeer int
+
+	// QuorumMembers makes the bootstrapped cluster member wait until that many cluster members have joined before
+	// running the OnQuorum hook, for example to run cluster-wide initialization once. The wait ends after
+	// QuorumTimeout, 30 minutes if unset, at which point the hook is run with the members that joined so far.
+	QuorumMembers int
+	QuorumTimeout time.Duration
+
+	// ClientCertificate is presented instead of the server certificate when this member queries the API of other
+	// cluster members, for example to use a certificate issued for client authentication. Dqlite connections keep
+	// using the server certificate. The certificate is recorded alongside the server certificate in the truststore
+	// of every member, which trusts either of them. A member that starts with a new client certificate keeps using
+	// its server certificate while starting up, and other members trust the new certificate from their next heartbeat.
+	ClientCertificate *shared.CertInfo
+
+	// DebugHTTPAddress is the address, such as "127.0.0.1:9001", of an optional plain HTTP listener serving the GET
+	// actions of a few /cluster/1.0 endpoints, such as the cluster members and the ready status, for local debugging
+	// tools and sidecars that can't present a client certificate. It never serves join tokens, the change and event
+	// feeds, or the database. It is INSECURE: any local process can read the cluster state through it without
+	// authentication.
+	// The daemon refuses to start if the address is not a loopback address. If unset, no debug listener is started.
+	DebugHTTPAddress string
+
+	// RootHandler replaces the response to requests for "/" on the daemon's listeners, for example to redirect to a
+	// web UI or to list the API versions of extension servers too. By default, it lists the "/1.0" API version, or
+	// the server information with ?recursion=1. NotFoundHandler replaces the response to requests that match no
+	// endpoint, which defaults to a JSON 404 error. The responses are sent as JSON unless they set another
+	// Content-Type.
+	RootHandler     func(s *state.State, r *http.Request) response.Response
+	NotFoundHandler func(s *state.State, r *http.Request) response.Response
+
+	// DivergencePolicy is how a restarting cluster member handles a local database that diverged from the rest of the
+	// cluster, for example because it was recovered on its own during a network partition. Defaults to
+	// types.DivergenceAbort, which refuses to start. See types.DivergencePolicy for the other policies.
+	DivergencePolicy types.DivergencePolicy
 }
 
 // App returns an instance of MicroCluster with a newly initialized filesystem if one does not exist.
@@ -58,11 +193,17 @@ func App(args Args) (*MicroCluster, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Missing absolute state directory: %w", err)
 	}
+	if args.ControlSocketPath != "" && !filepath.IsAbs(args.ControlSocketPath) {
+		return nil, fmt.Errorf("Control socket path %q must be absolute", args.ControlSocketPath)
+	}
+
 	os, err := sys.DefaultOS(stateDir, args.SocketGroup, true)
 	if err != nil {
 		return nil, err
 	}
 
+	os.ControlSocketFile = args.ControlSocketPath
+
 	return &MicroCluster{
 		FileSystem: os,
 		args:       args,
@@ -90,7 +231,37 @@ func (m *MicroCluster) Start(ctx context.Context, extensionsSchema []schema.Upda
 	ctx, cancel := signal.NotifyContext(ctx, unix.SIGPWR, unix.SIGTERM, unix.SIGINT, unix.SIGQUIT)
 	defer cancel()
 
-	err = d.Run(ctx, m.args.ListenPort, m.FileSystem.StateDir, m.FileSystem.SocketGroup, extensionsSchema, apiExtensions, m.args.ExtensionServers, hooks)
+	err = d.Run(ctx, m.args.ListenPort, m.FileSystem.StateDir, m.FileSystem.SocketGroup, extensionsSchema, apiExtensions, m.args.ExtensionServers, hooks, daemon.Options{
+		LogMemberContext:            m.args.LogMemberContext,
+		TLS:                         m.args.TLS,
+		Version:                     m.args.Version,
+		ListenInterface:             m.args.ListenInterface,
+		HeartbeatJitter:             m.args.HeartbeatJitter,
+		DrainTimeouts:               endpoints.DrainTimeouts{Requests: m.args.DrainConnectionsTimeout, Streams: m.args.DrainStreamsTimeout},
+		TokenExpirySkew:             m.args.TokenExpirySkew,
+		HealthAddress:               m.args.HealthAddress,
+		AccessLog:                   m.args.AccessLog,
+		AccessLogExcludedPaths:      m.args.AccessLogExcludedPaths,
+		SlowTransactionThreshold:    m.args.SlowTransactionThreshold,
+		MinVoters:                   m.args.MinVoters,
+		StrictSocketGroup:           m.args.StrictSocketGroup,
+		CertExpiryWarning:           m.args.CertExpiryWarning,
+		DqliteTCPUserTimeout:        m.args.DqliteTCPUserTimeout,
+		DqliteTCPKeepAlivePeriod:    m.args.DqliteTCPKeepAlivePeriod,
+		ReadOnly:                    m.args.ReadOnly,
+		SchemaHooks:                 m.args.SchemaHooks,
+		DqliteMaxConnections:        m.args.DqliteMaxConnections,
+		DqliteMaxConnectionsPerPeer: m.args.DqliteMaxConnectionsPerPeer,
+		ControlSocketPath:           m.args.ControlSocketPath,
+		CertificateCustomizer:       m.args.CertificateCustomizer,
+		QuorumMembers:               m.args.QuorumMembers,
+		QuorumTimeout:               m.args.QuorumTimeout,
+		ClientCertificate:           m.args.ClientCertificate,
+		DebugHTTPAddress:            m.args.DebugHTTPAddress,
+		RootHandler:                 m.args.RootHandler,
+		NotFoundHandler:             m.args.NotFoundHandler,
+		DivergencePolicy:            m.args.DivergencePolicy,
+	})
 	if err != nil {
 		return fmt.Errorf("Daemon stopped with error: %w", err)
 	}
@@ -105,13 +276,45 @@ func (m *MicroCluster) Status(ctx context.Context) (*internalTypes.Server, error
 		return nil, err
 	}
 
-	server := internalTypes.Server{}
-	err = c.QueryStruct(ctx, "GET", internalTypes.PublicEndpoint, nil, nil, &server)
+	server, err := c.GetServerInfo(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get cluster status: %w", err)
 	}
 
-	return &server, nil
+	return server, nil
+}
+
+// ActiveConnections returns the number of connections still open to the daemon, including the one used to make this
+// request. During a rolling restart, this can be used to check whether the daemon has finished draining.
+func (m *MicroCluster) ActiveConnections(ctx context.Context) (int64, error) {
+	c, err := m.LocalClient()
+	if err != nil {
+		return 0, err
+	}
+
+	connections, err := c.GetConnections(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get active connections: %w", err)
+	}
+
+	return connections.Active, nil
+}
+
+// Listeners returns the listeners of the daemon, with the address each is bound to and the fingerprint of the
+// certificate served by each https listener. This shows which certificate is served on which port, for example when
+// an extension server serves a dedicated certificate.
+func (m *MicroCluster) Listeners(ctx context.Context) ([]internalTypes.ListenerInfo, error) {
+	c, err := m.LocalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners, err := c.GetListeners(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get listeners: %w", err)
+	}
+
+	return listeners, nil
 }
 
 // Ready waits for the daemon to report it has finished initial setup and is ready to be bootstrapped or join an
@@ -205,6 +408,23 @@ func (m *MicroCluster) JoinCluster(ctx context.Context, name string, address str
 	return c.ControlDaemon(ctx, internalTypes.Control{JoinToken: token, Address: addr, Name: name, InitConfig: initConfig})
 }
 
+// JoinClusterAsObserver joins an existing cluster like JoinCluster, as an observer. Observers replicate the database
+// and serve the API, but are kept as dqlite spares and never promoted to voters or stand-bys, even as other cluster
+// members come and go. They are listed with the cluster.Observer role.
+func (m *MicroCluster) JoinClusterAsObserver(ctx context.Context, name string, address string, token string, initConfig map[string]string) error {
+	c, err := m.LocalClient()
+	if err != nil {
+		return err
+	}
+
+	addr, err := types.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("Received invalid address %q: %w", address, err)
+	}
+
+	return c.ControlDaemon(ctx, internalTypes.Control{JoinToken: token, Address: addr, Name: name, InitConfig: initConfig, Observer: true})
+}
+
 // NewJoinToken creates and records a new join token containing all the necessary credentials for joining a cluster.
 // Join tokens are tied to the server certificate of the joining node, and will be deleted once the node has joined the
 // cluster.
@@ -222,6 +442,22 @@ func (m *MicroCluster) NewJoinToken(ctx context.Context, name string) (string, e
 	return secret, nil
 }
 
+// NewJoinTokens creates and records a join token for each of the given requests, each with its own name and expiry.
+// Either all of the tokens are created, or none are.
+func (m *MicroCluster) NewJoinTokens(ctx context.Context, tokens []internalTypes.TokenRequest) ([]internalTypes.TokenRecord, error) {
+	c, err := m.LocalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := c.CreateTokens(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
 // ListJoinTokens lists all the join tokens currently available for use.
 func (m *MicroCluster) ListJoinTokens(ctx context.Context) ([]internalTypes.TokenRecord, error) {
 	c, err := m.LocalClient()
@@ -252,6 +488,42 @@ func (m *MicroCluster) RevokeJoinToken(ctx context.Context, name string) error {
 	return nil
 }
 
+// RotateClusterCertificate generates a new cluster keypair and rotates it across all cluster members without a
+// restart. The new certificate is first staged on every member to be trusted alongside the current one, then every
+// member switches to the new keypair, and finally the previous certificate is no longer trusted. If the rotation fails
+// after the new certificate is staged, members keep trusting both certificates so that the cluster stays connected.
+func (m *MicroCluster) RotateClusterCertificate(ctx context.Context) error {
+	current, err := m.FileSystem.ClusterCert()
+	if err != nil {
+		return err
+	}
+
+	currentCert, err := current.PublicKeyX509()
+	if err != nil {
+		return err
+	}
+
+	certPEM, keyPEM, err := m.generateClusterCert(currentCert)
+	if err != nil {
+		return fmt.Errorf("Failed to generate cluster certificate: %w", err)
+	}
+
+	c, err := m.LocalClient()
+	if err != nil {
+		return err
+	}
+
+	args := types.ClusterCertificatePut{PublicKey: string(certPEM), PrivateKey: string(keyPEM)}
+	for _, phase := range []types.ClusterCertificateRotation{types.ClusterCertificateStage, types.ClusterCertificateCommit, types.ClusterCertificateFinalize} {
+		err = c.RotateClusterCertificate(ctx, phase, args)
+		if err != nil {
+			return fmt.Errorf("Failed to %s cluster certificate rotation: %w", phase, err)
+		}
+	}
+
+	return nil
+}
+
 // LocalClient returns a client connected to the local control socket.
 func (m *MicroCluster) LocalClient() (*client.Client, error) {
 	c := m.args.Client
@@ -352,3 +624,35 @@ func (m *MicroCluster) SQL(ctx context.Context, query string) (string, *internal
 
 	return "", batch, err
 }
+
+// BackupDatabase streams a backup of the database to w, as a tar archive of the dqlite database files. The backup is
+// streamed as it is received, so it isn't held in memory by the client, and its checksum is verified at the end.
+func (m *MicroCluster) BackupDatabase(ctx context.Context, w io.Writer) error {
+	c, err := m.LocalClient()
+	if err != nil {
+		return err
+	}
+
+	return c.GetDatabaseBackup(ctx, w)
+}
+
+// generateClusterCert returns a new PEM encoded cluster certificate and key. The certificate keeps the subject, names
+// and validity period of the current one, so that clients still verify it against the same server name, unless the
+// CertificateCustomizer changes them.
+func (m *MicroCluster) generateClusterCert(current *x509.Certificate) ([]byte, []byte, error) {
+	options := types.CertificateOptions{
+		CommonName:  current.Subject.CommonName,
+		DNSNames:    current.DNSNames,
+		IPAddresses: current.IPAddresses,
+	}
+
+	if len(current.Subject.Organization) > 0 {
+		options.Organization = current.Subject.Organization[0]
+	}
+
+	if m.args.CertificateCustomizer != nil {
+		m.args.CertificateCustomizer(&options)
+	}
+
+	return sys.NewCertificate(options, current.NotAfter.Sub(current.NotBefore))
+}
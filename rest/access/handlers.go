@@ -8,6 +8,7 @@ import (
 	"github.com/canonical/lxd/lxd/request"
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/lxd/util"
+	"github.com/canonical/lxd/shared"
 	"github.com/canonical/lxd/shared/logger"
 
 	"github.com/canonical/microcluster/internal/rest/access"
@@ -25,6 +26,41 @@ func (e ErrInvalidHost) Unwrap() error {
 	return e.error
 }
 
+// Identity holds information about the caller of a request.
+type Identity = access.Identity
+
+// PeerIdentity returns the identity of the caller based on its TLS peer certificate.
+// Returns nil if the request did not present a certificate, such as requests over the unix socket.
+func PeerIdentity(r *http.Request) *Identity {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+
+	return &Identity{
+		CommonName:  cert.Subject.CommonName,
+		Fingerprint: shared.CertFingerprint(cert),
+	}
+}
+
+// RequestIdentity returns the identity of the caller stored in the request context during authentication.
+// Its Name is that of the cluster member that made the request, as resolved from the truststore by the fingerprint of
+// its TLS peer certificate, or LocalPeerName for requests over the unix socket.
+// Access handlers can set Roles and Extra on the returned Identity to pass them to the main handler.
+// Returns nil if the caller has no identity.
+func RequestIdentity(r *http.Request) *Identity {
+	trustedReq, ok := r.Context().Value(request.CtxAccess).(access.TrustedRequest)
+	if !ok {
+		return nil
+	}
+
+	return trustedReq.Identity
+}
+
+// LocalPeerName is the name of the caller for requests over the unix socket.
+const LocalPeerName = access.LocalPeerName
+
 // AllowAuthenticated checks if the request is trusted by extracting access.TrustedRequest from the request context.
 // This handler is used as an access handler by default if AllowUntrusted is false on a rest.EndpointAction.
 func AllowAuthenticated(state *state.State, r *http.Request) response.Response {
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Encoder writes the API response envelope in a format other than JSON.
+type Encoder func(w io.Writer, v any) error
+
+// encoders holds the registered response encoders, keyed by media type.
+var encoders = struct {
+	mu       sync.RWMutex
+	byFormat map[string]Encoder
+}{
+	byFormat: map[string]Encoder{
+		"application/yaml": encodeYAML,
+	},
+}
+
+// encodeYAML writes the value as YAML.
+func encodeYAML(w io.Writer, v any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// RegisterEncoder registers an encoder for API responses in the given media type, such as "application/msgpack".
+// Clients select it with the Accept header. Responses are sent as JSON if the client accepts no registered format.
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+
+	encoders.byFormat[strings.ToLower(mediaType)] = encoder
+}
+
+// ResponseEncoder returns the registered encoder preferred by the given Accept header, along with its media type.
+// It returns false if the client prefers JSON, or accepts none of the registered formats.
+func ResponseEncoder(accept string) (string, Encoder, bool) {
+	type acceptedType struct {
+		mediaType string
+		quality   float64
+	}
+
+	var accepted []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		q, ok := params["q"]
+		if ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if quality > 0 {
+			accepted = append(accepted, acceptedType{mediaType: mediaType, quality: quality})
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].quality > accepted[j].quality })
+
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+
+	for _, a := range accepted {
+		if a.mediaType == "application/json" {
+			return "", nil, false
+		}
+
+		encoder, ok := encoders.byFormat[a.mediaType]
+		if ok {
+			return a.mediaType, encoder, true
+		}
+	}
+
+	return "", nil, false
+}
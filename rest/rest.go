@@ -3,10 +3,12 @@ package rest
 import (
 	"fmt"
 	"net/http"
+	"path/filepath"
 
 	"github.com/canonical/lxd/lxd/response"
 	"github.com/canonical/lxd/shared"
 
+	"github.com/canonical/microcluster/internal/endpoints"
 	"github.com/canonical/microcluster/rest/types"
 	"github.com/canonical/microcluster/state"
 )
@@ -23,6 +25,10 @@ type EndpointAction struct {
 	AccessHandler  func(state *state.State, r *http.Request) response.Response
 	AllowUntrusted bool
 	ProxyTarget    bool // Allow forwarding of the request to a target if ?target=name is specified.
+
+	// ForwardToLeader forwards the request to the dqlite leader when it is made to a read-only cluster member, for
+	// actions that modify the database. It takes precedence over ProxyTarget, which is then applied by the leader.
+	ForwardToLeader bool
 }
 
 // Endpoint represents a URL in our API.
@@ -38,6 +44,7 @@ type Endpoint struct {
 
 	AllowedDuringShutdown bool // Whether we should return Unavailable Error (503) if daemon is shutting down.
 	AllowedBeforeInit     bool // Whether we should return Unavailabel Error (503) if the daemon has not been initialized (is not yet part of a cluster).
+	AllowedWhenDBOffline  bool // Whether we should return Unavailable Error (503) if the database can't be reached.
 }
 
 // Resources represents all the resources served over the same path.
@@ -47,12 +54,51 @@ type Resources struct {
 }
 
 // Server contains configuration and handlers for additional listeners to be instantiated after app startup.
+//
+// Where the server's resources are reachable depends on its flags:
+//   - CoreAPI: served on the core listener and on the control socket. Address, Protocol and Certificate are unused.
+//   - ServeUnix: served on the control socket from daemon startup, in addition to the server's own listener,
+//     which is only started once the daemon is initialized. If neither Address nor Interface is set, the server
+//     has no listener of its own. Not allowed with CoreAPI, which already implies it.
+//   - SocketPath: served on a separate unix socket at the given path from daemon startup, in addition to the server's
+//     own listener, as with ServeUnix. The socket's group is SocketGroup, or the daemon's primary group if unset.
+//     This allows for example a privileged API on a socket that only root can access. Not allowed with CoreAPI or
+//     ServeUnix.
+//   - PreInit: all of the server's endpoints answer before the daemon is initialized, as if AllowedBeforeInit was
+//     set on each. Requires CoreAPI, ServeUnix or SocketPath, as a standalone listener is not started before
+//     initialization.
 type Server struct {
 	CoreAPI     bool
+	ServeUnix   bool
+	PreInit     bool
 	Protocol    string
 	Address     types.AddrPort
 	Certificate *shared.CertInfo
 	Resources   []Resources
+
+	// SocketPath is the absolute path of a separate unix socket serving the server's resources.
+	SocketPath string
+
+	// SocketGroup is the group granted access to the unix socket at SocketPath.
+	SocketGroup string
+
+	// Compress enables gzip compression of responses for clients that accept it. Small and already compressed
+	// responses, and connection upgrades, are sent as is. For the core API server, this applies to the core listener
+	// and the control socket.
+	Compress bool
+
+	// TLS holds optional hardening parameters for the server's listener. Core API servers use the daemon's settings.
+	TLS types.TLSOptions
+
+	// Interface is the name of a network interface to listen on. Its address is resolved when the server starts.
+	// If the interface has more than one address, Address must be set to the one to use.
+	// Otherwise Address may be left with an unspecified IP (0.0.0.0 or ::) to only set the port and address family.
+	Interface string
+
+	// Order sets when the server is started relative to the other extension servers, from the lowest to the highest.
+	// Servers with the same order are started in the order they are given in. A server that relies on another one
+	// being up should have a higher order.
+	Order int
 }
 
 // ValidateServerConfigs checks that the server configuration is valid.
@@ -61,6 +107,50 @@ func (s Server) ValidateServerConfigs() error {
 		if s.Address != (types.AddrPort{}) || s.Protocol != "" || s.Certificate != nil {
 			return fmt.Errorf("Core API server cannot have Address, Protocol or Certificate")
 		}
+
+		if s.TLS.MinVersion != 0 || len(s.TLS.CipherSuites) > 0 {
+			return fmt.Errorf("Core API server cannot have TLS options")
+		}
+
+		if s.Interface != "" {
+			return fmt.Errorf("Core API server cannot have Interface")
+		}
+
+		if s.ServeUnix {
+			return fmt.Errorf("Core API server is always served on the control socket and cannot have ServeUnix")
+		}
+
+		if s.SocketPath != "" {
+			return fmt.Errorf("Core API server is always served on the control socket and cannot have SocketPath")
+		}
+	}
+
+	if s.SocketPath != "" {
+		if s.ServeUnix {
+			return fmt.Errorf("Server cannot have both ServeUnix and SocketPath")
+		}
+
+		if !filepath.IsAbs(s.SocketPath) {
+			return fmt.Errorf("Server socket path %q must be absolute", s.SocketPath)
+		}
+	} else if s.SocketGroup != "" {
+		return fmt.Errorf("Server cannot have SocketGroup without SocketPath")
+	}
+
+	if s.PreInit && !s.CoreAPI && !s.ServeUnix && s.SocketPath == "" {
+		return fmt.Errorf("PreInit server must be a Core API server or have ServeUnix or SocketPath, as its own listener is not started before initialization")
+	}
+
+	if s.Interface != "" {
+		_, err := endpoints.ResolveInterfaceAddress(s.Interface, s.Address)
+		if err != nil {
+			return fmt.Errorf("Invalid server interface: %w", err)
+		}
+	}
+
+	err := s.TLS.Validate()
+	if err != nil {
+		return fmt.Errorf("Invalid TLS options: %w", err)
 	}
 
 	return nil
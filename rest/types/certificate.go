@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"net"
 )
 
 // ClusterCertificatePut represents the content of a new cluster keypair and CA.
@@ -14,6 +15,29 @@ type ClusterCertificatePut struct {
 	CA         string `json:"ca"          yaml:"ca"`
 }
 
+// ClusterCertificateRotation is a phase of a coordinated rotation of the cluster keypair across all cluster members.
+type ClusterCertificateRotation string
+
+const (
+	// ClusterCertificateStage trusts the new cluster certificate alongside the current one on each member.
+	ClusterCertificateStage ClusterCertificateRotation = "stage"
+
+	// ClusterCertificateCommit replaces the cluster keypair on each member, which keeps trusting the previous
+	// certificate until the rotation is finalized.
+	ClusterCertificateCommit ClusterCertificateRotation = "commit"
+
+	// ClusterCertificateFinalize stops trusting the previous cluster certificate on each member.
+	ClusterCertificateFinalize ClusterCertificateRotation = "finalize"
+)
+
+// CertificateOptions are the subject and names of a server or cluster certificate generated by the daemon.
+type CertificateOptions struct {
+	CommonName   string
+	Organization string
+	DNSNames     []string
+	IPAddresses  []net.IP
+}
+
 // X509Certificate is a json/yaml marshallable/unmarshallable type wrapper for x509.Certificate.
 type X509Certificate struct {
 	*x509.Certificate
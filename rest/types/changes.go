@@ -0,0 +1,22 @@
+package types
+
+// ChangeAction represents the kind of write that produced a ChangeEvent.
+type ChangeAction string
+
+const (
+	// ChangeCreate is recorded when a row is inserted into a table.
+	ChangeCreate ChangeAction = "create"
+
+	// ChangeUpdate is recorded when a row is modified.
+	ChangeUpdate ChangeAction = "update"
+
+	// ChangeDelete is recorded when a row is removed from a table.
+	ChangeDelete ChangeAction = "delete"
+)
+
+// ChangeEvent represents a committed change to a row of a watched table.
+type ChangeEvent struct {
+	Table  string       `json:"table"  yaml:"table"`
+	Action ChangeAction `json:"action" yaml:"action"`
+	Key    string       `json:"key"    yaml:"key"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"fmt"
+)
+
+// DivergencePolicy is how a cluster member handles a local database that diverged from the rest of the cluster, for
+// example because it was recovered on its own during a network partition, when it restarts and rejoins the cluster.
+type DivergencePolicy string
+
+const (
+	// DivergenceAbort makes the cluster member refuse to start, and log why its database diverged. It is the default,
+	// as it leaves both the local database and the cluster untouched.
+	DivergenceAbort DivergencePolicy = "abort"
+
+	// DivergenceResync moves the local database aside, and lets the cluster member catch up from the dqlite leader.
+	// Any changes that only the local database holds are lost.
+	DivergenceResync DivergencePolicy = "resync"
+
+	// DivergenceConfirm makes the cluster member refuse to start like DivergenceAbort, until an operator confirms the
+	// resync by creating the resync-database file in the state directory. The member then resyncs like with
+	// DivergenceResync, and removes the file.
+	DivergenceConfirm DivergencePolicy = "confirm"
+)
+
+// Validate checks that the divergence policy is supported. An empty policy is the same as DivergenceAbort.
+func (p DivergencePolicy) Validate() error {
+	switch p {
+	case "", DivergenceAbort, DivergenceResync, DivergenceConfirm:
+		return nil
+	}
+
+	return fmt.Errorf("Unsupported database divergence policy %q", p)
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"net/http"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Errors returned when joining a cluster with a token. Each carries its own HTTP status code, so that clients can
+// tell them apart with api.StatusErrorCheck.
+var (
+	// ErrTokenFingerprintMismatch is returned if the certificate of a join address doesn't match the fingerprint
+	// in the join token.
+	ErrTokenFingerprintMismatch = api.NewStatusError(http.StatusForbidden, "Cluster certificate token does not match")
+
+	// ErrTokenExpired is returned if the join token has expired.
+	ErrTokenExpired = api.NewStatusError(http.StatusGone, "Join token has expired")
+
+	// ErrAllJoinAddressesFailed is returned if none of the join addresses in the join token accepted the join
+	// request.
+	ErrAllJoinAddressesFailed = api.NewStatusError(http.StatusServiceUnavailable, "All join addresses failed")
+)
+
+// ErrReadOnlyMember is returned by transactions that modify the database on a read-only cluster member.
+var ErrReadOnlyMember = api.NewStatusError(http.StatusForbidden, "Cluster member is read-only")
+
+// Errors returned for cluster member names that are not valid fully qualified domain names, each describing the
+// violated constraint. They are returned with a 400 status code.
+var (
+	// ErrNameLength is returned if the name is empty or longer than 255 characters.
+	ErrNameLength = api.NewStatusError(http.StatusBadRequest, "Name must be 1-255 characters long")
+
+	// ErrNameEmptyLabel is returned if the name starts or ends with a dot, or has consecutive dots.
+	ErrNameEmptyLabel = api.NewStatusError(http.StatusBadRequest, "Name must not have empty labels between dots")
+
+	// ErrNameLabelLength is returned if a dot-separated label of the name is longer than 63 characters.
+	ErrNameLabelLength = api.NewStatusError(http.StatusBadRequest, "Name labels must be at most 63 characters long")
+
+	// ErrNameHyphen is returned if a label of the name starts or ends with a hyphen.
+	ErrNameHyphen = api.NewStatusError(http.StatusBadRequest, "Name labels must not start or end with a hyphen")
+
+	// ErrNameCharacter is returned if the name contains characters other than letters, digits, hyphens and dots.
+	ErrNameCharacter = api.NewStatusError(http.StatusBadRequest, "Name labels may only contain letters, digits and hyphens")
+
+	// ErrNameNumeric is returned if a label of the name is a number.
+	ErrNameNumeric = api.NewStatusError(http.StatusBadRequest, "Name labels must not be numbers")
+)
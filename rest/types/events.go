@@ -0,0 +1,31 @@
+package types
+
+import (
+	"time"
+)
+
+// EventType represents the kind of a cluster lifecycle event.
+type EventType string
+
+const (
+	// EventMemberJoined is published when a cluster member joins the cluster, by the new member and each existing one.
+	EventMemberJoined EventType = "member-joined"
+
+	// EventMemberRemoved is published by each remaining cluster member when a cluster member is removed.
+	EventMemberRemoved EventType = "member-removed"
+
+	// EventLeaderChanged is published by each cluster member when it notices that the dqlite leader has changed.
+	EventLeaderChanged EventType = "leader-changed"
+
+	// EventConfigUpdated is published when a key in the cluster-wide configuration store is set or deleted, by the
+	// cluster member that made the change.
+	EventConfigUpdated EventType = "config-updated"
+)
+
+// Event represents a cluster lifecycle event published by a cluster member.
+type Event struct {
+	Type      EventType         `json:"type"      yaml:"type"`
+	Timestamp time.Time         `json:"timestamp" yaml:"timestamp"`
+	Location  string            `json:"location"  yaml:"location"`
+	Metadata  map[string]string `json:"metadata"  yaml:"metadata"`
+}
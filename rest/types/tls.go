@@ -0,0 +1,47 @@
+package types
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSOptions holds optional hardening parameters for a TLS listener.
+// Unset values keep the default server TLS configuration.
+type TLSOptions struct {
+	// MinVersion is the minimum TLS version the listener accepts, e.g. tls.VersionTLS13.
+	MinVersion uint16
+
+	// CipherSuites restricts the cipher suites the listener accepts. Only applies to TLS 1.2 connections.
+	CipherSuites []uint16
+}
+
+// Validate checks that the TLS options are supported.
+func (o TLSOptions) Validate() error {
+	if o.MinVersion != 0 && o.MinVersion != tls.VersionTLS12 && o.MinVersion != tls.VersionTLS13 {
+		return fmt.Errorf("Unsupported minimum TLS version %q", tls.VersionName(o.MinVersion))
+	}
+
+	supported := map[uint16]bool{}
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.ID] = true
+	}
+
+	for _, id := range o.CipherSuites {
+		if !supported[id] {
+			return fmt.Errorf("Unsupported TLS cipher suite %q", tls.CipherSuiteName(id))
+		}
+	}
+
+	return nil
+}
+
+// Apply sets any configured hardening parameters on the given TLS config.
+func (o TLSOptions) Apply(config *tls.Config) {
+	if o.MinVersion != 0 {
+		config.MinVersion = o.MinVersion
+	}
+
+	if len(o.CipherSuites) > 0 {
+		config.CipherSuites = append([]uint16{}, o.CipherSuites...)
+	}
+}
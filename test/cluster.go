@@ -0,0 +1,260 @@
+// Package test starts clusters of in-process microcluster daemons for the integration tests of consumers.
+//
+// Every member runs the full daemon, with its own state directory and a listener on an ephemeral port of the loopback
+// interface. Some daemon callbacks, such as those used to change the address or name of a member, to reload its
+// cluster certificate, or to reset it once it is removed, are shared by all daemons of a process. Tests relying on
+// those operations, or on a member re-executing itself, should run each member in its own process instead.
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/lxd/lxd/db/schema"
+
+	"github.com/canonical/microcluster/client"
+	"github.com/canonical/microcluster/config"
+	"github.com/canonical/microcluster/microcluster"
+	"github.com/canonical/microcluster/state"
+)
+
+// DefaultTimeout is how long NewCluster waits for each member to start, bootstrap or join.
+const DefaultTimeout = 30 * time.Second
+
+// Options configures the cluster started by NewCluster.
+type Options struct {
+	// Args are the arguments of every member. The state directory is replaced by a temporary one for each member.
+	Args microcluster.Args
+
+	// Schema and APIExtensions are the schema updates and API extensions of every member.
+	Schema        []schema.Update
+	APIExtensions []string
+
+	// Hooks are the hooks of every member.
+	Hooks *config.Hooks
+
+	// InitConfig is passed to the PostBootstrap, PreJoin and PostJoin hooks.
+	InitConfig map[string]string
+
+	// Timeout is how long to wait for each member to start, bootstrap or join. Defaults to DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Member is a cluster member started by NewCluster.
+type Member struct {
+	// Name of the cluster member, such as "member01".
+	Name string
+
+	// Address of the cluster member on the loopback interface.
+	Address string
+
+	// App manages the daemon of the cluster member.
+	App *microcluster.MicroCluster
+
+	stateDir string
+	done     chan error
+
+	mu    sync.Mutex
+	state *state.State
+}
+
+// Client returns a client connected to the control socket of the member.
+func (m *Member) Client() (*client.Client, error) {
+	return m.App.LocalClient()
+}
+
+// State returns the state of the daemon of the member, for assertions on its database or configuration.
+func (m *Member) State() *state.State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// Cluster is a set of in-process microcluster daemons forming a cluster.
+type Cluster struct {
+	// Members of the cluster. The first one bootstrapped the cluster.
+	Members []*Member
+
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+// NewCluster starts the given number of daemons, bootstraps the cluster on the first one and joins the others to it
+// with join tokens. The daemons are stopped and their state directories removed when the test ends. The test fails
+// if the cluster can't be formed.
+func NewCluster(t testing.TB, size int, options Options) *Cluster {
+	t.Helper()
+
+	if size < 1 {
+		t.Fatalf("Cluster must have at least one member, got %d", size)
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Cluster{cancel: cancel}
+	t.Cleanup(func() {
+		err := c.Stop()
+		if err != nil {
+			t.Errorf("Failed to stop cluster: %v", err)
+		}
+	})
+
+	for i := 0; i < size; i++ {
+		member, err := startMember(ctx, fmt.Sprintf("member%02d", i+1), options)
+		if member != nil {
+			c.Members = append(c.Members, member)
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to start cluster member %d: %v", i+1, err)
+		}
+	}
+
+	bootstrap := c.Members[0]
+	err := withTimeout(ctx, options.Timeout, func(ctx context.Context) error {
+		return bootstrap.App.NewCluster(ctx, bootstrap.Name, bootstrap.Address, options.InitConfig)
+	})
+	if err != nil {
+		t.Fatalf("Failed to bootstrap cluster on %q: %v", bootstrap.Name, err)
+	}
+
+	for _, member := range c.Members[1:] {
+		err := withTimeout(ctx, options.Timeout, func(ctx context.Context) error {
+			token, err := bootstrap.App.NewJoinToken(ctx, member.Name)
+			if err != nil {
+				return fmt.Errorf("Failed to create join token: %w", err)
+			}
+
+			return member.App.JoinCluster(ctx, member.Name, member.Address, token, options.InitConfig)
+		})
+		if err != nil {
+			t.Fatalf("Failed to join %q to the cluster: %v", member.Name, err)
+		}
+	}
+
+	return c
+}
+
+// Stop stops every daemon of the cluster and removes their state directories. Only the first call has any effect.
+func (c *Cluster) Stop() error {
+	var errs []error
+	c.stopOnce.Do(func() {
+		c.cancel()
+
+		for _, member := range c.Members {
+			select {
+			case err := <-member.done:
+				if err != nil {
+					errs = append(errs, fmt.Errorf("Cluster member %q stopped with error: %w", member.Name, err))
+				}
+
+			case <-time.After(DefaultTimeout):
+				errs = append(errs, fmt.Errorf("Timed out stopping cluster member %q", member.Name))
+			}
+
+			err := os.RemoveAll(member.stateDir)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// startMember starts a daemon with a temporary state directory, and waits for it to be ready. The state of the daemon
+// is captured once it has started. The returned member, if any, must be stopped even if an error is returned.
+func startMember(ctx context.Context, name string, options Options) (*Member, error) {
+	address, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the path short, as it holds the control socket.
+	stateDir, err := os.MkdirTemp("", "microcluster-")
+	if err != nil {
+		return nil, err
+	}
+
+	args := options.Args
+	args.StateDir = stateDir
+	app, err := microcluster.App(args)
+	if err != nil {
+		_ = os.RemoveAll(stateDir)
+		return nil, err
+	}
+
+	member := &Member{
+		Name:     name,
+		Address:  address,
+		App:      app,
+		stateDir: stateDir,
+		done:     make(chan error, 1),
+	}
+
+	hooks := config.Hooks{}
+	if options.Hooks != nil {
+		hooks = *options.Hooks
+	}
+
+	onStart := hooks.OnStart
+	hooks.OnStart = func(s *state.State) error {
+		member.mu.Lock()
+		member.state = s
+		member.mu.Unlock()
+
+		if onStart != nil {
+			return onStart(s)
+		}
+
+		return nil
+	}
+
+	go func() {
+		member.done <- app.Start(ctx, options.Schema, options.APIExtensions, &hooks)
+	}()
+
+	err = withTimeout(ctx, options.Timeout, app.Ready)
+	if err != nil {
+		return member, err
+	}
+
+	return member, nil
+}
+
+// freeAddress returns an address on the loopback interface with a port that is currently free.
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	address := listener.Addr().String()
+	err = listener.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return address, nil
+}
+
+// withTimeout runs f with a context that expires after the timeout.
+func withTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return f(ctx)
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type clusterSuite struct {
+	suite.Suite
+}
+
+func TestClusterSuite(t *testing.T) {
+	suite.Run(t, new(clusterSuite))
+}
+
+// Ensures every member of a new cluster is joined, reachable over its control socket and exposes its state.
+func (t *clusterSuite) Test_newCluster() {
+	if testing.Short() {
+		t.T().Skip("Skipping in-process cluster in short mode")
+	}
+
+	c := NewCluster(t.T(), 2, Options{})
+	t.Require().Len(c.Members, 2)
+
+	for _, member := range c.Members {
+		t.Require().NotNil(member.State())
+		t.Equal(member.Name, member.State().Name())
+
+		client, err := member.Client()
+		t.Require().NoError(err)
+
+		members, err := client.GetClusterMembers(context.Background())
+		t.Require().NoError(err)
+		t.Len(members, 2)
+	}
+
+	t.NoError(c.Stop())
+}